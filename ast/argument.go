@@ -0,0 +1,34 @@
+package ast
+
+import "Monkey/token"
+
+// Spread of an iterable in a call or in a literal
+// f(...args), [1, ...xs], {...defaults, name: "x"}
+type SpreadElement struct {
+	Token token.Token // ... Token
+	Value Expression
+}
+
+func (se *SpreadElement) ExpressionNode() {}
+func (se *SpreadElement) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SpreadElement) ToString() string {
+	return "..." + se.Value.ToString()
+}
+
+// Argument passed to the parameter with its name
+// f(b: 2)
+type NamedArgument struct {
+	Token token.Token // The identifier Token
+	Name  string
+	Value Expression
+}
+
+func (na *NamedArgument) ExpressionNode() {}
+func (na *NamedArgument) TokenLiteral() string {
+	return na.Token.Literal
+}
+func (na *NamedArgument) ToString() string {
+	return na.Name + ": " + na.Value.ToString()
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"Monkey/options"
 	"Monkey/token"
+	"math/big"
 	"strings"
 )
 
@@ -57,9 +58,12 @@ func (p *Program) ToString() string {
 
 // A let statement
 type LetStatement struct {
-	Token token.Token // LET Token
-	Name  *Identifier // Variable Name
-	Value Expression  // Value
+	Token   token.Token // LET Token
+	Name    *Identifier // Variable Name, nil when it destructures
+	Pattern Expression  // The *ArrayPattern or *HashPattern of let [a, b] = value
+	Value   Expression  // Value
+
+	Doc string // The /// doc comment above the statement
 }
 
 func (ls *LetStatement) StatementNode() {}
@@ -71,7 +75,11 @@ func (ls *LetStatement) ToString() string {
 
 	AddOpeningBrace(&out)
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.ToString())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.ToString())
+	} else {
+		out.WriteString(ls.Name.ToString())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -185,10 +193,10 @@ func (es *ExpressionStatement) ToString() string {
 	return ""
 }
 
-// An expression Literal
+// An integer Literal
 type IntegerLiteral struct {
 	Token token.Token // INT Token
-	Value float64     // Number Value
+	Value int64       // Number Value
 }
 
 func (il *IntegerLiteral) ExpressionNode() {}
@@ -204,6 +212,62 @@ func (il *IntegerLiteral) ToString() string {
 	return out.String()
 }
 
+// A float Literal
+type FloatLiteral struct {
+	Token token.Token // FLOAT Token
+	Value float64     // Number Value
+}
+
+func (fl *FloatLiteral) ExpressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	out.WriteString(fl.Token.Literal)
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+type BigIntLiteral struct {
+	Token token.Token // BIGINT Token
+	Value *big.Int    // Number Value
+}
+
+func (bl *BigIntLiteral) ExpressionNode() {}
+func (bl *BigIntLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+func (bl *BigIntLiteral) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	out.WriteString(bl.Token.Literal)
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+type DecimalLiteral struct {
+	Token token.Token // DECIMAL Token
+	Value *big.Int    // The digits without the point
+	Scale int         // The amount of digits after the point
+}
+
+func (dl *DecimalLiteral) ExpressionNode() {}
+func (dl *DecimalLiteral) TokenLiteral() string {
+	return dl.Token.Literal
+}
+func (dl *DecimalLiteral) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	out.WriteString(dl.Token.Literal)
+	AddClosingBrace(&out)
+	return out.String()
+}
+
 // An expression prefix
 type PrefixExpression struct {
 	Token    token.Token // Operator Prefix Token
@@ -248,6 +312,25 @@ func (ie *InfixExpression) ToString() string {
 	return out.String()
 }
 
+// An increment or a decrement, x++ and x-- return the value x had, ++x and --x the value it gets
+type UpdateExpression struct {
+	Token    token.Token // ++ or -- Token
+	Operator string
+	Target   Expression // An identifier, an index or a member
+	Prefix   bool
+}
+
+func (ue *UpdateExpression) ExpressionNode() {}
+func (ue *UpdateExpression) TokenLiteral() string {
+	return ue.Token.Literal
+}
+func (ue *UpdateExpression) ToString() string {
+	if ue.Prefix {
+		return "(" + ue.Operator + ue.Target.ToString() + ")"
+	}
+	return "(" + ue.Target.ToString() + ue.Operator + ")"
+}
+
 type Null struct {
 	Token token.Token
 }
@@ -338,6 +421,193 @@ func (ie *IfExpression) ToString() string {
 	return out.String()
 }
 
+// A try catch finally expression
+type TryExpression struct {
+	Token     token.Token     // TRY Token
+	Block     *BlockStatement // The Guarded Block
+	Parameter *Identifier     // The Caught Error, nil when it is not bound
+	Catch     *BlockStatement // Runs when the block fails, nil without catch
+	Finally   *BlockStatement // Always runs, nil without finally
+}
+
+func (te *TryExpression) ExpressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	out.WriteString("try ")
+	out.WriteString(te.Block.ToString())
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Parameter != nil {
+			out.WriteString("(" + te.Parameter.ToString() + ") ")
+		}
+		out.WriteString(te.Catch.ToString())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.ToString())
+	}
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+// A throw statement
+type ThrowStatement struct {
+	Token token.Token // THROW Token
+	Value Expression  // The thrown value
+}
+
+func (ts *ThrowStatement) StatementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	out.WriteString(ts.TokenLiteral())
+	if ts.Value != nil {
+		out.WriteString(" " + ts.Value.ToString())
+	}
+	out.WriteString(";")
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+// A while loop
+type WhileStatement struct {
+	Token     token.Token     // WHILE Token
+	Label     string          // The loop label, empty when it is not labeled
+	Condition Expression      // Loop Condition
+	Body      *BlockStatement // Loop Body
+}
+
+func (ws *WhileStatement) StatementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	addLabel(&out, ws.Label)
+	out.WriteString("while ")
+	out.WriteString(ws.Condition.ToString())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.ToString())
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+// A c style for loop, for (init; condition; step) {}
+type ForStatement struct {
+	Token     token.Token     // FOR Token
+	Label     string          // The loop label, empty when it is not labeled
+	Init      Statement       // Runs once before the loop, nil when missing
+	Condition Expression      // Loop Condition, nil loops forever
+	Step      Expression      // Runs after every iteration, nil when missing
+	Body      *BlockStatement // Loop Body
+}
+
+func (fs *ForStatement) StatementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	addLabel(&out, fs.Label)
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(fs.Init.ToString())
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.ToString())
+	}
+	out.WriteString("; ")
+	if fs.Step != nil {
+		out.WriteString(fs.Step.ToString())
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.ToString())
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+// A for in loop over the elements of an iterable, for x in iterable {}
+type ForInStatement struct {
+	Token    token.Token     // FOR Token
+	Label    string          // The loop label, empty when it is not labeled
+	Variable *Identifier     // The element variable
+	Iterable Expression      // The iterated value
+	Body     *BlockStatement // Loop Body
+}
+
+func (fs *ForInStatement) StatementNode() {}
+func (fs *ForInStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForInStatement) ToString() string {
+	var out strings.Builder
+
+	AddOpeningBrace(&out)
+	addLabel(&out, fs.Label)
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.ToString())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.ToString())
+	out.WriteString(" ")
+	out.WriteString(fs.Body.ToString())
+	AddClosingBrace(&out)
+	return out.String()
+}
+
+// A break statement inside a loop
+type BreakStatement struct {
+	Token token.Token // BREAK Token
+	Label string      // The loop broken out of, empty for the innermost
+}
+
+func (bs *BreakStatement) StatementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) ToString() string {
+	if bs.Label != "" {
+		return bs.TokenLiteral() + " " + bs.Label + ";"
+	}
+	return bs.TokenLiteral() + ";"
+}
+
+// A continue statement inside a loop
+type ContinueStatement struct {
+	Token token.Token // CONTINUE Token
+	Label string      // The loop continued, empty for the innermost
+}
+
+func (cs *ContinueStatement) StatementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) ToString() string {
+	if cs.Label != "" {
+		return cs.TokenLiteral() + " " + cs.Label + ";"
+	}
+	return cs.TokenLiteral() + ";"
+}
+
+func addLabel(out *strings.Builder, label string) {
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+}
+
 // A module expression
 type ModuleExpression struct {
 	Token token.Token
@@ -357,6 +627,23 @@ type FunctionLiteral struct {
 	Token      token.Token     // Fn Token
 	Parameters []*Identifier   // List of Parameters
 	Body       *BlockStatement // The Function Body
+	Name       string          // The name it is assigned to, used in stack traces
+
+	// The pattern destructuring each parameter, nil for plain parameters or when there are none.
+	// The parameter of a pattern is named after its source, so it cannot be referred to
+	Patterns []Expression
+
+	// The default value of each parameter, nil for parameters without one or when there are none
+	Defaults []Expression
+
+	// The ...rest parameter collecting the extra arguments, nil without one
+	Rest *Identifier
+
+	// Whether calls return a generator running the body, for fn* and functions with a yield
+	Generator bool
+
+	// Whether calls run the body in a task and return the promise of its result, for async fn
+	Async bool
 }
 
 func (fl *FunctionLiteral) ExpressionNode() {}
@@ -368,13 +655,26 @@ func (fl *FunctionLiteral) ToString() string {
 
 	// Build Param list
 	var params []string
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if fl.Defaults != nil && fl.Defaults[i] != nil {
+			params = append(params, p.ToString()+" = "+fl.Defaults[i].ToString())
+			continue
+		}
 		params = append(params, p.ToString())
 	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.ToString())
+	}
 
 	AddOpeningBrace(&out)
 
+	if fl.Async {
+		out.WriteString("async ")
+	}
 	out.WriteString(fl.TokenLiteral())
+	if fl.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -385,11 +685,36 @@ func (fl *FunctionLiteral) ToString() string {
 	return out.String()
 }
 
+// Signature returns how the function is declared, without its body: fn(a, b = 2, ...rest), fn*() or async fn(x)
+func (fl *FunctionLiteral) Signature() string {
+	keyword := "fn"
+	switch {
+	case fl.Generator:
+		keyword = "fn*"
+	case fl.Async:
+		keyword = "async fn"
+	}
+
+	var params []string
+	for i, param := range fl.Parameters {
+		if fl.Defaults != nil && fl.Defaults[i] != nil {
+			params = append(params, param.Value+" = "+fl.Defaults[i].ToString())
+			continue
+		}
+		params = append(params, param.Value)
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.Value)
+	}
+	return keyword + "(" + strings.Join(params, ", ") + ")"
+}
+
 // Function Call Expression
 type CallExpression struct {
 	Token     token.Token  // ( Token
 	Function  Expression   // The target Function
 	Arguments []Expression // Argument list
+	Optional  bool         // f?.(), null when the function is null
 }
 
 func (ce *CallExpression) ExpressionNode() {}
@@ -407,6 +732,9 @@ func (ce *CallExpression) ToString() string {
 	AddOpeningBrace(&out)
 
 	out.WriteString(ce.Function.ToString())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -415,6 +743,24 @@ func (ce *CallExpression) ToString() string {
 	return out.String()
 }
 
+// IncludeLocation returns the location given to a call of function with a string literal,
+// like include("lib/x") or import("lib/x"), it returns false for any other node
+func IncludeLocation(node Node, function string) (string, bool) {
+	call, ok := node.(*CallExpression)
+	if !ok {
+		return "", false
+	}
+	identifier, ok := call.Function.(*Identifier)
+	if !ok || identifier.Value != function || len(call.Arguments) != 1 {
+		return "", false
+	}
+	location, ok := call.Arguments[0].(*StringLiteral)
+	if !ok {
+		return "", false
+	}
+	return location.Value, true
+}
+
 // String
 type StringLiteral struct {
 	Token token.Token
@@ -429,6 +775,33 @@ func (sl *StringLiteral) ToString() string {
 	return sl.Value
 }
 
+// An interpolated string, "Hello ${name}", its parts are string literals and expressions
+type TemplateLiteral struct {
+	Token token.Token // TEMPLATE Token
+	Parts []Expression
+}
+
+func (tl *TemplateLiteral) ExpressionNode() {}
+func (tl *TemplateLiteral) TokenLiteral() string {
+	return tl.Token.Literal
+}
+func (tl *TemplateLiteral) ToString() string {
+	var out strings.Builder
+
+	out.WriteString("\"")
+	for _, part := range tl.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.ToString())
+		out.WriteString("}")
+	}
+	out.WriteString("\"")
+	return out.String()
+}
+
 // Array object
 type ArrayLiteral struct {
 	Token    token.Token
@@ -467,6 +840,7 @@ type IndexExpression struct {
 	Start    Expression
 	End      Expression
 	HasRange bool
+	Optional bool // x?.[k], null when x is null
 }
 
 func (ie *IndexExpression) ExpressionNode() {}
@@ -478,6 +852,9 @@ func (ie *IndexExpression) ToString() string {
 
 	AddOpeningBrace(&out)
 	out.WriteString(ie.Left.ToString())
+	if ie.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	if ie.Start != nil {
 		out.WriteString(ie.Start.ToString())
@@ -497,6 +874,9 @@ func (ie *IndexExpression) ToString() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+
+	// The hashes spread into the literal, their pairs are copied in order before the pairs of the literal
+	Spreads []*SpreadElement
 }
 
 func (hl *HashLiteral) ExpressionNode() {}
@@ -507,6 +887,9 @@ func (hl *HashLiteral) ToString() string {
 	var out strings.Builder
 
 	var pairs []string
+	for _, spread := range hl.Spreads {
+		pairs = append(pairs, spread.ToString())
+	}
 	for key, value := range hl.Pairs {
 		pairs = append(pairs, key.ToString()+":"+value.ToString())
 	}
@@ -546,6 +929,15 @@ func (ml *MacroLiteral) ToString() string {
 	return out.String()
 }
 
+// Signature returns how the macro is declared, without its body: macro(a, b)
+func (ml *MacroLiteral) Signature() string {
+	var params []string
+	for _, param := range ml.Parameters {
+		params = append(params, param.Value)
+	}
+	return "macro(" + strings.Join(params, ", ") + ")"
+}
+
 func AddOptionalString(out *strings.Builder, str string) {
 	if !options.NicerToString {
 		out.WriteString(str)
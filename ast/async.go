@@ -0,0 +1,20 @@
+package ast
+
+import (
+	"Monkey/token"
+)
+
+// An await expression, it waits for a promise to settle and evaluates to its value,
+// the error of a rejected promise is thrown
+type AwaitExpression struct {
+	Token token.Token // await Token
+	Value Expression  // The promise, or the task, waited for
+}
+
+func (ae *AwaitExpression) ExpressionNode() {}
+func (ae *AwaitExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AwaitExpression) ToString() string {
+	return "(await " + ae.Value.ToString() + ")"
+}
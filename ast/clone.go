@@ -0,0 +1,291 @@
+package ast
+
+// Clone returns a deep copy of an ast tree, tokens are copied by value
+func Clone(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		return &Program{Statements: cloneStatements(node.Statements)}
+	case *LetStatement:
+		return &LetStatement{
+			Token:   node.Token,
+			Name:    cloneIdentifier(node.Name),
+			Pattern: cloneExpression(node.Pattern),
+			Value:   cloneExpression(node.Value),
+			Doc:     node.Doc,
+		}
+	case *ReturnStatement:
+		return &ReturnStatement{
+			Token:       node.Token,
+			ReturnValue: cloneExpression(node.ReturnValue),
+		}
+	case *ExpressionStatement:
+		return &ExpressionStatement{
+			Token:      node.Token,
+			Expression: cloneExpression(node.Expression),
+		}
+	case *PrintExpressionStatement:
+		return &PrintExpressionStatement{
+			Token:      node.Token,
+			Expression: cloneExpression(node.Expression),
+		}
+	case *BlockStatement:
+		return cloneBlock(node)
+	case *AssignmentExpression:
+		return &AssignmentExpression{
+			Token: node.Token,
+			Ident: cloneIdentifier(node.Ident),
+			Value: cloneExpression(node.Value),
+		}
+	case *Identifier:
+		return cloneIdentifier(node)
+	case *IntegerLiteral:
+		return &IntegerLiteral{Token: node.Token, Value: node.Value}
+	case *FloatLiteral:
+		return &FloatLiteral{Token: node.Token, Value: node.Value}
+	case *BigIntLiteral:
+		return &BigIntLiteral{Token: node.Token, Value: node.Value}
+	case *DecimalLiteral:
+		return &DecimalLiteral{Token: node.Token, Value: node.Value, Scale: node.Scale}
+	case *StringLiteral:
+		return &StringLiteral{Token: node.Token, Value: node.Value}
+	case *Boolean:
+		return &Boolean{Token: node.Token, Value: node.Value}
+	case *Null:
+		return &Null{Token: node.Token}
+	case *Break:
+		return &Break{Token: node.Token}
+	case *PrefixExpression:
+		return &PrefixExpression{
+			Token:    node.Token,
+			Operator: node.Operator,
+			Right:    cloneExpression(node.Right),
+		}
+	case *UpdateExpression:
+		return &UpdateExpression{
+			Token:    node.Token,
+			Operator: node.Operator,
+			Target:   cloneExpression(node.Target),
+			Prefix:   node.Prefix,
+		}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Operator: node.Operator,
+			Left:     cloneExpression(node.Left),
+			Right:    cloneExpression(node.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   cloneExpression(node.Condition),
+			Consequence: cloneBlock(node.Consequence),
+			Alternative: cloneBlock(node.Alternative),
+		}
+	case *TryExpression:
+		return &TryExpression{
+			Token:     node.Token,
+			Block:     cloneBlock(node.Block),
+			Parameter: cloneIdentifier(node.Parameter),
+			Catch:     cloneBlock(node.Catch),
+			Finally:   cloneBlock(node.Finally),
+		}
+	case *ThrowStatement:
+		return &ThrowStatement{Token: node.Token, Value: cloneExpression(node.Value)}
+	case *WhileStatement:
+		return &WhileStatement{
+			Token:     node.Token,
+			Label:     node.Label,
+			Condition: cloneExpression(node.Condition),
+			Body:      cloneBlock(node.Body),
+		}
+	case *ForStatement:
+		var init Statement
+		if node.Init != nil {
+			init, _ = Clone(node.Init).(Statement)
+		}
+		return &ForStatement{
+			Token:     node.Token,
+			Label:     node.Label,
+			Init:      init,
+			Condition: cloneExpression(node.Condition),
+			Step:      cloneExpression(node.Step),
+			Body:      cloneBlock(node.Body),
+		}
+	case *ForInStatement:
+		return &ForInStatement{
+			Token:    node.Token,
+			Label:    node.Label,
+			Variable: cloneIdentifier(node.Variable),
+			Iterable: cloneExpression(node.Iterable),
+			Body:     cloneBlock(node.Body),
+		}
+	case *BreakStatement:
+		return &BreakStatement{Token: node.Token, Label: node.Label}
+	case *ContinueStatement:
+		return &ContinueStatement{Token: node.Token, Label: node.Label}
+	case *ModuleExpression:
+		return &ModuleExpression{Token: node.Token, Body: cloneBlock(node.Body)}
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:      node.Token,
+			Parameters: cloneIdentifiers(node.Parameters),
+			Body:       cloneBlock(node.Body),
+			Name:       node.Name,
+			Patterns:   cloneExpressions(node.Patterns),
+			Defaults:   cloneExpressions(node.Defaults),
+			Rest:       cloneIdentifier(node.Rest),
+			Generator:  node.Generator,
+			Async:      node.Async,
+		}
+	case *MacroLiteral:
+		return &MacroLiteral{
+			Token:      node.Token,
+			Parameters: cloneIdentifiers(node.Parameters),
+			Body:       cloneBlock(node.Body),
+		}
+	case *CallExpression:
+		return &CallExpression{
+			Token:     node.Token,
+			Function:  cloneExpression(node.Function),
+			Arguments: cloneExpressions(node.Arguments),
+			Optional:  node.Optional,
+		}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: cloneExpressions(node.Elements)}
+	case *ArrayPattern:
+		return &ArrayPattern{Token: node.Token, Elements: clonePatternElements(node.Elements), Rest: cloneIdentifier(node.Rest)}
+	case *HashPattern:
+		return &HashPattern{Token: node.Token, Elements: clonePatternElements(node.Elements)}
+	case *TemplateLiteral:
+		return &TemplateLiteral{Token: node.Token, Parts: cloneExpressions(node.Parts)}
+	case *IndexExpression:
+		return &IndexExpression{
+			Token:    node.Token,
+			Left:     cloneExpression(node.Left),
+			Start:    cloneExpression(node.Start),
+			End:      cloneExpression(node.End),
+			HasRange: node.HasRange,
+			Optional: node.Optional,
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for key, value := range node.Pairs {
+			pairs[cloneExpression(key)] = cloneExpression(value)
+		}
+		var spreads []*SpreadElement
+		for _, spread := range node.Spreads {
+			spreads = append(spreads, &SpreadElement{Token: spread.Token, Value: cloneExpression(spread.Value)})
+		}
+		return &HashLiteral{Token: node.Token, Pairs: pairs, Spreads: spreads}
+	case *MatchExpression:
+		arms := make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			arms[i] = &MatchArm{
+				Token:   arm.Token,
+				Pattern: cloneExpression(arm.Pattern),
+				Guard:   cloneExpression(arm.Guard),
+				Body:    cloneBlock(arm.Body),
+			}
+		}
+		return &MatchExpression{Token: node.Token, Value: cloneExpression(node.Value), Arms: arms}
+	case *ConditionalExpression:
+		return &ConditionalExpression{
+			Token:       node.Token,
+			Condition:   cloneExpression(node.Condition),
+			Consequence: cloneExpression(node.Consequence),
+			Alternative: cloneExpression(node.Alternative),
+		}
+	case *OptionalChain:
+		return &OptionalChain{Token: node.Token, Expression: cloneExpression(node.Expression)}
+	case *YieldExpression:
+		return &YieldExpression{Token: node.Token, Value: cloneExpression(node.Value)}
+	case *AwaitExpression:
+		return &AwaitExpression{Token: node.Token, Value: cloneExpression(node.Value)}
+	case *SelectExpression:
+		arms := make([]*SelectArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			arms[i] = &SelectArm{
+				Token:   arm.Token,
+				Name:    cloneIdentifier(arm.Name),
+				Channel: cloneExpression(arm.Channel),
+				Value:   cloneExpression(arm.Value),
+				Send:    arm.Send,
+				Body:    cloneBlock(arm.Body),
+			}
+		}
+		return &SelectExpression{Token: node.Token, Arms: arms}
+	case *SpreadElement:
+		return &SpreadElement{Token: node.Token, Value: cloneExpression(node.Value)}
+	case *NamedArgument:
+		return &NamedArgument{Token: node.Token, Name: node.Name, Value: cloneExpression(node.Value)}
+	}
+	return node
+}
+
+func cloneExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	cloned, _ := Clone(exp).(Expression)
+	return cloned
+}
+
+func cloneExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	result := make([]Expression, len(exps))
+	for i, exp := range exps {
+		result[i] = cloneExpression(exp)
+	}
+	return result
+}
+
+func cloneStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	result := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		if stmt != nil {
+			result[i], _ = Clone(stmt).(Statement)
+		}
+	}
+	return result
+}
+
+func cloneBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: cloneStatements(block.Statements)}
+}
+
+func cloneIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	return &Identifier{Token: ident.Token, Value: ident.Value}
+}
+
+func clonePatternElements(elements []*PatternElement) []*PatternElement {
+	if elements == nil {
+		return nil
+	}
+	result := make([]*PatternElement, len(elements))
+	for i, el := range elements {
+		result[i] = &PatternElement{Key: el.Key, Target: cloneExpression(el.Target), Default: cloneExpression(el.Default)}
+	}
+	return result
+}
+
+func cloneIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	result := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		result[i] = cloneIdentifier(ident)
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"Monkey/token"
+	"strings"
+)
+
+// A ternary expression, condition ? consequence : alternative
+type ConditionalExpression struct {
+	Token       token.Token // ? Token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) ExpressionNode() {}
+func (ce *ConditionalExpression) TokenLiteral() string {
+	return ce.Token.Literal
+}
+func (ce *ConditionalExpression) ToString() string {
+	var out strings.Builder
+
+	out.WriteString("(")
+	out.WriteString(ce.Condition.ToString())
+	out.WriteString(" ? ")
+	out.WriteString(ce.Consequence.ToString())
+	out.WriteString(" : ")
+	out.WriteString(ce.Alternative.ToString())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// An optional chain, the chain of members, indexes and calls of x?.y.z that is null as a whole
+// when the value before one of its ?. is null. The ?. links are infix expressions with the ?. operator,
+// and the index and call expressions marked as optional
+type OptionalChain struct {
+	Token      token.Token // The first ?. Token
+	Expression Expression
+}
+
+func (oc *OptionalChain) ExpressionNode() {}
+func (oc *OptionalChain) TokenLiteral() string {
+	return oc.Token.Literal
+}
+func (oc *OptionalChain) ToString() string {
+	return oc.Expression.ToString()
+}
+
+// OptionalLink returns the token of the first ?. of a chain, its links are the member, index and call expressions
+// on the left of each other
+func OptionalLink(exp Expression) (token.Token, bool) {
+	var link token.Token
+	found := false
+	for {
+		switch node := exp.(type) {
+		case *InfixExpression:
+			if node.Operator != token.DOT && node.Operator != token.OPTIONAL_DOT {
+				return link, found
+			}
+			if node.Operator == token.OPTIONAL_DOT {
+				link, found = node.Token, true
+			}
+			exp = node.Left
+		case *IndexExpression:
+			if node.Optional {
+				link, found = node.Token, true
+			}
+			exp = node.Left
+		case *CallExpression:
+			if node.Optional {
+				link, found = node.Token, true
+			}
+			exp = node.Function
+		default:
+			return link, found
+		}
+	}
+}
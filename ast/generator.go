@@ -0,0 +1,23 @@
+package ast
+
+import (
+	"Monkey/token"
+)
+
+// A yield expression, it suspends the generator running the function around it until its next() is called,
+// and evaluates to the value given to that next()
+type YieldExpression struct {
+	Token token.Token // yield Token
+	Value Expression  // The value given to the caller of next(), nil for a bare yield
+}
+
+func (ye *YieldExpression) ExpressionNode() {}
+func (ye *YieldExpression) TokenLiteral() string {
+	return ye.Token.Literal
+}
+func (ye *YieldExpression) ToString() string {
+	if ye.Value == nil {
+		return "yield"
+	}
+	return "(yield " + ye.Value.ToString() + ")"
+}
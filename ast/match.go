@@ -0,0 +1,94 @@
+package ast
+
+import (
+	"Monkey/token"
+	"strings"
+	"unicode"
+)
+
+// A pattern matching expression, it evaluates the body of the first arm matching the value
+// match value { 0 => "zero", [x, ...rest] if x > 0 => x, String => "text", _ => null }
+type MatchExpression struct {
+	Token token.Token // match Token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+func (me *MatchExpression) ExpressionNode() {}
+func (me *MatchExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+func (me *MatchExpression) ToString() string {
+	var arms []string
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.ToString())
+	}
+	return "match " + me.Value.ToString() + " {" + strings.Join(arms, ", ") + "}"
+}
+
+// An arm of a match expression, pattern if guard => body
+type MatchArm struct {
+	Token   token.Token // => Token
+	Pattern Expression  // A literal, an identifier or a destructuring pattern of literals and identifiers
+	Guard   Expression  // Must be truthy for the arm to match, nil without one
+	Body    *BlockStatement
+}
+
+func (ma *MatchArm) ToString() string {
+	out := ma.Pattern.ToString()
+	if ma.Guard != nil {
+		out += " if " + ma.Guard.ToString()
+	}
+	return out + " => " + ma.Body.ToString()
+}
+
+// The identifiers of a match pattern are a wildcard when they are _, and else a binding.
+// An identifier starting with an upper case letter, like String, is a type pattern when it is bound
+// to a type name where the match is: it matches the values of that type and binds the type name again.
+// Else it binds the value like any other identifier, like X and Y in [X, Y]
+const Wildcard = "_"
+
+// IsTypePattern returns whether an identifier of a match pattern may be a type pattern,
+// its value decides whether it is one
+func IsTypePattern(ident *Identifier) bool {
+	for _, ch := range ident.Value {
+		return unicode.IsUpper(ch)
+	}
+	return false
+}
+
+// MatchBindings returns the identifiers bound by a match pattern in their order, type patterns included
+func MatchBindings(pattern Expression) []*Identifier {
+	var bindings []*Identifier
+	for _, ident := range PatternIdentifiers(pattern) {
+		if ident.Value != Wildcard {
+			bindings = append(bindings, ident)
+		}
+	}
+	return bindings
+}
+
+// MatchValues returns the literals and the possible type patterns of a match pattern in their order,
+// they are evaluated before the value is matched. A possible type pattern whose name is not bound is null
+func MatchValues(pattern Expression) []Expression {
+	switch pattern := pattern.(type) {
+	case *Identifier:
+		if IsTypePattern(pattern) {
+			return []Expression{pattern}
+		}
+		return nil
+	case *ArrayPattern:
+		var values []Expression
+		for _, el := range pattern.Elements {
+			values = append(values, MatchValues(el.Target)...)
+		}
+		return values
+	case *HashPattern:
+		var values []Expression
+		for _, el := range pattern.Elements {
+			values = append(values, MatchValues(el.Target)...)
+		}
+		return values
+	}
+	return []Expression{pattern}
+}
@@ -11,6 +11,8 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		}
 	case *ExpressionStatement:
 		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+	case *PrintExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
 
 	case *InfixExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
@@ -19,6 +21,9 @@ func Modify(node Node, modifier ModifierFunc) Node {
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 
+	case *UpdateExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+
 	case *IndexExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Start, _ = Modify(node.Start, modifier).(Expression)
@@ -30,6 +35,34 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+	case *TryExpression:
+		node.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+	case *ThrowStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		if node.Init != nil {
+			node.Init, _ = Modify(node.Init, modifier).(Statement)
+		}
+		if node.Condition != nil {
+			node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		}
+		if node.Step != nil {
+			node.Step, _ = Modify(node.Step, modifier).(Expression)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForInStatement:
+		node.Variable, _ = Modify(node.Variable, modifier).(*Identifier)
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 	case *BlockStatement:
 		for i, _ := range node.Statements {
 			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
@@ -38,20 +71,51 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
 
 	case *LetStatement:
-		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		if node.Pattern != nil {
+			node.Pattern, _ = Modify(node.Pattern, modifier).(Expression)
+		} else {
+			node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		}
 		node.Value, _ = Modify(node.Value, modifier).(Expression)
 
 	case *FunctionLiteral:
 		for i, _ := range node.Parameters {
 			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
 		}
+		for i, pattern := range node.Patterns {
+			if pattern != nil {
+				node.Patterns[i], _ = Modify(pattern, modifier).(Expression)
+			}
+		}
+		for i, value := range node.Defaults {
+			if value != nil {
+				node.Defaults[i], _ = Modify(value, modifier).(Expression)
+			}
+		}
+		if node.Rest != nil {
+			node.Rest, _ = Modify(node.Rest, modifier).(*Identifier)
+		}
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 
+	case *ArrayPattern:
+		modifyPatternElements(node.Elements, modifier)
+		if node.Rest != nil {
+			node.Rest, _ = Modify(node.Rest, modifier).(*Identifier)
+		}
+
+	case *HashPattern:
+		modifyPatternElements(node.Elements, modifier)
+
 	case *ArrayLiteral:
 		for i, _ := range node.Elements {
 			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
 		}
 
+	case *TemplateLiteral:
+		for i := range node.Parts {
+			node.Parts[i], _ = Modify(node.Parts[i], modifier).(Expression)
+		}
+
 	case *HashLiteral:
 		newPairs := make(map[Expression]Expression)
 		for key, val := range node.Pairs {
@@ -60,6 +124,52 @@ func Modify(node Node, modifier ModifierFunc) Node {
 			newPairs[newKey] = newValue
 		}
 		node.Pairs = newPairs
+		for _, spread := range node.Spreads {
+			spread.Value, _ = Modify(spread.Value, modifier).(Expression)
+		}
+
+	case *MatchExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+		for _, arm := range node.Arms {
+			arm.Pattern, _ = Modify(arm.Pattern, modifier).(Expression)
+			if arm.Guard != nil {
+				arm.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			arm.Body, _ = Modify(arm.Body, modifier).(*BlockStatement)
+		}
+
+	case *ConditionalExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(Expression)
+		node.Alternative, _ = Modify(node.Alternative, modifier).(Expression)
+
+	case *OptionalChain:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+
+	case *YieldExpression:
+		if node.Value != nil {
+			node.Value, _ = Modify(node.Value, modifier).(Expression)
+		}
+
+	case *AwaitExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *SelectExpression:
+		for _, arm := range node.Arms {
+			if arm.Channel != nil {
+				arm.Channel, _ = Modify(arm.Channel, modifier).(Expression)
+			}
+			if arm.Value != nil {
+				arm.Value, _ = Modify(arm.Value, modifier).(Expression)
+			}
+			arm.Body, _ = Modify(arm.Body, modifier).(*BlockStatement)
+		}
+
+	case *SpreadElement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *NamedArgument:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	case *CallExpression:
 		node.Function, _ = Modify(node.Function, modifier).(Expression)
 		for i, _ := range node.Arguments {
@@ -67,6 +177,20 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		}
 	case *MacroLiteral:
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ModuleExpression:
+		if node.Body != nil {
+			node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+		}
 	}
 	return modifier(node)
 }
+
+// modifyPatternElements modifies the targets and the defaults of the elements of a pattern
+func modifyPatternElements(elements []*PatternElement, modifier ModifierFunc) {
+	for _, el := range elements {
+		el.Target, _ = Modify(el.Target, modifier).(Expression)
+		if el.Default != nil {
+			el.Default, _ = Modify(el.Default, modifier).(Expression)
+		}
+	}
+}
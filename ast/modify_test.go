@@ -165,6 +165,10 @@ func TestModify(t *testing.T) {
 			&ArrayLiteral{Elements: []Expression{one(), one()}},
 			&ArrayLiteral{Elements: []Expression{two(), two()}},
 		},
+		{
+			&TemplateLiteral{Parts: []Expression{&StringLiteral{Value: "a"}, one()}},
+			&TemplateLiteral{Parts: []Expression{&StringLiteral{Value: "a"}, two()}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -191,11 +195,11 @@ func TestModify(t *testing.T) {
 	for key, val := range hashLiteral.Pairs {
 		key, _ := key.(*IntegerLiteral)
 		if key.Value != 2 {
-			t.Errorf("value not %d, got=%f", 2, key.Value)
+			t.Errorf("value not %d, got=%d", 2, key.Value)
 		}
 		val, _ := val.(*IntegerLiteral)
 		if val.Value != 2 {
-			t.Errorf("value is not %d, got=%f", 2, val.Value)
+			t.Errorf("value is not %d, got=%d", 2, val.Value)
 		}
 	}
 }
@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"Monkey/token"
+	"strings"
+)
+
+// A target of a pattern with its default value, the parts of a pattern
+type PatternElement struct {
+	Key     string     // The key of a hash pattern element
+	Target  Expression // An *Identifier, an *ArrayPattern or a *HashPattern
+	Default Expression // Used when the value is missing or null, nil without a default
+}
+
+func (pe *PatternElement) ToString() string {
+	out := pe.Target.ToString()
+	if pe.Default != nil {
+		out += " = " + pe.Default.ToString()
+	}
+	return out
+}
+
+// Array destructuring pattern
+// let [a, b = 1, [c], ...rest] = value
+type ArrayPattern struct {
+	Token    token.Token // [ Token
+	Elements []*PatternElement
+	Rest     *Identifier // The identifier of ...rest, nil without one
+}
+
+func (ap *ArrayPattern) ExpressionNode() {}
+func (ap *ArrayPattern) TokenLiteral() string {
+	return ap.Token.Literal
+}
+func (ap *ArrayPattern) ToString() string {
+	var elements []string
+	for _, el := range ap.Elements {
+		elements = append(elements, el.ToString())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.ToString())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Hash destructuring pattern, it also destructures modules
+// let {name, age: years = 0, address: {city}} = value
+type HashPattern struct {
+	Token    token.Token // { Token
+	Elements []*PatternElement
+}
+
+func (hp *HashPattern) ExpressionNode() {}
+func (hp *HashPattern) TokenLiteral() string {
+	return hp.Token.Literal
+}
+func (hp *HashPattern) ToString() string {
+	var elements []string
+	for _, el := range hp.Elements {
+		if ident, ok := el.Target.(*Identifier); ok && ident.Value == el.Key {
+			elements = append(elements, el.ToString())
+		} else {
+			elements = append(elements, el.Key+": "+el.ToString())
+		}
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+// PatternIdentifiers returns the identifiers bound by a pattern in their order
+func PatternIdentifiers(pattern Expression) []*Identifier {
+	switch pattern := pattern.(type) {
+	case *Identifier:
+		return []*Identifier{pattern}
+	case *ArrayPattern:
+		var identifiers []*Identifier
+		for _, el := range pattern.Elements {
+			identifiers = append(identifiers, PatternIdentifiers(el.Target)...)
+		}
+		if pattern.Rest != nil {
+			identifiers = append(identifiers, pattern.Rest)
+		}
+		return identifiers
+	case *HashPattern:
+		var identifiers []*Identifier
+		for _, el := range pattern.Elements {
+			identifiers = append(identifiers, PatternIdentifiers(el.Target)...)
+		}
+		return identifiers
+	}
+	return nil
+}
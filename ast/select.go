@@ -0,0 +1,70 @@
+package ast
+
+import (
+	"Monkey/token"
+	"strings"
+)
+
+// A select expression, it waits until one of its channel operations can run
+// and evaluates the body of that arm, or the body of the _ arm when none can run right away
+// select { msg = inbox.recv() => msg, outbox.send(1) => "sent", _ => null }
+type SelectExpression struct {
+	Token token.Token // select Token
+	Arms  []*SelectArm
+}
+
+func (se *SelectExpression) ExpressionNode() {}
+func (se *SelectExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SelectExpression) ToString() string {
+	var arms []string
+	for _, arm := range se.Arms {
+		arms = append(arms, arm.ToString())
+	}
+	return "select {" + strings.Join(arms, ", ") + "}"
+}
+
+// An arm of a select expression, name = channel.recv() => body, channel.send(value) => body or _ => body
+type SelectArm struct {
+	Token   token.Token // => Token
+	Name    *Identifier // The variable receiving the value, nil without one
+	Channel Expression  // nil for the _ arm
+	Value   Expression  // The value sent, nil for a receive
+	Send    bool
+	Body    *BlockStatement
+}
+
+func (sa *SelectArm) ToString() string {
+	var out strings.Builder
+	switch {
+	case sa.Channel == nil:
+		out.WriteString(Wildcard)
+	case sa.Send:
+		out.WriteString(sa.Channel.ToString() + ".send(" + sa.Value.ToString() + ")")
+	default:
+		if sa.Name != nil {
+			out.WriteString(sa.Name.ToString() + " = ")
+		}
+		out.WriteString(sa.Channel.ToString() + ".recv()")
+	}
+	out.WriteString(" => ")
+	out.WriteString(sa.Body.ToString())
+	return out.String()
+}
+
+// SelectOperands returns the channels of the arms of a select and the values of its sends in their order,
+// they are evaluated before the select waits
+func SelectOperands(node *SelectExpression) []Expression {
+	var operands []Expression
+	for _, arm := range node.Arms {
+		if arm.Channel == nil {
+			continue
+		}
+		operands = append(operands, arm.Channel)
+		if arm.Send {
+			operands = append(operands, arm.Value)
+		}
+	}
+	return operands
+}
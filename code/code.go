@@ -0,0 +1,312 @@
+package code
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// A list of bytecode instructions
+type Instructions []byte
+
+// The first byte of an instruction
+type Opcode byte
+
+// All Opcodes
+// Operands referring to tokens are indexes into the Tokens of the compiled function
+const (
+	// Constants
+	OpConstant Opcode = iota // const
+	OpTrue
+	OpFalse
+	OpNull
+	OpBreak
+
+	// Stack
+	OpPop
+	OpDup    // count, pushes the top count values again
+	OpRotate // count, moves the top of the stack below the count values under it
+
+	// Operators
+	OpInfix  // operator, token
+	OpPrefix // operator, token
+	OpBool   // converts the top of the stack to its truthiness
+
+	// Jumps
+	OpJump          // position
+	OpJumpNotTruthy // position
+	OpJumpNotNull   // position, jumps keeping the top of the stack when it is not null, else pops it
+	OpJumpNull      // position, jumps keeping the top of the stack when it is null
+	// The locals of lets are hoisted, they jump once the let defined the variable
+	OpJumpDefinedLocal // local, position
+	OpJumpDefinedFree  // free, position
+
+	// Variables
+	OpGetGlobal    // name const, token
+	OpDefineGlobal // name const
+	OpSetGlobal    // name const, token
+	OpLookupGlobal // name const, pushes null when the global is not defined
+	OpGetLocal     // local
+	OpSetLocal     // local
+	OpGetFree      // free
+	OpSetFree      // free
+	OpLoadCell     // local, pushes the cell of a local to capture it
+	OpLoadFreeCell // free, pushes the cell of a free variable to capture it
+	OpThis         // token
+
+	// Literals
+	OpArray       // length
+	OpInterpolate // length, joins the parts of an interpolated string
+	OpHash        // pairs, token
+	OpSpread      // token, adds the elements of the top of the stack to the array below it, or the pairs to the hash
+
+	// Access
+	OpIndex          // has range, token
+	OpSetIndex       // token
+	OpMember         // token
+	OpOptionalMember // token, null when the value is null or has no such member
+	OpSetMember      // token
+	OpMemberTarget   // token, pushes the value of a member to update it
+
+	// Destructuring, they push a part of the value on top of the stack and keep the value
+	OpPatternElement // index, has default, token
+	OpPatternRest    // start, token
+	OpPatternKey     // key const, has default, token
+	// Replaces an iterable destructured by an array pattern with an array of the elements the pattern takes
+	OpPatternIterable // elements, has rest, token
+
+	// Match, OpMatch pops the value and the values of the literals of the pattern above it,
+	// it pushes the values of the bindings and true when the value matches, else false
+	OpMatch   // pattern const, literals, token
+	OpNoMatch // token, fails with the value on top of the stack
+
+	// Select, OpSelect pops the channels of the arms and the values they send,
+	// it runs the operation of an arm and pushes the value it received and the index of the arm
+	OpSelect // select const
+
+	// Quote, OpQuote pops the values of the unquote calls of the quote and pushes a copy of it where they are spliced
+	OpQuote // quote const, unquotes
+
+	// Functions
+	OpCall        // arguments, token
+	OpApply       // names const, token, calls with an array of arguments followed by the values of the named arguments
+	OpReturnValue //
+	OpReturn      //
+	OpClosure     // function const, free variables
+	OpModule      // function const, free variables
+	OpYield       // suspends the generator with the value on top of the stack, replaced by the value it is resumed with
+	OpAwait       // token, replaces the promise on top of the stack with its value once it settles
+
+	// IO
+	OpPrint // token
+
+	// Errors
+	OpTry    // catch position, fatal errors until OpEndTry jump to the position with the error pushed
+	OpEndTry //
+	OpThrow  // token
+
+	// Loops
+	OpIter      // token, replaces the iterable on the stack with its iterator
+	OpIterNext  // position, pops an iterator and pushes its next element, jumping to the position once it is exhausted
+	OpIterClose // pops an iterator left before its end and closes it
+)
+
+// The name and operands of an opcode
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+	OpBreak:    {"OpBreak", []int{}},
+
+	OpPop:    {"OpPop", []int{}},
+	OpDup:    {"OpDup", []int{1}},
+	OpRotate: {"OpRotate", []int{1}},
+
+	OpInfix:  {"OpInfix", []int{1, 2}},
+	OpPrefix: {"OpPrefix", []int{1, 2}},
+	OpBool:   {"OpBool", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJumpNotNull:   {"OpJumpNotNull", []int{2}},
+	OpJumpNull:      {"OpJumpNull", []int{2}},
+
+	OpJumpDefinedLocal: {"OpJumpDefinedLocal", []int{1, 2}},
+	OpJumpDefinedFree:  {"OpJumpDefinedFree", []int{1, 2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2, 2}},
+	OpDefineGlobal: {"OpDefineGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2, 2}},
+	OpLookupGlobal: {"OpLookupGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpSetFree:      {"OpSetFree", []int{1}},
+	OpLoadCell:     {"OpLoadCell", []int{1}},
+	OpLoadFreeCell: {"OpLoadFreeCell", []int{1}},
+	OpThis:         {"OpThis", []int{2}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpHash:        {"OpHash", []int{2, 2}},
+	OpSpread:      {"OpSpread", []int{2}},
+
+	OpIndex:          {"OpIndex", []int{1, 2}},
+	OpSetIndex:       {"OpSetIndex", []int{2}},
+	OpMember:         {"OpMember", []int{2}},
+	OpOptionalMember: {"OpOptionalMember", []int{2}},
+	OpSetMember:      {"OpSetMember", []int{2}},
+	OpMemberTarget:   {"OpMemberTarget", []int{2}},
+
+	OpPatternElement:  {"OpPatternElement", []int{2, 1, 2}},
+	OpPatternRest:     {"OpPatternRest", []int{2, 2}},
+	OpPatternKey:      {"OpPatternKey", []int{2, 1, 2}},
+	OpPatternIterable: {"OpPatternIterable", []int{2, 1, 2}},
+
+	OpMatch:   {"OpMatch", []int{2, 1, 2}},
+	OpNoMatch: {"OpNoMatch", []int{2}},
+
+	OpSelect: {"OpSelect", []int{2}},
+
+	OpQuote: {"OpQuote", []int{2, 2}},
+
+	OpCall:        {"OpCall", []int{1, 2}},
+	OpApply:       {"OpApply", []int{2, 2}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpModule:      {"OpModule", []int{2, 1}},
+	OpYield:       {"OpYield", []int{}},
+	OpAwait:       {"OpAwait", []int{2}},
+
+	OpPrint: {"OpPrint", []int{2}},
+
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	OpThrow:  {"OpThrow", []int{2}},
+
+	OpIter:      {"OpIter", []int{2}},
+	OpIterNext:  {"OpIterNext", []int{2}},
+	OpIterClose: {"OpIterClose", []int{}},
+}
+
+// The operators of OpInfix, indexed by its first operand
+var InfixOperators = []string{"+", "-", "*", "/", "%", "==", "!=", "<", ">", "<=", ">=", "xor", "**", "&", "|", "^", "<<", ">>"}
+
+// The operators of OpPrefix, indexed by its first operand
+var PrefixOperators = []string{"!", "-", "+", "~"}
+
+// Lookup returns the definition of an opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, returning the bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return ins[0]
+}
+
+// String disassembles the instructions
+func (ins Instructions) String() string {
+	var out strings.Builder
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	case 3:
+		return fmt.Sprintf("%s %d %d %d", def.Name, operands[0], operands[1], operands[2])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
@@ -0,0 +1,1734 @@
+package compiler
+
+import (
+	"Monkey/ast"
+	"Monkey/code"
+	"Monkey/evaluator"
+	"Monkey/object"
+	"Monkey/token"
+	"fmt"
+	"sort"
+)
+
+// Error is a compilation error at a token
+type Error struct {
+	Message string
+	Token   token.Token
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// State is shared by the compilations of one interpreter, the constant pool only grows
+type State struct {
+	Constants []object.Object
+
+	// Literals and builtins are stored once, numbers are copied by the vm when loaded
+	integers map[int64]int
+	floats   map[float64]int
+	strings  map[string]int
+	builtins map[string]int
+}
+
+// NewState creates an empty constant pool
+func NewState() *State {
+	return &State{
+		integers: make(map[int64]int),
+		floats:   make(map[float64]int),
+		strings:  make(map[string]int),
+		builtins: make(map[string]int),
+	}
+}
+
+// Bytecode is the result of a compilation
+type Bytecode struct {
+	Instructions code.Instructions
+	Tokens       []token.Token
+	Constants    []object.Object
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled
+type CompilationScope struct {
+	instructions code.Instructions
+	tokens       []token.Token
+
+	lastInstruction EmittedInstruction
+
+	// The last instruction popped the value of an expression statement
+	lastIsValue bool
+
+	// The finally blocks of the try blocks being compiled, innermost last,
+	// nil for those without a finally block
+	tries []*ast.BlockStatement
+
+	// The loops being compiled, innermost last
+	loops []*loop
+
+	// The jumps to the end of the optional chains being compiled, innermost last
+	chains [][]int
+
+	// The amount of blocks being compiled, the function body is the first one
+	blocks int
+}
+
+// loop is a loop being compiled, its break and continue jumps are patched once their targets are known
+type loop struct {
+	label string
+	// the amount of try blocks around the loop, jumps leave those started inside of it
+	tries int
+	// the hidden variable holding the iterator of a for in loop, closed by the jumps leaving the loop
+	iterator *Symbol
+
+	breaks    []int
+	continues []int
+}
+
+type Compiler struct {
+	state       *State
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+// New creates a compiler for a program
+func New(state *State) *Compiler {
+	return &Compiler{
+		state:       state,
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// Bytecode returns the compiled program
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Tokens:       c.scopes[c.scopeIndex].tokens,
+		Constants:    c.state.Constants,
+	}
+}
+
+// Compile lowers a node to bytecode, programs return the value of their last statement
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+		c.emitReturn()
+
+		if len(c.state.Constants) > 1<<16 {
+			return &Error{Message: "too many constants"}
+		}
+
+	case *ast.ExpressionStatement:
+		if node.Expression == nil {
+			return nil
+		}
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		c.scopes[c.scopeIndex].lastIsValue = true
+
+	case *ast.PrintExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpPrint)
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Pattern != nil {
+			if err := c.compilePattern(node.Pattern); err != nil {
+				return err
+			}
+		} else {
+			c.emitDefine(c.symbolTable.Define(node.Name.Value), node.Token)
+		}
+		c.emit(code.OpPop)
+
+		// a let of the function body runs before the code after it, which reads the local right away
+		if c.scopes[c.scopeIndex].blocks == 1 {
+			if node.Pattern != nil {
+				for _, ident := range ast.PatternIdentifiers(node.Pattern) {
+					c.symbolTable.Bind(ident.Value)
+				}
+			} else {
+				c.symbolTable.Bind(node.Name.Value)
+			}
+		}
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.compileFinallies(); err != nil {
+			return err
+		}
+		loops := c.scopes[c.scopeIndex].loops
+		c.closeIterators(loops, 0)
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpThrow)
+
+	case *ast.BlockStatement:
+		return c.compileBlock(node)
+
+	case *ast.WhileStatement:
+		return c.compileWhile(node)
+
+	case *ast.ForStatement:
+		return c.compileFor(node)
+
+	case *ast.ForInStatement:
+		return c.compileForIn(node)
+
+	case *ast.BreakStatement:
+		return c.compileJump(node.Token, node.Label, false)
+
+	case *ast.ContinueStatement:
+		return c.compileJump(node.Token, node.Label, true)
+
+	case *ast.IntegerLiteral:
+		index, ok := c.state.integers[node.Value]
+		if !ok {
+			index = c.addConstant(&object.Integer{Value: node.Value})
+			c.state.integers[node.Value] = index
+		}
+		c.emit(code.OpConstant, index)
+
+	case *ast.FloatLiteral:
+		index, ok := c.state.floats[node.Value]
+		if !ok {
+			index = c.addConstant(&object.Float{Value: node.Value})
+			c.state.floats[node.Value] = index
+		}
+		c.emit(code.OpConstant, index)
+
+	case *ast.BigIntLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Value}))
+
+	case *ast.DecimalLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Decimal{Value: node.Value, Scale: node.Scale}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addString(node.Value))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Null:
+		c.emit(code.OpNull)
+
+	case *ast.Break:
+		c.emit(code.OpBreak)
+
+	case *ast.Identifier:
+		return c.compileIdentifier(node)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		operator, ok := operatorIndex(code.PrefixOperators, node.Operator)
+		if !ok {
+			return &Error{Message: fmt.Sprintf("unknown operator: %s", node.Operator), Token: node.Token}
+		}
+		c.emitToken(node.Token, code.OpPrefix, operator)
+
+	case *ast.UpdateExpression:
+		return c.compileUpdate(node)
+
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.MatchExpression:
+		return c.compileMatch(node)
+
+	case *ast.SelectExpression:
+		return c.compileSelect(node)
+
+	case *ast.ConditionalExpression:
+		return c.compileConditional(node)
+
+	case *ast.OptionalChain:
+		return c.compileOptionalChain(node)
+
+	case *ast.YieldExpression:
+		if node.Value == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpYield)
+
+	case *ast.AwaitExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpAwait)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+
+	case *ast.ModuleExpression:
+		return c.compileModule(node)
+
+	case *ast.MacroLiteral:
+		return &Error{Message: "macro literals can only be defined at the top level", Token: node.Token}
+
+	case *ast.CallExpression:
+		return c.compileCall(node)
+
+	case *ast.ArrayLiteral:
+		if err := c.compileElements(node.Elements); err != nil {
+			return err
+		}
+
+	case *ast.TemplateLiteral:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.HashLiteral:
+		// The pairs of the spreads are copied first, the pairs of the literal replace them
+		if len(node.Spreads) > 0 {
+			c.emitToken(node.Token, code.OpHash, 0)
+			for _, spread := range node.Spreads {
+				if err := c.Compile(spread.Value); err != nil {
+					return err
+				}
+				c.emitToken(spread.Token, code.OpSpread)
+			}
+		}
+
+		// Hashes are unordered, sort the keys to compile deterministically
+		keys := make([]ast.Expression, 0, len(node.Pairs))
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].ToString() < keys[j].ToString()
+		})
+
+		for _, key := range keys {
+			if err := c.Compile(key); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[key]); err != nil {
+				return err
+			}
+		}
+		c.emitToken(node.Token, code.OpHash, len(keys))
+		if len(node.Spreads) > 0 {
+			c.emitToken(node.Token, code.OpSpread)
+		}
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if node.Optional {
+			c.emitChainJump()
+		}
+		if err := c.compileOptional(node.Start); err != nil {
+			return err
+		}
+		if err := c.compileOptional(node.End); err != nil {
+			return err
+		}
+		hasRange := 0
+		if node.HasRange {
+			hasRange = 1
+		}
+		c.emitToken(node.Token, code.OpIndex, hasRange)
+
+	default:
+		return &Error{Message: fmt.Sprintf("cannot compile %T", node)}
+	}
+
+	return nil
+}
+
+// compileOptional compiles an expression that can be missing, leaving null
+func (c *Compiler) compileOptional(exp ast.Expression) error {
+	if exp == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+	return c.Compile(exp)
+}
+
+// compileBlock compiles the statements of a block, leaving the value of the last one on the stack
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	c.scopes[c.scopeIndex].lastIsValue = false
+	if block != nil {
+		c.scopes[c.scopeIndex].blocks++
+		for _, s := range block.Statements {
+			c.scopes[c.scopeIndex].lastIsValue = false
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+		c.scopes[c.scopeIndex].blocks--
+	}
+
+	if c.lastInstructionIs(code.OpPop) && c.scopes[c.scopeIndex].lastIsValue {
+		c.removeLastInstruction()
+	} else {
+		c.emit(code.OpNull)
+	}
+	c.scopes[c.scopeIndex].lastIsValue = false
+	return nil
+}
+
+func (c *Compiler) compileIdentifier(node *ast.Identifier) error {
+	if node.Value == "this" {
+		c.emitToken(node.Token, code.OpThis)
+		return nil
+	}
+
+	// builtins shadow every variable, like the evaluator
+	if builtin, ok := evaluator.LookupBuiltin(node.Value); ok {
+		index, ok := c.state.builtins[node.Value]
+		if !ok {
+			index = c.addConstant(builtin)
+			c.state.builtins[node.Value] = index
+		}
+		c.emit(code.OpConstant, index)
+		return nil
+	}
+
+	c.emitGet(c.symbolTable.Resolve(node.Value), node.Token)
+	return nil
+}
+
+// emitGet pushes the value of a symbol
+func (c *Compiler) emitGet(symbol Symbol, t token.Token) {
+	if symbol.Hoisted {
+		c.emitHoisted(symbol, func(symbol Symbol) { c.emitGet(symbol, t) })
+		return
+	}
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitToken(t, code.OpGetGlobal, c.addString(symbol.Name))
+	case LocalScope:
+		c.emit(code.OpGetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, symbol.Index)
+	}
+}
+
+// emitLookup pushes the value of a symbol like emitGet, null for a global that is not defined
+func (c *Compiler) emitLookup(symbol Symbol) {
+	if symbol.Hoisted {
+		c.emitHoisted(symbol, c.emitLookup)
+	} else if symbol.Scope == GlobalScope {
+		c.emit(code.OpLookupGlobal, c.addString(symbol.Name))
+	} else {
+		c.emitGet(symbol, token.Token{})
+	}
+}
+
+// emitDefine stores the top of the stack in a new variable, keeping it on the stack
+func (c *Compiler) emitDefine(symbol Symbol, t token.Token) {
+	symbol.Hoisted = false
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpDefineGlobal, c.addString(symbol.Name))
+	} else {
+		c.emitSet(symbol, t)
+	}
+}
+
+// emitSet stores the top of the stack in a symbol, keeping it on the stack
+func (c *Compiler) emitSet(symbol Symbol, t token.Token) {
+	if symbol.Hoisted {
+		c.emitHoisted(symbol, func(symbol Symbol) { c.emitSet(symbol, t) })
+		return
+	}
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emitToken(t, code.OpSetGlobal, c.addString(symbol.Name))
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	}
+}
+
+// emitHoisted emits the access of a hoisted local once its let ran, or else the access of the symbol
+// its name resolves to past the function
+func (c *Compiler) emitHoisted(symbol Symbol, emit func(Symbol)) {
+	outer := c.symbolTable.ResolveOuter(symbol)
+	symbol.Hoisted = false
+
+	op := code.OpJumpDefinedLocal
+	if symbol.Scope == FreeScope {
+		op = code.OpJumpDefinedFree
+	}
+	defined := c.emit(op, symbol.Index, 9999)
+	emit(outer)
+	end := c.emit(code.OpJump, 9999)
+	c.changeOperand(defined, len(c.currentInstructions()))
+	emit(symbol)
+	c.changeOperand(end, len(c.currentInstructions()))
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	switch node.Operator {
+	case token.ASSIGN:
+		return c.compileAssignment(node)
+
+	case token.DOT:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compileKey(node.Right); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpMember)
+		return nil
+
+	case token.OPTIONAL_DOT:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emitChainJump()
+		if err := c.compileKey(node.Right); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpOptionalMember)
+		return nil
+
+	case token.NULLISH:
+		// left ?? right is left when it is not null
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		jump := c.emit(code.OpJumpNotNull, 9999)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jump, len(c.currentInstructions()))
+		return nil
+
+	case token.AND:
+		// left ? bool(right) : false
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		jumpFalse := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpBool)
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpFalse, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return nil
+
+	case token.OR:
+		// left ? true : bool(right)
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		jumpRight := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpRight, len(c.currentInstructions()))
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpBool)
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return nil
+	}
+
+	if operator, ok := token.CompoundAssignments[token.TokenType(node.Operator)]; ok {
+		return c.compileCompoundAssignment(node, operator)
+	}
+
+	operator, ok := operatorIndex(code.InfixOperators, node.Operator)
+	if !ok {
+		return &Error{Message: fmt.Sprintf("unknown operator: %s", node.Operator), Token: node.Token}
+	}
+
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.emitToken(node.Token, code.OpInfix, operator)
+	return nil
+}
+
+// compileKey compiles the right side of a dot, identifiers are used as names
+func (c *Compiler) compileKey(exp ast.Expression) error {
+	if ident, ok := exp.(*ast.Identifier); ok {
+		c.emit(code.OpConstant, c.addString(ident.Value))
+		return nil
+	}
+	return c.Compile(exp)
+}
+
+func (c *Compiler) compileAssignment(node *ast.InfixExpression) error {
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emitSet(c.symbolTable.Resolve(left.Value), node.Token)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		if err := c.Compile(left.Left); err != nil {
+			return err
+		}
+		if err := c.compileOptional(left.Start); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpSetIndex)
+
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		if err := c.Compile(left.Left); err != nil {
+			return err
+		}
+		if err := c.compileKey(left.Right); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpSetMember)
+
+	default:
+		return &Error{Message: "Cannot use non identifier in an expression", Token: node.Token}
+	}
+	return nil
+}
+
+// compileCompoundAssignment compiles x op= y, the container and the index of x stay on the stack
+// under its value so that they are evaluated once
+func (c *Compiler) compileCompoundAssignment(node *ast.InfixExpression, operator token.TokenType) error {
+	targets, err := c.compileUpdateTarget(node.Token, node.Left)
+	if err != nil {
+		return err
+	}
+
+	// x ??= y keeps x when it is not null
+	jumpKeep := -1
+	if operator == token.NULLISH {
+		jumpKeep = c.emit(code.OpJumpNotNull, 9999)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+	} else {
+		index, ok := operatorIndex(code.InfixOperators, string(operator))
+		if !ok {
+			return &Error{Message: fmt.Sprintf("unknown operator: %s", node.Operator), Token: node.Token}
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emitToken(node.Token, code.OpInfix, index)
+	}
+	c.emitUpdateStore(node.Token, node.Left, targets)
+
+	if jumpKeep != -1 {
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpKeep, len(c.currentInstructions()))
+		if targets > 0 {
+			c.emit(code.OpRotate, targets)
+			for i := 0; i < targets; i++ {
+				c.emit(code.OpPop)
+			}
+		}
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileUpdate compiles x++ like x += 1, x++ keeps the value x had under the targets to return it
+func (c *Compiler) compileUpdate(node *ast.UpdateExpression) error {
+	targets, err := c.compileUpdateTarget(node.Token, node.Target)
+	if err != nil {
+		return err
+	}
+	if !node.Prefix {
+		c.emit(code.OpDup, 1)
+		if targets > 0 {
+			c.emit(code.OpRotate, targets+1)
+		}
+	}
+
+	operator := token.Updates[token.TokenType(node.Operator)]
+	index, ok := operatorIndex(code.InfixOperators, string(operator))
+	if !ok {
+		return &Error{Message: fmt.Sprintf("unknown operator: %s", node.Operator), Token: node.Token}
+	}
+	c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: 1}))
+	c.emitToken(node.Token, code.OpInfix, index)
+	c.emitUpdateStore(node.Token, node.Target, targets)
+
+	if !node.Prefix {
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compileUpdateTarget pushes the container and the index of the target of an update, they are the targets,
+// and then its value. The targets stay on the stack so that they are evaluated once
+func (c *Compiler) compileUpdateTarget(t token.Token, target ast.Expression) (targets int, err error) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		if err := c.Compile(target); err != nil {
+			return 0, err
+		}
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return 0, err
+		}
+		if err := c.compileOptional(target.Start); err != nil {
+			return 0, err
+		}
+		targets = 2
+		c.emit(code.OpDup, targets)
+		c.emit(code.OpNull)
+		c.emitToken(t, code.OpIndex, 0)
+
+	case *ast.InfixExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return 0, err
+		}
+		if err := c.compileKey(target.Right); err != nil {
+			return 0, err
+		}
+		targets = 2
+		c.emit(code.OpDup, targets)
+		c.emitToken(t, code.OpMemberTarget)
+
+	default:
+		return 0, &Error{Message: "Cannot use non identifier in an expression", Token: t}
+	}
+	return targets, nil
+}
+
+// emitUpdateStore stores the top of the stack in the target of an update, consuming its targets
+func (c *Compiler) emitUpdateStore(t token.Token, target ast.Expression, targets int) {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		c.emitSet(c.symbolTable.Resolve(target.Value), t)
+	case *ast.IndexExpression:
+		c.emit(code.OpRotate, targets)
+		c.emitToken(t, code.OpSetIndex)
+	case *ast.InfixExpression:
+		c.emit(code.OpRotate, targets)
+		c.emitToken(t, code.OpSetMember)
+	}
+}
+
+// compilePattern stores the parts of the value on top of the stack in the identifiers of a pattern, keeping the value
+func (c *Compiler) compilePattern(pattern ast.Expression) error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		c.emitDefine(c.symbolTable.Define(pattern.Value), pattern.Token)
+
+	case *ast.ArrayPattern:
+		c.emitToken(pattern.Token, code.OpPatternIterable, len(pattern.Elements), hasRest(pattern))
+		for i, el := range pattern.Elements {
+			c.emitToken(pattern.Token, code.OpPatternElement, i, hasDefault(el))
+			if err := c.compilePatternElement(el); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			c.emitToken(pattern.Token, code.OpPatternRest, len(pattern.Elements))
+			c.emitDefine(c.symbolTable.Define(pattern.Rest.Value), pattern.Rest.Token)
+			c.emit(code.OpPop)
+		}
+
+	case *ast.HashPattern:
+		for _, el := range pattern.Elements {
+			c.emitToken(pattern.Token, code.OpPatternKey, c.addString(el.Key), hasDefault(el))
+			if err := c.compilePatternElement(el); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return &Error{Message: fmt.Sprintf("cannot destructure with %T", pattern)}
+	}
+	return nil
+}
+
+// compilePatternElement destructures the part on top of the stack, or the default of the element when it is null,
+// and pops it
+func (c *Compiler) compilePatternElement(el *ast.PatternElement) error {
+	if el.Default != nil {
+		jump := c.emit(code.OpJumpNotNull, 9999)
+		if err := c.Compile(el.Default); err != nil {
+			return err
+		}
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	if err := c.compilePattern(el.Target); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+func hasDefault(el *ast.PatternElement) int {
+	if el.Default != nil {
+		return 1
+	}
+	return 0
+}
+
+func hasRest(pattern *ast.ArrayPattern) int {
+	if pattern.Rest != nil {
+		return 1
+	}
+	return 0
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlock(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlock(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileConditional(node *ast.ConditionalExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if err := c.Compile(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+	return nil
+}
+
+// compileOptionalChain compiles a chain whose ?. jump to its end, keeping the null on the stack.
+// A ?. is reached with only the value on its left pushed by the chain
+func (c *Compiler) compileOptionalChain(node *ast.OptionalChain) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.chains = append(scope.chains, nil)
+	if err := c.Compile(node.Expression); err != nil {
+		return err
+	}
+
+	scope = &c.scopes[c.scopeIndex]
+	jumps := scope.chains[len(scope.chains)-1]
+	scope.chains = scope.chains[:len(scope.chains)-1]
+	for _, jump := range jumps {
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// emitChainJump jumps to the end of the optional chain being compiled when the top of the stack is null
+func (c *Compiler) emitChainJump() {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.chains) == 0 {
+		return
+	}
+	jump := c.emit(code.OpJumpNull, 9999)
+	scope.chains[len(scope.chains)-1] = append(scope.chains[len(scope.chains)-1], jump)
+}
+
+// compileTry compiles a try expression, the catch block starts with the error on the stack
+// and the finally block is compiled on every way out of the expression
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	try := c.emit(code.OpTry, 9999)
+	if err := c.compileTryBlock(node.Block, node.Finally); err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 9999)}
+
+	c.changeOperand(try, len(c.currentInstructions()))
+	if node.Catch != nil {
+		restore := func() {}
+		if node.Parameter != nil {
+			c.emitDefine(c.symbolTable.Define(node.Parameter.Value), node.Parameter.Token)
+			restore = c.symbolTable.Bind(node.Parameter.Value)
+		}
+		c.emit(code.OpPop)
+
+		if node.Finally == nil {
+			err := c.compileBlock(node.Catch)
+			restore()
+			if err != nil {
+				return err
+			}
+			c.changeOperand(jumps[0], len(c.currentInstructions()))
+			return nil
+		}
+
+		// errors of the catch block run the finally block too
+		rethrow := c.emit(code.OpTry, 9999)
+		err := c.compileTryBlock(node.Catch, node.Finally)
+		restore()
+		if err != nil {
+			return err
+		}
+		jumps = append(jumps, c.emit(code.OpJump, 9999))
+		c.changeOperand(rethrow, len(c.currentInstructions()))
+	}
+
+	// run the finally block and throw the error on the stack again
+	if err := c.compileBlock(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	c.emitToken(node.Token, code.OpThrow)
+
+	for _, jump := range jumps {
+		c.changeOperand(jump, len(c.currentInstructions()))
+	}
+	if err := c.compileBlock(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileMatch compiles a match expression, the value is kept in a hidden variable
+// so that break, continue and return in the arms do not have to clean the stack
+func (c *Compiler) compileMatch(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	// the name cannot be written in a program
+	value := c.symbolTable.Define(fmt.Sprintf("match %d", len(c.currentInstructions())))
+	c.emitDefine(value, node.Token)
+	c.emit(code.OpPop)
+
+	// the possible type patterns are resolved before the arms define their bindings
+	types := map[string]Symbol{}
+	for _, arm := range node.Arms {
+		for _, ident := range ast.PatternIdentifiers(arm.Pattern) {
+			if _, ok := types[ident.Value]; !ok && ast.IsTypePattern(ident) {
+				types[ident.Value] = c.symbolTable.Resolve(ident.Value)
+			}
+		}
+	}
+
+	var ends []int
+	for _, arm := range node.Arms {
+		c.emitGet(value, node.Token)
+		literals := ast.MatchValues(arm.Pattern)
+		for _, literal := range literals {
+			if ident, ok := literal.(*ast.Identifier); ok {
+				c.emitLookup(types[ident.Value])
+				continue
+			}
+			if err := c.Compile(literal); err != nil {
+				return err
+			}
+		}
+		if len(literals) > 255 {
+			return &Error{Message: "too many literals in the pattern", Token: arm.Token}
+		}
+		c.emitToken(arm.Token, code.OpMatch, c.addConstant(&object.Quote{Node: arm.Pattern}), len(literals))
+		next := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		// the values of the bindings are on the stack in their order
+		bindings := ast.MatchBindings(arm.Pattern)
+		if arm.Guard == nil {
+			for i := len(bindings) - 1; i >= 0; i-- {
+				c.emitDefine(c.symbolTable.Define(bindings[i].Value), bindings[i].Token)
+				c.emit(code.OpPop)
+			}
+		} else if err := c.compileGuard(arm, bindings, &next); err != nil {
+			return err
+		}
+		restores := make([]func(), len(bindings))
+		for i, ident := range bindings {
+			restores[i] = c.symbolTable.Bind(ident.Value)
+		}
+		err := c.compileBlock(arm.Body)
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+		if err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.patchJumps(next, len(c.currentInstructions()))
+	}
+
+	c.emitGet(value, node.Token)
+	c.emitToken(node.Token, code.OpNoMatch)
+	c.patchJumps(ends, len(c.currentInstructions()))
+	return nil
+}
+
+// compileGuard compiles the guard of a match arm whose bindings are on the stack. The guard sees the bindings
+// under hidden names, they are only defined once the guard passes so that a rejected arm leaves the variables as they were
+func (c *Compiler) compileGuard(arm *ast.MatchArm, bindings []*ast.Identifier, next *[]int) error {
+	// the names cannot be written in a program
+	position := len(c.currentInstructions())
+	hidden := make([]Symbol, len(bindings))
+	var restores []func()
+	for i := len(bindings) - 1; i >= 0; i-- {
+		var restore func()
+		hidden[i], restore = c.symbolTable.Shadow(bindings[i].Value, fmt.Sprintf("match %d %s", position, bindings[i].Value))
+		restores = append(restores, restore)
+		c.emitDefine(hidden[i], bindings[i].Token)
+		c.emit(code.OpPop)
+	}
+
+	err := c.Compile(arm.Guard)
+	for i := len(restores) - 1; i >= 0; i-- {
+		restores[i]()
+	}
+	if err != nil {
+		return err
+	}
+	*next = append(*next, c.emit(code.OpJumpNotTruthy, 9999))
+
+	for i, ident := range bindings {
+		c.emitGet(hidden[i], ident.Token)
+		c.emitDefine(c.symbolTable.Define(ident.Value), ident.Token)
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compileSelect compiles a select expression, the index of the arm that ran and the value it received
+// are kept in hidden variables like the value of a match
+func (c *Compiler) compileSelect(node *ast.SelectExpression) error {
+	for _, operand := range ast.SelectOperands(node) {
+		if err := c.Compile(operand); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpSelect, c.addConstant(&object.Quote{Node: node}))
+
+	// the names cannot be written in a program
+	position := len(c.currentInstructions())
+	index := c.symbolTable.Define(fmt.Sprintf("select %d", position))
+	c.emitDefine(index, node.Token)
+	c.emit(code.OpPop)
+	value := c.symbolTable.Define(fmt.Sprintf("select value %d", position))
+	c.emitDefine(value, node.Token)
+	c.emit(code.OpPop)
+
+	equal, _ := operatorIndex(code.InfixOperators, token.EQ)
+	var ends []int
+	for i, arm := range node.Arms {
+		c.emitGet(index, node.Token)
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(i)}))
+		c.emitToken(arm.Token, code.OpInfix, equal)
+		next := c.emit(code.OpJumpNotTruthy, 9999)
+
+		restore := func() {}
+		if arm.Name != nil {
+			c.emitGet(value, arm.Name.Token)
+			c.emitDefine(c.symbolTable.Define(arm.Name.Value), arm.Name.Token)
+			c.emit(code.OpPop)
+			restore = c.symbolTable.Bind(arm.Name.Value)
+		}
+		err := c.compileBlock(arm.Body)
+		restore()
+		if err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.patchJumps([]int{next}, len(c.currentInstructions()))
+	}
+
+	// a select always runs one of its arms
+	c.emit(code.OpNull)
+	c.patchJumps(ends, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTryBlock compiles a block guarded by the last OpTry
+func (c *Compiler) compileTryBlock(block *ast.BlockStatement, finally *ast.BlockStatement) error {
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, finally)
+	err := c.compileBlock(block)
+
+	tries := c.scopes[c.scopeIndex].tries
+	c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	return nil
+}
+
+// compileFinallies leaves the try blocks of the function before a return,
+// running their finally blocks, the returned value stays on the stack
+func (c *Compiler) compileFinallies() error {
+	tries := c.scopes[c.scopeIndex].tries
+
+	// try blocks outside of the last finally block are dropped by the return
+	outer := len(tries)
+	for i := len(tries) - 1; i >= 0; i-- {
+		if tries[i] != nil {
+			outer = i
+		}
+	}
+
+	return c.leaveTries(outer)
+}
+
+// leaveTries ends the try blocks started after the first depth ones, running their finally blocks
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i] == nil {
+			continue
+		}
+		// returns in the finally block only leave the try blocks around it
+		c.scopes[c.scopeIndex].tries = append([]*ast.BlockStatement{}, tries[:i]...)
+		err := c.compileBlock(tries[i])
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	return nil
+}
+
+// compileWhile compiles a while loop, loops leave nothing on the stack
+func (c *Compiler) compileWhile(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	l, err := c.compileLoopBody(node.Label, node.Body, nil)
+	if err != nil {
+		return err
+	}
+	c.patchJumps(l.continues, start)
+	c.emit(code.OpJump, start)
+
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.patchJumps(l.breaks, len(c.currentInstructions()))
+	return nil
+}
+
+// compileFor compiles a c style for loop, continue jumps to the step
+func (c *Compiler) compileFor(node *ast.ForStatement) error {
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+	}
+
+	start := len(c.currentInstructions())
+	exit := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exit = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	l, err := c.compileLoopBody(node.Label, node.Body, nil)
+	if err != nil {
+		return err
+	}
+	c.patchJumps(l.continues, len(c.currentInstructions()))
+	if node.Step != nil {
+		if err := c.Compile(node.Step); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, start)
+
+	if exit != -1 {
+		c.changeOperand(exit, len(c.currentInstructions()))
+	}
+	c.patchJumps(l.breaks, len(c.currentInstructions()))
+	return nil
+}
+
+// compileForIn compiles a for in loop, the iterator is kept in a hidden variable
+// so that break and continue do not have to clean the stack
+func (c *Compiler) compileForIn(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	position := c.emitToken(node.Token, code.OpIter)
+	// the name cannot be written in a program
+	iterator := c.symbolTable.Define(fmt.Sprintf("for %d", position))
+	c.emitDefine(iterator, node.Token)
+	c.emit(code.OpPop)
+
+	start := len(c.currentInstructions())
+	c.emitGet(iterator, node.Token)
+	exit := c.emit(code.OpIterNext, 9999)
+	c.emitDefine(c.symbolTable.Define(node.Variable.Value), node.Variable.Token)
+	c.emit(code.OpPop)
+
+	restore := c.symbolTable.Bind(node.Variable.Value)
+	l, err := c.compileLoopBody(node.Label, node.Body, &iterator)
+	restore()
+	if err != nil {
+		return err
+	}
+	c.patchJumps(l.continues, start)
+	c.emit(code.OpJump, start)
+
+	c.changeOperand(exit, len(c.currentInstructions()))
+	c.patchJumps(l.breaks, len(c.currentInstructions()))
+	return nil
+}
+
+// compileLoopBody compiles the body of a loop and drops its value, it returns the jumps out of it.
+// The iterator is the variable holding the iterator of a for in loop, nil for other loops
+func (c *Compiler) compileLoopBody(label string, body *ast.BlockStatement, iterator *Symbol) (*loop, error) {
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{label: label, tries: len(scope.tries), iterator: iterator}
+	scope.loops = append(scope.loops, l)
+
+	err := c.compileBlock(body)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	if err != nil {
+		return nil, err
+	}
+	c.emit(code.OpPop)
+	return l, nil
+}
+
+// compileJump compiles a break or a continue, leaving the try blocks started inside of the loop
+func (c *Compiler) compileJump(t token.Token, label string, isContinue bool) error {
+	loops := c.scopes[c.scopeIndex].loops
+	var target *loop
+	index := 0
+	for i := len(loops) - 1; i >= 0; i-- {
+		if label == "" || loops[i].label == label {
+			target, index = loops[i], i
+			break
+		}
+	}
+	if target == nil {
+		return &Error{Message: fmt.Sprintf("%s outside of a loop", t.Literal), Token: t}
+	}
+
+	if err := c.leaveTries(target.tries); err != nil {
+		return err
+	}
+	// a continue stays in its loop
+	if isContinue {
+		index++
+	}
+	c.closeIterators(loops, index)
+	jump := c.emit(code.OpJump, 9999)
+	if isContinue {
+		target.continues = append(target.continues, jump)
+	} else {
+		target.breaks = append(target.breaks, jump)
+	}
+	return nil
+}
+
+// closeIterators closes the iterators of the for in loops left from the loop at index, innermost first
+func (c *Compiler) closeIterators(loops []*loop, index int) {
+	for i := len(loops) - 1; i >= index; i-- {
+		if loops[i].iterator != nil {
+			c.emitGet(*loops[i].iterator, token.Token{})
+			c.emit(code.OpIterClose)
+		}
+	}
+}
+
+func (c *Compiler) patchJumps(jumps []int, position int) {
+	for _, jump := range jumps {
+		c.changeOperand(jump, position)
+	}
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	c.enterScope(NewEnclosedSymbolTable(c.symbolTable))
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+	for _, pattern := range node.Patterns {
+		for _, ident := range ast.PatternIdentifiers(pattern) {
+			c.symbolTable.Define(ident.Value)
+		}
+	}
+	// lets are hoisted so that closures defined earlier capture the same slot,
+	// the name resolves past the function until the let runs like in the evaluator
+	hoistLets(c.symbolTable, node.Body)
+
+	if c.symbolTable.NumDefinitions() > 256 {
+		return &Error{Message: "too many local variables", Token: node.Token}
+	}
+
+	// The defaults replace the null arguments, before the parameter is destructured
+	for i, param := range node.Parameters {
+		symbol := c.symbolTable.Resolve(param.Value)
+		if node.Defaults != nil && node.Defaults[i] != nil {
+			c.emitGet(symbol, param.Token)
+			jump := c.emit(code.OpJumpNotNull, 9999)
+			if err := c.Compile(node.Defaults[i]); err != nil {
+				return err
+			}
+			c.changeOperand(jump, len(c.currentInstructions()))
+			c.emitSet(symbol, param.Token)
+			c.emit(code.OpPop)
+		}
+		if node.Patterns != nil && node.Patterns[i] != nil {
+			c.emitGet(symbol, param.Token)
+			if err := c.compilePattern(node.Patterns[i]); err != nil {
+				return err
+			}
+			c.emit(code.OpPop)
+		}
+	}
+
+	if err := c.compileBlock(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	fn := &object.CompiledFunction{
+		NumParameters: len(node.Parameters),
+		Parameters:    node.Parameters,
+		Body:          node.Body,
+		Name:          node.Name,
+		Defaults:      node.Defaults,
+		Rest:          node.Rest,
+		Generator:     node.Generator,
+		Async:         node.Async,
+	}
+	if node.Rest != nil {
+		fn.NumParameters++
+	}
+	return c.leaveFunctionScope(fn, code.OpClosure)
+}
+
+func (c *Compiler) compileModule(node *ast.ModuleExpression) error {
+	c.enterScope(NewModuleSymbolTable(c.symbolTable))
+
+	if node.Body != nil {
+		for _, s := range node.Body.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	}
+	c.emit(code.OpReturn)
+
+	fn := &object.CompiledFunction{Body: node.Body}
+	return c.leaveFunctionScope(fn, code.OpModule)
+}
+
+// leaveFunctionScope finishes fn and emits the instruction creating it with its captured cells
+func (c *Compiler) leaveFunctionScope(fn *object.CompiledFunction, op code.Opcode) error {
+	free := c.symbolTable.FreeSymbols
+	fn.NumLocals = c.symbolTable.NumDefinitions()
+	fn.Instructions, fn.Tokens = c.leaveScope()
+
+	if len(free) > 255 {
+		return &Error{Message: "too many captured variables"}
+	}
+
+	for _, s := range free {
+		switch s.Scope {
+		case LocalScope:
+			c.emit(code.OpLoadCell, s.Index)
+		case FreeScope:
+			c.emit(code.OpLoadFreeCell, s.Index)
+		}
+	}
+
+	c.emit(op, c.addConstant(fn), len(free))
+	return nil
+}
+
+func (c *Compiler) compileCall(node *ast.CallExpression) error {
+	if ident, ok := node.Function.(*ast.Identifier); ok {
+		switch ident.Value {
+		case "quote":
+			return c.compileQuote(node)
+		case "unquote":
+			return &Error{Message: "unquote can only be used inside of quote", Token: node.Token}
+		}
+	}
+
+	if len(node.Arguments) > 255 {
+		return &Error{Message: "too many arguments", Token: node.Token}
+	}
+
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+	if node.Optional {
+		c.emitChainJump()
+	}
+
+	var positional []ast.Expression
+	names := &object.Array{}
+	for _, arg := range node.Arguments {
+		if named, ok := arg.(*ast.NamedArgument); ok {
+			names.Elements = append(names.Elements, &object.String{Value: named.Name})
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	// Spread and named arguments are passed in an array, followed by the values of the named arguments
+	if len(names.Elements) > 0 || hasSpread(positional) {
+		if err := c.compileElements(positional); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments[len(positional):] {
+			if err := c.Compile(arg.(*ast.NamedArgument).Value); err != nil {
+				return err
+			}
+		}
+		c.emitToken(node.Token, code.OpApply, c.addConstant(names))
+		return nil
+	}
+
+	for _, arg := range node.Arguments {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+	c.emitToken(node.Token, code.OpCall, len(node.Arguments))
+	return nil
+}
+
+// compileElements creates an array of elements, the runs of elements between spreads are
+// created as arrays and added with the spreads to an empty array
+func (c *Compiler) compileElements(elements []ast.Expression) error {
+	if !hasSpread(elements) {
+		for _, el := range elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(elements))
+		return nil
+	}
+
+	c.emit(code.OpArray, 0)
+	run := 0
+	var tok token.Token
+	for _, el := range elements {
+		spread, ok := el.(*ast.SpreadElement)
+		if !ok {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+			run++
+			continue
+		}
+		tok = spread.Token
+		if run > 0 {
+			c.emit(code.OpArray, run)
+			c.emitToken(tok, code.OpSpread)
+			run = 0
+		}
+		if err := c.Compile(spread.Value); err != nil {
+			return err
+		}
+		c.emitToken(tok, code.OpSpread)
+	}
+	if run > 0 {
+		c.emit(code.OpArray, run)
+		c.emitToken(tok, code.OpSpread)
+	}
+	return nil
+}
+
+func hasSpread(elements []ast.Expression) bool {
+	for _, el := range elements {
+		if _, ok := el.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileQuote stores quotes without unquote calls as constants,
+// the unquoted values are spliced into a copy of the quote when it is evaluated
+func (c *Compiler) compileQuote(node *ast.CallExpression) error {
+	if len(node.Arguments) != 1 {
+		return &Error{
+			Message: fmt.Sprintf("quote only takes one argument. got=%d", len(node.Arguments)),
+			Token:   node.Token,
+		}
+	}
+
+	quote := c.addConstant(&object.Quote{Node: node.Arguments[0]})
+	calls := evaluator.UnquoteCalls(node.Arguments[0])
+	if len(calls) == 0 {
+		c.emit(code.OpConstant, quote)
+		return nil
+	}
+
+	for _, call := range calls {
+		if err := c.Compile(call.Arguments[0]); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpQuote, quote, len(calls))
+	return nil
+}
+
+// hoistLets defines the lets of a function body, including those nested in if blocks
+func hoistLets(table *SymbolTable, node ast.Node) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, s := range node.Statements {
+			hoistLets(table, s)
+		}
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			for _, ident := range ast.PatternIdentifiers(node.Pattern) {
+				table.Hoist(ident.Value)
+			}
+		} else {
+			table.Hoist(node.Name.Value)
+		}
+		hoistLets(table, node.Value)
+	case *ast.ExpressionStatement:
+		hoistLets(table, node.Expression)
+	case *ast.ReturnStatement:
+		hoistLets(table, node.ReturnValue)
+	case *ast.PrintExpressionStatement:
+		hoistLets(table, node.Expression)
+	case *ast.MatchExpression:
+		hoistLets(table, node.Value)
+		for _, arm := range node.Arms {
+			// the possible type patterns are left to resolve to the variables they may name
+			for _, ident := range ast.MatchBindings(arm.Pattern) {
+				if !ast.IsTypePattern(ident) {
+					table.Hoist(ident.Value)
+				}
+			}
+			hoistLets(table, arm.Guard)
+			hoistLets(table, arm.Body)
+		}
+	case *ast.IfExpression:
+		hoistLets(table, node.Condition)
+		hoistLets(table, node.Consequence)
+		if node.Alternative != nil {
+			hoistLets(table, node.Alternative)
+		}
+	case *ast.TryExpression:
+		hoistLets(table, node.Block)
+		if node.Catch != nil {
+			if node.Parameter != nil {
+				table.Hoist(node.Parameter.Value)
+			}
+			hoistLets(table, node.Catch)
+		}
+		if node.Finally != nil {
+			hoistLets(table, node.Finally)
+		}
+	case *ast.ThrowStatement:
+		hoistLets(table, node.Value)
+	case *ast.WhileStatement:
+		hoistLets(table, node.Condition)
+		hoistLets(table, node.Body)
+	case *ast.ForStatement:
+		if node.Init != nil {
+			hoistLets(table, node.Init)
+		}
+		hoistLets(table, node.Condition)
+		hoistLets(table, node.Step)
+		hoistLets(table, node.Body)
+	case *ast.ForInStatement:
+		table.Hoist(node.Variable.Value)
+		hoistLets(table, node.Iterable)
+		hoistLets(table, node.Body)
+	case *ast.InfixExpression:
+		hoistLets(table, node.Left)
+		hoistLets(table, node.Right)
+	case *ast.ConditionalExpression:
+		hoistLets(table, node.Condition)
+		hoistLets(table, node.Consequence)
+		hoistLets(table, node.Alternative)
+	case *ast.OptionalChain:
+		hoistLets(table, node.Expression)
+	case *ast.YieldExpression:
+		hoistLets(table, node.Value)
+	case *ast.AwaitExpression:
+		hoistLets(table, node.Value)
+	case *ast.SelectExpression:
+		for _, arm := range node.Arms {
+			if arm.Name != nil {
+				table.Hoist(arm.Name.Value)
+			}
+			hoistLets(table, arm.Channel)
+			hoistLets(table, arm.Value)
+			hoistLets(table, arm.Body)
+		}
+	case *ast.PrefixExpression:
+		hoistLets(table, node.Right)
+	case *ast.UpdateExpression:
+		hoistLets(table, node.Target)
+	}
+}
+
+func operatorIndex(operators []string, operator string) (int, bool) {
+	for i, op := range operators {
+		if op == operator {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (c *Compiler) addString(value string) int {
+	if index, ok := c.state.strings[value]; ok {
+		return index
+	}
+	index := c.addConstant(&object.String{Value: value})
+	c.state.strings[value] = index
+	return index
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.state.Constants = append(c.state.Constants, obj)
+	return len(c.state.Constants) - 1
+}
+
+// emitToken emits an instruction whose last operand refers to a token
+func (c *Compiler) emitToken(t token.Token, op code.Opcode, operands ...int) int {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tokens = append(scope.tokens, t)
+	return c.emit(op, append(operands, len(scope.tokens)-1)...)
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	scope := &c.scopes[c.scopeIndex]
+
+	position := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: position}
+	scope.lastIsValue = false
+	return position
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	scope := c.scopes[c.scopeIndex]
+	return len(scope.instructions) != 0 && scope.lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastInstruction() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.instructions = scope.instructions[:scope.lastInstruction.Position]
+}
+
+// changeOperand changes the last operand of the instruction at position
+func (c *Compiler) changeOperand(position int, operand int) {
+	op := code.Opcode(c.currentInstructions()[position])
+	def, _ := code.Lookup(byte(op))
+	operands, _ := code.ReadOperands(def, c.currentInstructions()[position+1:])
+	operands[len(operands)-1] = operand
+	ins := code.Make(op, operands...)
+	copy(c.scopes[c.scopeIndex].instructions[position:], ins)
+}
+
+// emitReturn ends the program with the value of its last expression statement
+func (c *Compiler) emitReturn() {
+	if c.lastInstructionIs(code.OpPop) && c.scopes[c.scopeIndex].lastIsValue {
+		c.removeLastInstruction()
+		c.emit(code.OpReturnValue)
+	} else {
+		c.emit(code.OpReturn)
+	}
+}
+
+func (c *Compiler) enterScope(table *SymbolTable) {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = table
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, []token.Token) {
+	scope := c.scopes[c.scopeIndex]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return scope.instructions, scope.tokens
+}
@@ -0,0 +1,228 @@
+package compiler
+
+import (
+	"Monkey/code"
+	"Monkey/lexer"
+	"Monkey/object"
+	"Monkey/parser"
+	"testing"
+)
+
+func TestResolveScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+
+	inner := NewEnclosedSymbolTable(outer)
+	inner.Define("c")
+
+	expected := map[string]Symbol{
+		"a":       {Name: "a", Scope: GlobalScope},
+		"b":       {Name: "b", Scope: FreeScope, Index: 0},
+		"c":       {Name: "c", Scope: LocalScope, Index: 0},
+		"missing": {Name: "missing", Scope: GlobalScope},
+	}
+
+	for name, want := range expected {
+		got := inner.Resolve(name)
+		if got != want {
+			t.Errorf("%s resolved wrong. want=%+v, got=%+v", name, want, got)
+		}
+	}
+
+	if len(inner.FreeSymbols) != 1 || inner.FreeSymbols[0] != (Symbol{Name: "b", Scope: LocalScope}) {
+		t.Errorf("wrong free symbols. got=%+v", inner.FreeSymbols)
+	}
+}
+
+func TestModuleDefinesByName(t *testing.T) {
+	function := NewEnclosedSymbolTable(NewSymbolTable())
+	function.Define("x")
+
+	module := NewModuleSymbolTable(function)
+	if symbol := module.Define("y"); symbol.Scope != GlobalScope {
+		t.Errorf("module definitions should be global. got=%+v", symbol)
+	}
+	if symbol := module.Resolve("x"); symbol.Scope != FreeScope {
+		t.Errorf("function locals should be captured by modules. got=%+v", symbol)
+	}
+}
+
+type compilerTest struct {
+	input        string
+	instructions []code.Instructions
+}
+
+func TestCompile(t *testing.T) {
+	tests := []compilerTest{
+		{
+			input: "let a = 1\na",
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpDefineGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "if true { 1 }",
+			instructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a) { let b = a\nfn() { b } }",
+			instructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// The array and the index are evaluated once, and kept under the value
+			input: "let a = [1]\na[0] ??= 2",
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpDefineGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpIndex, 0, 1),
+				code.Make(code.OpJumpNotNull, 39),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpRotate, 2),
+				code.Make(code.OpSetIndex, 2),
+				code.Make(code.OpJump, 43),
+				code.Make(code.OpRotate, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpPop),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// The value before the increment is kept under the array and the index
+			input: "let a = [1]\na[0]++",
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpDefineGlobal, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpIndex, 0, 1),
+				code.Make(code.OpDup, 1),
+				code.Make(code.OpRotate, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpInfix, 0, 2),
+				code.Make(code.OpRotate, 2),
+				code.Make(code.OpSetIndex, 3),
+				code.Make(code.OpPop),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// The runs of elements between spreads are spread as arrays
+			input: "[1, ...[2], 3]",
+			instructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// The unquoted values are spliced into a copy of the quote
+			input: "quote(1 + unquote(2))",
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpQuote, 0, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+		checkInstructions(t, tt.input, tt.instructions, bytecode.Instructions)
+	}
+}
+
+func TestCompileClosure(t *testing.T) {
+	bytecode := compile(t, "fn(a) { let b = a\nfn() { b } }")
+
+	outer, ok := bytecode.Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not a function. got=%T", bytecode.Constants[1])
+	}
+	if outer.NumLocals != 2 || outer.NumParameters != 1 {
+		t.Errorf("wrong locals. got=%d params=%d", outer.NumLocals, outer.NumParameters)
+	}
+
+	checkInstructions(t, "outer", []code.Instructions{
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetLocal, 1),
+		code.Make(code.OpPop),
+		code.Make(code.OpLoadCell, 1),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpReturnValue),
+	}, outer.Instructions)
+
+	inner := bytecode.Constants[0].(*object.CompiledFunction)
+	checkInstructions(t, "inner", []code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpReturnValue),
+	}, inner.Instructions)
+}
+
+func TestCompileErrors(t *testing.T) {
+	inputs := []string{
+		"unquote(1)",
+		"fn() { macro(a) { a } }",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input, "test")).ParseProgram()
+		if err := New(NewState()).Compile(program); err == nil {
+			t.Errorf("expected a compile error for %q", input)
+		}
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	program := parser.New(lexer.New(input, "test")).ParseProgram()
+	c := New(NewState())
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compile error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func checkInstructions(t *testing.T, name string, expected []code.Instructions, actual code.Instructions) {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != actual.String() {
+		t.Errorf("wrong instructions for %s.\nwant=\n%s\ngot=\n%s", name, concatted, actual)
+	}
+}
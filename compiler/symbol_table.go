@@ -0,0 +1,175 @@
+package compiler
+
+// Where a symbol is stored
+type SymbolScope string
+
+const (
+	// Looked up by name in the environment of the closure, like the tree walking evaluator
+	GlobalScope SymbolScope = "GLOBAL"
+	// A slot on the stack frame
+	LocalScope SymbolScope = "LOCAL"
+	// A variable captured from an enclosing function
+	FreeScope SymbolScope = "FREE"
+)
+
+// A resolved identifier
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// A local hoisted from a let of the function, the name resolves past the function
+	// until the let runs, see ResolveOuter
+	Hoisted bool
+}
+
+// SymbolTable resolves identifiers for a function, a module or the program
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	// The symbols of the enclosing functions captured by this one
+	FreeSymbols []Symbol
+
+	// Named tables define their symbols by name in the environment
+	named bool
+}
+
+// NewSymbolTable creates the table of a program
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store: make(map[string]Symbol),
+		named: true,
+	}
+}
+
+// NewEnclosedSymbolTable creates the table of a function
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{
+		Outer: outer,
+		store: make(map[string]Symbol),
+	}
+}
+
+// NewModuleSymbolTable creates the table of a module, which defines into the module environment
+func NewModuleSymbolTable(outer *SymbolTable) *SymbolTable {
+	table := NewEnclosedSymbolTable(outer)
+	table.named = true
+	return table
+}
+
+// Define a new symbol, defining an existing local again reuses its slot
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.named {
+		symbol := Symbol{Name: name, Scope: GlobalScope}
+		s.store[name] = symbol
+		return symbol
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == LocalScope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Scope: LocalScope, Index: s.numDefinitions}
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// Hoist defines the local of a let before the let runs, a parameter keeps its slot
+func (s *SymbolTable) Hoist(name string) {
+	if symbol, ok := s.store[name]; ok && symbol.Scope == LocalScope {
+		return
+	}
+	symbol := s.Define(name)
+	symbol.Hoisted = true
+	s.store[name] = symbol
+}
+
+// Bind marks a hoisted local as defined while the code running after its binding is compiled,
+// restore marks it hoisted again
+func (s *SymbolTable) Bind(name string) (restore func()) {
+	symbol, ok := s.store[name]
+	if !ok || !symbol.Hoisted {
+		return func() {}
+	}
+	bound := symbol
+	bound.Hoisted = false
+	s.store[name] = bound
+	return func() {
+		s.store[name] = symbol
+	}
+}
+
+// Shadow defines a symbol named hidden that name resolves to until restore is called,
+// the symbol name had before is left untouched
+func (s *SymbolTable) Shadow(name, hidden string) (symbol Symbol, restore func()) {
+	previous, ok := s.store[name]
+	symbol = s.Define(hidden)
+	s.store[name] = symbol
+	return symbol, func() {
+		if ok {
+			s.store[name] = previous
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+// NumDefinitions returns the amount of local slots
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+// Resolve an identifier, unknown identifiers are global and looked up at runtime
+func (s *SymbolTable) Resolve(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+
+	if s.Outer == nil {
+		return Symbol{Name: name, Scope: GlobalScope}
+	}
+
+	symbol := s.Outer.Resolve(name)
+	if symbol.Scope == GlobalScope {
+		return symbol
+	}
+
+	symbol = s.capture(symbol)
+	s.store[name] = symbol
+	return symbol
+}
+
+// ResolveOuter resolves the name of a hoisted symbol past the function whose let defines it,
+// like the evaluator looks it up before the let runs
+func (s *SymbolTable) ResolveOuter(symbol Symbol) Symbol {
+	if symbol.Scope == FreeScope {
+		return s.capture(s.Outer.ResolveOuter(s.FreeSymbols[symbol.Index]))
+	}
+	if s.Outer == nil {
+		return Symbol{Name: symbol.Name, Scope: GlobalScope}
+	}
+	return s.capture(s.Outer.Resolve(symbol.Name))
+}
+
+// capture captures a symbol of an enclosing function once, globals are looked up by name
+func (s *SymbolTable) capture(original Symbol) Symbol {
+	if original.Scope == GlobalScope {
+		return original
+	}
+
+	index := -1
+	for i, free := range s.FreeSymbols {
+		if free.Scope == original.Scope && free.Index == original.Index {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		s.FreeSymbols = append(s.FreeSymbols, original)
+		index = len(s.FreeSymbols) - 1
+	}
+	return Symbol{Name: original.Name, Scope: FreeScope, Index: index, Hoisted: original.Hoisted}
+}
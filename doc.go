@@ -0,0 +1,75 @@
+package main
+
+import (
+	"Monkey/doc"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// runDoc writes the documentation of the files and directories in args and of the files they include or import,
+// libraries are looked up in paths. It returns the exit code
+func runDoc(args []string, paths []string, directory string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	html := flags.Bool("html", false, "write a HTML page instead of Markdown")
+	output := flags.String("o", "", "write the documentation to a file instead of printing it")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey doc [-html] [-o file] files or directories")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	filenames, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	files, err := doc.Collect(filenames, paths)
+	if err != nil {
+		if e, ok := err.(*doc.Error); ok && e.Source != "" {
+			reportFormatError(stderr, e.Err, e.Source)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+		return 1
+	}
+
+	// Files are named relative to the directory, when they are inside it
+	for _, file := range files {
+		abs, err := filepath.Abs(file.Filename)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(directory, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			file.Filename = filepath.ToSlash(rel)
+		}
+	}
+
+	var out bytes.Buffer
+	if *html {
+		err = doc.HTML(&out, files, filepath.Base(flags.Arg(0)))
+	} else {
+		err = doc.Markdown(&out, files)
+	}
+	if err == nil && *output != "" {
+		err = ioutil.WriteFile(*output, out.Bytes(), 0644)
+	} else if err == nil {
+		_, err = stdout.Write(out.Bytes())
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package doc
+
+import (
+	"Monkey/ast"
+	"Monkey/lexer"
+	"Monkey/options"
+	"Monkey/parser"
+	"Monkey/runner"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Entry is a documented let statement
+type Entry struct {
+	Name string
+	// Such as "let add = fn(a, b)"
+	Signature string
+	// The /// doc comment of the let
+	Doc string
+
+	// The definitions of a module literal, named module.name
+	Members []*Entry
+}
+
+// File is the documentation of a source file
+type File struct {
+	Filename string
+	Entries  []*Entry
+}
+
+// Error is a file that could not be documented
+type Error struct {
+	Filename string
+	// The source of the file, empty when it could not be read
+	Source string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Filename, e.Err)
+}
+
+// Collect documents the files and every file they include or import, each file once in the order they are found.
+// The locations are resolved like the runner does, looking up libraries in paths
+func Collect(filenames []string, paths []string) ([]*File, error) {
+	c := &collector{paths: paths, seen: map[string]bool{}}
+	for _, filename := range filenames {
+		if err := c.collect(filepath.Clean(filename)); err != nil {
+			return nil, err
+		}
+	}
+	return c.files, nil
+}
+
+type collector struct {
+	paths []string
+	seen  map[string]bool
+	files []*File
+}
+
+func (c *collector) collect(filename string) error {
+	if c.seen[filename] {
+		return nil
+	}
+	c.seen[filename] = true
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return &Error{Filename: filename, Err: err}
+	}
+	source := string(content)
+	p := parser.New(lexer.New(source, filename))
+	program := p.ParseProgram()
+	if p.HasError() {
+		return &Error{Filename: filename, Source: source, Err: parser.ParseErrors(p.Errors())}
+	}
+
+	file := &File{Filename: filename}
+	lines := strings.Split(strings.ReplaceAll(source, "\r", ""), "\n")
+	var locations []string
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if location, ok := ast.IncludeLocation(stmt.Value, "import"); ok {
+				locations = append(locations, location)
+			}
+			if entry := document(stmt, "", lines); entry != nil {
+				file.Entries = append(file.Entries, entry)
+			}
+		case *ast.ExpressionStatement:
+			if location, ok := ast.IncludeLocation(stmt.Expression, "include"); ok {
+				locations = append(locations, location)
+			}
+		}
+	}
+	c.files = append(c.files, file)
+
+	for _, location := range locations {
+		r := runner.New(filepath.Dir(filename), c.paths, &options.Options{}, ioutil.Discard)
+		if err := c.collect(r.ToAbsolute(location)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// document returns the entry of a let, or nil when its name starts with _ as it is private
+func document(let *ast.LetStatement, prefix string, lines []string) *Entry {
+	if let.Name == nil || strings.HasPrefix(let.Name.Value, "_") {
+		return nil
+	}
+	entry := &Entry{
+		Name: prefix + let.Name.Value,
+		Doc:  let.Doc,
+	}
+
+	signature := "let " + let.Name.Value + " = "
+	switch value := let.Value.(type) {
+	case *ast.FunctionLiteral:
+		entry.Signature = signature + value.Signature()
+	case *ast.MacroLiteral:
+		entry.Signature = signature + value.Signature()
+	case *ast.ModuleExpression:
+		entry.Signature = signature + "module"
+		if value.Body == nil {
+			break
+		}
+		for _, stmt := range value.Body.Statements {
+			if member, ok := stmt.(*ast.LetStatement); ok {
+				if member := document(member, entry.Name+".", lines); member != nil {
+					entry.Members = append(entry.Members, member)
+				}
+			}
+		}
+	default:
+		// Other values are shown as they are written
+		if row := int(let.Token.RowNumber); row >= 1 && row <= len(lines) {
+			entry.Signature = strings.TrimSpace(lines[row-1])
+		}
+	}
+	return entry
+}
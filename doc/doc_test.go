@@ -0,0 +1,160 @@
+package doc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCollect(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.mky": `include("util.mky")
+
+/// Adds two numbers
+let add = fn(a, b) { a + b }
+
+/// Shapes
+let shapes = module {
+    /// The area of a square
+    let square = fn(side) { side * side }
+    let _cache = {}
+}
+let maths = import("maths.mky")
+let _private = 1
+let limit = 10 // not a doc
+let walk = fn*(from, step = 1, ...rest) { yield from }
+let fetch = async fn(url) { url }
+let twice = macro(x) { quote(unquote(x) * 2) }
+`,
+		"util.mky": `/// Twice x
+/// for numbers
+let double = fn(x) { x * 2 }
+include("main.mky")
+`,
+		"maths.mky": "let pi = 3.14\n",
+	})
+	defer os.RemoveAll(dir)
+
+	files, err := Collect([]string{filepath.Join(dir, "main.mky")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file.Filename))
+	}
+	if strings.Join(names, " ") != "main.mky util.mky maths.mky" {
+		t.Fatalf("wrong files. got=%v", names)
+	}
+
+	main := files[0].Entries
+	expected := []Entry{
+		{Name: "add", Signature: "let add = fn(a, b)", Doc: "Adds two numbers"},
+		{Name: "shapes", Signature: "let shapes = module", Doc: "Shapes"},
+		{Name: "maths", Signature: `let maths = import("maths.mky")`},
+		{Name: "limit", Signature: "let limit = 10 // not a doc"},
+		{Name: "walk", Signature: "let walk = fn*(from, step = 1, ...rest)"},
+		{Name: "fetch", Signature: "let fetch = async fn(url)"},
+		{Name: "twice", Signature: "let twice = macro(x)"},
+	}
+	if len(main) != len(expected) {
+		t.Fatalf("wrong amount of entries. want=%d, got=%d", len(expected), len(main))
+	}
+	for i, want := range expected {
+		got := main[i]
+		if got.Name != want.Name || got.Signature != want.Signature || got.Doc != want.Doc {
+			t.Errorf("entries[%d] wrong. want=%+v, got=%+v", i, want, *got)
+		}
+	}
+	if members := main[1].Members; len(members) != 1 || members[0].Name != "shapes.square" ||
+		members[0].Signature != "let square = fn(side)" || members[0].Doc != "The area of a square" {
+		t.Errorf("wrong module members. got=%v", members)
+	}
+	if double := files[1].Entries[0]; double.Doc != "Twice x\nfor numbers" {
+		t.Errorf("wrong doc of double. got=%q", double.Doc)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.mky":   `include("broken.mky")`,
+		"broken.mky": "let = 1",
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := Collect([]string{filepath.Join(dir, "main.mky")}, nil)
+	e, ok := err.(*Error)
+	if !ok || filepath.Base(e.Filename) != "broken.mky" || e.Source != "let = 1" {
+		t.Fatalf("expected a parse error in broken.mky. got=%v", err)
+	}
+
+	_, err = Collect([]string{filepath.Join(dir, "missing.mky")}, nil)
+	if e, ok := err.(*Error); !ok || e.Source != "" {
+		t.Errorf("expected a read error. got=%v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	files := []*File{
+		{Filename: "empty.mky"},
+		{Filename: "lib.mky", Entries: []*Entry{
+			{Name: "less", Signature: "let less = fn(a, b)", Doc: "Whether a < b\n\nFor numbers"},
+			{Name: "m", Signature: "let m = module", Members: []*Entry{
+				{Name: "m.x", Signature: "let x = 1"},
+			}},
+		}},
+	}
+
+	var markdown strings.Builder
+	if err := Markdown(&markdown, files); err != nil {
+		t.Fatal(err)
+	}
+	want := "# lib.mky\n\n## less\n\n```\nlet less = fn(a, b)\n```\n\nWhether a < b\n\nFor numbers\n" +
+		"\n## m\n\n```\nlet m = module\n```\n\n### m.x\n\n```\nlet x = 1\n```\n"
+	if markdown.String() != want {
+		t.Errorf("wrong markdown.\nwant=%q\ngot= %q", want, markdown.String())
+	}
+
+	var html strings.Builder
+	if err := HTML(&html, files, "lib"); err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"<title>lib</title>", "<h2>lib.mky</h2>", "<p>Whether a &lt; b</p>\n<p>For numbers</p>",
+		`<article id="m.x">`, "<pre><code>let x = 1</code></pre>"} {
+		if !strings.Contains(html.String(), part) {
+			t.Errorf("html does not contain %q. got=%s", part, html.String())
+		}
+	}
+	if strings.Contains(html.String(), "empty.mky") {
+		t.Errorf("files without definitions are documented")
+	}
+}
+
+// Every builtin is documented
+func TestBuiltinDocs(t *testing.T) {
+	files, err := Collect([]string{"../lib/std/builtin.mky"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range files[0].Entries {
+		if entry.Doc == "" {
+			t.Errorf("%s is not documented", entry.Name)
+		}
+	}
+}
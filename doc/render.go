@@ -0,0 +1,82 @@
+package doc
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Markdown writes the documentation of the files as Markdown, a section for every file with definitions
+func Markdown(w io.Writer, files []*File) error {
+	var out strings.Builder
+	for _, file := range files {
+		if len(file.Entries) == 0 {
+			continue
+		}
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "# %s\n", file.Filename)
+		for _, entry := range file.Entries {
+			writeMarkdown(&out, entry, 2)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func writeMarkdown(out *strings.Builder, entry *Entry, level int) {
+	fmt.Fprintf(out, "\n%s %s\n\n```\n%s\n```\n", strings.Repeat("#", level), entry.Name, entry.Signature)
+	if entry.Doc != "" {
+		fmt.Fprintf(out, "\n%s\n", entry.Doc)
+	}
+	for _, member := range entry.Members {
+		writeMarkdown(out, member, level+1)
+	}
+}
+
+// HTML writes the documentation of the files as a page with the title
+func HTML(w io.Writer, files []*File, title string) error {
+	var documented []*File
+	for _, file := range files {
+		if len(file.Entries) > 0 {
+			documented = append(documented, file)
+		}
+	}
+	return page.Execute(w, struct {
+		Title string
+		Files []*File
+	}{title, documented})
+}
+
+// paragraphs splits a doc comment at its empty lines
+func paragraphs(doc string) []string {
+	var result []string
+	for _, paragraph := range strings.Split(doc, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			result = append(result, paragraph)
+		}
+	}
+	return result
+}
+
+var page = template.Must(template.New("page").Funcs(template.FuncMap{"paragraphs": paragraphs}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Files}}<section>
+<h2>{{.Filename}}</h2>
+{{range .Entries}}{{template "entry" .}}{{end}}</section>
+{{end}}</body>
+</html>
+{{define "entry"}}<article id="{{.Name}}">
+<h3>{{.Name}}</h3>
+<pre><code>{{.Signature}}</code></pre>
+{{range paragraphs .Doc}}<p>{{.}}</p>
+{{end}}{{range .Members}}{{template "entry" .}}{{end}}</article>
+{{end}}`))
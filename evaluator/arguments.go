@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// Eval the elements of an array literal or the positional arguments of a call, the elements of ...spread are inserted.
+// A single error is returned alone, like EvalExpressions
+func EvalElements(elements []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range elements {
+		spread, ok := e.(*ast.SpreadElement)
+		if !ok {
+			evaluated := Eval(e, env)
+			if CheckError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			result = append(result, evaluated)
+			continue
+		}
+
+		value := Eval(spread.Value, env)
+		if CheckError(value) {
+			return []object.Object{value}
+		}
+		values, err := Spread(spread.Token, value, env)
+		if err != nil {
+			return []object.Object{err}
+		}
+		result = append(result, values...)
+	}
+
+	return result
+}
+
+// Eval the arguments of a call into the positional arguments and the named arguments
+func EvalArguments(arguments []ast.Expression, env *object.Environment) ([]object.Object, []object.NamedArgument, object.Object) {
+	var positional []ast.Expression
+	var named []*ast.NamedArgument
+	for _, arg := range arguments {
+		if arg, ok := arg.(*ast.NamedArgument); ok {
+			named = append(named, arg)
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	args := EvalElements(positional, env)
+	if len(args) == 1 && CheckError(args[0]) {
+		return nil, nil, args[0]
+	}
+
+	var values []object.NamedArgument
+	for _, arg := range named {
+		value := Eval(arg.Value, env)
+		if CheckError(value) {
+			return nil, nil, value
+		}
+		values = append(values, object.NamedArgument{Name: arg.Name, Value: value})
+	}
+	return args, values, nil
+}
+
+// Spread returns the elements of an iterable spread into a call or an array
+func Spread(token token.Token, value object.Object, env *object.Environment) ([]object.Object, *object.Error) {
+	if array, ok := value.(*object.Array); ok {
+		return array.Elements, nil
+	}
+
+	iterator, err := Iterate(token, value, env)
+	if err != nil {
+		return nil, NewFatalError(token.ToTokenData(), "cannot spread %s", value.Type())
+	}
+	var values []object.Object
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return values, nil
+		}
+		if CheckError(value) {
+			return nil, value.(*object.Error)
+		}
+		values = append(values, value)
+	}
+}
+
+// SpreadPairs copies the pairs of a hash spread into a hash literal
+func SpreadPairs(token token.Token, value object.Object, pairs map[object.HashKey]object.HashPair) *object.Error {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return NewFatalError(token.ToTokenData(), "cannot spread %s into a hash", value.Type())
+	}
+	for key, pair := range hash.Pairs {
+		pairs[key] = pair
+	}
+	return nil
+}
+
+// BindArguments matches the arguments of a call with the parameters of a *object.Function or an *object.CompiledFunction.
+// The named arguments go to the parameter with their name, and the extra arguments are collected by the ...rest
+// parameter in an array. It returns a value for every parameter and the rest, missing arguments are null and are
+// replaced by the defaults of the function. With strict arity, a missing argument without a default,
+// or extra arguments without a rest parameter, are errors instead of being null or dropped
+func BindArguments(token token.Token, function object.Object, args []object.Object, named []object.NamedArgument, strict bool) ([]object.Object, *object.Error) {
+	var name string
+	var params []*ast.Identifier
+	var defaults []ast.Expression
+	var rest *ast.Identifier
+	switch fn := function.(type) {
+	case *object.Function:
+		name, params, defaults, rest = fn.Name, fn.Parameters, fn.Defaults, fn.Rest
+	case *object.CompiledFunction:
+		name, params, defaults, rest = fn.Name, fn.Parameters, fn.Defaults, fn.Rest
+	}
+	name = object.FunctionName(name)
+
+	values := make([]object.Object, len(params), len(params)+1)
+	copy(values, args)
+
+	var extra []object.Object
+	if len(args) > len(params) {
+		extra = args[len(params):]
+		if strict && rest == nil {
+			return nil, NewFatalError(token.ToTokenData(), "%s takes at most %d arguments, got %d", name, len(params), len(args))
+		}
+	}
+
+	for _, arg := range named {
+		index := -1
+		for i, param := range params {
+			if param.Value == arg.Name {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return nil, NewFatalError(token.ToTokenData(), "%s has no parameter named %s", name, arg.Name)
+		}
+		if values[index] != nil {
+			return nil, NewFatalError(token.ToTokenData(), "the argument %s of %s is given twice", arg.Name, name)
+		}
+		values[index] = arg.Value
+	}
+
+	for i, value := range values {
+		if value != nil {
+			continue
+		}
+		if strict && (defaults == nil || defaults[i] == nil) {
+			return nil, NewFatalError(token.ToTokenData(), "%s is missing the argument %s", name, params[i].Value)
+		}
+		values[i] = NULL
+	}
+
+	if rest != nil {
+		values = append(values, &object.Array{Elements: append([]object.Object{}, extra...)})
+	}
+	return values, nil
+}
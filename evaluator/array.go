@@ -26,12 +26,10 @@ func handleArray(operator string, token token.Token, left object.Object, right o
 			leftVal[i] = result
 		}
 		return leftObj
-	case *object.Integer:
-		rightVal := right.(*object.Integer)
-
+	case *object.Integer, *object.BigInt, *object.Decimal, *object.Float:
 		for i := 0; i < len(leftVal); i++ {
 			left := leftVal[i]
-			result := EvalOperatorExpression(token, operator, left, rightVal)
+			result := EvalOperatorExpression(token, operator, left, right)
 			leftVal[i] = result
 		}
 		return leftObj
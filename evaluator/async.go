@@ -0,0 +1,308 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+	"time"
+)
+
+// Async calls an async function: call runs in a new task once the running task waits,
+// and the promise of its result is returned right away, see ResolvePromise
+func Async(token token.Token, name string, env *object.Environment, call func() object.Object) *object.Promise {
+	promise := &object.Promise{}
+	runAsync(token, name, promise, env, call)
+	return promise
+}
+
+// runAsync runs call in a new task and resolves promise with its result
+func runAsync(token token.Token, name string, promise *object.Promise, env *object.Environment, call func() object.Object) {
+	startTask(&object.Task{Name: name}, env, func() object.Object {
+		result := call()
+		ResolvePromise(promise, result, env)
+		return result
+	})
+}
+
+// applyAsync calls an async function of the evaluator, its arguments are bound in the task
+func applyAsync(token token.Token, fn *object.Function, args []object.Object, named []object.NamedArgument, env *object.Environment) object.Object {
+	return Async(token, fn.Name, env, func() object.Object {
+		call := *fn
+		call.Async = false
+		return ApplyFunctionNamed(token, &call, args, named, env)
+	})
+}
+
+// ResolvePromise settles a promise with the result of a computation: a fatal error rejects it,
+// a promise passes its state on once it settles, and any other value fulfills it
+func ResolvePromise(promise *object.Promise, result object.Object, env *object.Environment) {
+	if result == nil {
+		result = NULL
+	}
+	if other, ok := result.(*object.Promise); ok {
+		if other == promise {
+			settlePromise(promise, NewFatalError(nil, "a promise cannot resolve to itself"), true, env)
+			return
+		}
+		other.OnSettled(func() {
+			settlePromise(promise, other.Result(), other.State() == object.PromiseRejected, env)
+		})
+		return
+	}
+	settlePromise(promise, result, CheckError(result), env)
+}
+
+// settlePromise settles a promise and wakes the tasks awaiting it,
+// a rejection nobody handles is reported once the event loop drains
+func settlePromise(promise *object.Promise, value object.Object, rejected bool, env *object.Environment) {
+	if !promise.Settle(value, rejected) {
+		return
+	}
+	runtime := RuntimeOf(env)
+	if rejected && !promise.Handled() {
+		runtime.Unhandled = append(runtime.Unhandled, promise)
+	}
+	runtime.Notify()
+}
+
+// Eval an await expression
+func EvalAwaitExpression(node *ast.AwaitExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if CheckError(value) {
+		return value
+	}
+	return Await(node.Token, value, env)
+}
+
+// Await waits for a promise to settle and returns its value, the error of a rejected promise is thrown.
+// A task is waited for like with wait(), and any other value is returned as it is
+func Await(token token.Token, value object.Object, env *object.Environment) object.Object {
+	switch value := value.(type) {
+	case *object.Promise:
+		value.Handle()
+		if !RuntimeOf(env).Wait(value.Settled) {
+			return DeadlockError(token)
+		}
+		if value.State() == object.PromiseRejected {
+			return ThrowError(token, value.Result())
+		}
+		return value.Result()
+	case *object.Task:
+		return WaitTask(token, value, env)
+	}
+	return value
+}
+
+// RunEventLoop runs the event loop of the runtime of env until no task and no timer is left.
+// It reports the rejected promises and the failed tasks nobody handled, and returns the error of the first one
+func RunEventLoop(env *object.Environment) *object.Error {
+	RuntimeOf(env).Drain()
+	return reportUnhandled(env)
+}
+
+// RunUntil runs the event loop of the runtime of env until the tasks wait for the timers after until,
+// see object.Runtime.RunUntil. It reports the rejected promises nobody handled like RunEventLoop
+func RunUntil(env *object.Environment, until time.Time) *object.Error {
+	RuntimeOf(env).RunUntil(until)
+	return reportUnhandled(env)
+}
+
+// reportUnhandled returns the error of the first rejected promise or failed task nobody handled,
+// the errors of the others are logged
+func reportUnhandled(env *object.Environment) *object.Error {
+	runtime := RuntimeOf(env)
+	var first *object.Error
+	for _, rejection := range runtime.Unhandled {
+		if rejection.Handled() {
+			continue
+		}
+		err := rejection.Result().(*object.Error)
+		if first == nil {
+			first = err
+		} else {
+			LogError(err, env)
+		}
+	}
+	runtime.Unhandled = nil
+	return first
+}
+
+// SetTimer calls a function with args in a new task once delay passed, and again every delay for an interval.
+// The promise of each call is not handled, a failing call is reported once the event loop drains
+func SetTimer(token token.Token, method string, function object.Object, delay time.Duration, interval bool, args []object.Object, env *object.Environment) object.Object {
+	name, ok := functionName(function)
+	if !ok {
+		return ArgumentNotSupported(method, function.Type(), token)
+	}
+
+	runtime := RuntimeOf(env)
+	timer := &object.Timer{At: runtime.Now().Add(delay)}
+	if interval {
+		timer.Interval = delay
+	}
+	timer.Fire = func() {
+		Async(token, name, env, func() object.Object {
+			return ApplyFunction(token, function, args, env)
+		})
+	}
+	runtime.SetTimer(timer)
+	return timer
+}
+
+// Sleep waits until delay passed, the other tasks and the timers run meanwhile
+func Sleep(delay time.Duration, env *object.Environment) object.Object {
+	runtime := RuntimeOf(env)
+	awake := false
+	timer := &object.Timer{At: runtime.Now().Add(delay)}
+	timer.Fire = func() {
+		// The sleeping task wakes after the calls of the timers that fired before its own
+		runtime.Spawn(&object.Task{}, nil, func() object.Object {
+			awake = true
+			return NULL
+		})
+	}
+	runtime.SetTimer(timer)
+	runtime.Wait(func() bool {
+		return awake
+	})
+	return NULL
+}
+
+// timerDelay reads a delay in milliseconds, an integer or a float
+func timerDelay(method string, value object.Object, token token.Token) (time.Duration, *object.Error) {
+	var delay time.Duration
+	switch value := value.(type) {
+	case *object.Integer:
+		delay = time.Duration(value.Value) * time.Millisecond
+	case *object.Float:
+		delay = time.Duration(value.Value * float64(time.Millisecond))
+	default:
+		return 0, ArgumentNotSupported(method, value.Type(), token)
+	}
+	if delay < 0 {
+		return 0, ProhibitedValue(method, value.Inspect(), "the delay cannot be negative", token)
+	}
+	return delay, nil
+}
+
+// NewPromise creates a promise and calls executor with its resolve and reject functions,
+// an error thrown by the executor rejects the promise
+func NewPromise(tok token.Token, executor object.Object, env *object.Environment) object.Object {
+	if _, ok := functionName(executor); !ok {
+		return ArgumentNotSupported("promise", executor.Type(), tok)
+	}
+
+	promise := &object.Promise{}
+	resolve := &object.Builtin{
+		Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+			var value object.Object = NULL
+			if len(args) > 0 {
+				value = args[0]
+			}
+			ResolvePromise(promise, value, env)
+			return NULL
+		},
+		Parameters: 1,
+	}
+	reject := &object.Builtin{
+		Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+			var value object.Object = NULL
+			if len(args) > 0 {
+				value = args[0]
+			}
+			settlePromise(promise, ThrowError(token, value), true, env)
+			return NULL
+		},
+		Parameters: 1,
+	}
+
+	result := ApplyFunction(tok, executor, []object.Object{resolve, reject}, env)
+	if CheckError(result) {
+		settlePromise(promise, result, true, env)
+	}
+	return promise
+}
+
+// thenPromise calls onFulfilled with the value of a promise, or onRejected with its error, in a new task
+// once the promise settles. It returns the promise of the result of the call, a missing function
+// passes the state of the promise on
+func thenPromise(token token.Token, method string, promise *object.Promise, onFulfilled, onRejected object.Object, env *object.Environment) object.Object {
+	for _, handler := range []object.Object{onFulfilled, onRejected} {
+		if _, ok := functionName(handler); !ok && handler != NULL {
+			return ArgumentNotSupported(method, handler.Type(), token)
+		}
+	}
+
+	next := &object.Promise{}
+	promise.OnSettled(func() {
+		handler, value := onFulfilled, promise.Result()
+		rejected := promise.State() == object.PromiseRejected
+		if rejected {
+			handler = onRejected
+		}
+		if handler == NULL {
+			settlePromise(next, value, rejected, env)
+			return
+		}
+		if rejected {
+			caught := *value.(*object.Error)
+			caught.Fatal = false
+			value = &caught
+		}
+
+		name, _ := functionName(handler)
+		runAsync(token, name, next, env, func() object.Object {
+			return ApplyFunction(token, handler, []object.Object{value}, env)
+		})
+	})
+	return next
+}
+
+// promisePrototypes are the prototype functions of promises
+func promisePrototypes() *object.Hash {
+	methods := map[string]*object.Builtin{
+		// then(onFulfilled, onRejected) calls onFulfilled with the value, or onRejected with the error,
+		// once the promise settles and returns the promise of its result
+		"then": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 1 || len(args) > 2 {
+					return WrongArgumentsAmount("then", len(args), "1 or 2", token)
+				}
+				var onRejected object.Object = NULL
+				if len(args) == 2 {
+					onRejected = args[1]
+				}
+				return thenPromise(token, "then", thisPromise(env), args[0], onRejected, env)
+			},
+			VarArgs:   true,
+			Prototype: true,
+		},
+		// recover(onRejected) calls onRejected with the error once the promise is rejected,
+		// and returns the promise of its result, or of the value of the promise
+		"recover": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("recover", len(args), "1", token)
+				}
+				return thenPromise(token, "recover", thisPromise(env), NULL, args[0], env)
+			},
+			Parameters: 1,
+			Prototype:  true,
+		},
+		// state() returns "pending", "fulfilled" or "rejected"
+		"state": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return &object.String{Value: thisPromise(env).State().String()}
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+	}
+	return prototypeHash(methods)
+}
+
+func thisPromise(env *object.Environment) *object.Promise {
+	this, _ := env.Get("this")
+	promise, _ := this.(*object.Promise)
+	return promise
+}
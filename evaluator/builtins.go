@@ -2,13 +2,14 @@ package evaluator
 
 import (
 	"Monkey/object"
+	"Monkey/parser"
 	"Monkey/token"
-	"bufio"
 	"fmt"
-	"os"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
-	"time"
+	"unicode/utf8"
 )
 
 // Argument not supported error
@@ -38,9 +39,9 @@ func init() {
 
 		"__time": {
 			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
-				now := time.Now()
+				now := RuntimeOf(env).Now()
 				return &object.Integer{
-					Value: float64(now.UnixNano() / 1000000),
+					Value: now.UnixNano() / 1000000,
 				}
 			},
 			Parameters: 0,
@@ -102,7 +103,7 @@ func init() {
 		},
 
 		// Array
-		"__len": {
+		"len": {
 			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
 				if len(args) != 1 {
 					return WrongArgumentsAmount("len", len(args), "1", token)
@@ -110,11 +111,11 @@ func init() {
 
 				switch arg := args[0].(type) {
 				case *object.String:
-					return &object.Integer{Value: float64(len(arg.Value))}
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 				case *object.Array:
-					return &object.Integer{Value: float64(len(arg.Elements))}
+					return &object.Integer{Value: int64(len(arg.Elements))}
 				case *object.Hash:
-					return &object.Integer{Value: float64(len(arg.Pairs))}
+					return &object.Integer{Value: int64(len(arg.Pairs))}
 				default:
 					return ArgumentNotSupported("len", args[0].Type(), token)
 				}
@@ -122,6 +123,21 @@ func init() {
 			Parameters: 1,
 		},
 
+		"bytes": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("bytes", len(args), "1", token)
+				}
+
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return ArgumentNotSupported("bytes", args[0].Type(), token)
+				}
+				return StringBytes(str)
+			},
+			Parameters: 1,
+		},
+
 		"__keys": {
 			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
 				if len(args) != 1 {
@@ -162,7 +178,7 @@ func init() {
 
 					var eles []object.Object
 					for i := 0; i < int(amount.Value); i++ {
-						eles = append(eles, &object.Integer{Value: float64(i)})
+						eles = append(eles, &object.Integer{Value: int64(i)})
 					}
 
 					return &object.Array{Elements: eles}
@@ -285,9 +301,9 @@ func init() {
 					return ArgumentNotSupported("loop", args[0].Type(), token)
 				}
 
-				fn := args[0].(*object.Function)
+				fn := args[0]
 
-				t := &object.Integer{Value: float64(0)}
+				t := &object.Integer{Value: 0}
 
 				switch len(args) {
 				case 1:
@@ -339,8 +355,8 @@ func init() {
 					return ArgumentNotSupported("while", args[1].Type(), token)
 				}
 
-				fn := args[0].(*object.Function)
-				exe := args[1].(*object.Function)
+				fn := args[0]
+				exe := args[1]
 
 				result := ApplyFunction(token, fn, []object.Object{}, env)
 				if CheckError(result) {
@@ -416,7 +432,7 @@ func init() {
 					out = append(out, obj.Inspect())
 				}
 
-				fmt.Print(strings.Join(out, " "))
+				fmt.Fprint(RuntimeOf(env).Stdout, strings.Join(out, " "))
 				return NULL
 			},
 			VarArgs: true,
@@ -428,7 +444,7 @@ func init() {
 					out = append(out, obj.Inspect())
 				}
 
-				fmt.Println(strings.Join(out, " "))
+				fmt.Fprintln(RuntimeOf(env).Stdout, strings.Join(out, " "))
 				return NULL
 			},
 			VarArgs: true,
@@ -439,10 +455,11 @@ func init() {
 				//	return WrongArgumentsAmount("take", len(args), "0-1", token)
 				//}
 
-				reader := bufio.NewReader(os.Stdin)
+				runtime := RuntimeOf(env)
+				reader := runtime.Stdin
 
 				if len(args) == 1 {
-					fmt.Print(args[0].Inspect() + " > ")
+					fmt.Fprint(runtime.Stdout, args[0].Inspect()+" > ")
 				}
 
 				text, _, _ := reader.ReadLine()
@@ -458,10 +475,11 @@ func init() {
 					return WrongArgumentsAmount("takeLine", len(args), "0-1", token)
 				}
 
-				reader := bufio.NewReader(os.Stdin)
+				runtime := RuntimeOf(env)
+				reader := runtime.Stdin
 
 				if len(args) == 1 {
-					fmt.Println(args[0].Inspect() + " > ")
+					fmt.Fprintln(runtime.Stdout, args[0].Inspect()+" > ")
 				}
 				text, _, _ := reader.ReadLine()
 				return &object.String{
@@ -471,7 +489,31 @@ func init() {
 			Parameters: 1,
 		},
 
-		// TODO: Add make error && panic/fatalError
+		// Errors
+		"error": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 1 {
+					return WrongArgumentsAmount("error", len(args), "1-2", token)
+				}
+
+				message := args[0].Inspect()
+				if str, ok := args[0].(*object.String); ok {
+					message = str.Value
+				}
+				err := &object.Error{
+					Message:   message,
+					TokenData: token.ToTokenData(),
+					Stack:     RuntimeOf(env).Traceback(),
+				}
+				if len(args) == 2 {
+					err.Data = args[1]
+				}
+				return err
+			},
+			Parameters: 2,
+		},
+
+		// TODO: Add panic/fatalError
 		// Checking
 		"error?": {
 			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
@@ -522,21 +564,10 @@ func init() {
 				//	return WrongArgumentsAmount("string!", len(args), "1", token)
 				//}
 
-				target := args[0]
-
-				switch target.(type) {
-				case *object.Error:
-					err := target.(*object.Error)
-					return &object.String{
-						Value: err.Message,
-					}
-				case *object.String:
+				if target, ok := args[0].(*object.String); ok {
 					return target
-				default:
-					return &object.String{
-						Value: target.Inspect(),
-					}
 				}
+				return &object.String{Value: Stringify(args[0])}
 			},
 			Parameters: 1,
 		},
@@ -548,7 +579,7 @@ func init() {
 				target := args[0]
 
 				switch target.(type) {
-				case *object.Integer:
+				case *object.Integer, *object.BigInt, *object.Decimal, *object.Float:
 					return target
 
 				case *object.Boolean:
@@ -565,12 +596,18 @@ func init() {
 
 				case *object.String:
 					s := target.(*object.String)
+					if v, err := strconv.ParseInt(s.Value, 10, 64); err == nil {
+						return &object.Integer{Value: v}
+					} else if err.(*strconv.NumError).Err == strconv.ErrRange {
+						v, _ := new(big.Int).SetString(s.Value, 10)
+						return &object.BigInt{Value: v}
+					}
 					v, err := strconv.ParseFloat(s.Value, 64)
 					if err != nil {
 						return NewError(token.ToTokenData(), "casting to number not successful. got=%s",
 							s.Value)
 					}
-					return &object.Integer{
+					return &object.Float{
 						Value: v,
 					}
 				}
@@ -579,5 +616,187 @@ func init() {
 			},
 			Parameters: 1,
 		},
+		"bigint!": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("bigint!", len(args), "1", token)
+				}
+
+				// Fractions are dropped like in the division of integers
+				switch target := args[0].(type) {
+				case *object.BigInt:
+					return target
+				case *object.Integer:
+					return &object.BigInt{Value: big.NewInt(target.Value)}
+				case *object.Decimal:
+					return &object.BigInt{Value: new(big.Int).Quo(target.Value, object.Pow10(target.Scale))}
+				case *object.Float:
+					if math.IsInf(target.Value, 0) || math.IsNaN(target.Value) {
+						return NewError(token.ToTokenData(), "casting to bigint not successful. got=%s",
+							target.Inspect())
+					}
+					v, _ := new(big.Float).SetFloat64(target.Value).Int(nil)
+					return &object.BigInt{Value: v}
+				case *object.Boolean:
+					if target.Value {
+						return &object.BigInt{Value: big.NewInt(1)}
+					}
+					return &object.BigInt{Value: big.NewInt(0)}
+				case *object.String:
+					v, ok := new(big.Int).SetString(target.Value, 10)
+					if !ok {
+						return NewError(token.ToTokenData(), "casting to bigint not successful. got=%s",
+							target.Value)
+					}
+					return &object.BigInt{Value: v}
+				}
+
+				return ArgumentNotSupported("bigint!", args[0].Type(), token)
+			},
+			Parameters: 1,
+		},
+		"decimal!": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("decimal!", len(args), "1", token)
+				}
+
+				var text string
+				switch target := args[0].(type) {
+				case *object.Decimal:
+					return target
+				case *object.Integer, *object.BigInt:
+					return toDecimal(target)
+				case *object.Float:
+					if decimal, ok := floatToDecimal(target.Value); ok {
+						return decimal
+					}
+					text = target.Inspect()
+				case *object.Boolean:
+					text = "0"
+					if target.Value {
+						text = "1"
+					}
+				case *object.String:
+					text = target.Value
+				default:
+					return ArgumentNotSupported("decimal!", args[0].Type(), token)
+				}
+
+				v, scale, ok := parser.ParseDecimal(text)
+				if !ok {
+					return NewError(token.ToTokenData(), "casting to decimal not successful. got=%s", text)
+				}
+				return &object.Decimal{Value: v, Scale: scale}
+			},
+			Parameters: 1,
+		},
+
+		// Tasks
+		"spawn": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 1 {
+					return WrongArgumentsAmount("spawn", len(args), "1 or more", token)
+				}
+				return SpawnTask(token, args[0], args[1:], env)
+			},
+			VarArgs: true,
+		},
+		"channel": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 || args[0] == NULL {
+					return &object.Channel{}
+				}
+				capacity, ok := args[0].(*object.Integer)
+				if !ok {
+					return ArgumentNotSupported("channel", args[0].Type(), token)
+				}
+				if capacity.Value < 0 {
+					return ProhibitedValue("channel", capacity.Value, "the capacity cannot be negative", token)
+				}
+				return &object.Channel{Capacity: int(capacity.Value)}
+			},
+			Parameters: 1,
+		},
+
+		// Event loop
+		"setTimeout": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return setTimer(token, "setTimeout", false, args, env)
+			},
+			VarArgs: true,
+		},
+		"setInterval": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return setTimer(token, "setInterval", true, args, env)
+			},
+			VarArgs: true,
+		},
+		"clearTimeout": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return clearTimer(token, "clearTimeout", args, env)
+			},
+			Parameters: 1,
+		},
+		"clearInterval": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return clearTimer(token, "clearInterval", args, env)
+			},
+			Parameters: 1,
+		},
+		"sleep": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("sleep", len(args), "1", token)
+				}
+				delay, err := timerDelay("sleep", args[0], token)
+				if err != nil {
+					return err
+				}
+				return Sleep(delay, env)
+			},
+			Parameters: 1,
+		},
+		"promise": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("promise", len(args), "1", token)
+				}
+				return NewPromise(token, args[0], env)
+			},
+			Parameters: 1,
+		},
+	}
+	// len was named __len, the old name still works for existing programs
+	builtins["__len"] = builtins["len"]
+}
+
+// setTimer sets a timer calling a function with the arguments after the delay, setTimeout(fn, delay, ...args)
+func setTimer(token token.Token, method string, interval bool, args []object.Object, env *object.Environment) object.Object {
+	if len(args) < 2 {
+		return WrongArgumentsAmount(method, len(args), "2 or more", token)
+	}
+	delay, err := timerDelay(method, args[1], token)
+	if err != nil {
+		return err
+	}
+	if interval && delay == 0 {
+		return ProhibitedValue(method, args[1].Inspect(), "the interval must be positive", token)
+	}
+	return SetTimer(token, method, args[0], delay, interval, args[2:], env)
+}
+
+// clearTimer stops a timer set by setTimeout or setInterval, null is ignored
+func clearTimer(token token.Token, method string, args []object.Object, env *object.Environment) object.Object {
+	if len(args) != 1 {
+		return WrongArgumentsAmount(method, len(args), "1", token)
+	}
+	switch timer := args[0].(type) {
+	case *object.Timer:
+		RuntimeOf(env).StopTimer(timer)
+	case *object.Null:
+	default:
+		return ArgumentNotSupported(method, timer.Type(), token)
 	}
+	return NULL
 }
@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// Eval a ternary expression, only the branch chosen by the condition is evaluated
+func EvalConditionalExpression(node *ast.ConditionalExpression, env *object.Environment) object.Object {
+	condition := Eval(node.Condition, env)
+	if CheckError(condition) {
+		return condition
+	}
+
+	if IsTruthful(condition) {
+		return Eval(node.Consequence, env)
+	}
+	return Eval(node.Alternative, env)
+}
+
+// Eval an optional chain, it is null without evaluating the rest of the chain when the value before a ?. is null
+func EvalOptionalChain(node *ast.OptionalChain, env *object.Environment) object.Object {
+	result, _ := evalChain(node.Expression, env)
+	return result
+}
+
+// evalChain evaluates a link of an optional chain after the links on its left,
+// it returns whether the chain stopped at a null
+func evalChain(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	var left ast.Expression
+	optional := false
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		if node.Operator == token.DOT || node.Operator == token.OPTIONAL_DOT {
+			left, optional = node.Left, node.Operator == token.OPTIONAL_DOT
+		}
+	case *ast.IndexExpression:
+		left, optional = node.Left, node.Optional
+	case *ast.CallExpression:
+		left, optional = node.Function, node.Optional
+	}
+	if left == nil {
+		return Eval(node, env), false
+	}
+
+	value, stopped := evalChain(left, env)
+	if stopped || CheckError(value) {
+		return value, stopped
+	}
+	if optional && value == NULL {
+		return NULL, true
+	}
+
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		return EvalDotExpression(value, node, env), false
+	case *ast.IndexExpression:
+		return EvalIndex(node, value, env), false
+	default:
+		return EvalCall(node.(*ast.CallExpression), value, env), false
+	}
+}
+
+// EvalOptionalMember looks up left?.key, it is null when left is null or has no member named key
+func EvalOptionalMember(token token.Token, left object.Object, key string, env *object.Environment) object.Object {
+	if left == NULL || !HasMember(left, key, env) {
+		return NULL
+	}
+	return EvalMember(token, left, key, env)
+}
+
+// HasMember returns whether left.key is a pair of a hash, a variable of a module or a prototype function
+func HasMember(left object.Object, key string, env *object.Environment) bool {
+	keyString := &object.String{Value: key}
+	prototypes := RuntimeOf(env).Prototypes
+
+	switch value := left.(type) {
+	case *object.Module:
+		_, ok := value.Env.Get(key)
+		return ok
+	case *object.Hash:
+		if _, ok := value.Pairs[keyString.HashKey()]; ok {
+			return true
+		}
+	case *object.String:
+		if _, ok := prototypes[object.ObjectType(value.Value)]; ok && key == "prototype" {
+			return true
+		}
+	}
+
+	obj, ok := prototypes[left.Type()]
+	if !ok {
+		return false
+	}
+	_, ok = obj.Pairs[keyString.HashKey()]
+	return ok
+}
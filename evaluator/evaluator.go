@@ -3,11 +3,16 @@ package evaluator
 import (
 	"Monkey/ast"
 	"Monkey/object"
-	"Monkey/options"
 	"Monkey/token"
 	"fmt"
+	"math"
+	"math/big"
+	"sort"
 )
 
+// The deepest function calls can go, so that recursion fails with an error instead of crashing
+const MaxCallDepth = 1 << 16
+
 var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
@@ -16,10 +21,10 @@ var (
 )
 
 func NewFatalError(data *token.TokenData, format string, a ...interface{}) *object.Error {
-	options.FatalErrors = true
 	return &object.Error{
 		Message:   fmt.Sprintf(format, a...),
 		TokenData: data,
+		Fatal:     true,
 	}
 }
 
@@ -29,39 +34,73 @@ func NewError(data *token.TokenData, format string, a ...interface{}) *object.Er
 		Message:   fmt.Sprintf(format, a...),
 		TokenData: data,
 	}
-	//if options.FatalErrors {
-	//	fmt.Println(message.Inspect())
-	//}
 	return message
 }
 
-// CheckError returns if the object is an error object
-func CheckError(obj object.Object) bool {
-	if !options.FatalErrors {
-		return false
+// ThrowError makes a value fatal, values that are not errors become the data of a new error
+func ThrowError(token token.Token, value object.Object) *object.Error {
+	if err, ok := value.(*object.Error); ok {
+		thrown := *err
+		thrown.Fatal = true
+		return &thrown
+	}
+
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+	return &object.Error{
+		Message:   message,
+		TokenData: token.ToTokenData(),
+		Fatal:     true,
+		Data:      value,
 	}
-	return obj.Type() == object.ErrorObj
 }
 
-// Modified here
-// Is the object an error
-func LogError(obj object.Object) bool {
+// CatchError turns a fatal error back into a value that can be handled
+func CatchError(err *object.Error) *object.Error {
+	caught := *err
+	caught.Fatal = false
+	return &caught
+}
+
+// isLoopControl returns if the object is a break or continue on its way to its loop
+func isLoopControl(obj object.Object) bool {
+	_, ok := obj.(*object.LoopControl)
+	return ok
+}
+
+// CheckError returns if the object is a fatal error object
+func CheckError(obj object.Object) bool {
 	if obj == nil {
 		return false
 	}
-	isError := obj.Type() == object.ErrorObj
+	err, ok := obj.(*object.Error)
+	return ok && err.Fatal
+}
 
-	if isError {
-		// If Fatal errors is set, we stop the exec
-		if options.FatalErrors {
-			fmt.Println(obj.Inspect())
-			return true
-		} else {
-			// Else we treat error as a valid value
-			return false
-		}
+// promoteError turns an error value into a fatal error when the FatalErrors option is set
+func promoteError(obj object.Object, env *object.Environment) object.Object {
+	err, ok := obj.(*object.Error)
+	if !ok || err.Fatal || !RuntimeOf(env).Options.FatalErrors {
+		return obj
+	}
+	promoted := *err
+	promoted.Fatal = true
+	return &promoted
+}
+
+// Modified here
+// Is the object an error, a fatal error is written to the Stderr of the runtime.
+// The errors returned to the caller of the evaluation are not logged, it reports them
+func LogError(obj object.Object, env *object.Environment) bool {
+	// If Fatal errors is set, we stop the exec
+	if CheckError(obj) {
+		fmt.Fprintln(RuntimeOf(env).Stderr, obj.Inspect())
+		return true
 	}
 
+	// Else we treat error as a valid value
 	return false
 }
 
@@ -73,6 +112,13 @@ func IsTruthful(obj object.Object) bool {
 	case obj.Type() == object.IntegerObj:
 		integer := obj.(*object.Integer)
 		return integer.Value != 0
+	case obj.Type() == object.FloatObj:
+		float := obj.(*object.Float)
+		return float.Value != 0
+	case obj.Type() == object.BigIntObj:
+		return obj.(*object.BigInt).Value.Sign() != 0
+	case obj.Type() == object.DecimalObj:
+		return obj.(*object.Decimal).Value.Sign() != 0
 	default:
 		return true
 	}
@@ -84,9 +130,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	// Good
 	case *ast.Program:
-		result := EvalProgram(node, env)
-		LogError(result)
-		return result
+		return EvalProgram(node, env)
 
 		// Good
 	case *ast.ExpressionStatement:
@@ -102,6 +146,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.BigIntLiteral:
+		return &object.BigInt{Value: node.Value}
+
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value, Scale: node.Scale}
+
 	case *ast.Null:
 		return NULL
 
@@ -120,6 +173,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		//CheckError(result)
 		return result
 
+	case *ast.UpdateExpression:
+		return EvalUpdateExpression(node, env)
+
 	case *ast.InfixExpression:
 		// We need to short circuit AND or OR gates
 		return EvalInfixExpression(node, env)
@@ -130,21 +186,49 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return EvalIfExpression(node, env)
 
+	case *ast.TryExpression:
+		return EvalTryExpression(node, env)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if CheckError(val) {
+			return val
+		}
+		return ThrowError(node.Token, val)
+
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
-		if CheckError(val) {
+		if CheckError(val) || isLoopControl(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if CheckError(val) {
+		if CheckError(val) || isLoopControl(val) {
 			return val
 		}
 
+		if node.Pattern != nil {
+			return Destructure(node.Pattern, val, env)
+		}
 		env.Store(node.Name.Value, val)
 
+	case *ast.WhileStatement:
+		return EvalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return EvalForStatement(node, env)
+
+	case *ast.ForInStatement:
+		return EvalForInStatement(node, env)
+
+	case *ast.BreakStatement:
+		return &object.LoopControl{Label: node.Label}
+
+	case *ast.ContinueStatement:
+		return &object.LoopControl{Continue: true, Label: node.Label}
+
 	case *ast.Identifier:
 		return EvalIdentifier(node, env)
 
@@ -162,7 +246,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Patterns:   node.Patterns,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       body,
+			Name:       node.Name,
+			Generator:  node.Generator,
+			Async:      node.Async,
+		}
 
 	case *ast.CallExpression:
 		// short circuit
@@ -177,28 +271,20 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if CheckError(function) {
 			return function
 		}
-
-		args := EvalExpressions(node.Arguments, env)
-		if len(args) == 1 && CheckError(args[0]) {
-			return args[0]
-		}
-		return ApplyFunction(node.Token, function, args, env)
+		return EvalCall(node, function, env)
 
 	case *ast.StringLiteral:
-		//value := node.Value
-		//
-		//// do the parsing part
-		//for index := 0; index < len(value); index++ {
-		//	c := value[index]
-		//	if c == '#' && value[index+1] == '{' {
-		//		Eval()
-		//	}
-		//}
-
 		return &object.String{Value: node.Value}
 
+	case *ast.TemplateLiteral:
+		parts := EvalExpressions(node.Parts, env)
+		if len(parts) == 1 && CheckError(parts[0]) {
+			return parts[0]
+		}
+		return Interpolate(parts)
+
 	case *ast.ArrayLiteral:
-		elements := EvalExpressions(node.Elements, env)
+		elements := EvalElements(node.Elements, env)
 		if len(elements) == 1 && CheckError(elements[0]) {
 			return elements[0]
 		}
@@ -211,30 +297,78 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if CheckError(left) {
 			return left
 		}
+		return EvalIndex(node, left, env)
 
-		start := Eval(node.Start, env)
-		if CheckError(start) {
-			return start
-		}
+	case *ast.HashLiteral:
+		return EvalHashLiteral(node, env)
 
-		end := Eval(node.End, env)
-		if CheckError(end) {
-			return end
-		}
+	case *ast.MatchExpression:
+		return EvalMatchExpression(node, env)
 
-		return EvalIndexExpression(left, start, end, node.Token, node.HasRange)
+	case *ast.ConditionalExpression:
+		return EvalConditionalExpression(node, env)
 
-	case *ast.HashLiteral:
-		return EvalHashLiteral(node, env)
+	case *ast.OptionalChain:
+		return EvalOptionalChain(node, env)
+
+	case *ast.YieldExpression:
+		return EvalYieldExpression(node, env)
+
+	case *ast.AwaitExpression:
+		return EvalAwaitExpression(node, env)
+	case *ast.SelectExpression:
+		return EvalSelectExpression(node, env)
 	}
 
 	return NULL
 }
 
+// EvalCall calls the evaluated function of a call expression with its arguments
+func EvalCall(node *ast.CallExpression, function object.Object, env *object.Environment) object.Object {
+	if node.Optional && function == NULL {
+		return NULL
+	}
+
+	args, named, err := EvalArguments(node.Arguments, env)
+	if err != nil {
+		return err
+	}
+	return ApplyFunctionNamed(node.Token, function, args, named, env)
+}
+
+// EvalIndex indexes the evaluated left side of an index expression
+func EvalIndex(node *ast.IndexExpression, left object.Object, env *object.Environment) object.Object {
+	if node.Optional && left == NULL {
+		return NULL
+	}
+
+	start := Eval(node.Start, env)
+	if CheckError(start) {
+		return start
+	}
+
+	end := Eval(node.End, env)
+	if CheckError(end) {
+		return end
+	}
+
+	return EvalIndexExpression(left, start, end, node.Token, node.HasRange)
+}
+
 // Evaluate hash maps
 func EvalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
+	for _, spread := range node.Spreads {
+		value := Eval(spread.Value, env)
+		if CheckError(value) {
+			return value
+		}
+		if err := SpreadPairs(spread.Token, value, pairs); err != nil {
+			return err
+		}
+	}
+
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if CheckError(key) {
@@ -274,13 +408,19 @@ func EvalIndexExpression(exp object.Object, start object.Object, end object.Obje
 }
 
 func EvalStringIndexExpression(exp object.Object, start object.Object, end object.Object, token token.Token, hasRange bool) object.Object {
+	// Strings are indexed by code points
 	stringObj := exp.(*object.String)
-	length := int64(len(stringObj.Value))
+	runes := []rune(stringObj.Value)
+	length := int64(len(runes))
 
 	// Four Options
 	switch {
 	case !hasRange:
-		s := int64(start.(*object.Integer).Value)
+		index, ok := start.(*object.Integer)
+		if !ok {
+			return NewFatalError(token.ToTokenData(), "index must be an integer. got=%s", start.Type())
+		}
+		s := index.Value
 		if s < 0 {
 			s = length + s
 		}
@@ -289,7 +429,7 @@ func EvalStringIndexExpression(exp object.Object, start object.Object, end objec
 				s, 0, length-1)
 		}
 		return &object.String{
-			Value: string(stringObj.Value[s]),
+			Value: string(runes[s]),
 		}
 
 	case hasRange:
@@ -299,14 +439,14 @@ func EvalStringIndexExpression(exp object.Object, start object.Object, end objec
 
 			switch {
 			case start.Type() == object.IntegerObj && end.Type() == object.NullObj:
-				startIndex = int64(start.(*object.Integer).Value)
+				startIndex = start.(*object.Integer).Value
 				endIndex = length
 			case start.Type() == object.NullObj && end.Type() == object.IntegerObj:
 				startIndex = 0
-				endIndex = int64(end.(*object.Integer).Value)
+				endIndex = end.(*object.Integer).Value
 			case start.Type() == object.IntegerObj && end.Type() == object.IntegerObj:
-				startIndex = int64(start.(*object.Integer).Value)
-				endIndex = int64(end.(*object.Integer).Value)
+				startIndex = start.(*object.Integer).Value
+				endIndex = end.(*object.Integer).Value
 			default:
 				// Full Range
 				return &object.String{
@@ -336,7 +476,7 @@ func EvalStringIndexExpression(exp object.Object, start object.Object, end objec
 			}
 
 			return &object.String{
-				Value: stringObj.Value[startIndex:endIndex],
+				Value: string(runes[startIndex:endIndex]),
 			}
 		}
 
@@ -373,7 +513,11 @@ func EvalArrayIndexExpression(array object.Object, start object.Object, end obje
 	// Four Options
 	switch {
 	case !hasRange:
-		s := int64(start.(*object.Integer).Value)
+		index, ok := start.(*object.Integer)
+		if !ok {
+			return NewFatalError(token.ToTokenData(), "index must be an integer. got=%s", start.Type())
+		}
+		s := index.Value
 		if s < 0 {
 			s = length + s
 		}
@@ -390,14 +534,14 @@ func EvalArrayIndexExpression(array object.Object, start object.Object, end obje
 
 			switch {
 			case start.Type() == object.IntegerObj && end.Type() == object.NullObj:
-				startIndex = int64(start.(*object.Integer).Value)
+				startIndex = start.(*object.Integer).Value
 				endIndex = length
 			case start.Type() == object.NullObj && end.Type() == object.IntegerObj:
 				startIndex = 0
-				endIndex = int64(end.(*object.Integer).Value)
+				endIndex = end.(*object.Integer).Value
 			case start.Type() == object.IntegerObj && end.Type() == object.IntegerObj:
-				startIndex = int64(start.(*object.Integer).Value)
-				endIndex = int64(end.(*object.Integer).Value)
+				startIndex = start.(*object.Integer).Value
+				endIndex = end.(*object.Integer).Value
 			default:
 				// Full Range
 				return arrayObj
@@ -436,21 +580,46 @@ func EvalArrayIndexExpression(array object.Object, start object.Object, end obje
 
 // Create a function and eval it
 func ApplyFunction(token token.Token, function object.Object, args []object.Object, environment *object.Environment) object.Object {
+	return ApplyFunctionNamed(token, function, args, nil, environment)
+}
+
+// Create a function and eval it with positional and named arguments
+func ApplyFunctionNamed(token token.Token, function object.Object, args []object.Object, named []object.NamedArgument, environment *object.Environment) object.Object {
+	if _, ok := function.(*object.Builtin); ok && named != nil {
+		return NewFatalError(token.ToTokenData(), "builtin functions do not take named arguments")
+	}
+
 	switch fn := function.(type) {
 	case *object.Function:
-		requiredPar := len(fn.Parameters)
+		if fn.Async {
+			return applyAsync(token, fn, args, named, environment)
+		}
+		runtime := RuntimeOf(environment)
+		args, err := BindArguments(token, fn, args, named, runtime.Options.StrictArity)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return applyGenerator(fn, args, environment)
+		}
 
-		for len(args) < requiredPar {
-			args = append(args, NULL)
+		if len(runtime.CallStack) >= MaxCallDepth {
+			return NewFatalError(token.ToTokenData(), "stack overflow")
 		}
+		depth := runtime.PushFrame(object.FunctionName(fn.Name), *token.ToTokenData())
 
-		extendedEnv := ExtendFunctionEnv(fn, args[:requiredPar])
-		evaluated := Eval(fn.Body, extendedEnv)
-		return UnwrapReturnValue(evaluated)
+		extendedEnv := ExtendFunctionEnv(fn, args)
+		if err := applyDefaults(fn, args, extendedEnv); err != nil {
+			runtime.PopFrames(depth, err)
+			return err
+		}
+		evaluated := UnwrapReturnValue(Eval(fn.Body, extendedEnv))
+		runtime.PopFrames(depth, evaluated)
+		return evaluated
 
 	case *object.Builtin:
 		if fn.VarArgs {
-			return fn.Fn(token, environment, args...)
+			return promoteError(fn.Fn(token, environment, args...), environment)
 		}
 
 		requiredPar := fn.Parameters
@@ -458,28 +627,26 @@ func ApplyFunction(token token.Token, function object.Object, args []object.Obje
 			args = args[:requiredPar]
 		}
 
-		return fn.Fn(token, environment, args...)
+		return promoteError(fn.Fn(token, environment, args...), environment)
+	case *object.Closure:
+		return machineOf(fn, environment).CallClosure(token, fn, fn.This, args, named)
+
 	case *object.PrototypeFunction:
+		// this is stored in an environment of the call, the environments of the function
+		// and of the caller can be shared by tasks
 		var result object.Object
 		switch Fn := fn.Fn.(type) {
+		case *object.Closure:
+			result = machineOf(Fn, environment).CallClosure(token, Fn, *fn.This, args, named)
 		case *object.Function:
-			oldThis, ok := Fn.Env.Get("this")
-			Fn.Env.Store("this", *fn.This)
-			result = ApplyFunction(token, fn.Fn, args, environment)
-			if ok {
-				Fn.Env.Replace("this", oldThis)
-			} else {
-				Fn.Env.Delete("this")
-			}
+			bound := *Fn
+			bound.Env = object.NewEnclosingEnvironment(Fn.Env)
+			bound.Env.Store("this", *fn.This)
+			result = ApplyFunctionNamed(token, &bound, args, named, environment)
 		case *object.Builtin:
-			oldThis, ok := environment.Get("this")
-			environment.Store("this", *fn.This)
-			result = ApplyFunction(token, fn.Fn, args, environment)
-			if ok {
-				environment.Replace("this", oldThis)
-			} else {
-				environment.Delete("this")
-			}
+			thisEnv := object.NewEnclosingEnvironment(environment)
+			thisEnv.Store("this", *fn.This)
+			result = ApplyFunctionNamed(token, fn.Fn, args, named, thisEnv)
 		}
 
 		return result
@@ -489,6 +656,14 @@ func ApplyFunction(token token.Token, function object.Object, args []object.Obje
 	}
 }
 
+// machineOf returns the machine to call a closure with, the one of the running task
+func machineOf(closure *object.Closure, env *object.Environment) object.Machine {
+	if machine := RuntimeOf(env).Machine; machine != nil {
+		return machine
+	}
+	return closure.Machine
+}
+
 // Unwrap the return value for an object
 func UnwrapReturnValue(evaluated object.Object) object.Object {
 	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
@@ -498,6 +673,26 @@ func UnwrapReturnValue(evaluated object.Object) object.Object {
 	return evaluated
 }
 
+// applyDefaults stores the defaults of the null arguments and destructures the parameters with a pattern,
+// in the order of the parameters so that a default can refer to the parameters before it
+func applyDefaults(fn *object.Function, args []object.Object, env *object.Environment) object.Object {
+	for i, param := range fn.Parameters {
+		if fn.Defaults != nil && fn.Defaults[i] != nil && args[i] == NULL {
+			args[i] = Eval(fn.Defaults[i], env)
+			if CheckError(args[i]) {
+				return args[i]
+			}
+			env.Store(param.Value, args[i])
+		}
+		if fn.Patterns != nil && fn.Patterns[i] != nil {
+			if err := Destructure(fn.Patterns[i], args[i], env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Create the environment for the function
 func ExtendFunctionEnv(function *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosingEnvironment(function.Env)
@@ -506,6 +701,9 @@ func ExtendFunctionEnv(function *object.Function, args []object.Object) *object.
 	for paramIdx, param := range function.Parameters {
 		env.Store(param.Value, args[paramIdx])
 	}
+	if function.Rest != nil {
+		env.Store(function.Rest.Value, args[len(function.Parameters)])
+	}
 
 	return env
 }
@@ -525,6 +723,22 @@ func EvalExpressions(arguments []ast.Expression, env *object.Environment) []obje
 	return result
 }
 
+// LookupBuiltin returns the builtin function with the name
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// BuiltinNames returns the names of the builtin functions, sorted
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Fetch the value from env and return it
 func EvalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if builtin, ok := builtins[node.Value]; ok {
@@ -548,7 +762,7 @@ func EvalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ReturnValueObj || CheckError(result) {
+			if rt == object.ReturnValueObj || rt == object.LoopControlObj || CheckError(result) {
 				return result
 			}
 		}
@@ -573,6 +787,28 @@ func EvalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// Eval Try Expression, the finally block can replace the result by failing or returning
+func EvalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+	if CheckError(result) && te.Catch != nil {
+		if te.Parameter != nil {
+			env.Store(te.Parameter.Value, CatchError(result.(*object.Error)))
+		}
+		result = Eval(te.Catch, env)
+	}
+	if result == nil {
+		result = NULL
+	}
+
+	if te.Finally != nil {
+		final := Eval(te.Finally, env)
+		if _, ok := final.(*object.ReturnValue); ok || isLoopControl(final) || CheckError(final) {
+			return final
+		}
+	}
+	return result
+}
+
 func UnhandledOperationError(token token.Token, left object.Object, right object.Object, operator string) object.Object {
 	return NewFatalError(token.ToTokenData(), "unknown operation: %s %s %s",
 		left.Type(), operator, right.Type())
@@ -631,42 +867,20 @@ func EvalAssignmentExpression(node *ast.InfixExpression, env *object.Environment
 		if CheckError(index) {
 			return index
 		}
-		switch val := value.(type) {
-		case *object.Hash:
-			hashKey, ok := index.(object.Hashable)
-			if !ok {
-				return NewFatalError(node.Token.ToTokenData(), "unusable as hash key: %s", index.Type())
-			}
-
-			val.Pairs[hashKey.HashKey()] = object.HashPair{Key: index, Value: right}
-
-		case *object.Array:
-			index, ok := index.(*object.Integer)
-			if !ok {
-				return NewFatalError(node.Token.ToTokenData(), "unusable index key: %s", index.Type())
-			}
-			val.Elements[int(index.Value)] = right
-		}
+		return AssignIndex(node.Token, value, index, right)
 	case *ast.InfixExpression:
 		value := Eval(lft.Left, env)
 		if CheckError(value) {
 			return value
 		}
-		switch val := value.(type) {
-		case *object.Module:
+		switch value.(type) {
+		case *object.Module, *object.Hash:
 			key := castExpressionToKey(lft.Right, node.Token, env)
 			if CheckError(key) {
 				return key
 			}
 			keyString, _ := key.(*object.String)
-			val.Env.Store(keyString.Value, right)
-		case *object.Hash:
-			key := castExpressionToKey(lft.Right, node.Token, env)
-			if CheckError(key) {
-				return key
-			}
-			keyString, _ := key.(*object.String)
-			val.Pairs[keyString.HashKey()] = object.HashPair{Key: keyString, Value: right}
+			return AssignMember(node.Token, value, keyString.Value, right)
 		default:
 			return NewFatalError(node.Token.ToTokenData(), "left expression is not a valid target. got=%s", value.Type())
 		}
@@ -686,6 +900,138 @@ func EvalAssignmentExpression(node *ast.InfixExpression, env *object.Environment
 	return right
 }
 
+// EvalCompoundAssignment evaluates x op= y as x = x op y, the containers and indexes of x are evaluated once.
+// x ??= y only evaluates y and assigns it when x is null
+func EvalCompoundAssignment(node *ast.InfixExpression, env *object.Environment) object.Object {
+	current, assign := evalAssignmentTarget(node.Token, node.Left, env)
+	if CheckError(current) {
+		return current
+	}
+
+	operator := token.CompoundAssignments[token.TokenType(node.Operator)]
+	if operator == token.NULLISH && current != NULL {
+		return current
+	}
+
+	value := Eval(node.Right, env)
+	if CheckError(value) {
+		return value
+	}
+	if operator != token.NULLISH {
+		value = EvalOperatorExpression(node.Token, string(operator), current, value)
+		if CheckError(value) {
+			return value
+		}
+	}
+	return assign(value)
+}
+
+// EvalUpdateExpression evaluates x++ as x += 1 and x-- as x -= 1, the containers and indexes of x are evaluated once.
+// The prefix forms return the new value of x, the postfix ones the value x had
+func EvalUpdateExpression(node *ast.UpdateExpression, env *object.Environment) object.Object {
+	current, assign := evalAssignmentTarget(node.Token, node.Target, env)
+	if CheckError(current) {
+		return current
+	}
+
+	operator := token.Updates[token.TokenType(node.Operator)]
+	value := EvalOperatorExpression(node.Token, string(operator), current, &object.Integer{Value: 1})
+	if CheckError(value) {
+		return value
+	}
+	if result := assign(value); CheckError(result) || node.Prefix {
+		return result
+	}
+	return current
+}
+
+// evalAssignmentTarget evaluates the containers and indexes of the target of an update once, it returns
+// the current value of the target and a function assigning it
+func evalAssignmentTarget(tok token.Token, target ast.Expression, env *object.Environment) (object.Object, func(value object.Object) object.Object) {
+	var current object.Object
+	var assign func(value object.Object) object.Object
+
+	switch lft := target.(type) {
+	case *ast.IndexExpression:
+		container := Eval(lft.Left, env)
+		if CheckError(container) {
+			return container, nil
+		}
+		index := Eval(lft.Start, env)
+		if CheckError(index) {
+			return index, nil
+		}
+		current = EvalIndexExpression(container, index, NULL, tok, false)
+		assign = func(value object.Object) object.Object {
+			return AssignIndex(tok, container, index, value)
+		}
+	case *ast.InfixExpression:
+		container := Eval(lft.Left, env)
+		if CheckError(container) {
+			return container, nil
+		}
+		key := castExpressionToKey(lft.Right, tok, env)
+		if CheckError(key) {
+			return key, nil
+		}
+		keyString, _ := key.(*object.String)
+		current = EvalMemberTarget(tok, container, keyString.Value)
+		assign = func(value object.Object) object.Object {
+			return AssignMember(tok, container, keyString.Value, value)
+		}
+	case *ast.Identifier:
+		current = Eval(lft, env)
+		assign = func(value object.Object) object.Object {
+			env.Replace(lft.Value, value)
+			return value
+		}
+	default:
+		return NewFatalError(tok.ToTokenData(), "Cannot use non identifier in an expression"), nil
+	}
+	return current, assign
+}
+
+// AssignIndex sets container[index] to value and returns the value
+func AssignIndex(token token.Token, container object.Object, index object.Object, value object.Object) object.Object {
+	switch val := container.(type) {
+	case *object.Hash:
+		hashKey, ok := index.(object.Hashable)
+		if !ok {
+			return NewFatalError(token.ToTokenData(), "unusable as hash key: %s", index.Type())
+		}
+
+		val.Pairs[hashKey.HashKey()] = object.HashPair{Key: index, Value: value}
+
+	case *object.Array:
+		integer, ok := index.(*object.Integer)
+		if !ok {
+			return NewFatalError(token.ToTokenData(), "unusable index key: %s", index.Type())
+		}
+		i := integer.Value
+		length := int64(len(val.Elements))
+		if !IsIndexInRange(i, length) {
+			return NewFatalError(token.ToTokenData(), "index out of range. got=%d, expected=%d-%d",
+				i, 0, length-1)
+		}
+		val.Elements[i] = value
+	}
+	return value
+}
+
+// AssignMember sets container.key to value and returns the value
+func AssignMember(token token.Token, container object.Object, key string, value object.Object) object.Object {
+	switch val := container.(type) {
+	case *object.Module:
+		val.Env.Store(key, value)
+	case *object.Hash:
+		keyString := &object.String{Value: key}
+		val.Pairs[keyString.HashKey()] = object.HashPair{Key: keyString, Value: value}
+	default:
+		return NewFatalError(token.ToTokenData(), "left expression is not a valid target. got=%s", container.Type())
+	}
+	return value
+}
+
 func EvalDotExpression(left object.Object, node *ast.InfixExpression, env *object.Environment) object.Object {
 	var key string
 	switch right := node.Right.(type) {
@@ -699,6 +1045,31 @@ func EvalDotExpression(left object.Object, node *ast.InfixExpression, env *objec
 		}
 		key = rightString.Value
 	}
+	if node.Operator == token.OPTIONAL_DOT {
+		return EvalOptionalMember(node.Token, left, key, env)
+	}
+	return EvalMember(node.Token, left, key, env)
+}
+
+// EvalMemberTarget returns the value of container.key to update it, members that are not set are null
+func EvalMemberTarget(token token.Token, container object.Object, key string) object.Object {
+	switch value := container.(type) {
+	case *object.Module:
+		if val, ok := value.Env.Get(key); ok {
+			return val
+		}
+	case *object.Hash:
+		if pair, ok := value.Pairs[(&object.String{Value: key}).HashKey()]; ok {
+			return pair.Value
+		}
+	default:
+		return NewFatalError(token.ToTokenData(), "left expression is not a valid target. got=%s", container.Type())
+	}
+	return NULL
+}
+
+// EvalMember looks up left.key in modules, hashes and then the prototypes
+func EvalMember(token token.Token, left object.Object, key string, env *object.Environment) object.Object {
 	switch value := left.(type) {
 	case *object.Module:
 		val, ok := value.Env.Get(key)
@@ -712,25 +1083,26 @@ func EvalDotExpression(left object.Object, node *ast.InfixExpression, env *objec
 		if ok {
 			return val.Value
 		}
-		return sortObjectPrototypes(node, key, value, env)
+		return sortObjectPrototypes(token, key, value, env)
 	default:
-		return sortObjectPrototypes(node, key, value, env)
+		return sortObjectPrototypes(token, key, value, env)
 	}
 }
 
-func sortObjectPrototypes(node *ast.InfixExpression, key string, value object.Object, env *object.Environment) object.Object {
+func sortObjectPrototypes(token token.Token, key string, value object.Object, env *object.Environment) object.Object {
 	typeStr, ok := value.(*object.String)
 	if ok && key == "prototype" {
-		pts, ok := prototypes[object.ObjectType(typeStr.Value)]
+		pts, ok := RuntimeOf(env).Prototypes[object.ObjectType(typeStr.Value)]
 		if ok {
 			return pts
 		}
 	}
 
 	// prototype
+	prototypes := RuntimeOf(env).Prototypes
 	obj, ok := prototypes[value.Type()]
 	if !ok {
-		return NewFatalError(node.Token.ToTokenData(), "no prototype functions found for type=%s", value.Type())
+		return NewFatalError(token.ToTokenData(), "no prototype functions found for type=%s", value.Type())
 	}
 
 	keyStr := &object.String{
@@ -738,21 +1110,15 @@ func sortObjectPrototypes(node *ast.InfixExpression, key string, value object.Ob
 	}
 	fn, ok := obj.Pairs[keyStr.HashKey()]
 	if !ok {
-		return NewFatalError(node.Token.ToTokenData(), "no prototype function named %q found for type=%s", keyStr.Value, value.Type())
+		return NewFatalError(token.ToTokenData(), "no prototype function named %q found for type=%s", keyStr.Value, value.Type())
 	}
 
 	fnObj, ok := fn.Value.(*object.Builtin)
 	if ok {
 		if fnObj.Eval {
-			oldThis, ok := env.Get("this")
-			env.Store("this", value)
-			result := fnObj.Fn(node.Token, env)
-			if ok {
-				env.Replace("this", oldThis)
-			} else {
-				env.Delete("this")
-			}
-			return result
+			thisEnv := object.NewEnclosingEnvironment(env)
+			thisEnv.Store("this", value)
+			return fnObj.Fn(token, thisEnv)
 		}
 	}
 
@@ -772,13 +1138,16 @@ func EvalInfixExpression(node *ast.InfixExpression, env *object.Environment) obj
 	if operator == token.ASSIGN {
 		return EvalAssignmentExpression(node, env)
 	}
+	if _, ok := token.CompoundAssignments[token.TokenType(operator)]; ok {
+		return EvalCompoundAssignment(node, env)
+	}
 
 	left := Eval(node.Left, env)
 	if CheckError(left) {
 		return left
 	}
 
-	if operator == token.DOT {
+	if operator == token.DOT || operator == token.OPTIONAL_DOT {
 		return EvalDotExpression(left, node, env)
 	}
 
@@ -786,6 +1155,11 @@ func EvalInfixExpression(node *ast.InfixExpression, env *object.Environment) obj
 	switch operator {
 	case token.AND, token.OR, token.XOR:
 		return EvalShortCircuitExpression(operator, left, node, env)
+	case token.NULLISH:
+		if left != NULL {
+			return left
+		}
+		return Eval(node.Right, env)
 	}
 
 	// Regular
@@ -933,36 +1307,12 @@ func EvalAndExpression(left object.Object, rightExp ast.Expression, env *object.
 // Eval Integer Expression
 // Legacy
 func EvalIntegerInfixExpression(operator string, left object.Object, right object.Object, token token.Token) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
-
-	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "%":
-		return &object.Integer{Value: float64(int64(leftVal) % int64(rightVal))}
-	case "<":
-		return NativeBoolToBooleanObject(leftVal < rightVal)
-	case "<=":
-		return NativeBoolToBooleanObject(leftVal <= rightVal)
-	case ">":
-		return NativeBoolToBooleanObject(leftVal > rightVal)
-	case ">=":
-		return NativeBoolToBooleanObject(leftVal >= rightVal)
-	case "==":
-		return NativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return NativeBoolToBooleanObject(leftVal != rightVal)
-	default:
+	result := EvalNumberInfixExpression(operator, left, right, token)
+	if result == nil {
 		return NewFatalError(token.ToTokenData(), "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+	return result
 }
 
 // Eval a prefix expression
@@ -974,6 +1324,8 @@ func EvalPrefixExpression(operator string, right object.Object, token token.Toke
 		return EvalMinusPrefixOperatorExpression(right, token)
 	case "+":
 		return EvalPlusPrefixOperatorExpression(right, token)
+	case "~":
+		return EvalBitNotPrefixOperatorExpression(right, token)
 	default:
 		return NewFatalError(token.ToTokenData(), "unknown operation: %s%s",
 			operator, right.Type())
@@ -982,22 +1334,42 @@ func EvalPrefixExpression(operator string, right object.Object, token token.Toke
 
 // Eval + infix operator
 func EvalPlusPrefixOperatorExpression(right object.Object, token token.Token) object.Object {
-	if right.Type() != object.IntegerObj {
+	if !IsNumber(right) {
 		return NewFatalError(token.ToTokenData(), "unknown operation: +%s", right.Type())
 	}
 
 	return right
 }
 
+// Eval ~ prefix operator, it flips the bits of an integer, ~x is -x - 1
+func EvalBitNotPrefixOperatorExpression(right object.Object, token token.Token) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Not(right.Value)}
+	default:
+		return NewFatalError(token.ToTokenData(), "unknown operation: ~%s", right.Type())
+	}
+}
+
 // Eval - infix operator
 func EvalMinusPrefixOperatorExpression(right object.Object, token token.Token) object.Object {
-	// Not Integer
-	if right.Type() != object.IntegerObj {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return &object.BigInt{Value: new(big.Int).Neg(big.NewInt(right.Value))}
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return &object.BigInt{Value: new(big.Int).Neg(right.Value)}
+	case *object.Decimal:
+		return &object.Decimal{Value: new(big.Int).Neg(right.Value), Scale: right.Scale}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return NewFatalError(token.ToTokenData(), "unknown operation: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 // Eval the bang/invert operator
@@ -1017,11 +1389,16 @@ func NativeBoolToBooleanObject(value bool) object.Object {
 // Eval Print ExpressionStmt
 func EvalPrintExpressionStatement(token token.Token, exp ast.Expression, env *object.Environment) object.Object {
 	result := Eval(exp, env)
-	if CheckError(result) {
+	if CheckError(result) || isLoopControl(result) {
 		return result
 	}
 
-	builtins["writeLine"].Fn(token, env, result)
+	return PrintValue(token, result, env)
+}
+
+// PrintValue writes a value on its own line to the runtime output
+func PrintValue(token token.Token, value object.Object, env *object.Environment) object.Object {
+	builtins["writeLine"].Fn(token, env, value)
 	//fmt.Println(result.Inspect())
 	return NULL
 }
@@ -1037,7 +1414,7 @@ func EvalProgram(program *ast.Program, env *object.Environment) object.Object {
 		case *object.ReturnValue:
 			return result.(*object.ReturnValue).Value
 		case *object.Error:
-			if options.FatalErrors {
+			if CheckError(result) {
 				return result
 			}
 		}
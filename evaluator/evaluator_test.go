@@ -5,7 +5,11 @@ import (
 	"Monkey/object"
 	"Monkey/options"
 	"Monkey/parser"
+	"io/ioutil"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHashIndexExpressions(t *testing.T) {
@@ -31,7 +35,7 @@ func TestHashIndexExpressions(t *testing.T) {
 		evaluated := CheckEval(tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
-			CheckIntegerObject(t, evaluated, float64(integer))
+			CheckIntegerObject(t, evaluated, int64(integer))
 		} else {
 			CheckNullObject(t, evaluated)
 		}
@@ -57,7 +61,7 @@ false: 6
 			evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]float64{
+	expected := map[object.HashKey]int64{
 		(&object.String{Value: "one"}).HashKey():   1,
 		(&object.String{Value: "two"}).HashKey():   2,
 		(&object.String{Value: "three"}).HashKey(): 3,
@@ -124,23 +128,23 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			`[1, 2, 3, 4, 5][1:3]`,
-			[]float64{2, 3},
+			[]int64{2, 3},
 		},
 		{
 			`[1, 2, 3, 4, 5][:3]`,
-			[]float64{1, 2, 3},
+			[]int64{1, 2, 3},
 		},
 		{
 			`[1, 2, 3, 4, 5][1:]`,
-			[]float64{2, 3, 4, 5},
+			[]int64{2, 3, 4, 5},
 		},
 		{
 			`[1, 2, 3, 4, 5][:]`,
-			[]float64{1, 2, 3, 4, 5},
+			[]int64{1, 2, 3, 4, 5},
 		},
 		{
 			`[1, 2, 3, 4, 5][1:-1]`,
-			[]float64{2, 3, 4},
+			[]int64{2, 3, 4},
 		},
 		{
 			`[1, 2, 3, 4, 5][-2]`,
@@ -153,20 +157,20 @@ func TestArrayIndexExpressions(t *testing.T) {
 		switch tt.expected.(type) {
 		case int:
 			i := tt.expected.(int)
-			CheckIntegerObject(t, evaluated, float64(i))
+			CheckIntegerObject(t, evaluated, int64(i))
 		case nil:
 			if _, ok := evaluated.(*object.Error); !ok {
 				t.Errorf("should be error. got=%T",
 					evaluated)
 			}
-		case []float64:
-			i := tt.expected.([]float64)
+		case []int64:
+			i := tt.expected.([]int64)
 			CheckArrayObject(t, evaluated, i)
 		}
 	}
 }
 
-func CheckArrayObject(t *testing.T, obj object.Object, expected []float64) bool {
+func CheckArrayObject(t *testing.T, obj object.Object, expected []int64) bool {
 	result, ok := obj.(*object.Array)
 	if !ok {
 		t.Errorf("obj not type Array. got=%T",
@@ -189,7 +193,7 @@ func CheckArrayObject(t *testing.T, obj object.Object, expected []float64) bool
 		}
 
 		if expected[index] != val.Value {
-			t.Errorf("ele(%d) is does not contain %f. got=%f",
+			t.Errorf("ele(%d) is does not contain %d. got=%d",
 				index, expected[index], val.Value)
 			continue
 		}
@@ -228,6 +232,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported. got INTEGER"},
+		{`__len("four")`, 4},
 	}
 
 	for _, tt := range tests {
@@ -235,7 +240,7 @@ func TestBuiltinFunctions(t *testing.T) {
 
 		switch expected := tt.expected.(type) {
 		case int:
-			CheckIntegerObject(t, evaluated, float64(expected))
+			CheckIntegerObject(t, evaluated, int64(expected))
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -303,7 +308,7 @@ addTwo(2)`
 func TestFunctionCalls(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected int64
 	}{
 		{"let identity = fn(x) { x } \nidentity(5)", 5},
 		{"let identity = fn(x) { return x } \nidentity(5)", 5},
@@ -351,7 +356,7 @@ func TestFunctionObject(t *testing.T) {
 func TestLetStatement(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected int64
 	}{
 		{"let a = 5; a", 5},
 		{"let a = 5 * 5; a", 25},
@@ -364,6 +369,65 @@ func TestLetStatement(t *testing.T) {
 	}
 }
 
+// Generators left at a yield are unwound when they are closed or collected, their goroutines do not leak
+func TestGeneratorsDoNotLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	evaluated := CheckEval(`let naturals = fn*() {
+	let i = 0
+	while true {
+		yield i
+		i += 1
+	}
+}
+let closed = naturals()
+closed.next()
+closed.close()
+let abandon = fn() {
+	let g = naturals()
+	g.next()
+	g.next().value
+}
+let i = 0
+while i < 20 {
+	abandon()
+	i += 1
+}
+for x in naturals() { if x > 3 { break } }
+let [a, b] = naturals()
+b`)
+	if evaluated.Inspect() != "1" {
+		t.Fatalf("wrong result. got=%s", evaluated.Inspect())
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("the goroutines of abandoned generators leaked. before=%d, after=%d", before, after)
+	}
+}
+
+// The tasks and the timers left by a program run once it ends, a rejection nobody handled is reported
+func TestRunEventLoop(t *testing.T) {
+	evaluated, env := checkEvalClock("let log = []\nsetTimeout(fn() { log.push(__time()) }, 250)\nlet f = async fn() { throw 'lost' }\nf()\nlet g = async fn() { throw 'handled' }\ng().recover(fn(e) { log.push(e.message) })\nlog")
+	if evaluated.Inspect() != "[]" {
+		t.Fatalf("the timers ran before the end of the program. got=%s", evaluated.Inspect())
+	}
+
+	err := RunEventLoop(env)
+	if err == nil || err.Message != "lost" {
+		t.Errorf("the unhandled rejection was not returned. got=%v", err)
+	}
+	if evaluated.Inspect() != "[handled, 250]" {
+		t.Errorf("the event loop did not drain. got=%s", evaluated.Inspect())
+	}
+	if err := RunEventLoop(env); err != nil {
+		t.Errorf("a rejection was reported twice. got=%s", err.Message)
+	}
+}
+
 // Test Error handling
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
@@ -378,6 +442,10 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"1++",
+			"Cannot use non identifier in an expression",
+		},
 		{
 			"5 + true 5",
 			"type mismatch: INTEGER + BOOLEAN",
@@ -412,7 +480,6 @@ func TestErrorHandling(t *testing.T) {
 		},
 	}
 
-	options.FatalErrors = true
 	for _, tt := range tests {
 		evaluated := CheckEval(tt.input)
 		errObj, ok := evaluated.(*object.Error)
@@ -428,11 +495,29 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+// An error thrown outside of a try stops the program
+func TestUncaughtThrow(t *testing.T) {
+	evaluated := CheckEval("try { 1 } finally { 2 }\nthrow 'uncaught'")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !errObj.Fatal || errObj.Message != "uncaught" {
+		t.Errorf("expected an uncaught error. got=%v", evaluated)
+	}
+}
+
+// A for in loop needs an iterable value
+func TestLoopOverNonIterable(t *testing.T) {
+	evaluated := CheckEval("for x in 5 { x }")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "value is not iterable: INTEGER" {
+		t.Errorf("expected a not iterable error. got=%v", evaluated)
+	}
+}
+
 // Test return statements
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected int64
 	}{
 		{"5", 5},
 		{"return 10", 10},
@@ -469,7 +554,7 @@ func TestIfElseExpressions(t *testing.T) {
 		evaluated := CheckEval(tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
-			CheckIntegerObject(t, evaluated, float64(integer))
+			CheckIntegerObject(t, evaluated, int64(integer))
 		} else {
 			CheckNullObject(t, evaluated)
 		}
@@ -488,7 +573,7 @@ func CheckNullObject(t *testing.T, obj object.Object) bool {
 func TestEvalIntegerExpression(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected int64
 	}{
 		{"5", 5},
 		{"10", 10},
@@ -496,12 +581,11 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"-5", -5},
 		{"+5", +5},
 		{"+10", +10},
-		{"-10.5", -10.5},
 		{"-0", 0},
-		{"1.5 + 2.5", 4},
-		{"3.2 * 3", 9.6},
-		{"4 / 2.0", 2},
-		{"5.0 % 2.0", 1},
+		{"7 / 2", 3},
+		{"-7 / 2", -3},
+		{"7 % 3", 1},
+		{"9007199254740993 + 0", 9007199254740993},
 		{" 5 + 5 + 5 + 5 - 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 + -50", 0},
@@ -521,6 +605,170 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+// Test Eval of Float and of the promotion of integers
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-10.5", -10.5},
+		{"1.5 + 2.5", 4},
+		{"3.2 * 3", 9.6},
+		{"4 / 2.0", 2},
+		{"1 / 4.0", 0.25},
+		{"5.0 % 2.0", 1},
+		{"7.5 % 2", 1.5},
+		{"2 - 0.5", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := CheckEval(tt.input)
+		CheckFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumberSemantics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 == 1.0", "true"},
+		{"1 < 1.5", "true"},
+		{"2.0", "2.0"},
+		{"1 / 2", "0"},
+		{"1.0 / 2", "0.5"},
+		{"1 / 0.0", "+Inf"},
+		{"let h = {1: 'int', 1.5: 'float'}\nh[1] + h[1.5]", "intfloat"},
+		{"let h = {1: 'one'}\nh[1.0]", "one"},
+		{"'a' + 1.5", "a1.5"},
+		{"number!('2')", "2"},
+		{"number!('2.5')", "2.5"},
+		{"-2.5", "-2.5"},
+		{"[1, 2, 3][1.0]", "index must be an integer. got=FLOAT"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"0xFF + 0b1 + 0o7 + 1_000", "1263"},
+		{"1e3", "1000.0"},
+		{"1e-9 * 1", "1e-09"},
+		{"decimal!('1.5e2')", "150"},
+	}
+
+	for _, tt := range tests {
+		evaluated := CheckEval(tt.input)
+		got := evaluated.Inspect()
+		if err, ok := evaluated.(*object.Error); ok {
+			got = err.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%q wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBitwiseAndPowerOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0b1100 & 0b1010", "8"},
+		{"0b1100 | 0b1010", "14"},
+		{"0b1100 ^ 0b1010", "6"},
+		{"~0", "-1"},
+		{"1 << 62", "4611686018427387904"},
+		{"1 << 63", "9223372036854775808"},
+		{"typeof(3 << 70 >> 70)", "BIGINT"},
+		{"-9 >> 1", "-5"},
+		{"1 >> 100", "0"},
+		{"(1 << 80) & ((1 << 80) | 5)", "1208925819614629174706176"},
+		{"~(1n << 64)", "-18446744073709551617"},
+		{"1 << -1", "negative shift amount -1"},
+		{"1 << (1n << 40)", "shift amount too large 1099511627776"},
+		{"1.5 | 1", "bitwise operators need integers. got=FLOAT | INTEGER"},
+		{"1d & 1", "bitwise operators need integers. got=DECIMAL & INTEGER"},
+		{"~1.5", "unknown operation: ~FLOAT"},
+		{"2 ** 10", "1024"},
+		{"2 ** 64", "18446744073709551616"},
+		{"(-3) ** 3", "-27"},
+		{"-3 ** 2", "-9"},
+		{"2 ** 3 ** 2", "512"},
+		{"2 ** -2", "0.25"},
+		{"0 ** 0", "1"},
+		{"4 ** 0.5", "2.0"},
+		{"10n ** 20", "100000000000000000000"},
+		{"1.5d ** 2", "2.25"},
+		{"1.5d ** -1", "0.66666666666666666667"},
+		{"2d ** 0.5d", "decimal powers need an integer exponent. got=0.5"},
+		{"0d ** -1", "division by zero"},
+		{"let x = 0b0101\nx &= 0b0100\nx |= 0b0010\nx ^= 1\nx <<= 2\nx >>= 1\nx **= 2\nx", "196"},
+		{"let a = [1, 2]\na[1] <<= 4\na", "[1, 32]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := CheckEval(tt.input)
+		got := evaluated.Inspect()
+		if err, ok := evaluated.(*object.Error); ok {
+			got = err.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%q wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"typeof(9223372036854775807 + 1)", "BIGINT"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"3037000500 * 3037000500", "9223372037000250000"},
+		{"typeof(1 + 1)", "INTEGER"},
+		{"100000000000000000000", "100000000000000000000"},
+		{"123n * 2", "246"},
+		{"7n / 2", "3"},
+		{"-7n % 2", "-1"},
+		{"2n < 3", "true"},
+		{"1.10d + 2.20d", "3.30"},
+		{"1.10d * 1.10d", "1.2100"},
+		{"1.10d + 2", "3.10"},
+		{"10d / 3", "3.33333333333333333333"},
+		{"2d / 3", "0.66666666666666666667"},
+		{"10.00d / 4", "2.50"},
+		{"7.5d % 2", "1.5"},
+		{"0.1d + 0.2d == 0.3d", "true"},
+		{"1.10d == 1.1", "true"},
+		{"1.5d + 0.25", "1.75"},
+		{"typeof(1.5d + 0.25)", "DECIMAL"},
+		{"-0.05d", "-0.05"},
+		{"let h = {1n: 'a', 2.50d: 'b', 1.5: 'c'}\nh[1] + h[2.5] + h[1.50d]", "abc"},
+		{"'total: ' + 1.10d", "total: 1.10"},
+		{"bigint!('123456789012345678901234567890') + 1", "123456789012345678901234567891"},
+		{"bigint!(2.9d)", "2"},
+		{"bigint!(-2.9)", "-2"},
+		{"decimal!(0.1)", "0.1"},
+		{"decimal!('19.99') * 3", "59.97"},
+		{"decimal!(3)", "3"},
+		{"number!('123456789012345678901234567890')", "123456789012345678901234567890"},
+		{"1n / 0", "division by zero"},
+		{"1.0d % 0", "division by zero"},
+		{"decimal!('abc')", "casting to decimal not successful. got=abc"},
+	}
+
+	for _, tt := range tests {
+		evaluated := CheckEval(tt.input)
+		got := evaluated.Inspect()
+		if err, ok := evaluated.(*object.Error); ok {
+			got = err.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%q wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
 // Eval an input
 func CheckEval(input string) object.Object {
 	options.NicerToString = false
@@ -530,6 +778,21 @@ func CheckEval(input string) object.Object {
 	env := object.NewEnvironment()
 	return Eval(program, env)
 }
+
+// checkEvalClock evaluates input with a fake clock starting at the epoch and jumping to the timers,
+// the output is discarded
+func checkEvalClock(input string) (object.Object, *object.Environment) {
+	options.NicerToString = false
+	program := parser.New(lexer.New(input, "testEval")).ParseProgram()
+	env := object.NewEnvironment()
+	runtime := NewRuntime(options.Default(), nil, ioutil.Discard, strings.NewReader(""))
+	clock := object.NewFakeClock(time.Unix(0, 0))
+	clock.AutoAdvance = true
+	runtime.Clock = clock
+	env.SetRuntime(runtime)
+	return Eval(program, env), env
+}
+
 func CheckEvalNice(input string) object.Object {
 	options.NicerToString = true
 	l := lexer.New(input, "testEvalNice")
@@ -540,7 +803,7 @@ func CheckEvalNice(input string) object.Object {
 }
 
 // Check if integer object
-func CheckIntegerObject(t *testing.T, obj object.Object, expected float64) bool {
+func CheckIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 
 	if !ok {
@@ -548,8 +811,8 @@ func CheckIntegerObject(t *testing.T, obj object.Object, expected float64) bool
 			obj, obj)
 		return false
 	}
-	if !parser.AlmostEqual(result.Value, expected) {
-		t.Errorf("object has wrong value. got=%f, expect=%f",
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, expect=%d",
 			result.Value, expected)
 		return false
 	}
@@ -557,6 +820,20 @@ func CheckIntegerObject(t *testing.T, obj object.Object, expected float64) bool
 
 }
 
+// Check if float object
+func CheckFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not type Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if !parser.AlmostEqual(result.Value, expected) {
+		t.Errorf("object has wrong value. got=%f, expect=%f", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 // Test Eval of Booleans
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// The name the yield of a generator is stored under in the environment of its body,
+// it cannot be written in a program since yield is a keyword
+const yieldName = "yield"
+
+// applyGenerator calls a generator function, the arguments are bound by the call
+// and the body, with the defaults of the parameters, runs from the first next()
+func applyGenerator(fn *object.Function, args []object.Object, env *object.Environment) object.Object {
+	extendedEnv := ExtendFunctionEnv(fn, args)
+	return object.NewGenerator(RuntimeOf(env), fn.Name, func(yield object.Yield) object.Object {
+		extendedEnv.Store(yieldName, &object.Builtin{
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return yield(args[0])
+			},
+			Parameters: 1,
+		})
+		if err := applyDefaults(fn, args, extendedEnv); err != nil {
+			return err
+		}
+		return UnwrapReturnValue(Eval(fn.Body, extendedEnv))
+	})
+}
+
+// Eval a yield, it evaluates to the value given to the next() resuming the generator
+func EvalYieldExpression(node *ast.YieldExpression, env *object.Environment) object.Object {
+	var value object.Object = NULL
+	if node.Value != nil {
+		value = Eval(node.Value, env)
+		if CheckError(value) {
+			return value
+		}
+	}
+
+	yield, ok := env.Get(yieldName)
+	if !ok {
+		return NewFatalError(node.Token.ToTokenData(), "yield outside of a generator")
+	}
+	return yield.(*object.Builtin).Fn(node.Token, env, value)
+}
+
+// ResumeGenerator runs a generator until its next yield, see object.Generator.Resume.
+// The value is null once the generator is done
+func ResumeGenerator(token token.Token, generator *object.Generator, value object.Object) (object.Object, bool) {
+	if generator.Running() {
+		return NewFatalError(token.ToTokenData(), "generator %s is already running", object.FunctionName(generator.Name)), true
+	}
+	result, done := generator.Resume(*token.ToTokenData(), value)
+	if result == nil {
+		result = NULL
+	}
+	return result, done
+}
+
+// IteratorResult is the result of the next() of an iterator, {"value": value, "done": done}
+func IteratorResult(value object.Object, done bool) *object.Hash {
+	valueKey := &object.String{Value: "value"}
+	doneKey := &object.String{Value: "done"}
+	return &object.Hash{Pairs: map[object.HashKey]object.HashPair{
+		valueKey.HashKey(): {Key: valueKey, Value: value},
+		doneKey.HashKey():  {Key: doneKey, Value: NativeBoolToBooleanObject(done)},
+	}}
+}
+
+// generatorPrototypes are the prototype functions of generators, next() follows the iterator protocol
+func generatorPrototypes() *object.Hash {
+	methods := map[string]*object.Builtin{
+		// next(value) resumes the generator, the yield it is suspended at evaluates to the value
+		"next": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				var value object.Object = NULL
+				if len(args) > 0 {
+					value = args[0]
+				}
+				result, done := ResumeGenerator(token, thisGenerator(env), value)
+				if CheckError(result) {
+					return result
+				}
+				return IteratorResult(result, done)
+			},
+			Parameters: 1,
+			Prototype:  true,
+		},
+		// close() ends the generator, the rest of its body is not run
+		"close": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				generator := thisGenerator(env)
+				if generator.Running() {
+					return NewFatalError(token.ToTokenData(), "generator %s is already running", object.FunctionName(generator.Name))
+				}
+				generator.Close()
+				return NULL
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+		// iter() returns the generator, which is its own iterator
+		"iter": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return thisGenerator(env)
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+	}
+	return prototypeHash(methods)
+}
+
+func thisGenerator(env *object.Environment) *object.Generator {
+	this, _ := env.Get("this")
+	generator, _ := this.(*object.Generator)
+	return generator
+}
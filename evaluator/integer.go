@@ -2,118 +2,418 @@ package evaluator
 
 import (
 	"Monkey/object"
+	"Monkey/parser"
 	"Monkey/token"
+	"math"
+	"math/big"
+	"strconv"
 )
 
-var Integer = map[string]InfixFn{
-	"+": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer)
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer)
-			return &object.Integer{Value: leftVal.Value + rightVal.Value}
-		default:
-			return nil
-		}
-	},
-	"-": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer)
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer)
-			return &object.Integer{Value: leftVal.Value - rightVal.Value}
-		default:
-			return nil
-		}
-	},
-	"*": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer)
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer)
-			return &object.Integer{Value: leftVal.Value * rightVal.Value}
-		default:
-			return nil
-		}
-	},
-	"/": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer)
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer)
-			return &object.Integer{Value: leftVal.Value / rightVal.Value}
-		default:
-			return nil
-		}
-	},
-	"%": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer)
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer)
-			return &object.Integer{Value: float64(int64(leftVal.Value) % int64(rightVal.Value))}
-		default:
-			return nil
-		}
-	},
-	"<": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal < rightVal)
-		default:
-			return nil
-		}
-	},
-	"<=": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal <= rightVal)
-		default:
-			return nil
-		}
-	},
-	">": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal > rightVal)
-		default:
-			return nil
-		}
-	},
-	">=": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal >= rightVal)
-		default:
-			return nil
-		}
-	},
-	"==": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal == rightVal)
-		default:
-			return nil
-		}
-	},
-	"!=": func(token token.Token, left object.Object, right object.Object) object.Object {
-		leftVal := left.(*object.Integer).Value
-		switch right.(type) {
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Value
-			return NativeBoolToBooleanObject(leftVal != rightVal)
-		default:
-			return nil
-		}
-	},
+// The operators of numbers, both numbers are promoted to the wider of their types,
+// from integers to big integers to decimals. A float makes a float of an integer, and is converted
+// to a decimal with a big integer or a decimal so that their digits are kept, 0.1 + 0.2d is 0.3.
+// Powers with a float stay floats
+var (
+	Integer = numberOperators()
+	BigInt  = numberOperators()
+	Decimal = numberOperators()
+	Float   = numberOperators()
+)
+
+// DecimalDivisionScale is the least amount of digits kept after the point by a division of decimals
+const DecimalDivisionScale = 20
+
+func numberOperators() map[string]InfixFn {
+	operators := map[string]InfixFn{}
+	for _, operator := range []string{"+", "-", "*", "/", "%", "**", "<", "<=", ">", ">=", "==", "!=", "&", "|", "^", "<<", ">>"} {
+		operator := operator
+		operators[operator] = func(token token.Token, left object.Object, right object.Object) object.Object {
+			return EvalNumberInfixExpression(operator, left, right, token)
+		}
+	}
+	return operators
+}
+
+// IsNumber returns whether the object is an integer, a big integer, a decimal or a float
+func IsNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Decimal, *object.Float:
+		return true
+	}
+	return false
+}
+
+// isInteger returns whether the object is an integer or a big integer
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	}
+	return false
+}
+
+// toFloat returns the value of a number as a float
+func toFloat(obj object.Object) float64 {
+	switch number := obj.(type) {
+	case *object.Integer:
+		return float64(number.Value)
+	case *object.BigInt:
+		float, _ := new(big.Float).SetInt(number.Value).Float64()
+		return float
+	case *object.Decimal:
+		float, _ := number.Rat().Float64()
+		return float
+	case *object.Float:
+		return number.Value
+	}
+	return math.NaN()
+}
+
+// toBigInt returns the value of an integer or a big integer as a big integer
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+	return obj.(*object.BigInt).Value
+}
+
+// toDecimal returns the value of an integer, a big integer or a decimal as a decimal
+func toDecimal(obj object.Object) *object.Decimal {
+	if decimal, ok := obj.(*object.Decimal); ok {
+		return decimal
+	}
+	return &object.Decimal{Value: toBigInt(obj)}
+}
+
+// floatToDecimal returns the shortest digits that read back as the float, 0.1 is 0.1d.
+// It returns false for infinities and NaN
+func floatToDecimal(value float64) (*object.Decimal, bool) {
+	digits, scale, ok := parser.ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64))
+	if !ok {
+		return nil, false
+	}
+	return &object.Decimal{Value: digits, Scale: scale}, true
+}
+
+// decimalOperand returns a number as a decimal, see floatToDecimal for floats
+func decimalOperand(obj object.Object, token token.Token) (*object.Decimal, *object.Error) {
+	float, ok := obj.(*object.Float)
+	if !ok {
+		return toDecimal(obj), nil
+	}
+	decimal, ok := floatToDecimal(float.Value)
+	if !ok {
+		return nil, NewFatalError(token.ToTokenData(), "cannot convert the float %s to a decimal", float.Inspect())
+	}
+	return decimal, nil
+}
+
+// EvalNumberInfixExpression applies an operator to two numbers, it returns nil when right is not a number
+func EvalNumberInfixExpression(operator string, left object.Object, right object.Object, token token.Token) object.Object {
+	if !IsNumber(left) || !IsNumber(right) {
+		return nil
+	}
+
+	switch operator {
+	case "&", "|", "^", "<<", ">>":
+		if !isInteger(left) || !isInteger(right) {
+			return NewFatalError(token.ToTokenData(), "bitwise operators need integers. got=%s %s %s",
+				left.Type(), operator, right.Type())
+		}
+	}
+
+	isType := func(typ object.ObjectType) bool {
+		return left.Type() == typ || right.Type() == typ
+	}
+	switch {
+	case isType(object.FloatObj) && (operator == "**" || !isType(object.DecimalObj) && !isType(object.BigIntObj)):
+		return evalFloatInfix(operator, toFloat(left), toFloat(right))
+	case isType(object.DecimalObj) || isType(object.FloatObj):
+		leftDecimal, err := decimalOperand(left, token)
+		if err != nil {
+			return err
+		}
+		rightDecimal, err := decimalOperand(right, token)
+		if err != nil {
+			return err
+		}
+		return evalDecimalInfix(operator, leftDecimal, rightDecimal, token)
+	case isType(object.BigIntObj):
+		return evalBigIntInfix(operator, toBigInt(left), toBigInt(right), token)
+	}
+	return IntegerInfix(operator, left.(*object.Integer).Value, right.(*object.Integer).Value, token)
+}
+
+// IntegerInfix applies an operator to two integers,
+// integers divide without their fraction, 7 / 2 is 3, and results that overflow become big integers.
+// Negative powers are floats, 2 ** -1 is 0.5, and shifting right keeps the sign, -8 >> 1 is -4
+func IntegerInfix(operator string, left int64, right int64, token token.Token) object.Object {
+	switch operator {
+	case "+":
+		if sum := left + right; (left^sum)&(right^sum) >= 0 {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if difference := left - right; (left^right)&(left^difference) >= 0 {
+			return &object.Integer{Value: difference}
+		}
+	case "*":
+		if product, ok := multiply(left, right); ok {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if right == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		if !(left == math.MinInt64 && right == -1) {
+			return &object.Integer{Value: left / right}
+		}
+	case "%":
+		if right == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		return &object.Integer{Value: left % right}
+	case "**":
+		if right < 0 {
+			return &object.Float{Value: math.Pow(float64(left), float64(right))}
+		}
+		if power, ok := power(left, right); ok {
+			return &object.Integer{Value: power}
+		}
+	case "&":
+		return &object.Integer{Value: left & right}
+	case "|":
+		return &object.Integer{Value: left | right}
+	case "^":
+		return &object.Integer{Value: left ^ right}
+	case "<<":
+		if right < 0 {
+			return NewFatalError(token.ToTokenData(), "negative shift amount %d", right)
+		}
+		if right < 63 && (left<<uint64(right))>>uint64(right) == left {
+			return &object.Integer{Value: left << uint64(right)}
+		}
+	case ">>":
+		if right < 0 {
+			return NewFatalError(token.ToTokenData(), "negative shift amount %d", right)
+		}
+		return &object.Integer{Value: left >> uint64(right)}
+	case "<":
+		return NativeBoolToBooleanObject(left < right)
+	case "<=":
+		return NativeBoolToBooleanObject(left <= right)
+	case ">":
+		return NativeBoolToBooleanObject(left > right)
+	case ">=":
+		return NativeBoolToBooleanObject(left >= right)
+	case "==":
+		return NativeBoolToBooleanObject(left == right)
+	case "!=":
+		return NativeBoolToBooleanObject(left != right)
+	default:
+		return nil
+	}
+	return evalBigIntInfix(operator, big.NewInt(left), big.NewInt(right), token)
+}
+
+// multiply returns the product of two integers, or false when it overflows
+func multiply(left int64, right int64) (int64, bool) {
+	product := left * right
+	return product, left == 0 || product/left == right && !(left == -1 && right == math.MinInt64)
+}
+
+// power raises an integer to a power that is not negative, or returns false when it overflows
+func power(base int64, exponent int64) (int64, bool) {
+	result := int64(1)
+	for ok := true; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			if result, ok = multiply(result, base); !ok {
+				return 0, false
+			}
+		}
+		if exponent > 1 {
+			if base, ok = multiply(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// Big integers divide without their fraction like integers
+func evalBigIntInfix(operator string, left *big.Int, right *big.Int, token token.Token) object.Object {
+	switch operator {
+	case "+":
+		return &object.BigInt{Value: new(big.Int).Add(left, right)}
+	case "-":
+		return &object.BigInt{Value: new(big.Int).Sub(left, right)}
+	case "*":
+		return &object.BigInt{Value: new(big.Int).Mul(left, right)}
+	case "/":
+		if right.Sign() == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		return &object.BigInt{Value: new(big.Int).Quo(left, right)}
+	case "%":
+		if right.Sign() == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		return &object.BigInt{Value: new(big.Int).Rem(left, right)}
+	case "**":
+		if right.Sign() < 0 {
+			return evalFloatInfix(operator, toFloat(&object.BigInt{Value: left}), toFloat(&object.BigInt{Value: right}))
+		}
+		return &object.BigInt{Value: new(big.Int).Exp(left, right, nil)}
+	case "&":
+		return &object.BigInt{Value: new(big.Int).And(left, right)}
+	case "|":
+		return &object.BigInt{Value: new(big.Int).Or(left, right)}
+	case "^":
+		return &object.BigInt{Value: new(big.Int).Xor(left, right)}
+	case "<<", ">>":
+		if right.Sign() < 0 {
+			return NewFatalError(token.ToTokenData(), "negative shift amount %s", right)
+		}
+		if !right.IsUint64() || right.Uint64() > math.MaxUint32 {
+			return NewFatalError(token.ToTokenData(), "shift amount too large %s", right)
+		}
+		if operator == "<<" {
+			return &object.BigInt{Value: new(big.Int).Lsh(left, uint(right.Uint64()))}
+		}
+		return &object.BigInt{Value: new(big.Int).Rsh(left, uint(right.Uint64()))}
+	}
+	return compareNumbers(operator, left.Cmp(right))
+}
+
+// Decimals keep the digits of both sides, 1.10 + 2 is 3.10 and 1.10 * 1.10 is 1.2100,
+// divisions are rounded half to even after DecimalDivisionScale digits
+func evalDecimalInfix(operator string, left *object.Decimal, right *object.Decimal, token token.Token) object.Object {
+	if operator == "**" {
+		return powerDecimal(left, right, token)
+	}
+
+	scale := left.Scale
+	if right.Scale > scale {
+		scale = right.Scale
+	}
+	leftValue := left.Rescale(scale)
+	rightValue := right.Rescale(scale)
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: new(big.Int).Add(leftValue, rightValue), Scale: scale}
+	case "-":
+		return &object.Decimal{Value: new(big.Int).Sub(leftValue, rightValue), Scale: scale}
+	case "*":
+		return &object.Decimal{Value: new(big.Int).Mul(left.Value, right.Value), Scale: left.Scale + right.Scale}
+	case "/":
+		if rightValue.Sign() == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		return divideDecimal(leftValue, rightValue, scale)
+	case "%":
+		if rightValue.Sign() == 0 {
+			return NewFatalError(token.ToTokenData(), "division by zero")
+		}
+		return &object.Decimal{Value: new(big.Int).Rem(leftValue, rightValue), Scale: scale}
+	}
+	return compareNumbers(operator, leftValue.Cmp(rightValue))
+}
+
+// Decimals are raised to integer powers exactly, 1.1 ** 2 is 1.21, and negative powers divide
+func powerDecimal(base *object.Decimal, exponent *object.Decimal, token token.Token) object.Object {
+	power := exponent.Rat()
+	if !power.IsInt() || !power.Num().IsInt64() {
+		return NewFatalError(token.ToTokenData(), "decimal powers need an integer exponent. got=%s", exponent.Inspect())
+	}
+	n := power.Num().Int64()
+	if n >= 0 {
+		return &object.Decimal{Value: new(big.Int).Exp(base.Value, big.NewInt(n), nil), Scale: base.Scale * int(n)}
+	}
+
+	value := new(big.Int).Exp(base.Value, big.NewInt(-n), nil)
+	if value.Sign() == 0 {
+		return NewFatalError(token.ToTokenData(), "division by zero")
+	}
+	scale := base.Scale * int(-n)
+	return divideDecimal(object.Pow10(scale), value, scale)
+}
+
+// divideDecimal divides two decimals of the same scale, the zeros past that scale are dropped
+func divideDecimal(left *big.Int, right *big.Int, scale int) *object.Decimal {
+	digits := scale
+	if digits < DecimalDivisionScale {
+		digits = DecimalDivisionScale
+	}
+
+	quotient, remainder := new(big.Int).QuoRem(new(big.Int).Mul(left, object.Pow10(digits)), right, new(big.Int))
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	if cmp := half.CmpAbs(right); cmp > 0 || cmp == 0 && quotient.Bit(0) == 1 {
+		if left.Sign() == right.Sign() {
+			quotient.Add(quotient, big.NewInt(1))
+		} else {
+			quotient.Sub(quotient, big.NewInt(1))
+		}
+	}
+
+	ten := big.NewInt(10)
+	for digits > scale {
+		shorter, rest := new(big.Int).QuoRem(quotient, ten, new(big.Int))
+		if rest.Sign() != 0 {
+			break
+		}
+		quotient = shorter
+		digits--
+	}
+	return &object.Decimal{Value: quotient, Scale: digits}
+}
+
+// compareNumbers applies a comparison operator to the result of a Cmp
+func compareNumbers(operator string, cmp int) object.Object {
+	switch operator {
+	case "<":
+		return NativeBoolToBooleanObject(cmp < 0)
+	case "<=":
+		return NativeBoolToBooleanObject(cmp <= 0)
+	case ">":
+		return NativeBoolToBooleanObject(cmp > 0)
+	case ">=":
+		return NativeBoolToBooleanObject(cmp >= 0)
+	case "==":
+		return NativeBoolToBooleanObject(cmp == 0)
+	case "!=":
+		return NativeBoolToBooleanObject(cmp != 0)
+	}
+	return nil
+}
+
+func evalFloatInfix(operator string, left float64, right float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: left + right}
+	case "-":
+		return &object.Float{Value: left - right}
+	case "*":
+		return &object.Float{Value: left * right}
+	case "/":
+		return &object.Float{Value: left / right}
+	case "%":
+		return &object.Float{Value: math.Mod(left, right)}
+	case "**":
+		return &object.Float{Value: math.Pow(left, right)}
+	case "<":
+		return NativeBoolToBooleanObject(left < right)
+	case "<=":
+		return NativeBoolToBooleanObject(left <= right)
+	case ">":
+		return NativeBoolToBooleanObject(left > right)
+	case ">=":
+		return NativeBoolToBooleanObject(left >= right)
+	case "==":
+		return NativeBoolToBooleanObject(left == right)
+	case "!=":
+		return NativeBoolToBooleanObject(left != right)
+	}
+	return nil
 }
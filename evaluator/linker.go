@@ -3,36 +3,40 @@ package evaluator
 import (
 	"Monkey/ast"
 	"Monkey/object"
-	"Monkey/options"
-	"Monkey/runner"
-	"Monkey/tmp"
 	"Monkey/token"
 	"errors"
 )
 
+// ErrFatal is returned when the evaluation of a file stopped on a fatal error
+var ErrFatal = errors.New("FatalError Encountered")
+
 var std = []string{
 	"std",
 }
 
 func LinkSTD(env *object.Environment) error {
 	for _, stdLocation := range std {
-		err := LinkAndEval(stdLocation, env)
+		_, err := LinkAndEval(stdLocation, env)
 		if err != nil {
 			return err
 		}
-
-		if options.FatalErrors {
-			return errors.New("FatalError Encountered")
-		}
 	}
 
 	return nil
 }
 
 func LinkAndEvalModule(filename string, module *object.Module, token token.Token) error {
-	old := tmp.CurrentProcessingFileDirectory
-	abs := runner.GetInstance().ToAbsolute(filename)
-	program, e := runner.GetInstance().CompileAbs(abs)
+	r := RuntimeOf(module.Env).Runner
+	old := r.Directory
+	defer func() {
+		r.Directory = old
+	}()
+
+	abs := r.ToAbsolute(filename)
+	if !r.Compiling(abs) {
+		defer r.Pop(abs)
+	}
+	program, e := r.CompileAbs(abs)
 	if e != nil {
 		return e
 	}
@@ -43,21 +47,30 @@ func LinkAndEvalModule(filename string, module *object.Module, token token.Token
 	DefineMacros(program, module.Env)
 	expanded := ExpandMacros(program, module.Env)
 
-	Eval(expanded, module.Env)
-	if options.FatalErrors {
-		return errors.New("FatalError Encountered")
+	result := Run(expanded.(*ast.Program), module.Env)
+	// the import fails with an error of its own, the error of the module is logged
+	if LogError(result, module.Env) {
+		return ErrFatal
 	}
-	runner.GetInstance().Pop(abs)
-	tmp.CurrentProcessingFileDirectory = old
 	return nil
 }
 
-func LinkAndEval(filename string, env *object.Environment) error {
-	old := tmp.CurrentProcessingFileDirectory
-	abs := runner.GetInstance().ToAbsolute(filename)
-	program, e := runner.GetInstance().CompileAbs(abs)
+// LinkAndEval evaluates a file into env, expanding its includes and macros
+// It returns the value of the last statement
+func LinkAndEval(filename string, env *object.Environment) (object.Object, error) {
+	r := RuntimeOf(env).Runner
+	old := r.Directory
+	defer func() {
+		r.Directory = old
+	}()
+
+	abs := r.ToAbsolute(filename)
+	if !r.Compiling(abs) {
+		defer r.Pop(abs)
+	}
+	program, e := r.CompileAbs(abs)
 	if e != nil {
-		return e
+		return nil, e
 	}
 
 	included := ExpandInclude(program, env)
@@ -65,13 +78,11 @@ func LinkAndEval(filename string, env *object.Environment) error {
 	DefineMacros(included.(*ast.Program), env)
 	expanded := ExpandMacros(included, env)
 
-	Eval(expanded, env)
-	if options.FatalErrors {
-		return errors.New("FatalError Encountered")
+	result := Run(expanded.(*ast.Program), env)
+	if CheckError(result) {
+		return result, ErrFatal
 	}
-	runner.GetInstance().Pop(abs)
-	tmp.CurrentProcessingFileDirectory = old
-	return nil
+	return result, nil
 }
 
 func ExpandInclude(program ast.Node, env *object.Environment) ast.Node {
@@ -97,7 +108,7 @@ func ExpandInclude(program ast.Node, env *object.Environment) ast.Node {
 		if !ok {
 			return node
 		}
-		err := LinkAndEval(filename.Value, env)
+		_, err := LinkAndEval(filename.Value, env)
 		if err != nil {
 			return node
 		}
@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+	"sort"
+)
+
+// Eval a while loop, it evaluates to null
+func EvalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if CheckError(condition) {
+			return condition
+		}
+		if !IsTruthful(condition) {
+			return NULL
+		}
+
+		if result, done := loopResult(Eval(node.Body, env), node.Label); done {
+			return result
+		}
+	}
+}
+
+// Eval a c style for loop, it evaluates to null
+func EvalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	if node.Init != nil {
+		init := Eval(node.Init, env)
+		if CheckError(init) {
+			return init
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition := Eval(node.Condition, env)
+			if CheckError(condition) {
+				return condition
+			}
+			if !IsTruthful(condition) {
+				return NULL
+			}
+		}
+
+		if result, done := loopResult(Eval(node.Body, env), node.Label); done {
+			return result
+		}
+
+		if node.Step != nil {
+			step := Eval(node.Step, env)
+			if CheckError(step) {
+				return step
+			}
+		}
+	}
+}
+
+// Eval a for in loop, the variable is stored in the environment of the loop.
+// The iterator is closed when a break or a return leaves the loop before its end
+func EvalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if CheckError(iterable) {
+		return iterable
+	}
+	iterator, err := Iterate(node.Token, iterable, env)
+	if err != nil {
+		return err
+	}
+
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			return NULL
+		}
+		if CheckError(value) {
+			return value
+		}
+		env.Store(node.Variable.Value, value)
+
+		if result, done := loopResult(Eval(node.Body, env), node.Label); done {
+			if !CheckError(result) {
+				CloseIterator(iterator)
+			}
+			return result
+		}
+	}
+}
+
+// loopResult decides what an iteration of the loop labeled label does with the result of its body,
+// done is true when the loop stops and evaluates to result
+func loopResult(result object.Object, label string) (object.Object, bool) {
+	switch result := result.(type) {
+	case *object.ReturnValue:
+		return result, true
+	case *object.LoopControl:
+		// Jumps to an outer loop leave this one first
+		if result.Label != "" && result.Label != label {
+			return result, true
+		}
+		if result.Continue {
+			return nil, false
+		}
+		return NULL, true
+	}
+	if CheckError(result) {
+		return result, true
+	}
+	return nil, false
+}
+
+// Iterate returns the elements of an iterable: the elements of an array, the characters of a string,
+// the keys of a hash, the values yielded by a generator, or the values given by the next() of the iterator
+// returned by its iter(). Next() returns {"value": value, "done": false} until it returns {"done": true},
+// or returns the values themselves until it returns break
+func Iterate(token token.Token, iterable object.Object, env *object.Environment) (*object.Iterator, *object.Error) {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		// The array is read as it is walked, so that the loop sees the elements it pushes
+		index := 0
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if index >= len(iterable.Elements) {
+				return nil, false
+			}
+			index++
+			return iterable.Elements[index-1], true
+		}}, nil
+	case *object.String:
+		runes := []rune(iterable.Value)
+		index := 0
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			if index >= len(runes) {
+				return nil, false
+			}
+			index++
+			return &object.String{Value: string(runes[index-1])}, true
+		}}, nil
+	case *object.Hash:
+		if _, ok := iterable.Pairs[(&object.String{Value: "iter"}).HashKey()]; !ok {
+			return iterateKeys(iterable), nil
+		}
+	case *object.Generator:
+		return &object.Iterator{
+			Next: func() (object.Object, bool) {
+				value, done := ResumeGenerator(token, iterable, NULL)
+				if CheckError(value) {
+					return value, true
+				}
+				return value, !done
+			},
+			Close: iterable.Close,
+		}, nil
+	case *object.Channel:
+		return &object.Iterator{Next: func() (object.Object, bool) {
+			value, closed := ReceiveChannel(token, iterable, env)
+			if CheckError(value) {
+				return value, true
+			}
+			return value, !closed
+		}}, nil
+	}
+
+	iter := EvalMember(token, iterable, "iter", env)
+	if CheckError(iter) {
+		return nil, NewFatalError(token.ToTokenData(), "value is not iterable: %s", iterable.Type())
+	}
+	iterator := ApplyFunction(token, iter, []object.Object{}, env)
+	if CheckError(iterator) {
+		return nil, iterator.(*object.Error)
+	}
+	if generator, ok := iterator.(*object.Generator); ok {
+		return Iterate(token, generator, env)
+	}
+
+	return &object.Iterator{
+		Next: func() (object.Object, bool) {
+			next := EvalMember(token, iterator, "next", env)
+			if CheckError(next) {
+				return next, true
+			}
+			value := ApplyFunction(token, next, []object.Object{}, env)
+			if value.Type() == object.BreakObj {
+				return nil, false
+			}
+			if CheckError(value) {
+				return value, true
+			}
+			return iteratorValue(value)
+		},
+		// an iterator with a close() is told when it is left before its end
+		Close: func() {
+			if HasMember(iterator, "close", env) {
+				ApplyFunction(token, EvalMember(token, iterator, "close", env), []object.Object{}, env)
+			}
+		},
+	}, nil
+}
+
+// iteratorValue reads the result of the next() of an iterator, a hash with a "done" key
+// is a {"value": value, "done": done} result and other values are the values themselves
+func iteratorValue(result object.Object) (object.Object, bool) {
+	hash, ok := result.(*object.Hash)
+	if !ok {
+		return result, true
+	}
+	done, ok := hash.Pairs[(&object.String{Value: "done"}).HashKey()]
+	if !ok {
+		return result, true
+	}
+	if IsTruthful(done.Value) {
+		return nil, false
+	}
+	if value, ok := hash.Pairs[(&object.String{Value: "value"}).HashKey()]; ok {
+		return value.Value, true
+	}
+	return NULL, true
+}
+
+// CloseIterator releases an iterator left before its end
+func CloseIterator(iterator *object.Iterator) {
+	if iterator.Close != nil {
+		iterator.Close()
+	}
+}
+
+// iterateKeys walks the keys of a hash, in the order of their printed values so that loops are repeatable
+func iterateKeys(hash *object.Hash) *object.Iterator {
+	keys := make([]object.Object, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		keys = append(keys, pair.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Inspect() < keys[j].Inspect()
+	})
+
+	index := 0
+	return &object.Iterator{Next: func() (object.Object, bool) {
+		if index >= len(keys) {
+			return nil, false
+		}
+		index++
+		return keys[index-1], true
+	}}
+}
@@ -32,7 +32,7 @@ func DefineMacros(program *ast.Program, env *object.Environment) {
 func isMacroDefinition(node ast.Statement) bool {
 	// is statement
 	letStatement, ok := node.(*ast.LetStatement)
-	if !ok {
+	if !ok || letStatement.Name == nil {
 		return false
 	}
 
@@ -100,6 +100,11 @@ unless(10 > 5, write("not"), write("greater"))
 `,
 			`if (!(10 > 5)) { write("not") } else { write("greater") }`,
 		},
+		{
+			`let show = macro(exp) { quote("value: ${unquote(exp)}") }
+				show(1 + 2)`,
+			`"value: ${1 + 2}"`,
+		},
 		//		{
 		//			`let ifnot$ = macro(cond, consq, alt) {
 		//    quote(if (!(unquote(cond))) {
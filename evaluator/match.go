@@ -0,0 +1,162 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// Eval a match expression, the body of the first arm whose pattern matches the value and whose guard is truthy
+func EvalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if CheckError(value) {
+		return value
+	}
+
+	for _, arm := range node.Arms {
+		values := evalMatchValues(arm.Pattern, env)
+		if len(values) == 1 && CheckError(values[0]) {
+			return values[0]
+		}
+
+		bound, ok := MatchPattern(arm.Token, arm.Pattern, value, values)
+		if !ok {
+			continue
+		}
+		bindings := ast.MatchBindings(arm.Pattern)
+
+		// The guard sees the bindings in an environment of its own,
+		// an arm rejected by its guard leaves the variables of env as they were
+		if arm.Guard != nil {
+			guardEnv := object.NewEnclosingEnvironment(env)
+			for i, ident := range bindings {
+				guardEnv.Store(ident.Value, bound[i])
+			}
+			guard := Eval(arm.Guard, guardEnv)
+			if CheckError(guard) {
+				return guard
+			}
+			if !IsTruthful(guard) {
+				continue
+			}
+		}
+		for i, ident := range bindings {
+			env.Store(ident.Value, bound[i])
+		}
+		return Eval(arm.Body, env)
+	}
+
+	return NoMatchError(node.Token, value)
+}
+
+// evalMatchValues evaluates the values of ast.MatchValues, a possible type pattern that is not bound is null
+func evalMatchValues(pattern ast.Expression, env *object.Environment) []object.Object {
+	var values []object.Object
+	for _, exp := range ast.MatchValues(pattern) {
+		if ident, ok := exp.(*ast.Identifier); ok {
+			value, ok := env.Get(ident.Value)
+			if !ok {
+				value = NULL
+			}
+			values = append(values, value)
+			continue
+		}
+		value := Eval(exp, env)
+		if CheckError(value) {
+			return []object.Object{value}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// NoMatchError is the error of a match expression without an arm matching the value
+func NoMatchError(token token.Token, value object.Object) *object.Error {
+	return NewFatalError(token.ToTokenData(), "no match arm for %s", value.Inspect())
+}
+
+// MatchPattern matches a value with the pattern of a match arm, values are the values of the literals and of the
+// possible type patterns of the pattern in the order of ast.MatchValues. It returns whether the value matches,
+// with the values of the bindings in the order of ast.MatchBindings
+func MatchPattern(token token.Token, pattern ast.Expression, value object.Object, values []object.Object) ([]object.Object, bool) {
+	m := &matcher{token: token, values: values}
+	if !m.match(pattern, value) {
+		return nil, false
+	}
+	return m.bound, true
+}
+
+// matcher walks a pattern, taking the values of its literals and collecting its bindings
+type matcher struct {
+	token  token.Token
+	values []object.Object
+	bound  []object.Object
+}
+
+// next returns the value of the next literal or type pattern
+func (m *matcher) next() object.Object {
+	value := m.values[0]
+	m.values = m.values[1:]
+	return value
+}
+
+func (m *matcher) bind(ident *ast.Identifier, value object.Object) {
+	if ident.Value != ast.Wildcard {
+		m.bound = append(m.bound, value)
+	}
+}
+
+func (m *matcher) match(pattern ast.Expression, value object.Object) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if !ast.IsTypePattern(pattern) {
+			m.bind(pattern, value)
+			return true
+		}
+		name, ok := m.next().(*object.String)
+		if !ok || !object.IsTypeName(name.Value) {
+			m.bind(pattern, value)
+			return true
+		}
+		// a type pattern binds the type name again, its variable keeps its value
+		m.bind(pattern, name)
+		return string(value.Type()) == name.Value
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) < len(pattern.Elements) {
+			return false
+		}
+		if pattern.Rest == nil && len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !m.match(el.Target, array.Elements[i]) {
+				return false
+			}
+		}
+		if pattern.Rest != nil {
+			m.bind(pattern.Rest, PatternRest(m.token, array, len(pattern.Elements)))
+		}
+		return true
+
+	case *ast.HashPattern:
+		switch value.(type) {
+		case *object.Hash, *object.Module:
+		default:
+			return false
+		}
+		for _, el := range pattern.Elements {
+			part := PatternKey(m.token, value, el.Key, false)
+			if CheckError(part) || !m.match(el.Target, part) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// The values of literals are compared with ==, values of different types are not equal
+	expected := m.next()
+	equal := EvalOperatorExpression(m.token, "==", value, expected)
+	return !CheckError(equal) && IsTruthful(equal)
+}
@@ -0,0 +1,146 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// Destructure stores the parts of value in the identifiers of a pattern, it returns an error when the value
+// does not have the shape of the pattern, or nil
+func Destructure(pattern ast.Expression, value object.Object, env *object.Environment) object.Object {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		env.Store(pattern.Value, value)
+
+	case *ast.ArrayPattern:
+		value = PatternIterable(pattern.Token, value, len(pattern.Elements), pattern.Rest != nil, env)
+		if CheckError(value) {
+			return value
+		}
+		for i, el := range pattern.Elements {
+			part := PatternElement(pattern.Token, value, i, el.Default != nil)
+			if err := destructureElement(el, part, env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := PatternRest(pattern.Token, value, len(pattern.Elements))
+			if CheckError(rest) {
+				return rest
+			}
+			env.Store(pattern.Rest.Value, rest)
+		}
+
+	case *ast.HashPattern:
+		for _, el := range pattern.Elements {
+			part := PatternKey(pattern.Token, value, el.Key, el.Default != nil)
+			if err := destructureElement(el, part, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// destructureElement destructures a part of a value, or the default of the element when the part is null
+func destructureElement(el *ast.PatternElement, value object.Object, env *object.Environment) object.Object {
+	if CheckError(value) {
+		return value
+	}
+	if value == NULL && el.Default != nil {
+		value = Eval(el.Default, env)
+		if CheckError(value) {
+			return value
+		}
+	}
+	return Destructure(el.Target, value, env)
+}
+
+// PatternElement returns the element at index of an array destructured by an array pattern.
+// A missing element is null when the pattern has a default for it
+func PatternElement(token token.Token, value object.Object, index int, hasDefault bool) object.Object {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return NewFatalError(token.ToTokenData(), "cannot destructure %s with an array pattern", value.Type())
+	}
+	if index < len(array.Elements) {
+		return array.Elements[index]
+	}
+	if hasDefault {
+		return NULL
+	}
+	return NewFatalError(token.ToTokenData(), "cannot destructure the element %d of an array of length %d", index, len(array.Elements))
+}
+
+// PatternIterable takes the elements of an iterable destructured by an array pattern into an array,
+// they are iterated like by a for in loop. It takes as many elements as the pattern has, or all of them for
+// a pattern with a ...rest, and closes the iterator. Arrays and the values that are not iterable are returned as they are
+func PatternIterable(token token.Token, value object.Object, count int, rest bool, env *object.Environment) object.Object {
+	switch value.(type) {
+	case *object.Array:
+		return value
+	case *object.String, *object.Hash, *object.Generator, *object.Channel:
+	default:
+		if !HasMember(value, "iter", env) {
+			return value
+		}
+	}
+
+	iterator, err := Iterate(token, value, env)
+	if err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for rest || len(elements) < count {
+		element, ok := iterator.Next()
+		if !ok {
+			return &object.Array{Elements: elements}
+		}
+		if CheckError(element) {
+			return element
+		}
+		elements = append(elements, element)
+	}
+	CloseIterator(iterator)
+	return &object.Array{Elements: elements}
+}
+
+// PatternRest returns a new array with the elements of an array from start, for the ...rest of an array pattern
+func PatternRest(token token.Token, value object.Object, start int) object.Object {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return NewFatalError(token.ToTokenData(), "cannot destructure %s with an array pattern", value.Type())
+	}
+	elements := []object.Object{}
+	if start < len(array.Elements) {
+		elements = append(elements, array.Elements[start:]...)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// PatternKey returns the value of a key of a hash or a module destructured by a hash pattern.
+// A missing key is null when the pattern has a default for it
+func PatternKey(token token.Token, value object.Object, key string, hasDefault bool) object.Object {
+	var result object.Object
+	switch value := value.(type) {
+	case *object.Hash:
+		if pair, ok := value.Pairs[(&object.String{Value: key}).HashKey()]; ok {
+			result = pair.Value
+		}
+	case *object.Module:
+		if val, ok := value.Env.Get(key); ok {
+			result = val
+		}
+	default:
+		return NewFatalError(token.ToTokenData(), "cannot destructure %s with a hash pattern", value.Type())
+	}
+
+	if result != nil {
+		return result
+	}
+	if hasDefault {
+		return NULL
+	}
+	return NewFatalError(token.ToTokenData(), "cannot destructure the missing key %q", key)
+}
@@ -3,10 +3,35 @@ package evaluator
 import (
 	"Monkey/object"
 	"Monkey/token"
+	"unicode/utf8"
 )
 
+// The builtin prototypes, every runtime gets its own copy through NewPrototypes
 var prototypes map[object.ObjectType]*object.Hash
 
+// NewPrototypes copies the builtin prototypes so that a runtime can modify them
+func NewPrototypes() map[object.ObjectType]*object.Hash {
+	result := make(map[object.ObjectType]*object.Hash, len(prototypes))
+	for typ, hash := range prototypes {
+		pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+		for key, pair := range hash.Pairs {
+			pairs[key] = pair
+		}
+		result[typ] = &object.Hash{Pairs: pairs}
+	}
+	return result
+}
+
+// prototypeHash creates the prototypes of a type from its methods
+func prototypeHash(methods map[string]*object.Builtin) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair, len(methods))
+	for name, method := range methods {
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: method}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
 type keyHashKeyPair struct {
 	keys map[string]*object.String
 }
@@ -31,10 +56,16 @@ func init() {
 	khkp := NewKHKP()
 	khkp.AddKey("double")
 	khkp.AddKey("length")
+	khkp.AddKey("bytes")
 	khkp.AddKey("keys")
 	khkp.AddKey("values")
 	khkp.AddKey("push")
 	khkp.AddKey("pop")
+	khkp.AddKey("message")
+	khkp.AddKey("stack")
+	khkp.AddKey("file")
+	khkp.AddKey("line")
+	khkp.AddKey("data")
 	prototypes = map[object.ObjectType]*object.Hash{
 		object.IntegerObj: {
 			Pairs: map[object.HashKey]object.HashPair{
@@ -55,6 +86,25 @@ func init() {
 				},
 			},
 		},
+		object.FloatObj: {
+			Pairs: map[object.HashKey]object.HashPair{
+				khkp.GetKeyHash("double"): {
+					Key: khkp.GetKey("double"),
+					Value: &object.Builtin{
+						Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+							self, _ := env.Get("this")
+							float, _ := self.(*object.Float)
+							float.Value *= 2
+							return float
+						},
+						Parameters: 0,
+						VarArgs:    false,
+						Prototype:  true,
+						Eval:       true,
+					},
+				},
+			},
+		},
 		object.StringObj: {
 			Pairs: map[object.HashKey]object.HashPair{
 				khkp.GetKeyHash("length"): {
@@ -63,7 +113,21 @@ func init() {
 						Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
 							self, _ := env.Get("this")
 							str, _ := self.(*object.String)
-							return &object.Integer{Value: float64(len(str.Value))}
+							return &object.Integer{Value: int64(utf8.RuneCountInString(str.Value))}
+						},
+						Parameters: 0,
+						VarArgs:    false,
+						Prototype:  true,
+						Eval:       true,
+					},
+				},
+				khkp.GetKeyHash("bytes"): {
+					Key: khkp.GetKey("bytes"),
+					Value: &object.Builtin{
+						Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+							self, _ := env.Get("this")
+							str, _ := self.(*object.String)
+							return StringBytes(str)
 						},
 						Parameters: 0,
 						VarArgs:    false,
@@ -81,7 +145,7 @@ func init() {
 						Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
 							self, _ := env.Get("this")
 							array, _ := self.(*object.Array)
-							return &object.Integer{Value: float64(len(array.Elements))}
+							return &object.Integer{Value: int64(len(array.Elements))}
 						},
 						Parameters: 0,
 						VarArgs:    false,
@@ -200,7 +264,7 @@ func init() {
 						Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
 							self, _ := env.Get("this")
 							hash, _ := self.(*object.Hash)
-							return &object.Integer{Value: float64(len(hash.Pairs))}
+							return &object.Integer{Value: int64(len(hash.Pairs))}
 						},
 						Parameters: 0,
 						VarArgs:    false,
@@ -257,5 +321,67 @@ func init() {
 				},
 			},
 		},
+		object.ErrorObj: {
+			Pairs: map[object.HashKey]object.HashPair{
+				khkp.GetKeyHash("message"): {
+					Key: khkp.GetKey("message"),
+					Value: errorProperty(func(err *object.Error) object.Object {
+						return &object.String{Value: err.Message}
+					}),
+				},
+				khkp.GetKeyHash("stack"): {
+					Key: khkp.GetKey("stack"),
+					Value: errorProperty(func(err *object.Error) object.Object {
+						return &object.String{Value: err.Traceback()}
+					}),
+				},
+				khkp.GetKeyHash("file"): {
+					Key: khkp.GetKey("file"),
+					Value: errorProperty(func(err *object.Error) object.Object {
+						if err.TokenData == nil {
+							return NULL
+						}
+						return &object.String{Value: err.Filename}
+					}),
+				},
+				khkp.GetKeyHash("line"): {
+					Key: khkp.GetKey("line"),
+					Value: errorProperty(func(err *object.Error) object.Object {
+						if err.TokenData == nil {
+							return NULL
+						}
+						return &object.Integer{Value: int64(err.RowNumber)}
+					}),
+				},
+				khkp.GetKeyHash("data"): {
+					Key: khkp.GetKey("data"),
+					Value: errorProperty(func(err *object.Error) object.Object {
+						if err.Data == nil {
+							return NULL
+						}
+						return err.Data
+					}),
+				},
+			},
+		},
+		object.GeneratorObj: generatorPrototypes(),
+		object.TaskObj:      taskPrototypes(),
+		object.ChannelObj:   channelPrototypes(),
+		object.PromiseObj:   promisePrototypes(),
+	}
+}
+
+// errorProperty creates a prototype reading a field of the error
+func errorProperty(get func(err *object.Error) object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+			self, _ := env.Get("this")
+			err, _ := self.(*object.Error)
+			return get(err)
+		},
+		Parameters: 0,
+		VarArgs:    false,
+		Prototype:  true,
+		Eval:       true,
 	}
 }
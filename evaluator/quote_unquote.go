@@ -3,7 +3,6 @@ package evaluator
 import (
 	"Monkey/ast"
 	"Monkey/object"
-	"Monkey/parser"
 	"Monkey/token"
 )
 
@@ -22,7 +21,8 @@ func EvalQuote(token token.Token, arguments []ast.Expression, environment *objec
 		return NewFatalError(token.ToTokenData(), "quote only takes one argument. got=%d", len(arguments))
 	}
 
-	argument := evalUnquoteCalls(arguments[0], environment)
+	// Copy the node so that the unquote calls of a macro body are kept for the next expansion
+	argument := evalUnquoteCalls(ast.Clone(arguments[0]), environment)
 
 	return &object.Quote{
 		Node: argument,
@@ -30,6 +30,37 @@ func EvalQuote(token token.Token, arguments []ast.Expression, environment *objec
 }
 
 func evalUnquoteCalls(quoted ast.Node, environment *object.Environment) ast.Node {
+	return modifyUnquoteCalls(quoted, func(call *ast.CallExpression) ast.Node {
+		unquoted := Eval(call.Arguments[0], environment)
+		return convertObjectToASTNode(unquoted, call.Token)
+	})
+}
+
+// UnquoteCalls returns the unquote calls of a quoted node in the order they are replaced,
+// the vm evaluates their arguments before SpliceUnquotes
+func UnquoteCalls(quoted ast.Node) []*ast.CallExpression {
+	var calls []*ast.CallExpression
+	modifyUnquoteCalls(ast.Clone(quoted), func(call *ast.CallExpression) ast.Node {
+		calls = append(calls, call)
+		return call
+	})
+	return calls
+}
+
+// SpliceUnquotes quotes a copy of quoted, its unquote calls replaced by the values of their arguments
+// in the order of UnquoteCalls
+func SpliceUnquotes(quoted ast.Node, values []object.Object) *object.Quote {
+	i := 0
+	node := modifyUnquoteCalls(ast.Clone(quoted), func(call *ast.CallExpression) ast.Node {
+		value := values[i]
+		i++
+		return convertObjectToASTNode(value, call.Token)
+	})
+	return &object.Quote{Node: node}
+}
+
+// modifyUnquoteCalls replaces the unquote calls taking one argument with the result of modifier
+func modifyUnquoteCalls(quoted ast.Node, modifier func(call *ast.CallExpression) ast.Node) ast.Node {
 	return ast.Modify(quoted, func(node ast.Node) ast.Node {
 		if !isUnquoteCall(node) {
 			return node
@@ -43,8 +74,7 @@ func evalUnquoteCalls(quoted ast.Node, environment *object.Environment) ast.Node
 			return node
 		}
 
-		unquoted := Eval(call.Arguments[0], environment)
-		return convertObjectToASTNode(unquoted, call.Token)
+		return modifier(call)
 	})
 }
 
@@ -53,7 +83,7 @@ func convertObjectToASTNode(unquoted object.Object, t token.Token) ast.Node {
 	case *object.Integer:
 		tmpt := token.NewToken(
 			token.INT,
-			parser.FormatFloat(obj.Value),
+			obj.Inspect(),
 			t.ToTokenData(),
 		)
 		//t := token.Token{
@@ -65,6 +95,18 @@ func convertObjectToASTNode(unquoted object.Object, t token.Token) ast.Node {
 		//}
 		return &ast.IntegerLiteral{Token: tmpt, Value: obj.Value}
 
+	case *object.Float:
+		tmpt := token.NewToken(token.FLOAT, obj.Inspect(), t.ToTokenData())
+		return &ast.FloatLiteral{Token: tmpt, Value: obj.Value}
+
+	case *object.BigInt:
+		tmpt := token.NewToken(token.BIGINT, obj.Inspect()+"n", t.ToTokenData())
+		return &ast.BigIntLiteral{Token: tmpt, Value: obj.Value}
+
+	case *object.Decimal:
+		tmpt := token.NewToken(token.DECIMAL, obj.Inspect()+"d", t.ToTokenData())
+		return &ast.DecimalLiteral{Token: tmpt, Value: obj.Value, Scale: obj.Scale}
+
 	case *object.Boolean:
 		var tmpt token.Token
 		if obj.Value {
@@ -0,0 +1,50 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/options"
+	"Monkey/runner"
+	"Monkey/tmp"
+	"bufio"
+	"io"
+	"os"
+)
+
+// NewRuntime creates the state of a new interpreter, with its own copy of the prototypes
+func NewRuntime(opts *options.Options, r *runner.Runner, stdout io.Writer, stdin io.Reader) *object.Runtime {
+	return &object.Runtime{
+		Options:    opts,
+		Runner:     r,
+		Prototypes: NewPrototypes(),
+		Stdout:     stdout,
+		Stderr:     os.Stderr,
+		Stdin:      bufio.NewReader(stdin),
+	}
+}
+
+// NewDefaultRuntime creates a runtime using the process directories and standard IO
+func NewDefaultRuntime() *object.Runtime {
+	opts := options.Default()
+	r := runner.New(tmp.CurrentDirectory, []string{tmp.STDDirectory}, opts, os.Stdout)
+	return NewRuntime(opts, r, os.Stdout, os.Stdin)
+}
+
+// RuntimeOf returns the runtime of an environment,
+// environments created without one get a default runtime on first use
+func RuntimeOf(env *object.Environment) *object.Runtime {
+	runtime := env.Runtime()
+	if runtime == nil {
+		runtime = NewDefaultRuntime()
+		env.SetRuntime(runtime)
+	}
+	return runtime
+}
+
+// Run evaluates a program with the engine of the runtime, the tree walking evaluator by default
+func Run(program *ast.Program, env *object.Environment) object.Object {
+	if engine := RuntimeOf(env).Engine; engine != nil {
+		return engine.Run(program, env)
+	}
+	return Eval(program, env)
+}
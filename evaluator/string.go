@@ -6,6 +6,37 @@ import (
 	"strings"
 )
 
+// Stringify converts an object into the text it has inside strings, errors are their message
+func Stringify(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return obj.Value
+	case *object.Error:
+		return obj.Message
+	default:
+		return obj.Inspect()
+	}
+}
+
+// StringBytes returns the UTF-8 bytes of a string as an array of integers,
+// strings are otherwise measured, indexed and sliced by code points
+func StringBytes(str *object.String) *object.Array {
+	elements := make([]object.Object, len(str.Value))
+	for i := 0; i < len(str.Value); i++ {
+		elements[i] = &object.Integer{Value: int64(str.Value[i])}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// Interpolate joins the evaluated parts of an interpolated string
+func Interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(Stringify(part))
+	}
+	return &object.String{Value: out.String()}
+}
+
 var String = map[string]InfixFn{
 	"+": func(token token.Token, left object.Object, right object.Object) object.Object {
 		leftVal := left.(*object.String).Value
@@ -13,8 +44,8 @@ var String = map[string]InfixFn{
 		case *object.String:
 			rightVal := right.(*object.String).Value
 			return &object.String{Value: leftVal + rightVal}
-		case *object.Integer:
-			rightVal := right.(*object.Integer).Inspect()
+		case *object.Integer, *object.BigInt, *object.Decimal, *object.Float:
+			rightVal := right.Inspect()
 			return &object.String{Value: leftVal + rightVal}
 		default:
 			return nil
@@ -0,0 +1,289 @@
+package evaluator
+
+import (
+	"Monkey/ast"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// DeadlockError is the error of a task waiting while every other task waits too
+func DeadlockError(token token.Token) *object.Error {
+	return NewFatalError(token.ToTokenData(), "deadlock, every task is waiting")
+}
+
+// SpawnTask calls a function with args in a new task, see object.Task.
+// The task runs once the running task waits, and shares the variables the function closes over.
+// An error of the task nobody waits for is reported once the event loop drains
+func SpawnTask(token token.Token, function object.Object, args []object.Object, env *object.Environment) object.Object {
+	name, ok := functionName(function)
+	if !ok {
+		return ArgumentNotSupported("spawn", function.Type(), token)
+	}
+
+	task := &object.Task{Name: name}
+	startTask(task, env, func() object.Object {
+		result := ApplyFunction(token, function, args, env)
+		if CheckError(result) && !task.Handled() {
+			runtime := RuntimeOf(env)
+			runtime.Unhandled = append(runtime.Unhandled, task)
+		}
+		return result
+	})
+	return task
+}
+
+// startTask runs run in a task, with a fork of the machine of the running task
+func startTask(task *object.Task, env *object.Environment, run func() object.Object) {
+	runtime := RuntimeOf(env)
+	var machine object.Machine
+	if runtime.Machine != nil {
+		machine = runtime.Machine.Fork()
+	}
+	runtime.Spawn(task, machine, run)
+}
+
+// functionName returns the name of a function that can run in a task, false for the values that cannot be called
+func functionName(function object.Object) (string, bool) {
+	switch fn := function.(type) {
+	case *object.Function:
+		return fn.Name, true
+	case *object.Closure:
+		return fn.Fn.Name, true
+	case *object.Builtin, *object.PrototypeFunction:
+		return "", true
+	}
+	return "", false
+}
+
+// WaitTask waits for the function of a task to return, and returns its result, its error is then handled
+func WaitTask(token token.Token, task *object.Task, env *object.Environment) object.Object {
+	task.Handle()
+	if !RuntimeOf(env).Wait(task.Done) {
+		return DeadlockError(token)
+	}
+	return task.Result()
+}
+
+// SendChannel sends a value on a channel, waiting while it cannot take it
+func SendChannel(token token.Token, channel *object.Channel, value object.Object, env *object.Environment) object.Object {
+	runtime := RuntimeOf(env)
+	if !runtime.Wait(channel.CanSend) {
+		return DeadlockError(token)
+	}
+	if channel.Closed() {
+		return NewFatalError(token.ToTokenData(), "send on a closed channel")
+	}
+	channel.Send(value)
+	runtime.Notify()
+	return NULL
+}
+
+// ReceiveChannel receives a value from a channel, waiting until there is one.
+// It returns null and true once the channel is closed and empty
+func ReceiveChannel(token token.Token, channel *object.Channel, env *object.Environment) (object.Object, bool) {
+	runtime := RuntimeOf(env)
+	if !channel.CanReceive() {
+		channel.Receiving(1)
+		runtime.Notify()
+		ok := runtime.Wait(channel.CanReceive)
+		channel.Receiving(-1)
+		if !ok {
+			return DeadlockError(token), true
+		}
+	}
+
+	value, ok := channel.Receive()
+	if !ok {
+		return NULL, true
+	}
+	runtime.Notify()
+	return value, false
+}
+
+// CloseChannel closes a channel, the tasks waiting to receive from it get null
+func CloseChannel(token token.Token, channel *object.Channel, env *object.Environment) object.Object {
+	if !channel.Close() {
+		return NewFatalError(token.ToTokenData(), "close of a closed channel")
+	}
+	RuntimeOf(env).Notify()
+	return NULL
+}
+
+// Eval a select expression, the channels and the values sent are evaluated before waiting for an arm
+func EvalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.Object {
+	operands := EvalExpressions(ast.SelectOperands(node), env)
+	if len(operands) == 1 && CheckError(operands[0]) {
+		return operands[0]
+	}
+
+	index, value, err := Select(node, operands, env)
+	if err != nil {
+		return err
+	}
+	arm := node.Arms[index]
+	if arm.Name != nil {
+		env.Store(arm.Name.Value, value)
+	}
+	return Eval(arm.Body, env)
+}
+
+// Select runs the operation of the first arm of a select that can run, waiting until one can
+// when the select has no _ arm. The operands are the values of ast.SelectOperands.
+// It returns the index of the arm that ran, with the value it received
+func Select(node *ast.SelectExpression, operands []object.Object, env *object.Environment) (int, object.Object, *object.Error) {
+	channels := make([]*object.Channel, len(node.Arms))
+	values := make([]object.Object, len(node.Arms))
+	fallback := -1
+	receives := false
+	for i, arm := range node.Arms {
+		if arm.Channel == nil {
+			fallback = i
+			continue
+		}
+
+		channel, ok := operands[0].(*object.Channel)
+		if !ok {
+			return 0, nil, NewFatalError(arm.Token.ToTokenData(), "select on %s, expected a channel", operands[0].Type())
+		}
+		channels[i] = channel
+		operands = operands[1:]
+		if arm.Send {
+			values[i] = operands[0]
+			operands = operands[1:]
+		} else {
+			receives = true
+		}
+	}
+
+	chosen := -1
+	ready := func() bool {
+		for i, arm := range node.Arms {
+			channel := channels[i]
+			if channel != nil && (arm.Send && channel.CanSend() || !arm.Send && channel.CanReceive()) {
+				chosen = i
+				return true
+			}
+		}
+		return false
+	}
+
+	if !ready() {
+		if fallback >= 0 {
+			return fallback, NULL, nil
+		}
+
+		runtime := RuntimeOf(env)
+		receiving(node, channels, 1)
+		if receives {
+			runtime.Notify()
+		}
+		ok := runtime.Wait(ready)
+		receiving(node, channels, -1)
+		if !ok {
+			return 0, nil, DeadlockError(node.Token)
+		}
+	}
+
+	arm := node.Arms[chosen]
+	if arm.Send {
+		if err, ok := SendChannel(arm.Token, channels[chosen], values[chosen], env).(*object.Error); ok {
+			return 0, nil, err
+		}
+		return chosen, NULL, nil
+	}
+	value, _ := ReceiveChannel(arm.Token, channels[chosen], env)
+	return chosen, value, nil
+}
+
+// receiving marks the task as waiting on the receives of a select
+func receiving(node *ast.SelectExpression, channels []*object.Channel, delta int) {
+	for i, arm := range node.Arms {
+		if channels[i] != nil && !arm.Send {
+			channels[i].Receiving(delta)
+		}
+	}
+}
+
+// taskPrototypes are the prototype functions of tasks
+func taskPrototypes() *object.Hash {
+	methods := map[string]*object.Builtin{
+		// wait() waits for the task and returns its result, an error of the task is thrown again
+		"wait": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return WaitTask(token, thisTask(env), env)
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+		// join() waits for the task and returns its result, an error of the task is returned as a value
+		"join": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				result := WaitTask(token, thisTask(env), env)
+				if err, ok := result.(*object.Error); ok && err.Fatal && thisTask(env).Done() {
+					value := *err
+					value.Fatal = false
+					return &value
+				}
+				return result
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+		// done() returns whether the task has returned
+		"done": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return NativeBoolToBooleanObject(thisTask(env).Done())
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+	}
+	return prototypeHash(methods)
+}
+
+// channelPrototypes are the prototype functions of channels, a for in loop receives until the channel is closed
+func channelPrototypes() *object.Hash {
+	methods := map[string]*object.Builtin{
+		// send(value) sends the value, waiting while the channel is full
+		"send": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return WrongArgumentsAmount("send", len(args), "1", token)
+				}
+				return SendChannel(token, thisChannel(env), args[0], env)
+			},
+			Parameters: 1,
+			Prototype:  true,
+		},
+		// recv() receives a value, waiting until there is one, it is null once the channel is closed and empty
+		"recv": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				value, _ := ReceiveChannel(token, thisChannel(env), env)
+				return value
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+		// close() closes the channel, nothing can be sent on it anymore
+		"close": {
+			Fn: func(token token.Token, env *object.Environment, args ...object.Object) object.Object {
+				return CloseChannel(token, thisChannel(env), env)
+			},
+			Parameters: 0,
+			Prototype:  true,
+		},
+	}
+	return prototypeHash(methods)
+}
+
+func thisTask(env *object.Environment) *object.Task {
+	this, _ := env.Get("this")
+	task, _ := this.(*object.Task)
+	return task
+}
+
+func thisChannel(env *object.Environment) *object.Channel {
+	this, _ := env.Get("this")
+	channel, _ := this.(*object.Channel)
+	return channel
+}
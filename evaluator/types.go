@@ -11,6 +11,9 @@ type InfixObj map[string]InfixFn
 
 var InfixMap = map[object.ObjectType]InfixObj{
 	object.IntegerObj: Integer,
+	object.BigIntObj:  BigInt,
+	object.DecimalObj: Decimal,
+	object.FloatObj:   Float,
 	object.StringObj:  String,
 	object.ArrayObj:   Array,
 }
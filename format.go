@@ -0,0 +1,121 @@
+package main
+
+import (
+	"Monkey/formatter"
+	"Monkey/parser"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runFmt formats the files and directories in args, or the standard input when there are none,
+// it returns the exit code
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the files instead of printing it")
+	check := flags.Bool("check", false, "list the files that are not formatted and fail if there are any")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey fmt [-w] [-check] [files or directories]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		source, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		formatted, err := formatter.Format(string(source), "<stdin>")
+		if err != nil {
+			reportFormatError(stderr, err, string(source))
+			return 1
+		}
+		if *check {
+			if formatted != string(source) {
+				fmt.Fprintln(stdout, "<stdin>")
+				return 1
+			}
+			return 0
+		}
+		fmt.Fprint(stdout, formatted)
+		return 0
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	code := 0
+	for _, filename := range files {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		source := string(content)
+		formatted, err := formatter.Format(source, filename)
+		if err != nil {
+			reportFormatError(stderr, err, source)
+			code = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != source {
+				fmt.Fprintln(stdout, filename)
+				code = 1
+			}
+		case *write:
+			if formatted != source {
+				if err := ioutil.WriteFile(filename, []byte(formatted), 0644); err != nil {
+					fmt.Fprintln(stderr, err)
+					code = 1
+				}
+			}
+		default:
+			fmt.Fprint(stdout, formatted)
+		}
+	}
+	return code
+}
+
+// sourceFiles lists the files in args, directories are searched for .mky files
+// and files are kept whatever their name
+func sourceFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && (path == arg || strings.HasSuffix(path, ".mky")) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func reportFormatError(stderr io.Writer, err error, source string) {
+	if errors, ok := err.(parser.ParseErrors); ok {
+		parser.RenderText(stderr, errors, source)
+		return
+	}
+	fmt.Fprintln(stderr, err)
+}
@@ -0,0 +1,329 @@
+package formatter
+
+import (
+	"Monkey/lexer"
+	"Monkey/parser"
+	"Monkey/token"
+	"strings"
+)
+
+// The indentation of one level
+const Indent = "    "
+
+// The most empty lines kept between two lines
+const maxEmptyLines = 1
+
+// Format prints a source in the canonical style, keeping its comments and line breaks,
+// it returns the parse errors when the source does not parse
+func Format(source string, filename string) (string, error) {
+	p := parser.New(lexer.New(source, filename))
+	p.ParseProgram()
+	if p.HasError() {
+		return "", parser.ParseErrors(p.Errors())
+	}
+
+	var tokens []token.Token
+	l := lexer.NewWithTrivia(source, filename)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.WHITESPACE {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	pr := &printer{braces: classifyBraces(tokens), questions: map[int]int{}}
+	for i, tok := range tokens {
+		switch tok.Type {
+		case token.NEWLINE:
+			pr.lineBreak(tok)
+		case token.COMMENT:
+			pr.comment(tok)
+		default:
+			pr.token(i, tok)
+		}
+	}
+
+	result := strings.TrimRight(pr.out.String(), "\n")
+	if result == "" {
+		return "", nil
+	}
+	return result + "\n", nil
+}
+
+// brace describes the block or the hash literal opened by a {
+type brace struct {
+	hash bool
+	// whether the hash literal spans many lines, it is then printed a pair per line
+	expand bool
+}
+
+// classifyBraces tells the blocks and the hash literals apart, by the index of their {
+func classifyBraces(tokens []token.Token) map[int]*brace {
+	braces := map[int]*brace{}
+	var open []*brace
+
+	var prev *token.Token
+	for i := range tokens {
+		tok := &tokens[i]
+		switch tok.Type {
+		case token.LBRACE:
+			// A { after a value or a keyword starts a block, else it is a hash literal
+			b := &brace{hash: true}
+			if prev != nil && (isOperand(prev) || opensBlock(prev.Type)) {
+				b.hash = false
+			}
+			braces[i] = b
+			open = append(open, b)
+		case token.RBRACE:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+		case token.NEWLINE, token.COMMENT:
+			if tok.Type == token.COMMENT && !strings.HasPrefix(tok.Literal, "//") && !strings.Contains(tok.Literal, "\n") {
+				break
+			}
+			for _, b := range open {
+				b.expand = b.hash
+			}
+		}
+		if tok.Type != token.COMMENT {
+			prev = tok
+		}
+	}
+	return braces
+}
+
+// isOperand returns whether a token ends a value
+func isOperand(tok *token.Token) bool {
+	switch tok.Type {
+	case token.IDENT, token.INT, token.FLOAT, token.BIGINT, token.DECIMAL, token.STRING, token.TEMPLATE, token.TRUE, token.FALSE, token.NULL, token.BREAK,
+		token.RPAREN, token.RBRACKET, token.RBRACE, token.INCREMENT, token.DECREMENT:
+		return true
+	}
+	return false
+}
+
+func opensBlock(typ token.TokenType) bool {
+	switch typ {
+	case token.ELSE, token.TRY, token.CATCH, token.FINALLY, token.MODULE, token.HASH, token.FAT_ARROW:
+		return true
+	}
+	return false
+}
+
+func isOpening(typ token.TokenType) bool {
+	return typ == token.LPAREN || typ == token.LBRACKET || typ == token.LBRACE
+}
+
+func isClosing(typ token.TokenType) bool {
+	return typ == token.RPAREN || typ == token.RBRACKET || typ == token.RBRACE
+}
+
+// frame is an open bracket
+type frame struct {
+	open token.TokenType
+	brace
+	// whether a line breaks inside the bracket, its content is then indented
+	multiline bool
+}
+
+type printer struct {
+	out    strings.Builder
+	braces map[int]*brace
+	frames []*frame
+
+	// the last token printed, comments aside
+	prev *token.Token
+	// whether prev is a prefix operator
+	unary bool
+	// whether prev is the * of fn*
+	star bool
+	// whether a block comment was printed after prev
+	block bool
+	// whether prev is the : of a ternary, which is spaced unlike those of hashes and slices
+	ternary bool
+	// the ? of the ternaries waiting for their :, by bracket depth
+	questions map[int]int
+
+	// the line breaks before the next token
+	newlines int
+	// whether the next token must start a line
+	force bool
+}
+
+func (pr *printer) top() *frame {
+	if len(pr.frames) == 0 {
+		return nil
+	}
+	return pr.frames[len(pr.frames)-1]
+}
+
+func (pr *printer) lineBreak(tok token.Token) {
+	n := strings.Count(tok.Literal, "\n")
+	if n == 0 {
+		n = strings.Count(tok.Literal, "\r")
+	}
+	if n > pr.newlines {
+		pr.newlines = n
+	}
+	if f := pr.top(); f != nil {
+		f.multiline = true
+	}
+}
+
+func (pr *printer) comment(tok token.Token) {
+	space := pr.prev != nil
+	if strings.HasPrefix(tok.Literal, "/*") && space {
+		space = pr.prev.Type != token.LPAREN && pr.prev.Type != token.LBRACKET
+	}
+	pr.write(strings.TrimRight(tok.Literal, " \t"), space, false)
+	if strings.HasPrefix(tok.Literal, "/*") {
+		pr.block = true
+		// a block comment over lines ends the line
+		if strings.Contains(tok.Literal, "\n") {
+			pr.newlines = 1
+		}
+	}
+}
+
+func (pr *printer) token(i int, tok token.Token) {
+	var closed *frame
+	if isClosing(tok.Type) && len(pr.frames) > 0 {
+		closed = pr.top()
+		delete(pr.questions, len(pr.frames))
+		pr.frames = pr.frames[:len(pr.frames)-1]
+		if closed.expand {
+			pr.force = true
+		}
+	}
+	if pr.force {
+		pr.force = false
+		if pr.newlines == 0 {
+			pr.newlines = 1
+		}
+	}
+
+	ternary := tok.Type == token.COLON && pr.questions[len(pr.frames)] > 0
+	if ternary {
+		pr.questions[len(pr.frames)]--
+	} else if tok.Type == token.QUESTION {
+		pr.questions[len(pr.frames)]++
+	}
+
+	// ++ and -- starting a line are prefix operators, the line is a new statement
+	unary := isUnary(tok.Type) && (pr.prev == nil || !isOperand(pr.prev) ||
+		pr.newlines > 0 && (tok.Type == token.INCREMENT || tok.Type == token.DECREMENT))
+	space := pr.spaceBefore(tok, closed) || ternary
+	if pr.block && tok.Type != token.COMMA && tok.Type != token.SEMICOLON && tok.Type != token.COLON {
+		space = true
+	}
+	pr.write(tok.Literal, space, closed != nil)
+	pr.block = false
+	pr.ternary = ternary
+	pr.unary = unary
+	pr.star = tok.Type == token.ASTERISK && pr.prev != nil && pr.prev.Type == token.FUNCTION
+	pr.prev = &tok
+
+	if isOpening(tok.Type) {
+		f := &frame{open: tok.Type}
+		if b, ok := pr.braces[i]; ok {
+			f.brace = *b
+		}
+		// Hash literals spanning many lines get a pair per line
+		if f.expand {
+			f.multiline = true
+			pr.force = true
+		}
+		pr.frames = append(pr.frames, f)
+	}
+	if f := pr.top(); tok.Type == token.COMMA && f != nil && f.expand {
+		pr.force = true
+	}
+}
+
+// write prints text at the start of a new line or after a space
+func (pr *printer) write(text string, space bool, closing bool) {
+	if pr.newlines > 0 && pr.out.Len() > 0 {
+		n := pr.newlines
+		// no empty lines after an opening bracket or before a closing one
+		if n > maxEmptyLines+1 {
+			n = maxEmptyLines + 1
+		}
+		if closing || pr.prev != nil && isOpening(pr.prev.Type) {
+			n = 1
+		}
+		pr.out.WriteString(strings.Repeat("\n", n))
+		pr.out.WriteString(strings.Repeat(Indent, pr.indent()))
+	} else if space && pr.out.Len() > 0 {
+		pr.out.WriteByte(' ')
+	}
+	pr.newlines = 0
+	pr.out.WriteString(text)
+}
+
+// indent returns the indentation of a new line, a level for every bracket broken over lines
+func (pr *printer) indent() int {
+	indent := 0
+	for _, f := range pr.frames {
+		if f.multiline {
+			indent++
+		}
+	}
+	return indent
+}
+
+// spaceBefore returns whether a space separates tok from the previous token on its line,
+// closed is the bracket tok closes
+func (pr *printer) spaceBefore(tok token.Token, closed *frame) bool {
+	prev := pr.prev
+	if prev == nil {
+		return false
+	}
+
+	switch tok.Type {
+	case token.COMMA, token.SEMICOLON, token.COLON, token.DOT, token.OPTIONAL_DOT, token.RPAREN, token.RBRACKET:
+		return false
+	case token.RBRACE:
+		// blocks are padded, { x }, hash literals are not
+		return prev.Type != token.LBRACE && (closed == nil || !closed.hash)
+	case token.LPAREN:
+		// calls
+		if isOperand(prev) || prev.Type == token.FUNCTION || prev.Type == token.MACRO || pr.star {
+			return false
+		}
+	case token.ASTERISK:
+		// generator functions, fn*()
+		if prev.Type == token.FUNCTION {
+			return false
+		}
+	case token.LBRACKET:
+		// indexes
+		if isOperand(prev) {
+			return false
+		}
+	case token.INCREMENT, token.DECREMENT:
+		// x++
+		if isOperand(prev) {
+			return false
+		}
+	}
+
+	switch prev.Type {
+	case token.LPAREN, token.LBRACKET, token.DOT, token.OPTIONAL_DOT, token.HASH, token.BIT_NOT, token.ELLIPSIS:
+		return false
+	case token.LBRACE:
+		f := pr.top()
+		return f == nil || !f.hash
+	case token.COLON:
+		// slices are not spaced, a[1:2]
+		f := pr.top()
+		return f == nil || f.open != token.LBRACKET || pr.ternary
+	case token.MINUS, token.BANG, token.INCREMENT, token.DECREMENT:
+		return !pr.unary
+	}
+	return true
+}
+
+// isUnary returns whether an operator is a prefix operator when it does not follow an operand
+func isUnary(typ token.TokenType) bool {
+	return typ == token.MINUS || typ == token.BANG || typ == token.INCREMENT || typ == token.DECREMENT
+}
@@ -0,0 +1,114 @@
+package formatter
+
+import (
+	"Monkey/lexer"
+	"Monkey/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let   x=1+2*3", "let x = 1 + 2 * 3\n"},
+		{"let f = fn (a,b){a-b}", "let f = fn(a, b) { a - b }\n"},
+		{"f( -1, !true )[0]", "f(-1, !true)[0]\n"},
+		{"a[1 : 2]", "a[1:2]\n"},
+		{"let h = { 'a' : 1,'b':[1,2] }", "let h = {'a': 1, 'b': [1, 2]}\n"},
+		{"if (x) {\n\t\ty\n} else {\nz\n}", "if (x) {\n    y\n} else {\n    z\n}\n"},
+		{"let x = 1  // one\n\n\n\n// two\nx", "let x = 1 // one\n\n// two\nx\n"},
+		{"let h = {'a': 1,\n  'b': 2, 'c': {'d': 3}}", "let h = {\n    'a': 1,\n    'b': 2,\n    'c': {'d': 3}\n}\n"},
+		{"for(let i = 0;i < 3;i = i+1){\n\n  i\n\n}", "for (let i = 0; i < 3; i = i + 1) {\n    i\n}\n"},
+		{"outer:for x in xs {\nwhile true{ continue outer }\n}", "outer: for x in xs {\n    while true { continue outer }\n}\n"},
+		{"list.map() fn(x){\nx&&y\n}", "list.map() fn(x) {\n    x && y\n}\n"},
+		{"foo(1,\n2)", "foo(1,\n    2)\n"},
+		{"'a\\'b'  ;", "'a\\'b';\n"},
+		{"let s = \"${ a+b } and ${f( x )}\"", "let s = \"${ a+b } and ${f( x )}\"\n"},
+		{"let q = \"\"\"\n  a  \n\"\"\"  +`b\n`", "let q = \"\"\"\n  a  \n\"\"\" + `b\n`\n"},
+		{"let h = {'a': 1 /* one */,'b':f( /* x */ 2)}", "let h = {'a': 1 /* one */, 'b': f(/* x */ 2)}\n"},
+		{"/* head\n * more\n */\n///  doc\nlet a = 1 /* a\n b */ let b = 2", "/* head\n * more\n */\n///  doc\nlet a = 1 /* a\n b */\nlet b = 2\n"},
+		{"let m=~x&0xFF|1<<4", "let m = ~x & 0xFF | 1 << 4\n"},
+		{"x **= 2", "x **= 2\n"},
+		{"let [a,b=1,...rest]=xs", "let [a, b = 1, ...rest] = xs\n"},
+		{"let f=fn(a,b=1,...rest){f(...rest,b:a)}", "let f = fn(a, b = 1, ...rest) { f(...rest, b: a) }\n"},
+		{"let f=fn({name,age:years}){name}", "let f = fn({name, age: years}) { name }\n"},
+		{"match x{\n0=>'zero'\n[a,...b] if a>1=>{\na\n}\n}", "match x {\n    0 => 'zero'\n    [a, ...b] if a > 1 => {\n        a\n    }\n}\n"},
+		{"let x=c ?a:b??d\nh?.a?.[1]?.(2).d\nx[c ? 1:2]", "let x = c ? a : b ?? d\nh?.a?.[1]?.(2).d\nx[c ? 1 : 2]\n"},
+		{"let f=(a,b)=>{\na+b\n}\nxs|>map(x=>x*2)|>sum", "let f = (a, b) => {\n    a + b\n}\nxs |> map(x => x * 2) |> sum\n"},
+		{"let g=fn *(x){\nyield x\nlet y=yield\n}", "let g = fn*(x) {\n    yield x\n    let y = yield\n}\n"},
+		{"a ++\n-- a\nlet b=a++ +--a-1\nh.n [0]--", "a++\n--a\nlet b = a++ + --a - 1\nh.n[0]--\n"},
+		{"let f=async  fn(a){\nawait  sleep(1)\n}\nlet g=async x=>await f(x)", "let f = async fn(a) {\n    await sleep(1)\n}\nlet g = async x => await f(x)\n"},
+		{"let r=select{\nv=a.recv()=>{\nv\n}\nb.send( 1 )=>null\n_=>0\n}", "let r = select {\n    v = a.recv() => {\n        v\n    }\n    b.send(1) => null\n    _ => 0\n}\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Format(tt.input, "TestFormat")
+		if err != nil {
+			t.Errorf("Format(%q) failed. got=%v", tt.input, err)
+			continue
+		}
+		if formatted != tt.expected {
+			t.Errorf("wrong format of %q.\nwant=%q\ngot= %q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestFormatParseError(t *testing.T) {
+	if _, err := Format("let = 1", "TestFormatParseError"); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
+
+// The formatter only changes the layout, and formatting twice changes nothing
+func TestFormatSources(t *testing.T) {
+	var files []string
+	for _, dir := range []string{"../lib", "../examples"} {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && strings.HasSuffix(path, ".mky") {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	if len(files) == 0 {
+		t.Fatalf("no sources found")
+	}
+
+	for _, filename := range files {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		formatted, err := Format(string(content), filename)
+		if err != nil {
+			t.Errorf("Format(%s) failed. got=%v", filename, err)
+			continue
+		}
+
+		if want, got := significantTokens(string(content)), significantTokens(formatted); want != got {
+			t.Errorf("formatting %s changed its tokens.\nwant=%s\ngot= %s", filename, want, got)
+		}
+		if again, _ := Format(formatted, filename); again != formatted {
+			t.Errorf("formatting %s is not idempotent.\nfirst=%q\nagain=%q", filename, formatted, again)
+		}
+	}
+}
+
+func significantTokens(source string) string {
+	var literals []string
+	l := lexer.NewWithTrivia(source, "significantTokens")
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.WHITESPACE, token.NEWLINE:
+		default:
+			literals = append(literals, tok.Literal)
+		}
+	}
+	return strings.Join(literals, " ")
+}
@@ -3,19 +3,33 @@ package lexer
 import (
 	"Monkey/token"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Lexer Struct
 type Lexer struct {
 	input        string // UTF-8 Input
-	position     int    // Current Pointer Position
-	readPosition int    // One after Current Pointer Position
+	position     int    // Byte offset of the current character
+	readPosition int    // Byte offset after the current character
 	ch           rune   // Current character
 
 	currentRow    int64
 	currentColumn int64
 
 	currentFile string
+
+	// Whether comments and whitespace are returned as tokens
+	trivia bool
+
+	// The errors found since they were last taken
+	errors []Error
+
+	// The lines of the /// doc comment read last and the row of its last line
+	doc    []string
+	docRow int64
+	// Whether the last block comment skipped a line break
+	brokenLine bool
 }
 
 // Create a new Lexer Struct
@@ -32,6 +46,33 @@ func New(input string, filename string) *Lexer {
 	return l
 }
 
+// NewAt creates a lexer for a source that starts at the row and column of another file,
+// such as the expressions inside a string
+func NewAt(input string, filename string, row int64, column int64) *Lexer {
+	l := &Lexer{
+		input:         input,
+		currentColumn: column - 1,
+		currentRow:    row,
+		currentFile:   filename,
+	}
+	l.ReadChar()
+
+	return l
+}
+
+// NewWithTrivia creates a lossless lexer for tools, it returns comments and whitespace
+// as tokens and the literal of every token is its source, so that joining them gives back the input
+func NewWithTrivia(input string, filename string) *Lexer {
+	l := New(input, filename)
+	l.trivia = true
+	return l
+}
+
+// Input returns the source being read
+func (l *Lexer) Input() string {
+	return l.input
+}
+
 // Read next Character and advance pointer
 func (l *Lexer) ReadChar() {
 
@@ -44,17 +85,26 @@ func (l *Lexer) ReadChar() {
 	}
 
 	// If overflows
+	size := 1
 	if l.readPosition >= len(l.input) {
 		// Set nil
 		l.ch = 0
 	} else {
-		// Else set the character on the current readPosition
-		l.ch = []rune(l.input)[l.readPosition]
+		// Else decode the character on the current readPosition
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	// Increase Pointers
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
+}
+
+// source returns the input from start to the end of the current character
+func (l *Lexer) source(start int) string {
+	if l.readPosition > len(l.input) {
+		return l.input[start:]
+	}
+	return l.input[start:l.readPosition]
 }
 
 // Deprecated
@@ -73,12 +123,46 @@ func (l *Lexer) PeekToken() token.Token {
 	return tok
 }
 
-// TODO: Goroutine
+// NextToken reads the next token, a /// doc comment right above it is kept in its Doc
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	if l.doc != nil && tok.Type != token.NEWLINE {
+		if tok.RowNumber == l.docRow+1 {
+			tok.Doc = strings.Join(l.doc, "\n")
+		}
+		l.doc = nil
+	}
+	return tok
+}
+
+// TODO: Goroutine
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
+	if l.trivia {
+		if tok, ok := l.readTrivia(); ok {
+			return tok
+		}
+	}
+
 	// Strip all the whitespace until a valid character is find
 	l.SkipWhitespace()
+	start := l.position
+	startRow, startColumn := l.currentRow, l.currentColumn
+
+	// A block comment over many lines ends the line like a line break
+	if l.brokenLine {
+		l.brokenLine = false
+		if l.ch != '\n' && l.ch != '\r' {
+			return token.Token{
+				Type:         token.NEWLINE,
+				Literal:      "\n",
+				RowNumber:    startRow,
+				ColumnNumber: startColumn,
+				Filename:     l.currentFile,
+			}
+		}
+	}
 
 	switch l.ch {
 	case '=':
@@ -94,6 +178,10 @@ func (l *Lexer) NextToken() token.Token {
 
 			// Set Literal
 			tok.Literal = string(ch) + string(l.ch)
+		} else if l.PeekChar() == '>' {
+			tok = NewToken(token.FAT_ARROW, 0)
+			l.ReadChar()
+			tok.Literal = "=>"
 		} else {
 			tok = NewToken(token.ASSIGN, l.ch)
 		}
@@ -112,19 +200,41 @@ func (l *Lexer) NextToken() token.Token {
 			l.ReadChar()
 		}
 	case '.':
-		tok = NewToken(token.DOT, l.ch)
+		if strings.HasPrefix(l.input[l.readPosition:], "..") {
+			l.ReadChar()
+			l.ReadChar()
+			tok = NewToken(token.ELLIPSIS, 0)
+			tok.Literal = token.ELLIPSIS
+		} else {
+			tok = NewToken(token.DOT, l.ch)
+		}
 	case '+':
-		tok = NewToken(token.PLUS, l.ch)
+		if l.PeekChar() == '+' {
+			l.ReadChar()
+			tok = token.Token{Type: token.INCREMENT, Literal: token.INCREMENT}
+		} else {
+			tok = l.operator(token.PLUS, token.PLUS_ASSIGN)
+		}
 	case '-':
-		tok = NewToken(token.MINUS, l.ch)
+		if l.PeekChar() == '-' {
+			l.ReadChar()
+			tok = token.Token{Type: token.DECREMENT, Literal: token.DECREMENT}
+		} else {
+			tok = l.operator(token.MINUS, token.MINUS_ASSIGN)
+		}
 	case '*':
-		tok = NewToken(token.ASTERISK, l.ch)
+		if l.PeekChar() == '*' {
+			l.ReadChar()
+			tok = l.operator(token.POWER, token.POWER_ASSIGN)
+		} else {
+			tok = l.operator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
 	case '/':
-		tok = NewToken(token.SLASH, l.ch)
+		tok = l.operator(token.SLASH, token.SLASH_ASSIGN)
 	case '#':
 		tok = NewToken(token.HASH, l.ch)
 	case '%':
-		tok = NewToken(token.PERCENT, l.ch)
+		tok = l.operator(token.PERCENT, token.PERCENT_ASSIGN)
 
 	case '&':
 		if l.PeekChar() == '&' {
@@ -135,7 +245,7 @@ func (l *Lexer) NextToken() token.Token {
 			// Advance Pointer
 			l.ReadChar()
 		} else {
-			tok = NewToken(token.ILLEGAL, l.ch)
+			tok = l.operator(token.BIT_AND, token.BIT_AND_ASSIGN)
 		}
 	case '|':
 		if l.PeekChar() == '|' {
@@ -145,9 +255,16 @@ func (l *Lexer) NextToken() token.Token {
 
 			// Advance Pointer
 			l.ReadChar()
+		} else if l.PeekChar() == '>' {
+			l.ReadChar()
+			tok = token.Token{Type: token.PIPE, Literal: token.PIPE}
 		} else {
-			tok = NewToken(token.ILLEGAL, l.ch)
+			tok = l.operator(token.BIT_OR, token.BIT_OR_ASSIGN)
 		}
+	case '^':
+		tok = l.operator(token.BIT_XOR, token.BIT_XOR_ASSIGN)
+	case '~':
+		tok = NewToken(token.BIT_NOT, l.ch)
 
 	case '!':
 		if l.PeekChar() == '=' {
@@ -181,6 +298,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.ReadChar()
 
 			tok.Literal = string(ch) + string(l.ch)
+		} else if l.PeekChar() == '<' {
+			l.ReadChar()
+			tok = l.operator(token.SHIFT_LEFT, token.SHIFT_LEFT_ASSIGN)
 		} else {
 			tok = NewToken(token.LT, l.ch)
 		}
@@ -193,15 +313,14 @@ func (l *Lexer) NextToken() token.Token {
 			l.ReadChar()
 
 			tok.Literal = string(ch) + string(l.ch)
+		} else if l.PeekChar() == '>' {
+			l.ReadChar()
+			tok = l.operator(token.SHIFT_RIGHT, token.SHIFT_RIGHT_ASSIGN)
 		} else {
 			tok = NewToken(token.GT, l.ch)
 		}
-	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.ReadString('"')
-	case '\'':
-		tok.Type = token.STRING
-		tok.Literal = l.ReadString('\'')
+	case '"', '\'', '`':
+		tok.Type, tok.Literal = l.ReadString()
 	case ';':
 		tok = NewToken(token.SEMICOLON, l.ch)
 	case ':':
@@ -223,8 +342,16 @@ func (l *Lexer) NextToken() token.Token {
 	case 0:
 		tok = NewToken(token.EOF, 0)
 	default:
-		// If is Text
-		if IsLetter(l.ch) {
+		// ? is a letter of identifiers like null?, but an identifier never starts with it
+		if l.ch == '?' && l.PeekChar() == '?' {
+			l.ReadChar()
+			tok = l.operator(token.NULLISH, token.NULLISH_ASSIGN)
+		} else if l.ch == '?' && l.PeekChar() == '.' {
+			l.ReadChar()
+			tok = token.Token{Type: token.OPTIONAL_DOT, Literal: token.OPTIONAL_DOT}
+		} else if l.ch == '?' {
+			tok = NewToken(token.QUESTION, l.ch)
+		} else if IsLetter(l.ch) {
 			tok.ColumnNumber = l.currentColumn
 			tok.RowNumber = l.currentRow
 			tok.Filename = l.currentFile
@@ -240,9 +367,16 @@ func (l *Lexer) NextToken() token.Token {
 			tok.RowNumber = l.currentRow
 			tok.Filename = l.currentFile
 
-			// Set Integer Type and Value
-			tok.Type = token.INT
-			tok.Literal = l.ReadNumber()
+			// Numbers with a fraction or an exponent are floats, the n and d suffixes make big integers and decimals
+			tok.Type, tok.Literal = l.ReadNumber()
+			if tok.Type == token.INT && l.ReadSuffix('n') {
+				tok.Literal += "n"
+				tok.Type = token.BIGINT
+			} else if l.ReadSuffix('d') {
+				tok.Literal += "d"
+				tok.Type = token.DECIMAL
+			}
+			tok.Literal = l.ReadInvalidSuffix(tok.Literal, tok.RowNumber, tok.ColumnNumber)
 			return tok
 		} else {
 			// Else return illegal character
@@ -255,12 +389,70 @@ func (l *Lexer) NextToken() token.Token {
 	tok.RowNumber = l.currentRow
 	tok.Filename = l.currentFile
 
+	// Strings are placed at their opening quote, so that the expressions inside them can be placed
+	if tok.Type == token.STRING || tok.Type == token.TEMPLATE {
+		tok.RowNumber, tok.ColumnNumber = startRow, startColumn
+	}
+
+	if l.trivia {
+		tok.Literal = l.input[start:l.position]
+		if tok.Type != token.EOF {
+			tok.Literal = l.source(start)
+		}
+	}
+
 	// Advance Pointer
 	l.ReadChar()
 
 	return tok
 }
 
+// readTrivia reads the whitespace or the comment at the current position
+func (l *Lexer) readTrivia() (token.Token, bool) {
+	tok := token.Token{
+		RowNumber:    l.currentRow,
+		ColumnNumber: l.currentColumn,
+		Filename:     l.currentFile,
+	}
+	start := l.position
+
+	switch {
+	case l.ch == ' ' || l.ch == '\t':
+		tok.Type = token.WHITESPACE
+		for l.ch == ' ' || l.ch == '\t' {
+			l.ReadChar()
+		}
+	case l.ch == '/' && l.PeekChar() == '/':
+		tok.Type = token.COMMENT
+		l.SkipLine()
+	case l.ch == '/' && l.PeekChar() == '*':
+		tok.Type = token.COMMENT
+		l.SkipBlockComment()
+	default:
+		return tok, false
+	}
+
+	tok.Literal = l.input[start:l.position]
+	return tok, true
+}
+
+// operator returns the operator ending at the current character, or its compound assignment when a = follows
+func (l *Lexer) operator(typ token.TokenType, compound token.TokenType) token.Token {
+	if l.PeekChar() == '=' {
+		l.ReadChar()
+		return token.Token{Type: compound, Literal: string(compound)}
+	}
+	return token.Token{Type: typ, Literal: string(typ)}
+}
+
+// Fork returns a copy of the lexer to read tokens ahead, the lexer does not read them again
+func (l *Lexer) Fork() *Lexer {
+	fork := *l
+	fork.errors = nil
+	fork.doc = nil
+	return &fork
+}
+
 // Create a new Token
 func NewToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
@@ -274,8 +466,11 @@ func (l *Lexer) ReadIdentifier() string {
 	// Cache Starting position
 	position := l.position
 
-	// Consume until the next rune is not a valid letter
+	// Consume until the next rune is not a valid letter, a ? that is an operator ends the identifier
 	for IsLetter(l.ch) {
+		if l.ch == '?' && l.questionIsOperator() {
+			break
+		}
 		l.ReadChar()
 	}
 
@@ -283,10 +478,40 @@ func (l *Lexer) ReadIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// If a rune is a valid letter
+// questionIsOperator returns whether the ? at the current character is an operator after an identifier,
+// it starts ?. or ?? or an operand follows it: x?1:2 and x? a : b are conditionals, null?(x) and let null? = y are names
+func (l *Lexer) questionIsOperator() bool {
+	rest := l.input[l.readPosition:]
+	next, _ := utf8.DecodeRuneInString(rest)
+	if next == '.' || next == '?' || startsOperand(next) {
+		return true
+	}
+
+	// After a space, an opening bracket or a prefix operator starts an operand too
+	trimmed := strings.TrimLeft(rest, " \t")
+	if len(trimmed) == len(rest) {
+		return false
+	}
+	next, size := utf8.DecodeRuneInString(trimmed)
+	switch next {
+	case '(', '[', '{':
+		return true
+	case '!', '-', '~':
+		return !strings.HasPrefix(trimmed[size:], "=")
+	}
+	return startsOperand(next)
+}
+
+// startsOperand returns whether an identifier, a number or a string starts with ch
+func startsOperand(ch rune) bool {
+	return isSuffixLetter(ch) || IsDigit(ch) || ch == '"' || ch == '\'' || ch == '`'
+}
+
+// If a rune is a valid letter, letters of every script are allowed
 func IsLetter(ch rune) bool {
-	// [a-zA-Z_\?]
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?' || ch == '!' || ch == '$'
+	// [\p{L}_\?!$]
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?' || ch == '!' || ch == '$' ||
+		ch > unicode.MaxASCII && unicode.IsLetter(ch)
 }
 
 // Eats up the entire line
@@ -305,9 +530,17 @@ func (l *Lexer) SkipWhitespace() {
 			continue
 		}
 		if l.ch == '/' && l.PeekChar() == '/' {
-			l.ReadChar()
-			l.ReadChar()
+			start, row := l.position, l.currentRow
 			l.SkipLine()
+			l.readDoc(l.input[start:l.position], row)
+			continue
+		}
+		if l.ch == '/' && l.PeekChar() == '*' {
+			row := l.currentRow
+			l.SkipBlockComment()
+			if l.currentRow != row {
+				l.brokenLine = true
+			}
 			continue
 		}
 
@@ -315,25 +548,51 @@ func (l *Lexer) SkipWhitespace() {
 	}
 }
 
-// Read an integer number and advance the pointer
-func (l *Lexer) ReadNumber() string {
-	// Cache Position
-	position := l.position
-
-	// Advance until it is not a number
-	for IsDigit(l.ch) {
-		l.ReadChar()
+// readDoc keeps the text of a /// doc comment line, the lines of a doc comment follow each other
+func (l *Lexer) readDoc(comment string, row int64) {
+	if !strings.HasPrefix(comment, "///") || strings.HasPrefix(comment, "////") {
+		return
 	}
+	if l.doc == nil || row != l.docRow+1 {
+		l.doc = []string{}
+	}
+	text := strings.TrimPrefix(strings.TrimRight(comment, " \t"), "///")
+	l.doc = append(l.doc, strings.TrimPrefix(text, " "))
+	l.docRow = row
+}
 
-	if l.ch == '.' && IsDigit(l.PeekChar()) {
-		l.ReadChar()
-		for IsDigit(l.ch) {
+// SkipBlockComment eats up a /* block comment */, block comments nest
+func (l *Lexer) SkipBlockComment() {
+	row, column := l.currentRow, l.currentColumn
+	depth := 0
+	for {
+		switch {
+		case l.ch == 0:
+			l.errorAt(ErrorComment, row, column, "/*", "expected */ to close the block comment")
+			return
+		case l.ch == '/' && l.PeekChar() == '*':
+			depth++
+			l.ReadChar()
+		case l.ch == '*' && l.PeekChar() == '/':
+			depth--
 			l.ReadChar()
+			if depth == 0 {
+				l.ReadChar()
+				return
+			}
 		}
+		l.ReadChar()
 	}
+}
 
-	// Return string-number slice
-	return l.input[position:l.position]
+// Read the suffix of a number, if it is not the start of a word
+func (l *Lexer) ReadSuffix(suffix rune) bool {
+	next := l.PeekChar()
+	if l.ch != suffix || IsLetter(next) || IsDigit(next) {
+		return false
+	}
+	l.ReadChar()
+	return true
 }
 
 // If a rune is a numeric number
@@ -345,30 +604,7 @@ func IsDigit(ch rune) bool {
 func (l *Lexer) PeekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return rune(l.input[l.readPosition])
-	}
-}
-
-// Read a string with the terminator being the ending
-func (l *Lexer) ReadString(terminator rune) string {
-	position := l.position + 1
-	var prevChar rune
-	for {
-		prevChar = l.ch
-		l.ReadChar()
-		if l.ch == terminator || l.ch == 0 {
-			if prevChar != '\\' {
-				break
-			}
-		}
 	}
-	str := l.input[position:l.position]
-
-	// Parsing special charcters
-	str = strings.ReplaceAll(str, `\n`, "\n")
-	str = strings.ReplaceAll(str, `\t`, "\t")
-	str = strings.ReplaceAll(str, `\"`, "\"")
-	str = strings.ReplaceAll(str, `\'`, "'")
-	return str
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
@@ -15,7 +15,7 @@ let add = fn(x, y) {
 }
 
 let result = add(five, ten)
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -35,6 +35,9 @@ if (5 < 10) {
 'foo\n\t\"\':)'
 "hello \"world\""
 [1, 2]
+1.5 - 2
+123n 1.10d 5d
+"a ${b + "}"} c"
 `
 
 	// What the Parser/Lexer should return
@@ -146,6 +149,15 @@ if (5 < 10) {
 		{token.INT, "2", 0, 0},
 		{token.RBRACKET, "]", 0, 0},
 
+		{token.FLOAT, "1.5", 0, 0},
+		{token.MINUS, "-", 0, 0},
+		{token.INT, "2", 0, 0},
+
+		{token.BIGINT, "123n", 0, 0},
+		{token.DECIMAL, "1.10d", 0, 0},
+		{token.DECIMAL, "5d", 0, 0},
+		{token.TEMPLATE, `"a ${b + "}"} c"`, 0, 0},
+
 		{token.EOF, "\x00", 10, 12},
 	}
 
@@ -180,3 +192,330 @@ if (5 < 10) {
 
 	}
 }
+
+func TestTriviaIsLossless(t *testing.T) {
+	input := "let a = 1  // one\r\n\n\tif a && !b { \"x\\\"y😀\" }\n// ünïcode\n/* a /* b */\n*/"
+
+	l := NewWithTrivia(input, "TestTriviaIsLossless")
+	var joined string
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		joined += tok.Literal
+		types = append(types, tok.Type)
+	}
+
+	if joined != input {
+		t.Fatalf("tokens are not lossless. want=%q, got=%q", input, joined)
+	}
+	comments := 0
+	for _, typ := range types {
+		if typ == token.COMMENT {
+			comments++
+		}
+	}
+	if comments != 3 || types[1] != token.WHITESPACE {
+		t.Errorf("wrong trivia tokens. got=%v", types)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb\tc\\d\"e\'f\$g"`, token.STRING, "a\nb\tc\\d\"e'f$g"},
+		{`'\x41\u00e9\u{1F600}\0'`, token.STRING, "Aé😀\x00"},
+		{"`raw \\n ${x} \"q\"`", token.STRING, `raw \n ${x} "q"`},
+		{`"\\${x}"`, token.TEMPLATE, `"\\${x}"`},
+		{`"""one"""`, token.STRING, "one"},
+		{"\"\"\"\n    SELECT *\n      FROM t\n\n    WHERE a = \"b\"\n    \"\"\"", token.STRING, "SELECT *\n  FROM t\n\nWHERE a = \"b\""},
+		{"'''\n\tx\\n\n\t'''", token.STRING, "x\n"},
+		{`""`, token.STRING, ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "TestStrings")
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("wrong token for %q. want=%s %q, got=%s %q",
+				tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if errors := l.TakeErrors(); len(errors) != 0 {
+			t.Errorf("unexpected errors for %q. got=%v", tt.input, errors)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("string %q did not end at its quote. got=%s %q", tt.input, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+		row     int64
+		column  int64
+	}{
+		{`"a\qb"`, `invalid escape sequence \q`, 1, 3},
+		{`"\x4g"`, `expected hexadecimal digits in the escape sequence \x4`, 1, 2},
+		{`"\u{110000}"`, `invalid unicode code point in the escape sequence \u{110000}`, 1, 2},
+		{`"\u{41"`, `expected } to close the escape sequence \u{41`, 1, 2},
+		{"\"\"\"\n  a\n   \\z\n  \"\"\"", `invalid escape sequence \z`, 3, 4},
+		{`"${x} \q"`, `invalid escape sequence \q`, 1, 7},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "TestStringErrors")
+		l.NextToken()
+		errors := l.TakeErrors()
+		if len(errors) != 1 {
+			t.Errorf("expected one error for %q. got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0].Message != tt.message || errors[0].RowNumber != tt.row || errors[0].ColumnNumber != tt.column {
+			t.Errorf("wrong error for %q. want=%q at %d:%d, got=%q at %d:%d", tt.input, tt.message, tt.row, tt.column,
+				errors[0].Message, errors[0].RowNumber, errors[0].ColumnNumber)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let größe = \"😀 ok\" + naïve\nπ"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedRow     int64
+		expectedColumn  int64
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "größe", 1, 5},
+		{token.ASSIGN, "=", 1, 11},
+		{token.STRING, "😀 ok", 1, 13},
+		{token.PLUS, "+", 1, 20},
+		{token.IDENT, "naïve", 1, 22},
+		{token.NEWLINE, "\n", 1, 27},
+		{token.IDENT, "π", 2, 1},
+		{token.EOF, "\x00", 2, 2},
+	}
+
+	l := New(input, "TestUnicode")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.RowNumber != tt.expectedRow || tok.ColumnNumber != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong position. expected=%d:%d, got=%d:%d",
+				i, tt.expectedRow, tt.expectedColumn, tok.RowNumber, tok.ColumnNumber)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `a /* one /* nested */ still one */ b
+c /* over
+lines */ d
+/// Adds
+///   two numbers
+
+//// not a doc
+/// The doc of e
+e
+f /* */
+g`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedDoc     string
+	}{
+		{token.IDENT, "a", ""},
+		{token.IDENT, "b", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.IDENT, "c", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.IDENT, "d", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.IDENT, "e", "The doc of e"},
+		{token.NEWLINE, "\n", ""},
+		{token.IDENT, "f", ""},
+		{token.NEWLINE, "\n", ""},
+		{token.IDENT, "g", ""},
+		{token.EOF, "\x00", ""},
+	}
+
+	l := New(input, "TestComments")
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral || tok.Doc != tt.expectedDoc {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q doc=%q, got=%s %q doc=%q",
+				i, tt.expectedType, tt.expectedLiteral, tt.expectedDoc, tok.Type, tok.Literal, tok.Doc)
+		}
+	}
+
+	l = New("/// Adds\n///   two numbers\nlet", "TestComments")
+	if tok := l.NextToken(); tok.Type != token.NEWLINE {
+		t.Fatalf("expected a line break. got=%s", tok.Type)
+	}
+	l.NextToken()
+	if tok := l.NextToken(); tok.Doc != "Adds\n  two numbers" {
+		t.Errorf("wrong doc. got=%q", tok.Doc)
+	}
+
+	l = New("a /* open /* */", "TestComments")
+	l.NextToken()
+	l.NextToken()
+	if errors := l.TakeErrors(); len(errors) != 1 || errors[0].Kind != ErrorComment || errors[0].ColumnNumber != 3 {
+		t.Errorf("expected an open comment error at 1:3. got=%v", errors)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"0xFF", token.INT, "0xFF"},
+		{"0b1010", token.INT, "0b1010"},
+		{"0O755", token.INT, "0O755"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"1.5E+3", token.FLOAT, "1.5E+3"},
+		{"0xFFn", token.BIGINT, "0xFFn"},
+		{"1e3d", token.DECIMAL, "1e3d"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "TestNumbers")
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("wrong token for %q. want=%s %q, got=%s %q",
+				tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if errors := l.TakeErrors(); len(errors) != 0 {
+			t.Errorf("unexpected errors for %q. got=%v", tt.input, errors)
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("number %q was not read whole. got=%s %q", tt.input, next.Type, next.Literal)
+		}
+	}
+
+	// An exponent without digits and the letters right after a number are read with it and reported
+	malformed := []struct {
+		input           string
+		expectedLiteral string
+		expectedMessage string
+	}{
+		{"1e", "1e", "expected digits in the exponent of the number 1e"},
+		{"1.5E+ 2", "1.5E+", "expected digits in the exponent of the number 1.5E+"},
+		{"3e)", "3e", "expected digits in the exponent of the number 3e"},
+		{"2else", "2else", "invalid suffix else on the number 2"},
+		{"1ex", "1ex", "invalid suffix ex on the number 1"},
+		{"1e3n", "1e3n", "invalid suffix n on the number 1e3"},
+		{"0xFFg1", "0xFFg1", "invalid suffix g1 on the number 0xFF"},
+		{"7né", "7né", "invalid suffix né on the number 7"},
+	}
+	for _, tt := range malformed {
+		l := New(tt.input, "TestNumbers")
+		if tok := l.NextToken(); tok.Literal != tt.expectedLiteral {
+			t.Errorf("malformed number %q was not read whole. want=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+		errors := l.TakeErrors()
+		if len(errors) != 1 || errors[0].Kind != ErrorNumber || errors[0].Message != tt.expectedMessage {
+			t.Errorf("wrong errors for %q. want=%q, got=%v", tt.input, tt.expectedMessage, errors)
+		}
+	}
+
+	// ? and ! after a number are operators
+	l := New("1? 2!", "TestNumbers")
+	for _, expected := range []string{"1", "?", "2", "!"} {
+		if tok := l.NextToken(); tok.Literal != expected {
+			t.Errorf("wrong token after a number. want=%q, got=%q", expected, tok.Literal)
+		}
+	}
+	if errors := l.TakeErrors(); len(errors) != 0 {
+		t.Errorf("unexpected errors for operators after numbers. got=%v", errors)
+	}
+
+	l = New("x = 0b12 + 1", "TestNumbers")
+	l.NextToken()
+	l.NextToken()
+	if tok := l.NextToken(); tok.Literal != "0b12" {
+		t.Errorf("malformed number was not read whole. got=%q", tok.Literal)
+	}
+	if errors := l.TakeErrors(); len(errors) != 1 || errors[0].Kind != ErrorNumber || errors[0].ColumnNumber != 5 {
+		t.Errorf("expected a number error at 1:5. got=%v", errors)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "& | ^ ~ << >> ** &= |= ^= <<= >>= **= && || <= >= * <\n+= -= *= /= %= ??= ?? null? ?? x-=1 ...a.b x |> f => g x++ --y a - -1"
+	expected := []token.TokenType{
+		token.BIT_AND, token.BIT_OR, token.BIT_XOR, token.BIT_NOT, token.SHIFT_LEFT, token.SHIFT_RIGHT, token.POWER,
+		token.BIT_AND_ASSIGN, token.BIT_OR_ASSIGN, token.BIT_XOR_ASSIGN, token.SHIFT_LEFT_ASSIGN, token.SHIFT_RIGHT_ASSIGN,
+		token.POWER_ASSIGN, token.AND, token.OR, token.LE, token.GE, token.ASTERISK, token.LT, token.NEWLINE,
+		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN,
+		token.NULLISH_ASSIGN, token.NULLISH, token.IDENT, token.NULLISH, token.IDENT, token.MINUS_ASSIGN, token.INT,
+		token.ELLIPSIS, token.IDENT, token.DOT, token.IDENT, token.IDENT, token.PIPE, token.IDENT, token.FAT_ARROW, token.IDENT,
+		token.IDENT, token.INCREMENT, token.DECREMENT, token.IDENT, token.IDENT, token.MINUS, token.MINUS, token.INT,
+		token.EOF,
+	}
+
+	l := New(input, "TestOperators")
+	for i, typ := range expected {
+		tok := l.NextToken()
+		if tok.Type != typ {
+			t.Fatalf("tests[%d] - wrong token. expected=%s, got=%s %q", i, typ, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestQuestionMarks(t *testing.T) {
+	input := "null?(x) error? = c ? a : b x?.y h?.[0] f?.() a??b quiz?? ?x\n" +
+		"x? 1 : 2 x?1:2 x?\"a\":'b' x? -1 : !y x? (y) : [y] count? -= 1"
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "null?"}, {Type: token.LPAREN, Literal: "("}, {Type: token.IDENT, Literal: "x"},
+		{Type: token.RPAREN, Literal: ")"}, {Type: token.IDENT, Literal: "error?"}, {Type: token.ASSIGN, Literal: "="},
+		{Type: token.IDENT, Literal: "c"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.IDENT, Literal: "a"},
+		{Type: token.COLON, Literal: ":"}, {Type: token.IDENT, Literal: "b"},
+		{Type: token.IDENT, Literal: "x"}, {Type: token.OPTIONAL_DOT, Literal: "?."}, {Type: token.IDENT, Literal: "y"},
+		{Type: token.IDENT, Literal: "h"}, {Type: token.OPTIONAL_DOT, Literal: "?."}, {Type: token.LBRACKET, Literal: "["},
+		{Type: token.INT, Literal: "0"}, {Type: token.RBRACKET, Literal: "]"},
+		{Type: token.IDENT, Literal: "f"}, {Type: token.OPTIONAL_DOT, Literal: "?."}, {Type: token.LPAREN, Literal: "("},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.IDENT, Literal: "a"}, {Type: token.NULLISH, Literal: "??"}, {Type: token.IDENT, Literal: "b"},
+		{Type: token.IDENT, Literal: "quiz"}, {Type: token.NULLISH, Literal: "??"},
+		{Type: token.QUESTION, Literal: "?"}, {Type: token.IDENT, Literal: "x"}, {Type: token.NEWLINE, Literal: "\n"},
+		// A ? followed by an operand is a conditional
+		{Type: token.IDENT, Literal: "x"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.INT, Literal: "1"},
+		{Type: token.COLON, Literal: ":"}, {Type: token.INT, Literal: "2"},
+		{Type: token.IDENT, Literal: "x"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.INT, Literal: "1"},
+		{Type: token.COLON, Literal: ":"}, {Type: token.INT, Literal: "2"},
+		{Type: token.IDENT, Literal: "x"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.STRING, Literal: "a"},
+		{Type: token.COLON, Literal: ":"}, {Type: token.STRING, Literal: "b"},
+		{Type: token.IDENT, Literal: "x"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.MINUS, Literal: "-"},
+		{Type: token.INT, Literal: "1"}, {Type: token.COLON, Literal: ":"}, {Type: token.BANG, Literal: "!"},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.IDENT, Literal: "x"}, {Type: token.QUESTION, Literal: "?"}, {Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "y"}, {Type: token.RPAREN, Literal: ")"}, {Type: token.COLON, Literal: ":"},
+		{Type: token.LBRACKET, Literal: "["}, {Type: token.IDENT, Literal: "y"}, {Type: token.RBRACKET, Literal: "]"},
+		{Type: token.IDENT, Literal: "count?"}, {Type: token.MINUS_ASSIGN, Literal: "-="}, {Type: token.INT, Literal: "1"},
+		{Type: token.EOF, Literal: "\x00"},
+	}
+
+	l := New(input, "TestQuestionMarks")
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want.Type || tok.Literal != want.Literal {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q", i, want.Type, want.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
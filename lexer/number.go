@@ -0,0 +1,138 @@
+package lexer
+
+import (
+	"Monkey/token"
+)
+
+// The names of the number bases written with a prefix
+var bases = map[rune]struct {
+	base int
+	name string
+}{
+	'x': {16, "hexadecimal"},
+	'b': {2, "binary"},
+	'o': {8, "octal"},
+}
+
+// Read a number and advance the pointer, the number is an INT or a FLOAT.
+// Integers can be written in hexadecimal 0xFF, binary 0b1010 or octal 0o755, floats can have an exponent 1e-9,
+// and underscores can separate digits 1_000_000. Malformed numbers are read whole and reported,
+// like an exponent without digits 1e+, see ReadInvalidSuffix for the letters after a number
+func (l *Lexer) ReadNumber() (token.TokenType, string) {
+	start, row, column := l.position, l.currentRow, l.currentColumn
+
+	// The prefix is read in lower case, 0X is 0x
+	if prefix, ok := bases[l.PeekChar()|0x20]; ok && l.ch == '0' {
+		l.ReadChar()
+		l.ReadChar()
+		// Every letter of hexadecimal is read, so that wrong digits are reported with their number
+		for isHex(l.ch) || l.ch == '_' {
+			l.ReadChar()
+		}
+		literal := l.input[start:l.position]
+		digits := literal[2:]
+
+		if digits == "" {
+			l.errorAt(ErrorNumber, row, column, literal, "expected %s digits after %s", prefix.name, literal)
+			return token.INT, literal
+		}
+		for _, ch := range digits {
+			if ch != '_' && digitValue(ch) >= prefix.base {
+				l.errorAt(ErrorNumber, row, column, literal, "invalid digit %q in the %s number %s", ch, prefix.name, literal)
+				return token.INT, literal
+			}
+		}
+		l.checkUnderscores(literal, digits, isHex, row, column)
+		return token.INT, literal
+	}
+
+	var typ token.TokenType = token.INT
+	l.readDigits()
+	if l.ch == '.' && IsDigit(l.PeekChar()) {
+		typ = token.FLOAT
+		l.ReadChar()
+		l.readDigits()
+	}
+
+	// An exponent, e5, e+5 or e-5
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.PeekChar()
+		if (next == '+' || next == '-') && l.readPosition+1 < len(l.input) {
+			next = rune(l.input[l.readPosition+1])
+		}
+		signed := l.PeekChar() == '+' || l.PeekChar() == '-'
+		if IsDigit(next) {
+			typ = token.FLOAT
+			l.ReadChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.ReadChar()
+			}
+			l.readDigits()
+		} else if signed || !isSuffixLetter(next) && !IsDigit(next) {
+			l.ReadChar()
+			if signed {
+				l.ReadChar()
+			}
+			literal := l.input[start:l.position]
+			l.errorAt(ErrorNumber, row, column, literal, "expected digits in the exponent of the number %s", literal)
+			return token.FLOAT, literal
+		}
+	}
+
+	literal := l.input[start:l.position]
+	l.checkUnderscores(literal, literal, IsDigit, row, column)
+	return typ, literal
+}
+
+// ReadInvalidSuffix reads the letters and digits right after a number and reports them,
+// 2else is not read as 2 followed by else. It returns the literal of the number with them
+func (l *Lexer) ReadInvalidSuffix(literal string, row int64, column int64) string {
+	if !isSuffixLetter(l.ch) {
+		return literal
+	}
+	start := l.position
+	for isSuffixLetter(l.ch) || IsDigit(l.ch) {
+		l.ReadChar()
+	}
+	suffix := l.input[start:l.position]
+	l.errorAt(ErrorNumber, row, column, literal+suffix, "invalid suffix %s on the number %s", suffix, literal)
+	return literal + suffix
+}
+
+// isSuffixLetter returns whether a rune would start an identifier, ? and ! can follow numbers as operators
+func isSuffixLetter(ch rune) bool {
+	return IsLetter(ch) && ch != '?' && ch != '!'
+}
+
+// readDigits reads decimal digits and underscores
+func (l *Lexer) readDigits() {
+	for IsDigit(l.ch) || l.ch == '_' {
+		l.ReadChar()
+	}
+}
+
+// checkUnderscores reports the underscores of the digits of a number that do not separate two digits
+func (l *Lexer) checkUnderscores(literal string, digits string, isDigit func(rune) bool, row int64, column int64) {
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(digits)-1 || !isDigit(rune(digits[i-1])) || !isDigit(rune(digits[i+1])) {
+			l.errorAt(ErrorNumber, row, column, literal, "_ must separate two digits in the number %s", literal)
+			return
+		}
+	}
+}
+
+// digitValue returns the value of a hexadecimal digit
+func digitValue(ch rune) int {
+	switch {
+	case IsDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+	return 16
+}
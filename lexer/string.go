@@ -0,0 +1,327 @@
+package lexer
+
+import (
+	"Monkey/token"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The kinds of errors found while reading
+type ErrorKind int
+
+const (
+	ErrorEscape  ErrorKind = iota // an invalid escape sequence in a string
+	ErrorComment                  // a block comment that is not closed
+	ErrorNumber                   // a malformed number literal
+)
+
+// An error found while reading, such as an invalid escape sequence in a string
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Literal string // the source of the error
+
+	RowNumber    int64
+	ColumnNumber int64
+}
+
+// TakeErrors returns the errors found since it was last called
+func (l *Lexer) TakeErrors() []Error {
+	errors := l.errors
+	l.errors = nil
+	return errors
+}
+
+func (l *Lexer) errorAt(kind ErrorKind, row int64, column int64, literal string, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{
+		Kind:         kind,
+		Message:      fmt.Sprintf(format, a...),
+		Literal:      literal,
+		RowNumber:    row,
+		ColumnNumber: column,
+	})
+}
+
+// Read a string from its opening quote to its closing quote.
+// "double", 'single' and """triple""" quoted strings read escapes and interpolations,
+// triple quoted strings can span lines and lose their common indentation, and `raw` strings are read as they are.
+// The literal of a string is its value, and the literal of an interpolated string is its source, split by ReadTemplate
+func (l *Lexer) ReadString() (token.TokenType, string) {
+	start, row, column := l.position, l.currentRow, l.currentColumn
+	l.skipString()
+
+	source := l.source(start)
+
+	parts, _ := l.splitString(source, row, column)
+	if len(parts) == 1 && !parts[0].Expression {
+		return token.STRING, parts[0].Value
+	}
+	return token.TEMPLATE, source
+}
+
+// quoteLength returns the length of the quotes around a string starting at the current character
+func (l *Lexer) quoteLength() int {
+	if l.ch != '`' && strings.HasPrefix(l.input[l.readPosition:], string(l.ch)+string(l.ch)) {
+		return 3
+	}
+	return 1
+}
+
+// skipString advances from the opening quote of a string to its closing quote, or to the end of the input
+func (l *Lexer) skipString() {
+	quote := l.ch
+	length := l.quoteLength()
+	for i := 1; i < length; i++ {
+		l.ReadChar()
+	}
+
+	for {
+		l.ReadChar()
+		switch {
+		case l.ch == 0:
+			return
+		case quote == '`':
+			if l.ch == quote {
+				return
+			}
+		case l.ch == '\\':
+			l.ReadChar()
+		case l.ch == '$' && l.PeekChar() == '{':
+			if !l.skipInterpolation() {
+				return
+			}
+		case l.ch == quote && (length == 1 || l.quoteLength() == 3):
+			for i := 1; i < length; i++ {
+				l.ReadChar()
+			}
+			return
+		}
+	}
+}
+
+// skipInterpolation advances from the $ of an interpolation to its closing brace,
+// it returns false when the input ends first
+func (l *Lexer) skipInterpolation() bool {
+	l.ReadChar()
+	depth := 0
+	for {
+		l.ReadChar()
+		switch l.ch {
+		case 0:
+			return false
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case '"', '\'', '`':
+			if l.skipString(); l.ch == 0 {
+				return false
+			}
+		}
+	}
+}
+
+// A piece of an interpolated string, either text or the source of an expression
+type TemplatePart struct {
+	Value      string
+	Expression bool
+
+	// Where the part starts
+	RowNumber    int64
+	ColumnNumber int64
+}
+
+// ReadTemplate splits the source of an interpolated string into its parts, the string starts at row and column.
+// It returns false when the last interpolation is not closed
+func ReadTemplate(source string, row int64, column int64) ([]TemplatePart, bool) {
+	return New("", "").splitString(source, row, column)
+}
+
+// splitString decodes the source of a string into its text and the source of its interpolations,
+// it returns false when the last interpolation is not closed
+func (l *Lexer) splitString(source string, row int64, column int64) ([]TemplatePart, bool) {
+	reader := NewAt(source, l.currentFile, row, column)
+	quote := reader.ch
+	length := reader.quoteLength()
+	for i := 0; i < length; i++ {
+		reader.ReadChar()
+	}
+
+	body := source[length:]
+	if len(body) >= length && strings.HasSuffix(body, source[:length]) {
+		body = body[:len(body)-length]
+	}
+	if quote == '`' {
+		return []TemplatePart{{Value: body, RowNumber: row, ColumnNumber: column + 1}}, true
+	}
+
+	// Triple quoted strings start after their first line break and end before the line of their closing quotes
+	indent := ""
+	if length == 3 {
+		if strings.HasPrefix(body, "\n") || strings.HasPrefix(body, "\r\n") {
+			newline := strings.Index(body, "\n") + 1
+			for i := 0; i < newline; i++ {
+				reader.ReadChar()
+			}
+			body = body[newline:]
+		}
+		if last := strings.LastIndex(body, "\n"); last != -1 && strings.TrimSpace(body[last:]) == "" {
+			body = strings.TrimSuffix(body[:last], "\r")
+		}
+		indent = commonIndent(body)
+	}
+
+	var parts []TemplatePart
+	var text strings.Builder
+	part := TemplatePart{RowNumber: reader.currentRow, ColumnNumber: reader.currentColumn}
+	end := reader.position + len(body)
+
+	for atLineStart := true; reader.position < end; reader.ReadChar() {
+		if atLineStart {
+			// The indentation is part of the source, not of the string
+			for i := 0; i < len(indent) && reader.position < end && reader.ch == rune(indent[i]); i++ {
+				reader.ReadChar()
+			}
+			atLineStart = false
+			if reader.position >= end {
+				break
+			}
+		}
+
+		switch {
+		case reader.ch == '\\':
+			l.readEscape(reader, &text)
+		case reader.ch == '$' && reader.PeekChar() == '{':
+			part.Value = text.String()
+			parts = append(parts, part)
+			text.Reset()
+
+			// The expression starts after the ${ on the same line
+			expression := TemplatePart{Expression: true, RowNumber: reader.currentRow, ColumnNumber: reader.currentColumn + 2}
+			position := reader.position + 2
+			closed := reader.skipInterpolation()
+			expression.Value = source[position:reader.position]
+			parts = append(parts, expression)
+			if !closed || reader.position >= end {
+				return parts, false
+			}
+			part = TemplatePart{RowNumber: reader.currentRow, ColumnNumber: reader.currentColumn + 1}
+		case reader.ch == '\r' && reader.PeekChar() == '\n':
+		case reader.ch == '\n':
+			text.WriteRune('\n')
+			atLineStart = true
+		default:
+			text.WriteRune(reader.ch)
+		}
+	}
+
+	part.Value = text.String()
+	return append(parts, part), true
+}
+
+// commonIndent returns the spaces and tabs starting every line of a text that is not blank
+func commonIndent(text string) string {
+	indent := ""
+	first := true
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lineIndent, false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
+// The characters written by escape sequences of one character
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+	'`':  '`',
+	'$':  '$',
+}
+
+// readEscape decodes the escape sequence starting at the backslash of the reader into out,
+// the reader stops at the last character of the sequence and the errors are reported on l.
+// \xHH and \uHHHH or \u{H...} write the unicode code point of their hexadecimal digits
+func (l *Lexer) readEscape(reader *Lexer, out *strings.Builder) {
+	row, column, start := reader.currentRow, reader.currentColumn, reader.position
+	reader.ReadChar()
+
+	if ch, ok := escapes[reader.ch]; ok {
+		out.WriteRune(ch)
+		return
+	}
+
+	var digits string
+	switch reader.ch {
+	case 'x':
+		if digits = reader.readHex(2); len(digits) != 2 {
+			digits = ""
+		}
+	case 'u':
+		if reader.PeekChar() != '{' {
+			if digits = reader.readHex(4); len(digits) != 4 {
+				digits = ""
+			}
+			break
+		}
+		reader.ReadChar()
+		digits = reader.readHex(6)
+		if reader.PeekChar() != '}' {
+			literal := reader.source(start)
+			l.errorAt(ErrorEscape, row, column, literal, "expected } to close the escape sequence %s", literal)
+			return
+		}
+		reader.ReadChar()
+	case 0:
+		l.errorAt(ErrorEscape, row, column, "\\", "expected an escape sequence after \\")
+		return
+	default:
+		literal := "\\" + string(reader.ch)
+		l.errorAt(ErrorEscape, row, column, literal, "invalid escape sequence %s", literal)
+		return
+	}
+
+	literal := reader.source(start)
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		l.errorAt(ErrorEscape, row, column, literal, "expected hexadecimal digits in the escape sequence %s", literal)
+		return
+	}
+	if !utf8.ValidRune(rune(code)) {
+		l.errorAt(ErrorEscape, row, column, literal, "invalid unicode code point in the escape sequence %s", literal)
+		return
+	}
+	out.WriteRune(rune(code))
+}
+
+// readHex reads up to n hexadecimal digits after the current character
+func (l *Lexer) readHex(n int) string {
+	var digits strings.Builder
+	for i := 0; i < n && isHex(l.PeekChar()); i++ {
+		l.ReadChar()
+		digits.WriteRune(l.ch)
+	}
+	return digits.String()
+}
+
+func isHex(ch rune) bool {
+	return IsDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
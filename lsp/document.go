@@ -0,0 +1,457 @@
+package lsp
+
+import (
+	"Monkey/ast"
+	"Monkey/lexer"
+	"Monkey/parser"
+	"Monkey/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Definition is a name bound by a let, a parameter or a prototype assignment
+type Definition struct {
+	Name  string
+	Kind  int         // the symbol kind
+	Token token.Token // the token of the name
+
+	// Shown on hover, such as "let add = fn(a, b)"
+	Signature string
+	// The comment lines right above the definition
+	Doc string
+
+	// The location imported by a module definition, empty otherwise
+	Import string
+	// The type of a prototype method, empty otherwise
+	Prototype string
+	// The definitions of a module literal
+	Children []*Definition
+
+	TopLevel bool
+}
+
+// Document is a parsed source file
+type Document struct {
+	Filename string
+	Text     string
+
+	Program     *ast.Program
+	Diagnostics []*parser.ParseError
+
+	// Every definition, sorted by position
+	Definitions []*Definition
+	// The prototype methods assigned in the file
+	Methods []*Definition
+	// The locations of the include calls
+	Includes []string
+
+	tokens []token.Token
+	// The ranges of the tokens, measured on their source
+	ranges []Range
+	lines  []string
+}
+
+// NewDocument parses and indexes a source
+func NewDocument(filename string, text string) *Document {
+	doc := &Document{
+		Filename: filename,
+		Text:     text,
+		lines:    strings.Split(strings.ReplaceAll(text, "\r", ""), "\n"),
+	}
+
+	l := lexer.New(text, filename)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		doc.tokens = append(doc.tokens, tok)
+	}
+	doc.ranges = sourceRanges(text, filename)
+
+	p := parser.New(lexer.New(text, filename))
+	doc.Program = p.ParseProgram()
+	doc.Diagnostics = p.Diagnostics()
+	doc.index()
+
+	return doc
+}
+
+// index collects the definitions and includes of the program
+func (doc *Document) index() {
+	seen := map[*ast.LetStatement]bool{}
+	for _, stmt := range doc.Program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Name != nil {
+			doc.Definitions = append(doc.Definitions, doc.defineLet(let, seen, true))
+		} else if ok {
+			doc.Definitions = append(doc.Definitions, definePattern(let, seen, true)...)
+		}
+	}
+
+	ast.Modify(doc.Program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if !seen[node] && node.Name != nil {
+				doc.Definitions = append(doc.Definitions, doc.defineLet(node, seen, false))
+			} else if !seen[node] {
+				doc.Definitions = append(doc.Definitions, definePattern(node, seen, false)...)
+			}
+		case *ast.FunctionLiteral:
+			for i, param := range node.Parameters {
+				// A destructured parameter defines the identifiers of its pattern
+				params := []*ast.Identifier{param}
+				if node.Patterns != nil && node.Patterns[i] != nil {
+					params = ast.PatternIdentifiers(node.Patterns[i])
+				}
+				for _, param := range params {
+					doc.Definitions = append(doc.Definitions, &Definition{
+						Name:      param.Value,
+						Kind:      SymbolVariable,
+						Token:     param.Token,
+						Signature: "(parameter) " + param.Value,
+					})
+				}
+			}
+			if node.Rest != nil {
+				doc.Definitions = append(doc.Definitions, &Definition{
+					Name:      node.Rest.Value,
+					Kind:      SymbolVariable,
+					Token:     node.Rest.Token,
+					Signature: "(parameter) ..." + node.Rest.Value,
+				})
+			}
+		case *ast.ForInStatement:
+			doc.Definitions = append(doc.Definitions, &Definition{
+				Name:      node.Variable.Value,
+				Kind:      SymbolVariable,
+				Token:     node.Variable.Token,
+				Signature: "(loop variable) " + node.Variable.Value,
+			})
+		case *ast.MatchExpression:
+			for _, arm := range node.Arms {
+				for _, ident := range ast.MatchBindings(arm.Pattern) {
+					doc.Definitions = append(doc.Definitions, &Definition{
+						Name:      ident.Value,
+						Kind:      SymbolVariable,
+						Token:     ident.Token,
+						Signature: "(match binding) " + ident.Value,
+					})
+				}
+			}
+		case *ast.SelectExpression:
+			for _, arm := range node.Arms {
+				if arm.Name != nil {
+					doc.Definitions = append(doc.Definitions, &Definition{
+						Name:      arm.Name.Value,
+						Kind:      SymbolVariable,
+						Token:     arm.Name.Token,
+						Signature: "(received value) " + arm.Name.Value,
+					})
+				}
+			}
+		case *ast.InfixExpression:
+			if method := doc.defineMethod(node); method != nil {
+				doc.Methods = append(doc.Methods, method)
+			}
+		case *ast.CallExpression:
+			if location, ok := ast.IncludeLocation(node, "include"); ok {
+				doc.Includes = append(doc.Includes, location)
+			}
+		}
+		return node
+	})
+
+	sort.SliceStable(doc.Definitions, func(i, j int) bool {
+		return before(doc.Definitions[i].Token, doc.Definitions[j].Token)
+	})
+}
+
+// definePattern returns the variables of a let that destructures its value
+func definePattern(let *ast.LetStatement, seen map[*ast.LetStatement]bool, topLevel bool) []*Definition {
+	seen[let] = true
+	var definitions []*Definition
+	for _, ident := range ast.PatternIdentifiers(let.Pattern) {
+		definitions = append(definitions, &Definition{
+			Name:      ident.Value,
+			Kind:      SymbolVariable,
+			Token:     ident.Token,
+			Signature: "let " + ident.Value,
+			TopLevel:  topLevel,
+		})
+	}
+	return definitions
+}
+
+func (doc *Document) defineLet(let *ast.LetStatement, seen map[*ast.LetStatement]bool, topLevel bool) *Definition {
+	seen[let] = true
+	def := &Definition{
+		Name:     let.Name.Value,
+		Kind:     SymbolVariable,
+		Token:    let.Name.Token,
+		Doc:      let.Doc,
+		TopLevel: topLevel,
+	}
+	if def.Doc == "" {
+		def.Doc = doc.comment(let.Token.RowNumber)
+	}
+
+	prefix := "let " + def.Name + " = "
+	switch value := let.Value.(type) {
+	case *ast.FunctionLiteral:
+		def.Kind = SymbolFunction
+		def.Signature = prefix + value.Signature()
+	case *ast.MacroLiteral:
+		def.Kind = SymbolFunction
+		def.Signature = prefix + value.Signature()
+	case *ast.ModuleExpression:
+		def.Kind = SymbolModule
+		def.Signature = prefix + "module"
+		if value.Body == nil {
+			break
+		}
+		for _, stmt := range value.Body.Statements {
+			if child, ok := stmt.(*ast.LetStatement); ok && child.Name != nil {
+				def.Children = append(def.Children, doc.defineLet(child, seen, false))
+			}
+		}
+	case *ast.CallExpression:
+		if location, ok := ast.IncludeLocation(value, "import"); ok {
+			def.Kind = SymbolModule
+			def.Import = location
+			def.Signature = fmt.Sprintf("%simport(%q)", prefix, location)
+		}
+	}
+	if def.Signature == "" {
+		def.Signature = strings.TrimSpace(doc.line(let.Token.RowNumber))
+	}
+	return def
+}
+
+// defineMethod returns the method of a Type.prototype.name = fn assignment, or nil
+func (doc *Document) defineMethod(node *ast.InfixExpression) *Definition {
+	if node.Operator != token.ASSIGN {
+		return nil
+	}
+	function, ok := node.Right.(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+	name, ok := node.Left.(*ast.InfixExpression)
+	if !ok || name.Operator != token.DOT {
+		return nil
+	}
+	method, ok := name.Right.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	prototype, ok := name.Left.(*ast.InfixExpression)
+	if !ok || prototype.Operator != token.DOT {
+		return nil
+	}
+	typ, ok := prototype.Left.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if key, ok := prototype.Right.(*ast.Identifier); !ok || key.Value != "prototype" {
+		return nil
+	}
+
+	return &Definition{
+		Name:      method.Value,
+		Kind:      SymbolMethod,
+		Token:     method.Token,
+		Signature: fmt.Sprintf("%s.prototype.%s = %s", typ.Value, method.Value, function.Signature()),
+		Doc:       doc.comment(method.Token.RowNumber),
+		Prototype: typ.Value,
+	}
+}
+
+// line returns the source of a one based row
+func (doc *Document) line(row int64) string {
+	if row < 1 || int(row) > len(doc.lines) {
+		return ""
+	}
+	return doc.lines[row-1]
+}
+
+// comment returns the // comment lines right above a one based row
+func (doc *Document) comment(row int64) string {
+	var lines []string
+	for row--; row >= 1; row-- {
+		line := strings.TrimSpace(doc.line(row))
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		lines = append([]string{strings.TrimSpace(strings.TrimPrefix(line, "//"))}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TopLevel returns the top level definition named name
+func (doc *Document) TopLevel(name string) *Definition {
+	for _, def := range doc.Definitions {
+		if def.TopLevel && def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+// Lookup returns the definition of name visible at pos, the closest one before it
+// or else a top level one defined later
+func (doc *Document) Lookup(name string, pos Position) *Definition {
+	var found *Definition
+	for _, def := range doc.Definitions {
+		if def.Name != name {
+			continue
+		}
+		if start := doc.Range(def.Token).Start; start.Line > pos.Line || start.Line == pos.Line && start.Character > pos.Character {
+			break
+		}
+		found = def
+	}
+	if found == nil {
+		found = doc.TopLevel(name)
+	}
+	return found
+}
+
+// TokenAt returns the index of the token under pos, or -1,
+// a cursor right after a token is on it unless another token starts there
+func (doc *Document) TokenAt(pos Position) int {
+	found := -1
+	for i, tok := range doc.tokens {
+		r := doc.TokenRange(i)
+		if tok.Type == token.NEWLINE || r.Start.Line != pos.Line || pos.Character < r.Start.Character {
+			continue
+		}
+		if pos.Character < r.End.Character {
+			return i
+		}
+		if pos.Character == r.End.Character {
+			found = i
+		}
+	}
+	return found
+}
+
+// Token returns the token at index i, or an ILLEGAL token when it is out of range
+func (doc *Document) Token(i int) token.Token {
+	if i < 0 || i >= len(doc.tokens) {
+		return token.Token{Type: token.ILLEGAL}
+	}
+	return doc.tokens[i]
+}
+
+// TokenRange returns the range of the token at index i
+func (doc *Document) TokenRange(i int) Range {
+	if i < 0 || i >= len(doc.ranges) {
+		return Range{}
+	}
+	return doc.ranges[i]
+}
+
+// Range returns the range of a token of the document, such as the name of a definition
+func (doc *Document) Range(tok token.Token) Range {
+	i := sort.Search(len(doc.tokens), func(i int) bool {
+		return !before(doc.tokens[i], tok)
+	})
+	if i < len(doc.tokens) && doc.tokens[i].RowNumber == tok.RowNumber && doc.tokens[i].ColumnNumber == tok.ColumnNumber {
+		return doc.TokenRange(i)
+	}
+	start := doc.position(tok.RowNumber, tok.ColumnNumber)
+	return Range{Start: start, End: advance(start, tok.Literal)}
+}
+
+// sourceRanges returns the ranges of the tokens of a source, the lossless lexer gives
+// the source of every token so that escapes and quotes are measured as they are written
+func sourceRanges(text string, filename string) []Range {
+	var ranges []Range
+	var pos Position
+	l := lexer.NewWithTrivia(text, filename)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		start := pos
+		pos = advance(pos, tok.Literal)
+		if tok.Type != token.WHITESPACE && tok.Type != token.COMMENT {
+			ranges = append(ranges, Range{Start: start, End: pos})
+		}
+	}
+	return ranges
+}
+
+// advance returns the position after source read from pos
+func advance(pos Position, source string) Position {
+	for _, r := range source {
+		switch r {
+		case '\n':
+			pos.Line++
+			pos.Character = 0
+		case '\r':
+		default:
+			pos.Character += utf16Len(r)
+		}
+	}
+	return pos
+}
+
+// position converts a one based row and column counted in characters to a position,
+// LSP counts the characters of a line in UTF-16 code units
+func (doc *Document) position(row int64, column int64) Position {
+	pos := Position{Line: int(row) - 1}
+	runes := []rune(doc.line(row))
+	for i := 0; i < int(column)-1; i++ {
+		if i < len(runes) {
+			pos.Character += utf16Len(runes[i])
+		} else {
+			pos.Character++
+		}
+	}
+	return pos
+}
+
+// runeColumn returns the index of the character at pos in the runes of its line
+func (doc *Document) runeColumn(pos Position) int {
+	runes := []rune(doc.line(int64(pos.Line + 1)))
+	character := 0
+	for i, r := range runes {
+		if character >= pos.Character {
+			return i
+		}
+		character += utf16Len(r)
+	}
+	return len(runes)
+}
+
+// utf16Len returns the number of UTF-16 code units of r
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func before(a token.Token, b token.Token) bool {
+	if a.RowNumber != b.RowNumber {
+		return a.RowNumber < b.RowNumber
+	}
+	return a.ColumnNumber < b.ColumnNumber
+}
+
+// diagnostics converts the parse errors of the document
+func (doc *Document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range doc.Diagnostics {
+		severity := SeverityError
+		if err.Severity == parser.SeverityWarning {
+			severity = SeverityWarning
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range: Range{
+				Start: doc.position(err.RowNumber, err.ColumnNumber),
+				End:   doc.position(err.EndRowNumber, err.EndColumnNumber),
+			},
+			Severity: severity,
+			Code:     err.Code,
+			Source:   "monkey",
+			Message:  err.Message,
+		})
+	}
+	return diagnostics
+}
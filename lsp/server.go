@@ -0,0 +1,616 @@
+package lsp
+
+import (
+	"Monkey/evaluator"
+	"Monkey/lexer"
+	"Monkey/options"
+	"Monkey/runner"
+	"Monkey/token"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoShutdown is returned by Serve when the client exits without a shutdown request
+var ErrNoShutdown = errors.New("exit before shutdown")
+
+// Server is a language server for .mky files speaking LSP over a stream
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// library search paths, the first one is the std directory
+	paths []string
+
+	// the open documents and their uris, by filename
+	documents map[string]*Document
+	uris      map[string]string
+
+	// the files read from the disk, reparsed when they are modified
+	files map[string]*file
+
+	shutdown bool
+}
+
+type file struct {
+	doc     *Document
+	modTime time.Time
+}
+
+// NewServer creates a server reading requests from in and writing to out,
+// libraries and the builtin declarations are looked up in paths
+func NewServer(in io.Reader, out io.Writer, paths []string) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		paths:     paths,
+		documents: map[string]*Document{},
+		uris:      map[string]string{},
+		files:     map[string]*file{},
+	}
+}
+
+// Serve answers requests until the client exits or the input ends
+func (s *Server) Serve() error {
+	for {
+		content, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+
+		result, err := s.handle(&msg)
+		// Notifications are not answered
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err == nil {
+		return writeMessage(s.out, response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+	re, ok := err.(*responseError)
+	if !ok {
+		re = &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: id, Error: re})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	content, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(content)
+	return writeMessage(s.out, message{JSONRPC: "2.0", Method: method, Params: raw})
+}
+
+func (s *Server) handle(msg *message) (result interface{}, err error) {
+	// A bug in a handler fails the request instead of the whole server
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &responseError{Code: codeInternalError, Message: fmt.Sprint(r)}
+		}
+	}()
+
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				PositionEncoding:       "utf-16",
+				TextDocumentSync:       syncFull,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				DocumentSymbolProvider: true,
+				CompletionProvider:     CompletionOptions{TriggerCharacters: []string{"."}},
+			},
+			ServerInfo: ServerInfo{Name: "monkey"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		// The document is synced in full, the last change is its whole content
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		filename := uriToFilename(params.TextDocument.URI)
+		delete(s.documents, filename)
+		delete(s.uris, filename)
+		return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if hover := s.hover(params); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if location := s.definition(params); location != nil {
+			return location, nil
+		}
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	}
+
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+}
+
+func decode(msg *message, params interface{}) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// open parses the content of a document and publishes its diagnostics
+func (s *Server) open(uri string, text string) error {
+	filename := uriToFilename(uri)
+	doc := NewDocument(filename, text)
+	s.documents[filename] = doc
+	s.uris[filename] = uri
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+// document returns the open document of a uri, or the file on the disk
+func (s *Server) document(uri string) *Document {
+	return s.load(uriToFilename(uri))
+}
+
+// load returns the document of a filename, nil when it cannot be read
+func (s *Server) load(filename string) *Document {
+	if doc, ok := s.documents[filename]; ok {
+		return doc
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil
+	}
+	if cached, ok := s.files[filename]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.doc
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	doc := NewDocument(filename, string(content))
+	s.files[filename] = &file{doc: doc, modTime: info.ModTime()}
+	return doc
+}
+
+// resolve finds the file of an include or import location, like the runner does
+func (s *Server) resolve(doc *Document, location string) string {
+	r := runner.New(filepath.Dir(doc.Filename), s.paths, &options.Options{}, ioutil.Discard)
+	return r.ToAbsolute(location)
+}
+
+// scope returns the documents whose top level definitions are visible in doc,
+// doc first, then its includes and the standard library
+func (s *Server) scope(doc *Document) []*Document {
+	visited := map[string]bool{}
+	var docs []*Document
+
+	var visit func(d *Document)
+	visit = func(d *Document) {
+		if visited[d.Filename] {
+			return
+		}
+		visited[d.Filename] = true
+		docs = append(docs, d)
+		for _, location := range d.Includes {
+			if included := s.load(s.resolve(d, location)); included != nil {
+				visit(included)
+			}
+		}
+	}
+
+	visit(doc)
+	if std := s.load(s.resolve(doc, "std")); std != nil {
+		visit(std)
+	}
+	return docs
+}
+
+// builtins returns the document declaring the builtin functions, or nil
+func (s *Server) builtins() *Document {
+	if len(s.paths) == 0 {
+		return nil
+	}
+	return s.load(filepath.Join(s.paths[0], "std", "builtin.mky"))
+}
+
+// lookup finds the definition of name used at pos in doc
+func (s *Server) lookup(doc *Document, name string, pos Position) *Definition {
+	if def := doc.Lookup(name, pos); def != nil {
+		return def
+	}
+	for _, d := range s.scope(doc)[1:] {
+		if def := d.TopLevel(name); def != nil {
+			return def
+		}
+	}
+	return s.lookupBuiltin(name)
+}
+
+func (s *Server) lookupBuiltin(name string) *Definition {
+	if _, ok := evaluator.LookupBuiltin(name); !ok {
+		return nil
+	}
+	if builtins := s.builtins(); builtins != nil {
+		if def := builtins.TopLevel(name); def != nil {
+			return def
+		}
+	}
+	return &Definition{Name: name, Kind: SymbolFunction, Signature: name}
+}
+
+// lookupMember finds the definition of the member name of the value named receiver
+func (s *Server) lookupMember(doc *Document, receiver string, name string, pos Position) *Definition {
+	if def := s.lookup(doc, receiver, pos); def != nil {
+		if members, ok := s.members(doc, def); ok {
+			for _, member := range members {
+				if member.Name == name {
+					return member
+				}
+			}
+			return nil
+		}
+	}
+
+	for _, method := range s.methods(doc) {
+		if method.Name == name {
+			return method
+		}
+	}
+	return nil
+}
+
+// members returns the top level definitions of a module, ok is false when def is not one
+func (s *Server) members(doc *Document, def *Definition) (members []*Definition, ok bool) {
+	if def.Kind != SymbolModule {
+		return nil, false
+	}
+	if def.Import == "" {
+		return def.Children, true
+	}
+
+	// the import is relative to the file defining the module
+	from := doc
+	if def.Token.Filename != "" && def.Token.Filename != doc.Filename {
+		if d := s.load(def.Token.Filename); d != nil {
+			from = d
+		}
+	}
+	module := s.load(s.resolve(from, def.Import))
+	if module == nil {
+		return nil, true
+	}
+	for _, member := range module.Definitions {
+		if member.TopLevel {
+			members = append(members, member)
+		}
+	}
+	return members, true
+}
+
+// methods returns the prototype methods visible in doc,
+// the ones assigned in the sources first and then the builtin ones
+func (s *Server) methods(doc *Document) []*Definition {
+	var methods []*Definition
+	for _, d := range s.scope(doc) {
+		methods = append(methods, d.Methods...)
+	}
+
+	var native []*Definition
+	for typ, prototype := range evaluator.NewPrototypes() {
+		name := strings.Title(strings.ToLower(string(typ)))
+		for _, pair := range prototype.Pairs {
+			key := pair.Key.Inspect()
+			native = append(native, &Definition{
+				Name:      key,
+				Kind:      SymbolMethod,
+				Signature: name + ".prototype." + key,
+				Prototype: name,
+			})
+		}
+	}
+	sort.Slice(native, func(i, j int) bool {
+		return native[i].Signature < native[j].Signature
+	})
+	return append(methods, native...)
+}
+
+// identifierAt returns the identifier under pos and the receiver it is a member of, if any
+func (doc *Document) identifierAt(pos Position) (tok token.Token, receiver string, ok bool) {
+	i := doc.TokenAt(pos)
+	tok = doc.Token(i)
+	if tok.Type != token.IDENT {
+		return tok, "", false
+	}
+	if doc.Token(i-1).Type == token.DOT && doc.Token(i-2).Type == token.IDENT {
+		receiver = doc.Token(i - 2).Literal
+	}
+	return tok, receiver, true
+}
+
+func (s *Server) resolveIdentifier(doc *Document, pos Position) (token.Token, *Definition) {
+	tok, receiver, ok := doc.identifierAt(pos)
+	if !ok {
+		return tok, nil
+	}
+	if receiver != "" {
+		return tok, s.lookupMember(doc, receiver, tok.Literal, pos)
+	}
+	return tok, s.lookup(doc, tok.Literal, pos)
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) *Hover {
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+	tok, def := s.resolveIdentifier(doc, params.Position)
+	if def == nil {
+		return nil
+	}
+
+	var value strings.Builder
+	fmt.Fprintf(&value, "```monkey\n%s\n```", def.Signature)
+	if def.Doc != "" {
+		fmt.Fprintf(&value, "\n\n%s", def.Doc)
+	}
+	if def.Token.Filename == "" || filepath.Base(def.Token.Filename) == "builtin.mky" {
+		value.WriteString("\n\n*builtin*")
+	}
+
+	r := doc.Range(tok)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value.String()},
+		Range:    &r,
+	}
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) *Location {
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+
+	// The location of an include or import leads to its file
+	i := doc.TokenAt(params.Position)
+	if doc.Token(i).Type == token.STRING && doc.Token(i-1).Type == token.LPAREN {
+		if function := doc.Token(i - 2).Literal; function == "include" || function == "import" {
+			filename := s.resolve(doc, doc.Token(i).Literal)
+			if _, err := os.Stat(filename); err != nil {
+				return nil
+			}
+			return &Location{URI: s.uri(filename)}
+		}
+	}
+
+	_, def := s.resolveIdentifier(doc, params.Position)
+	if def == nil || def.Token.Filename == "" {
+		return nil
+	}
+	target := s.load(def.Token.Filename)
+	if target == nil {
+		return &Location{URI: s.uri(def.Token.Filename)}
+	}
+	return &Location{URI: s.uri(def.Token.Filename), Range: target.Range(def.Token)}
+}
+
+func (s *Server) documentSymbols(params DocumentSymbolParams) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return symbols
+	}
+	for _, def := range doc.Definitions {
+		if def.TopLevel {
+			symbols = append(symbols, documentSymbol(doc, def))
+		}
+	}
+	return symbols
+}
+
+func documentSymbol(doc *Document, def *Definition) DocumentSymbol {
+	r := doc.Range(def.Token)
+	symbol := DocumentSymbol{
+		Name:           def.Name,
+		Detail:         def.Signature,
+		Kind:           def.Kind,
+		Range:          r,
+		SelectionRange: r,
+	}
+	for _, child := range def.Children {
+		symbol.Children = append(symbol.Children, documentSymbol(doc, child))
+	}
+	return symbol
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) []CompletionItem {
+	items := []CompletionItem{}
+	doc := s.document(params.TextDocument.URI)
+	if doc == nil {
+		return items
+	}
+
+	seen := map[string]bool{}
+	add := func(def *Definition) {
+		if seen[def.Name] {
+			return
+		}
+		seen[def.Name] = true
+		items = append(items, CompletionItem{
+			Label:         def.Name,
+			Kind:          completionKind(def.Kind),
+			Detail:        def.Signature,
+			Documentation: def.Doc,
+		})
+	}
+
+	// Members are completed after a dot, the source is read directly
+	// since the document does not parse while the member is typed
+	line := []rune(doc.line(int64(params.Position.Line + 1)))
+	start := doc.runeColumn(params.Position)
+	for start > 0 && lexer.IsLetter(line[start-1]) {
+		start--
+	}
+	if start > 0 && line[start-1] == '.' {
+		end := start - 1
+		// x?.y completes the members of x
+		if end > 0 && line[end-1] == '?' {
+			end--
+		}
+		begin := end
+		for begin > 0 && lexer.IsLetter(line[begin-1]) {
+			begin--
+		}
+		if def := s.lookup(doc, string(line[begin:end]), params.Position); def != nil {
+			if members, ok := s.members(doc, def); ok {
+				for _, member := range members {
+					add(member)
+				}
+				return items
+			}
+		}
+		for _, method := range s.methods(doc) {
+			add(method)
+		}
+		return items
+	}
+
+	for _, def := range doc.Definitions {
+		add(def)
+	}
+	for _, d := range s.scope(doc)[1:] {
+		for _, def := range d.Definitions {
+			if def.TopLevel {
+				add(def)
+			}
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(s.lookupBuiltin(name))
+	}
+	return items
+}
+
+func completionKind(kind int) int {
+	switch kind {
+	case SymbolFunction:
+		return CompletionFunction
+	case SymbolMethod:
+		return CompletionMethod
+	case SymbolModule:
+		return CompletionModule
+	default:
+		return CompletionVariable
+	}
+}
+
+// uri returns the uri of a file, the one the client uses when the file is open
+func (s *Server) uri(filename string) string {
+	if uri, ok := s.uris[filename]; ok {
+		return uri
+	}
+	return filenameToURI(filename)
+}
+
+func uriToFilename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// Windows drives are written as /C:/...
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func filenameToURI(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,256 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mainSource = `include("util.mky")
+let shapes = import("shapes.mky")
+
+// Adds two numbers
+let add = fn(a, b) {
+    a + b
+}
+
+let total = add(double(2), shapes.area(3))
+let names = ["a"]
+names.len
+len(names)
+`
+
+const utilSource = `// Doubles a number
+let double = fn(x) { x * 2 }
+`
+
+const shapesSource = `let area = fn(side) { side * side }
+let unit = module {
+    let size = 1
+}
+`
+
+// session runs the server over a list of requests and returns its responses by id
+// and the diagnostics it published by uri
+func session(t *testing.T, requests []map[string]interface{}) (map[float64]json.RawMessage, map[string][]Diagnostic) {
+	var in bytes.Buffer
+	for _, request := range requests {
+		request["jsonrpc"] = "2.0"
+		if err := writeMessage(&in, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	server := NewServer(&in, &out, []string{filepath.Join("..", "lib")})
+	if err := server.Serve(); err != nil {
+		t.Fatalf("Serve failed. got=%v", err)
+	}
+
+	responses := map[float64]json.RawMessage{}
+	diagnostics := map[string][]Diagnostic{}
+	reader := bufio.NewReader(&out)
+	for {
+		content, err := readMessage(reader)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			ID     *float64
+			Method string
+			Params PublishDiagnosticsParams
+			Result json.RawMessage
+			Error  *responseError
+		}
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatalf("invalid response %s", content)
+		}
+		switch {
+		case msg.Error != nil:
+			t.Errorf("request %v failed. got=%s", *msg.ID, msg.Error.Message)
+		case msg.ID != nil:
+			responses[*msg.ID] = msg.Result
+		case msg.Method == "textDocument/publishDiagnostics":
+			diagnostics[msg.Params.URI] = msg.Params.Diagnostics
+		}
+	}
+	return responses, diagnostics
+}
+
+func positionRequest(id int, method string, uri string, line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": line, "character": character},
+		},
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, source := range map[string]string{"util.mky": utilSource, "shapes.mky": shapesSource} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainURI := filenameToURI(filepath.Join(dir, "main.mky"))
+	brokenURI := filenameToURI(filepath.Join(dir, "broken.mky"))
+
+	open := func(uri string, text string) map[string]interface{} {
+		return map[string]interface{}{
+			"method": "textDocument/didOpen",
+			"params": map[string]interface{}{
+				"textDocument": map[string]interface{}{"uri": uri, "languageId": "monkey", "version": 1, "text": text},
+			},
+		}
+	}
+	responses, diagnostics := session(t, []map[string]interface{}{
+		{"id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		{"method": "initialized", "params": map[string]interface{}{}},
+		open(mainURI, mainSource),
+		open(brokenURI, "let x = (1 + \n"),
+		positionRequest(2, "textDocument/hover", mainURI, 8, 13),
+		positionRequest(3, "textDocument/hover", mainURI, 8, 18),
+		positionRequest(4, "textDocument/definition", mainURI, 8, 18),
+		positionRequest(5, "textDocument/definition", mainURI, 8, 34),
+		positionRequest(6, "textDocument/definition", mainURI, 0, 12),
+		positionRequest(7, "textDocument/completion", mainURI, 10, 6),
+		positionRequest(8, "textDocument/completion", mainURI, 8, 25),
+		{"id": 9, "method": "textDocument/documentSymbol", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": filenameToURI(filepath.Join(dir, "shapes.mky"))},
+		}},
+		positionRequest(10, "textDocument/hover", mainURI, 11, 1),
+		{"id": 11, "method": "shutdown"},
+		{"method": "exit"},
+	})
+
+	if len(diagnostics[mainURI]) != 0 {
+		t.Errorf("unexpected diagnostics. got=%+v", diagnostics[mainURI])
+	}
+	if broken := diagnostics[brokenURI]; len(broken) == 0 || broken[0].Severity != SeverityError || broken[0].Code == "" {
+		t.Errorf("expected an error diagnostic. got=%+v", broken)
+	}
+
+	var hover Hover
+	json.Unmarshal(responses[2], &hover)
+	if !strings.Contains(hover.Contents.Value, "let add = fn(a, b)") || !strings.Contains(hover.Contents.Value, "Adds two numbers") {
+		t.Errorf("wrong hover for add. got=%q", hover.Contents.Value)
+	}
+	json.Unmarshal(responses[3], &hover)
+	if !strings.Contains(hover.Contents.Value, "let double = fn(x)") || !strings.Contains(hover.Contents.Value, "Doubles a number") {
+		t.Errorf("wrong hover for double. got=%q", hover.Contents.Value)
+	}
+
+	expectLocation := func(id float64, file string, line int, character int) {
+		var location Location
+		if err := json.Unmarshal(responses[id], &location); err != nil {
+			t.Errorf("invalid location %s", responses[id])
+		}
+		if location.URI != filenameToURI(filepath.Join(dir, file)) || location.Range.Start.Line != line || location.Range.Start.Character != character {
+			t.Errorf("wrong definition for request %v. got=%+v", id, location)
+		}
+	}
+	expectLocation(4, "util.mky", 1, 4)
+	expectLocation(5, "shapes.mky", 0, 4)
+	expectLocation(6, "util.mky", 0, 0)
+
+	labels := func(id float64) map[string]bool {
+		var items []CompletionItem
+		json.Unmarshal(responses[id], &items)
+		result := map[string]bool{}
+		for _, item := range items {
+			result[item.Label] = true
+		}
+		return result
+	}
+	members := labels(7)
+	for _, want := range []string{"length", "push", "map"} {
+		if !members[want] {
+			t.Errorf("missing prototype method %q in completion", want)
+		}
+	}
+	identifiers := labels(8)
+	for _, want := range []string{"add", "total", "double", "shapes", "len", "writeLine", "Error"} {
+		if !identifiers[want] {
+			t.Errorf("missing identifier %q in completion", want)
+		}
+	}
+
+	var symbols []DocumentSymbol
+	json.Unmarshal(responses[9], &symbols)
+	if len(symbols) != 2 || symbols[0].Name != "area" || symbols[0].Kind != SymbolFunction ||
+		symbols[1].Kind != SymbolModule || len(symbols[1].Children) != 1 {
+		t.Errorf("wrong document symbols. got=%+v", symbols)
+	}
+
+	json.Unmarshal(responses[10], &hover)
+	if !strings.Contains(hover.Contents.Value, "let len = fn(ele)") || !strings.Contains(hover.Contents.Value, "builtin") {
+		t.Errorf("wrong hover for builtin. got=%q", hover.Contents.Value)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	var in, out bytes.Buffer
+	writeMessage(&in, map[string]interface{}{"jsonrpc": "2.0", "method": "exit"})
+	if err := NewServer(&in, &out, nil).Serve(); err != ErrNoShutdown {
+		t.Errorf("expected ErrNoShutdown. got=%v", err)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	source := "let s = \"a\\tb\" + `r\\n` + '''x\ny''' + \"😀\"; let é = s\n"
+	doc := NewDocument("ranges.mky", source)
+
+	tests := []struct {
+		index    int
+		expected Range
+	}{
+		{3, Range{Start: Position{Line: 0, Character: 8}, End: Position{Line: 0, Character: 14}}},  // "a\tb"
+		{5, Range{Start: Position{Line: 0, Character: 17}, End: Position{Line: 0, Character: 22}}}, // `r\n`
+		{7, Range{Start: Position{Line: 0, Character: 25}, End: Position{Line: 1, Character: 4}}},  // '''x\ny'''
+		{9, Range{Start: Position{Line: 1, Character: 7}, End: Position{Line: 1, Character: 11}}},  // "😀" is two UTF-16 code units
+		{12, Range{Start: Position{Line: 1, Character: 17}, End: Position{Line: 1, Character: 18}}},
+	}
+	for _, tt := range tests {
+		if r := doc.TokenRange(tt.index); r != tt.expected {
+			t.Errorf("wrong range of %q. want=%+v, got=%+v", doc.Token(tt.index).Literal, tt.expected, r)
+		}
+	}
+
+	def := doc.Lookup("é", Position{Line: 1, Character: 21})
+	if def == nil || doc.Range(def.Token) != tests[4].expected {
+		t.Errorf("wrong definition of é. got=%+v", def)
+	}
+	if i := doc.TokenAt(Position{Line: 1, Character: 17}); i != 12 {
+		t.Errorf("wrong token at the é. got=%d", i)
+	}
+	if column := doc.runeColumn(Position{Line: 1, Character: 17}); column != 16 {
+		t.Errorf("wrong rune column of the é. got=%d", column)
+	}
+
+	// Every token of the library has its range
+	files, err := filepath.Glob(filepath.Join("..", "lib", "std", "*.mky"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no library files. got=%v", err)
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc := NewDocument(file, string(content)); len(doc.ranges) != len(doc.tokens) {
+			t.Errorf("%s has %d tokens and %d ranges", file, len(doc.tokens), len(doc.ranges))
+		}
+	}
+}
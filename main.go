@@ -1,36 +1,61 @@
 package main
 
 import (
-	"Monkey/evaluator"
-	"Monkey/object"
+	"Monkey/lsp"
+	"Monkey/monkey"
 	"Monkey/repl"
+	"Monkey/tmp"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
 )
 
 func main() {
+	engine := flag.String("engine", monkey.EngineTree, "the engine running the program, vm or tree")
+	strict := flag.Bool("strict", false, "calling a function with too many or too few arguments is an error")
+	flag.Parse()
+
+	// Language server, speaking LSP over stdio
+	if flag.NArg() == 1 && flag.Arg(0) == "lsp" {
+		server := lsp.NewServer(os.Stdin, os.Stdout, []string{tmp.STDDirectory})
+		if err := server.Serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "Language server stopped: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Formatter
+	if flag.NArg() >= 1 && flag.Arg(0) == "fmt" {
+		os.Exit(runFmt(flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	// Documentation generator
+	if flag.NArg() >= 1 && flag.Arg(0) == "doc" {
+		os.Exit(runDoc(flag.Args()[1:], []string{tmp.STDDirectory}, tmp.CurrentDirectory, os.Stdout, os.Stderr))
+	}
 
 	// Run File
-	if len(os.Args) == 2 {
+	if flag.NArg() == 1 {
 
 		// Get filename
-		filename := os.Args[1]
+		filename := flag.Arg(0)
 
-		// Create env
-		env := object.NewEnvironment()
-
-		// Link std
-		err := evaluator.LinkSTD(env)
+		// Create the interpreter, this links std
+		interpreter, err := monkey.New(monkey.Options{Engine: *engine, Debug: true, StrictArity: *strict})
 		if err != nil {
-			fmt.Printf("Failed to compile the standard library\n")
+			fmt.Printf("Failed to create the interpreter: %s\n", err)
 			return
 		}
 
-		// Compile
-		err = evaluator.LinkAndEval(filename, env)
+		// Compile, the parse errors are rendered by the runner
+		_, err = interpreter.EvalFile(filename)
 
 		if err != nil {
+			if runtimeErr, ok := err.(*monkey.RuntimeError); ok {
+				fmt.Fprintln(os.Stderr, runtimeErr.Err.Inspect())
+			}
 			fmt.Printf("Failed to compile file %q\n", filename)
 			return
 		}
@@ -49,13 +74,5 @@ func main() {
 	fmt.Printf("REPL Started!\n")
 
 	// Start the repl
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, *engine)
 }
-
-//func LinkFile(libraryName string, env *object.Environment) {
-//	p, e := runner.GetInstance().Compile(libraryName)
-//	if e != nil {
-//		panic("Failed to link library " + libraryName)
-//	}
-//	evaluator.Eval(p, env)
-//}
@@ -0,0 +1,2637 @@
+package monkey
+
+import (
+	"Monkey/object"
+	"bytes"
+	"testing"
+)
+
+// engineTest is a program both engines must evaluate to the same result, expected is the inspected
+// result or the message of the error the program stops with
+type engineTest struct {
+	name     string
+	input    string
+	expected string
+}
+
+// countGenerator yields the numbers below n and returns 'end'
+const countGenerator = `let count = fn*(n) {
+	let i = 0
+	while i < n {
+		yield i
+		i += 1
+	}
+	return 'end'
+}
+`
+
+var engineTests = []engineTest{
+	// Operators and numbers
+	{name: "precedence", input: `1 + 2 * 3`, expected: "7"},
+	{name: "integer division", input: `(5 - 10) / 2`, expected: "-2"},
+	{name: "float division", input: `(5 - 10) / 2.0`, expected: "-2.5"},
+	{name: "float addition", input: `1.5 + 1.5`, expected: "3.0"},
+	{name: "decimal times float", input: `1.10d * 1.5`, expected: "1.650"},
+	{name: "float times decimal", input: `1.5 * 1.10d`, expected: "1.650"},
+	{name: "float plus decimal", input: `0.1 + 0.2d`, expected: "0.3"},
+	{name: "decimal plus float", input: `0.2d + 0.1`, expected: "0.3"},
+	{name: "big integer plus float", input: `2n ** 64 + 0.5`, expected: "18446744073709551616.5"},
+	{name: "float plus big integer", input: `0.5 + 2n ** 64`, expected: "18446744073709551616.5"},
+	{name: "decimal to a float power", input: `2.25d ** 0.5`, expected: "1.5"},
+	{name: "integers beyond float precision", input: `9007199254740993 + 0`, expected: "9007199254740993"},
+	{name: "float hash keys", input: `{1: 1, 1.5: 2}[1.5]`, expected: "2"},
+	{name: "integer overflow to bigint", input: `9223372036854775807 + 1`, expected: "9223372036854775808"},
+	{name: "decimal addition", input: `1.10d + 2.20d`, expected: "3.30"},
+	{name: "bigint literal", input: `123n * 2`, expected: "246"},
+	{
+		name: "template with expressions",
+		input: `let n = 2
+'${n} + ${n} = ${n + n}'`,
+		expected: "2 + 2 = 4",
+	},
+	{name: "modulo", input: `7 % 4`, expected: "3"},
+	{name: "bitwise operators", input: `0xF0 & 0x3C | 1 ^ 3`, expected: "50"},
+	{name: "shifts and complement", input: `~5 << 2 >> 1`, expected: "-12"},
+	{name: "power overflow to bigint", input: `2 ** 62 * 4`, expected: "18446744073709551616"},
+	{
+		name: "compound shift and or",
+		input: `let f = 1
+f <<= 3
+f |= 1
+f`,
+		expected: "9",
+	},
+	{name: "unary plus and minus", input: `-5 + +2`, expected: "-3"},
+	{name: "and", input: `1 < 2 and 2 < 1`, expected: "false"},
+	{name: "or", input: `0 or 3`, expected: "true"},
+	{name: "xor", input: `1 xor 0`, expected: "true"},
+	{name: "bang", input: `!0`, expected: "true"},
+	{name: "string concatenation", input: `"ab" + "c"`, expected: "abc"},
+	{name: "array index", input: `[1, 2 + 1][1]`, expected: "3"},
+	{name: "string slice", input: `"hello"[1:3]`, expected: "el"},
+	{name: "hash index", input: `{"a": 1}["a"]`, expected: "1"},
+	{name: "if without else", input: `if 1 > 2 { 1 }`, expected: "null"},
+	{name: "if else", input: `if 1 > 2 { 1 } else { 2 }`, expected: "2"},
+
+	// Strings
+	{
+		name: "template with identifier",
+		input: `let name = 'Ann'
+'Hello ${name}!'`,
+		expected: "Hello Ann!",
+	},
+	{
+		name: "template with call",
+		input: `let items = [1, 2]
+'${len(items)} items: ${items}'`,
+		expected: "2 items: [1, 2]",
+	},
+	{name: "adjacent templates", input: `"${1 + 2 * 3}${'x'}"`, expected: "7x"},
+	{name: "nested templates", input: `'nested ${"inner ${1 + 1}"}'`, expected: "nested inner 2"},
+	{name: "hash in template", input: `"${ {"a": 1}["a"] }"`, expected: "1"},
+	{name: "escaped template", input: `"\${escaped}"`, expected: "${escaped}"},
+	{name: "error in template", input: `"${error("oops")}"`, expected: "oops"},
+	{
+		name: "missing identifier in template",
+		input: `let f = fn() { '${missing}' }
+f()`,
+		expected: "identifier not found: missing",
+	},
+	{name: "len counts code points", input: `len("naïve 😀")`, expected: "7"},
+	{name: "length counts code points", input: `"naïve 😀".length`, expected: "7"},
+	{name: "index by code point", input: `"naïve 😀"[2]`, expected: "ï"},
+	{name: "negative index by code point", input: `"naïve 😀"[-1]`, expected: "😀"},
+	{name: "slice by code point", input: `"naïve 😀"[1:4]`, expected: "aïv"},
+	{name: "open slice by code point", input: `"naïve 😀"[6:]`, expected: "😀"},
+	{name: "bytes of a string", input: `bytes("é")`, expected: "[195, 169]"},
+	{name: "bytes member", input: `"aé".bytes`, expected: "[97, 195, 169]"},
+	{name: "bytes of an emoji", input: `len(bytes("😀"))`, expected: "4"},
+	{
+		name: "unicode identifier",
+		input: `let größe = 2
+größe * 2`,
+		expected: "4",
+	},
+	{name: "index out of range by code point", input: `"😀"[1]`, expected: "index out of range. got=1, expected=0-0"},
+
+	// Variables and assignments
+	{
+		name: "let",
+		input: `let a = 1
+let b = a + 1
+b`,
+		expected: "2",
+	},
+	{
+		name: "assign",
+		input: `let a = 1
+a = 5
+a`,
+		expected: "5",
+	},
+	{
+		name: "assign index",
+		input: `let a = [1, 2]
+a[0] = 3
+a`,
+		expected: "[3, 2]",
+	},
+	{
+		name: "assign member",
+		input: `let h = {}
+h.x = 2
+h.x`,
+		expected: "2",
+	},
+	{
+		name: "assign in nested block",
+		input: `let f = fn() {
+	let a = 1
+	if true {
+		let b = 2
+		a = a + b
+	}
+	a
+}
+f()`,
+		expected: "3",
+	},
+	{
+		name: "compound assign",
+		input: `let a = 2
+a *= 3
+a -= 1`,
+		expected: "5",
+	},
+	{
+		name: "compound assign captured",
+		input: `let f = fn() {
+	let a = 'x'
+	let g = fn() { a += 'y' }
+	g()
+	a += 'z'
+}
+f()`,
+		expected: "xyz",
+	},
+	{
+		name: "compound assign nested index",
+		input: `let a = [1, [2]]
+a[1][0] += 3
+a[0] ??= 5
+a`,
+		expected: "[1, [5]]",
+	},
+	{
+		name: "compound assign member",
+		input: `let h = {}
+h.n ??= 1
+h['n'] <<= 4
+h.m ??= h.n
+[h.n, h.m]`,
+		expected: "[16, 16]",
+	},
+	{
+		name: "compound assign target evaluated once",
+		input: `let n = 0
+let i = fn() {
+	n += 1
+	0
+}
+let a = [10]
+a[i()] /= 4
+[a, n]`,
+		expected: "[[2], 1]",
+	},
+	{
+		name: "increments and decrements",
+		input: `let a = 1
+[a++, ++a, a--, --a, a]`,
+		expected: "[1, 3, 3, 1, 1]",
+	},
+	{
+		name: "increment captured",
+		input: `let f = fn() {
+	let x = 1
+	let g = fn() { x++ }
+	[g(), g(), x]
+}
+f()`,
+		expected: "[1, 2, 3]",
+	},
+	{
+		name: "update target evaluated once",
+		input: `let n = 0
+let i = fn() {
+	n += 1
+	0
+}
+let a = [[10]]
+[a[0][i()]--, ++a[i()][0], a, n]`,
+		expected: "[10, 10, [[10]], 2]",
+	},
+	{
+		name: "update members",
+		input: `let h = {'n': 1}
+let f = fn() { [h.n++, --h.n, h['n']++, h] }
+f()`,
+		expected: "[1, 1, 1, {n: 2}]",
+	},
+	{
+		name: "let array pattern",
+		input: `let [a, [b], ...c] = [1, [2], 3, 4]
+[a, b, c]`,
+		expected: "[1, 2, [3, 4]]",
+	},
+	{
+		name: "let hash pattern",
+		input: `let {x, y: z = 3} = {'x': 1}
+[x, z]`,
+		expected: "[1, 3]",
+	},
+	{
+		name: "parameter patterns captured",
+		input: `let f = fn({n}, [m = n]) {
+	let g = fn() { n + m }
+	g()
+}
+f({'n': 2}, [])`,
+		expected: "4",
+	},
+	{
+		name: "compound assign chain",
+		input: `let a = 5
+a += 2
+a -= 1
+a *= 3
+a /= 4
+a %= 3
+a`,
+		expected: "1",
+	},
+	{
+		name: "compound assign string",
+		input: `let s = 'ab'
+s += 'c'`,
+		expected: "abc",
+	},
+	{
+		name: "compound assign index",
+		input: `let a = [1, 2]
+a[1] += 5
+a`,
+		expected: "[1, 7]",
+	},
+	{
+		name: "compound assign hash",
+		input: `let h = {'k': 1}
+h['k'] *= 10
+h.k -= 1
+h`,
+		expected: "{k: 9}",
+	},
+	{
+		name: "compound assign module member",
+		input: `let m = module { let count = 1 }
+m.count += 1
+m.count`,
+		expected: "2",
+	},
+	{
+		name: "null coalescing assign",
+		input: `let n = null
+n ??= 1
+n ??= 2
+n`,
+		expected: "1",
+	},
+	{
+		name: "null coalescing assign members",
+		input: `let h = {}
+h['a'] ??= [1]
+h.a ??= [2]
+h.b ??= 3
+[h.a, h.b]`,
+		expected: "[[1], 3]",
+	},
+	{
+		name: "null coalescing assign short circuits",
+		input: `let hits = 0
+let x = 1
+x ??= fn() { hits = 1 }()
+hits`,
+		expected: "0",
+	},
+	{
+		name: "compound assign index evaluated once",
+		input: `let calls = 0
+let a = [1, 2, 3]
+let at = fn(i) {
+	calls = calls + 1
+	i
+}
+a[at(2)] += a[at(0)]
+[a, calls]`,
+		expected: "[[1, 2, 4], 2]",
+	},
+	{
+		name: "compound assign member evaluated once",
+		input: `let calls = 0
+let h = {'n': 1}
+let get = fn() {
+	calls = calls + 1
+	h
+}
+get().n <<= 2
+get()['n'] |= 1
+[h.n, calls]`,
+		expected: "[5, 2]",
+	},
+	{
+		name: "compound assign in loop",
+		input: `let f = fn() {
+	let total = 0
+	for x in [1, 2, 3] { total += x }
+	total
+}
+f()`,
+		expected: "6",
+	},
+	{
+		name: "assign expression value",
+		input: `let a = 1
+(a += 1) + a`,
+		expected: "4",
+	},
+	{
+		name: "postfix increment value",
+		input: `let a = 1
+a++
+a++ + a`,
+		expected: "5",
+	},
+	{
+		name: "prefix and postfix",
+		input: `let a = 1
+[++a, a--, --a, a]`,
+		expected: "[2, 2, 0, 0]",
+	},
+	{
+		name: "increment float",
+		input: `let f = 1.5
+f++
+f`,
+		expected: "2.5",
+	},
+	{
+		name: "increment index evaluated once",
+		input: `let calls = 0
+let a = [1, 2]
+let at = fn(i) {
+	calls = calls + 1
+	i
+}
+[a[at(1)]++, a, calls]`,
+		expected: "[2, [1, 3], 1]",
+	},
+	{
+		name: "increment members",
+		input: `let h = {'n': 1}
+h.n++
+--h['n']
+h.n++ + h.n`,
+		expected: "3",
+	},
+	{
+		name: "increment in loop",
+		input: `let f = fn() {
+	let i = 0
+	while i < 3 { i++ }
+	i
+}
+f()`,
+		expected: "3",
+	},
+	{
+		name: "decrement string",
+		input: `let s = 'a'
+s--`,
+		expected: "type mismatch: STRING - INTEGER",
+	},
+	{name: "assign to missing", input: `b += 1`, expected: "identifier not found: b"},
+	{
+		name: "compound assign out of range",
+		input: `let a = [1]
+a[3] += 1`,
+		expected: "index out of range. got=3, expected=0-0",
+	},
+	{
+		name: "compound assign type mismatch",
+		input: `let a = 1
+a += 'x'`,
+		expected: "type mismatch: INTEGER + STRING",
+	},
+	{
+		name: "compound assign invalid target",
+		input: `let a = [1]
+a.x += 1`,
+		expected: "left expression is not a valid target. got=ARRAY",
+	},
+
+	// Destructuring
+	{
+		name: "array pattern",
+		input: `let [a, b] = [1, 2, 3]
+[b, a]`,
+		expected: "[2, 1]",
+	},
+	{
+		name: "array rest",
+		input: `let [a, ...rest] = [1, 2, 3]
+rest`,
+		expected: "[2, 3]",
+	},
+	{
+		name: "empty rest",
+		input: `let [a, b, ...rest] = [1, 2]
+rest`,
+		expected: "[]",
+	},
+	{
+		name: "nested array patterns",
+		input: `let [a, [b, [c]]] = [1, [2, [3]]]
+a + b + c`,
+		expected: "6",
+	},
+	{
+		name: "defaults",
+		input: `let [x = 5, y = x * 2] = []
+[x, y]`,
+		expected: "[5, 10]",
+	},
+	{
+		name: "default replaces null",
+		input: `let [x = 5] = [null]
+x`,
+		expected: "5",
+	},
+	{
+		name: "default not evaluated",
+		input: `let calls = 0
+let [x = fn() { calls = calls + 1 }()] = [1]
+calls`,
+		expected: "0",
+	},
+	{
+		name: "hash pattern",
+		input: `let {name, age: years} = {'name': 'Ann', 'age': 30}
+'${name} ${years}'`,
+		expected: "Ann 30",
+	},
+	{
+		name: "nested hash pattern",
+		input: `let {a: {b: [c, d = 4]}} = {'a': {'b': [3]}}
+[c, d]`,
+		expected: "[3, 4]",
+	},
+	{
+		name: "hash default",
+		input: `let {missing = 'none'} = {}
+missing`,
+		expected: "none",
+	},
+	{
+		name: "module pattern",
+		input: `let m = module { let x = 1 }
+let {x} = m
+x`,
+		expected: "1",
+	},
+	{
+		name: "parameter patterns",
+		input: `let f = fn([a, b], {c}) { a + b + c }
+f([1, 2], {'c': 3})`,
+		expected: "6",
+	},
+	{
+		name: "parameter rest pattern",
+		input: `let f = fn(x, [y, ...ys]) { [x, y, ys] }
+f(0, [1, 2, 3])`,
+		expected: "[0, 1, [2, 3]]",
+	},
+	{name: "missing element", input: `let [a, b] = [1]`, expected: "cannot destructure the element 1 of an array of length 1"},
+	{
+		name: "string with an array pattern",
+		input: `let [a, ...rest] = 'héllo'
+[a, rest]`,
+		expected: "[h, [é, l, l, o]]",
+	},
+	{
+		name: "hash keys with an array pattern",
+		input: `let [first, second] = {'b': 1, 'a': 2}
+first + second`,
+		expected: "ab",
+	},
+	{
+		name: "channel with an array pattern",
+		input: `let c = channel(3)
+c.send(1)
+c.send(2)
+c.close()
+let [x, ...rest] = c
+[x, rest]`,
+		expected: "[1, [2]]",
+	},
+	{name: "short string with an array pattern", input: `let [a, b] = 'a'`, expected: "cannot destructure the element 1 of an array of length 1"},
+	{name: "missing key", input: `let {a} = {'b': 1}`, expected: "cannot destructure the missing key \"a\""},
+	{name: "array with a hash pattern", input: `let {a} = [1]`, expected: "cannot destructure ARRAY with a hash pattern"},
+	{name: "integer with an array pattern", input: `let [a, ...b] = 1`, expected: "cannot destructure INTEGER with an array pattern"},
+	{
+		name: "missing argument pattern",
+		input: `let f = fn([a]) { a }
+f()`,
+		expected: "cannot destructure NULL with an array pattern",
+	},
+	{name: "missing identifier in default", input: `let [a = b] = []`, expected: "identifier not found: b"},
+
+	// Functions and arguments
+	{
+		name: "call",
+		input: `let add = fn(a, b) { a + b }
+add(1, 2)`,
+		expected: "3",
+	},
+	{
+		name: "missing argument",
+		input: `let f = fn(a, b) { b }
+f(1)`,
+		expected: "null",
+	},
+	{
+		name: "extra arguments",
+		input: `let f = fn(a) { a }
+f(1, 2, 3)`,
+		expected: "1",
+	},
+	{
+		name: "early return",
+		input: `let f = fn() {
+	return 1
+	2
+}
+f()`,
+		expected: "1",
+	},
+	{
+		name: "recursion",
+		input: `let fib = fn(n) {
+	if n < 2 { return n }
+	fib(n - 1) + fib(n - 2)
+}
+fib(15)`,
+		expected: "610",
+	},
+	{name: "builtin", input: `len([1, 2, 3])`, expected: "3"},
+	{
+		name: "default and rest",
+		input: `let f = fn(a, b = a * 2, ...rest) { [a, b, rest] }
+f(1)`,
+		expected: "[1, 2, []]",
+	},
+	{
+		name: "null argument takes default",
+		input: `let f = fn(a, b = a * 2, ...rest) { [a, b, rest] }
+f(1, null, 3, 4)`,
+		expected: "[1, 2, [3, 4]]",
+	},
+	{
+		name: "default pattern",
+		input: `let f = fn([a, b] = [1, 2]) { a + b }
+f()`,
+		expected: "3",
+	},
+	{
+		name: "named arguments",
+		input: `let f = fn(a, b) { a - b }
+f(b: 1, a: 5)`,
+		expected: "4",
+	},
+	{
+		name: "spread and named arguments",
+		input: `let f = fn(a, b, c) { [a, b, c] }
+let xs = [2, 3]
+f(...xs, c: 1)`,
+		expected: "[2, 3, 1]",
+	},
+	{
+		name: "spread into array",
+		input: `let xs = [2, 3]
+[1, ...xs, 4, ...'ab']`,
+		expected: "[1, 2, 3, 4, a, b]",
+	},
+	{
+		name: "spread into hash",
+		input: `let h = {'a': 1, 'b': 2}
+let g = {...h, 'b': 3}
+[g.a, g.b, h.b]`,
+		expected: "[1, 3, 2]",
+	},
+	{
+		name: "default",
+		input: `let f = fn(a, b = 10) { a + b }
+f(1)`,
+		expected: "11",
+	},
+	{
+		name: "default for null",
+		input: `let f = fn(a, b = 10) { a + b }
+f(1, null)`,
+		expected: "11",
+	},
+	{
+		name: "default uses earlier parameter",
+		input: `let f = fn(a, b = a * 2) { b }
+f(4)`,
+		expected: "8",
+	},
+	{
+		name: "default argument not evaluated",
+		input: `let calls = 0
+let f = fn(a = fn() { calls = calls + 1 }()) { a }
+f(1)
+calls`,
+		expected: "0",
+	},
+	{
+		name: "rest",
+		input: `let f = fn(a, ...rest) { rest }
+f(1, 2, 3)`,
+		expected: "[2, 3]",
+	},
+	{
+		name: "empty rest parameter",
+		input: `let f = fn(...rest) { rest }
+f()`,
+		expected: "[]",
+	},
+	{
+		name: "default hash pattern",
+		input: `let f = fn({x} = {'x': 5}) { x }
+f()`,
+		expected: "5",
+	},
+	{
+		name: "named arguments in any order",
+		input: `let f = fn(a, b) { a - b }
+f(b: 1, a: 10)`,
+		expected: "9",
+	},
+	{
+		name: "named argument skips default",
+		input: `let f = fn(a, b = 2, c = 3) { [a, b, c] }
+f(1, c: 5)`,
+		expected: "[1, 2, 5]",
+	},
+	{
+		name: "spread arguments",
+		input: `let f = fn(a, b, c) { a + b + c }
+let xs = [1, 2, 3]
+f(...xs)`,
+		expected: "6",
+	},
+	{
+		name: "spread arguments and rest",
+		input: `let f = fn(a, ...rest) { rest }
+f(...[1, 2], 3, ...'ab')`,
+		expected: "[2, 3, a, b]",
+	},
+	{
+		name: "extra argument ignored",
+		input: `let f = fn(a) { a }
+f(1, 2)`,
+		expected: "1",
+	},
+	{
+		name: "spread in array",
+		input: `let xs = [2, 3]
+[1, ...xs, 4]`,
+		expected: "[1, 2, 3, 4]",
+	},
+	{
+		name: "spread copies",
+		input: `let xs = [2, 3]
+let ys = [...xs]
+ys.push(4)
+xs`,
+		expected: "[2, 3]",
+	},
+	{
+		name: "spread hash",
+		input: `let h = {'a': 1, 'b': 2}
+let g = {...h, 'b': 3}
+[g.a, g.b, h.b, len(g)]`,
+		expected: "[1, 3, 2, 2]",
+	},
+	{
+		name: "unknown named argument",
+		input: `let f = fn(a) { a }
+f(b: 1)`,
+		expected: "f has no parameter named b",
+	},
+	{
+		name: "argument given twice",
+		input: `let f = fn(a) { a }
+f(1, a: 2)`,
+		expected: "the argument a of f is given twice",
+	},
+	{name: "named argument to builtin", input: `len(ele: 'a')`, expected: "builtin functions do not take named arguments"},
+	{name: "spread integer", input: `[...1]`, expected: "cannot spread INTEGER"},
+	{name: "spread integer into hash", input: `let h = {...1}`, expected: "cannot spread INTEGER into a hash"},
+	{
+		name: "missing identifier in default argument",
+		input: `let f = fn(a = b) { a }
+f()`,
+		expected: "identifier not found: b",
+	},
+
+	// Closures and modules
+	{
+		name: "adder",
+		input: `let adder = fn(a) { fn(b) { a + b } }
+adder(2)(3)`,
+		expected: "5",
+	},
+	{
+		name: "counter",
+		input: `let counter = fn() {
+	let c = 0
+	fn() { c = c + 1 }
+}
+let k = counter()
+k()
+k()`,
+		expected: "2",
+	},
+	{
+		name: "nested capture",
+		input: `let f = fn(a) { fn() { fn() { a } } }
+f(4)()()`,
+		expected: "4",
+	},
+	{
+		name: "closure before let",
+		input: `let f = fn() {
+	let g = fn() { x }
+	let x = 7
+	g()
+}
+f()`,
+		expected: "7",
+	},
+	{
+		name: "builtin calling closure",
+		input: `let f = fn() {
+	let n = 0
+	__loop(fn(t) { n = n + t }, 4)
+	n
+}
+f()`,
+		expected: "6",
+	},
+	{
+		name: "module member assign",
+		input: `let m = module {
+	let a = 1
+	let get = fn() { a }
+}
+m.a = 5
+m.get()`,
+		expected: "5",
+	},
+	{
+		name: "module capturing argument",
+		input: `let f = fn(x) { module { let y = x * 2 } }
+f(3).y`,
+		expected: "6",
+	},
+	{
+		name: "outer variable read before the let shadowing it",
+		input: `let x = 1
+let f = fn() {
+	let y = x
+	let x = 2
+	[y, x]
+}
+f()`,
+		expected: "[1, 2]",
+	},
+	{
+		name: "let initialized from the outer variable",
+		input: `let x = "global"
+let f = fn() {
+	let s = x + "!"
+	let x = s
+	x
+}
+f()`,
+		expected: "global!",
+	},
+	{
+		name: "let in a branch shadowing an argument",
+		input: `let n = 10
+fn() {
+	if n > 5 {
+		let n = 1
+		n
+	} else {
+		n
+	}
+}()`,
+		expected: "1",
+	},
+	{
+		name: "closure called before and after the let",
+		input: `let x = 1
+let f = fn() {
+	let g = fn() { x }
+	let before = g()
+	let x = 2
+	[before, g()]
+}
+f()`,
+		expected: "[1, 2]",
+	},
+
+	// Quotes
+	{
+		name: "unquoted global",
+		input: `let x = 2
+quote(x + unquote(x))`,
+		expected: "QUOTE((x + 2))",
+	},
+	{name: "unquoted argument", input: `fn(n) { quote(unquote(n) + 1) }(3)`, expected: "QUOTE((3 + 1))"},
+	{
+		name: "unquoted values of each type",
+		input: `let x = 2
+quote(unquote(quote(a)) * unquote(1 < 2) - unquote(1.5) + unquote(x + x))`,
+		expected: "QUOTE((((a * true) - 1.5) + 4))",
+	},
+	{
+		name: "quote spliced into a quote",
+		input: `let x = 2
+let negate = fn(q) { quote(-unquote(q)) }
+negate(quote(unquote(x) + 1))`,
+		expected: "QUOTE(-(2 + 1))",
+	},
+
+	// Arrow functions and pipes
+	{
+		name: "arrow",
+		input: `let double = x => x * 2
+double(4)`,
+		expected: "8",
+	},
+	{
+		name: "arrow block and default",
+		input: `let add = (a, b = 10) => { a + b }
+[add(1), add(1, 2)]`,
+		expected: "[11, 3]",
+	},
+	{
+		name: "arrow without parameters",
+		input: `let f = () => 42
+f()`,
+		expected: "42",
+	},
+	{
+		name: "arrow patterns and rest",
+		input: `let f = ([a, b], ...rest) => [b, rest]
+f([1, 2], 3, 4)`,
+		expected: "[2, [3, 4]]",
+	},
+	{
+		name: "curried arrows",
+		input: `let adder = a => b => a + b
+adder(2)(3)`,
+		expected: "5",
+	},
+	{
+		name: "arrow argument",
+		input: `let apply = (f, x) => f(x)
+apply(x => x * 10, 2)`,
+		expected: "20",
+	},
+	{
+		name: "pipe",
+		input: `let inc = x => x + 1
+5 |> inc |> inc`,
+		expected: "7",
+	},
+	{
+		name: "pipe with arguments",
+		input: `let sub = (a, b) => a - b
+10 |> sub(3)`,
+		expected: "7",
+	},
+	{
+		name: "pipe with named argument",
+		input: `let sub = (a, b) => a - b
+10 |> sub(b: 4)`,
+		expected: "6",
+	},
+	{name: "pipe into builtin", input: `[1, 2, 3] |> len`, expected: "3"},
+	{
+		name: "pipe evaluation order",
+		input: `let calls = []
+let log = (x, tag) => {
+	calls.push(tag)
+	x
+}
+1 |> log('a') |> log('b')
+calls`,
+		expected: "[a, b]",
+	},
+	{
+		name: "pipe prepends argument",
+		input: `let f = x => x
+3 |> f(4)`,
+		expected: "3",
+	},
+	{name: "pipe into integer", input: `5 |> 3`, expected: "not a function: INTEGER"},
+	{
+		name: "arrow in arrow",
+		input: `let apply = (f, x) => f(x)
+let f = () => apply(x => x * 10, 2)
+f()`,
+		expected: "20",
+	},
+	{
+		name: "pipe chain",
+		input: `let sub = (a, b) => a - b
+let inc = x => x + 1
+10 |> sub(3) |> inc`,
+		expected: "8",
+	},
+
+	// Conditionals and optional chaining
+	{name: "ternary", input: `true ? 1 : 2`, expected: "1"},
+	{name: "ternary falsy", input: `0 ? 1 : 2`, expected: "2"},
+	{name: "ternary without spaces", input: `let x = false; x?1:2`, expected: "2"},
+	{name: "ternary right after a name", input: `let x = true; x? 'yes' : 'no'`, expected: "yes"},
+	{name: "names ending with a question mark", input: `let empty? = fn(a) { len(a) == 0 }; empty?([]) ? 'empty' : 'full'`, expected: "empty"},
+	{
+		name: "nested ternary",
+		input: `let n = 85
+n > 90 ? 'A' : n > 80 ? 'B' : 'C'`,
+		expected: "B",
+	},
+	{
+		name: "ternary short circuits",
+		input: `let calls = 0
+let f = fn() { calls += 1 }
+true ? 1 : f()
+calls`,
+		expected: "0",
+	},
+	{name: "null coalescing", input: `null ?? 5`, expected: "5"},
+	{name: "null coalescing keeps false", input: `false ?? 5`, expected: "false"},
+	{
+		name: "null coalescing short circuits",
+		input: `let calls = 0
+let f = fn() { calls += 1 }
+1 ?? f()
+calls`,
+		expected: "0",
+	},
+	{
+		name: "optional chain",
+		input: `let h = {'a': {'b': [1, 2]}}
+h?.a?.b?.[1]`,
+		expected: "2",
+	},
+	{
+		name: "optional chain stops at null",
+		input: `let h = {'a': null}
+h.a?.b.c.d`,
+		expected: "null",
+	},
+	{
+		name: "optional missing member",
+		input: `let h = {}
+h?.missing?.b`,
+		expected: "null",
+	},
+	{
+		name: "optional chain with default",
+		input: `let h = {}
+h?.missing ?? 'default'`,
+		expected: "default",
+	},
+	{
+		name: "optional index",
+		input: `let n = null
+n?.[missing]`,
+		expected: "null",
+	},
+	{
+		name: "optional call",
+		input: `let f = null
+f?.(missing)`,
+		expected: "null",
+	},
+	{
+		name: "optional call of function",
+		input: `let f = fn(x) { x * 2 }
+f?.(4)`,
+		expected: "8",
+	},
+	{name: "optional string member", input: `'abc'?.length`, expected: "3"},
+	{
+		name: "optional module member",
+		input: `let m = module { let a = 1 }
+[m?.a, m?.b]`,
+		expected: "[1, null]",
+	},
+	{name: "ternary after predicate", input: `null?(null) ? 'null' : 'value'`, expected: "null"},
+	{
+		name: "missing member",
+		input: `let h = {}
+h.missing.b`,
+		expected: "no prototype function named \"missing\" found for type=HASH",
+	},
+	{
+		name: "member of integer",
+		input: `let h = {'a': 1}
+h?.a.b`,
+		expected: "no prototype function named \"b\" found for type=INTEGER",
+	},
+	{
+		name: "nested ternary in function",
+		input: `let f = fn(n) { n > 90 ? 'A' : n > 80 ? 'B' : 'C' }
+[f(95), f(85), f(1)]`,
+		expected: "[A, B, C]",
+	},
+	{
+		name: "short circuits",
+		input: `let calls = 0
+let f = fn() { calls += 1 }
+false ? f() : 1
+1 ?? f()
+calls`,
+		expected: "0",
+	},
+	{name: "coalescing", input: `[null ?? 5, false ?? 5]`, expected: "[5, false]"},
+	{
+		name: "optional chain in function",
+		input: `let f = fn(h) { h?.a?.b?.[1] }
+[f({'a': {'b': [1, 2]}}), f({}), f(null)]`,
+		expected: "[2, null, null]",
+	},
+	{
+		name: "optional call in array",
+		input: `let f = fn(g) { [1, g?.(missing), 2] }
+f(null)`,
+		expected: "[1, null, 2]",
+	},
+	{
+		name: "optional call and member",
+		input: `let f = fn(x) { x * 2 }
+f?.(4) + (null?.x ?? 1)`,
+		expected: "9",
+	},
+
+	// Match
+	{name: "literal", input: `match 0 { 0 => 'zero', _ => 'other' }`, expected: "zero"},
+	{name: "negative literal", input: `match -1 { -1 => 'minus', _ => 'other' }`, expected: "minus"},
+	{name: "float literal", input: `match 2.0 { 2 => 'two', _ => 'other' }`, expected: "two"},
+	{name: "array patterns", input: `match [1, 2, 3] { [a] => a, [a, ...rest] => rest }`, expected: "[2, 3]"},
+	{name: "array length", input: `match [1, 2] { [a, b, c] => 0, [a, b] => a + b }`, expected: "3"},
+	{name: "hash patterns", input: `match {'name': 'Ann', 'age': 3} { {'name': n, 'age': 4} => 0, {'name': n} => n }`, expected: "Ann"},
+	{
+		name: "type patterns",
+		input: `let Int = 'INTEGER'
+let String = 'STRING'
+match 5 { String => 's', Int => 'i' }`,
+		expected: "i",
+	},
+	{
+		name: "type pattern binds",
+		input: `let Int = 'INTEGER'
+match 5 { Int => Int }`,
+		expected: "INTEGER",
+	},
+	{name: "unbound uppercase binds", input: `match [3, 4] { [X, Y] => X + Y }`, expected: "7"},
+	{
+		name: "uppercase bindings again",
+		input: `match [1, 2] { [X, Y] => X }
+match [3, 4] { [X, Y] => X + Y }`,
+		expected: "7",
+	},
+	{
+		name: "uppercase bound to a non type",
+		input: `let Int = 1
+match 2 { Int => Int }`,
+		expected: "2",
+	},
+	{name: "guards", input: `match 7 { n if n > 10 => 'big', n if n > 5 => 'medium', _ => 'small' }`, expected: "medium"},
+	{
+		name: "rejected guard keeps variable",
+		input: `let n = 1
+match 5 { n if n > 10 => 'x', _ => 'y' }
+n`,
+		expected: "1",
+	},
+	{
+		name: "guard binding",
+		input: `let n = 1
+match 20 { n if n > 10 => 'x', _ => 'y' }
+n`,
+		expected: "20",
+	},
+	{
+		name: "arm block",
+		input: `match 'x' {
+	'y' => 1, s => {
+		let t = s + s
+		t
+	}
+}`,
+		expected: "xx",
+	},
+	{
+		name: "return in arm",
+		input: `let f = fn(x) {
+	match x { 0 => { return 'early' }, _ => 1 }
+	'late'
+}
+f(0)`,
+		expected: "early",
+	},
+	{
+		name: "continue in arm",
+		input: `let n = 0
+for x in [1, 0, 2] {
+	match x { 0 => continue, _ => null }
+	n += x
+}
+n`,
+		expected: "3",
+	},
+	{name: "boolean literal", input: `match 1 { true => 't', _ => 'other' }`, expected: "other"},
+	{name: "no match", input: `match 3 { 1 => 'one' }`, expected: "no match arm for 3"},
+	{
+		name: "hash pattern in function",
+		input: `let f = fn(h) { match h { {'name': n, 'age': 4} => 0, {'name': n} => n } }
+f({'name': 'Ann'})`,
+		expected: "Ann",
+	},
+	{
+		name: "type patterns with guards",
+		input: `let Int = 'INTEGER'
+let f = fn(x) { match x { Int if x < 0 => 'negative', Int => Int, _ => 'other' } }
+[f(-1), f(1), f('s')]`,
+		expected: "[negative, INTEGER, other]",
+	},
+	{
+		name: "uppercase bindings in function",
+		input: `let f = fn(p, q) {
+	let a = match p { [X, Y] => X }
+	match q { [X, Y] => a + X + Y }
+}
+f([1, 2], [3, 4])`,
+		expected: "8",
+	},
+	{
+		name: "guards in function",
+		input: `let f = fn(x) { match x { n if n > 10 => 'big', n if n > 5 => 'medium', _ => 'small' } }
+f(7)`,
+		expected: "medium",
+	},
+	{
+		name: "guard bindings captured",
+		input: `let f = fn() {
+	let n = 1
+	let r = match [5, 6] { [n, m] if n > 10 => 'x', [n, m] if m == 6 => fn() { n + m }(), _ => 'y' }
+	[r, n]
+}
+f()`,
+		expected: "[11, 5]",
+	},
+	{
+		name: "continue in arm in function",
+		input: `let f = fn() {
+	let n = 0
+	for x in [1, 0, 2] {
+		match x { 0 => continue, _ => null }
+		n += x
+	}
+	n
+}
+f()`,
+		expected: "3",
+	},
+
+	// Try, catch and finally
+	{name: "try value", input: `try { 1 } catch { 2 }`, expected: "1"},
+	{name: "catch", input: `try { 1 + true } catch { 2 }`, expected: "2"},
+	{name: "catch message", input: `try { throw 'bad' } catch (e) { e.message }`, expected: "bad"},
+	{name: "catch data", input: `try { throw 5 } catch (e) { e.data }`, expected: "5"},
+	{name: "throw error with data", input: `try { throw error('bad', [1]) } catch (e) { e.data }`, expected: "[1]"},
+	{name: "error line", input: `try { [1][3] } catch (e) { e.line }`, expected: "1"},
+	{
+		name: "finally",
+		input: `let x = 0
+try { x = 1 } finally { x = x + 1 }
+x`,
+		expected: "2",
+	},
+	{
+		name: "return in finally",
+		input: `let f = fn() { try { return 1 } finally { return 2 } }
+f()`,
+		expected: "2",
+	},
+	{
+		name: "error from call",
+		input: `let f = fn() { throw 'deep' }
+try { f() } catch (e) { e.message }`,
+		expected: "deep",
+	},
+	{name: "finally rethrows", input: `try { try { throw 1 } finally { 2 } } catch (e) { e.data }`, expected: "1"},
+	{name: "throw in catch", input: `try { try { throw 1 } catch (e) { throw e.data + 1 } } catch (e) { e.data }`, expected: "2"},
+	{
+		name: "error predicate",
+		input: `let e = error('value')
+error?(e)`,
+		expected: "true",
+	},
+	{
+		name: "catch in function",
+		input: `let f = fn() { try { throw 'x' } catch (e) { e.message + '!' } }
+f()`,
+		expected: "x!",
+	},
+	{
+		name: "error through calls",
+		input: `let f = fn() { throw 'deep' }
+let g = fn() { f() }
+try { g() } catch (e) { e.message }`,
+		expected: "deep",
+	},
+	{
+		name: "catch shadows local",
+		input: `let f = fn() {
+	let e = 1
+	try { throw 2 } catch (e) { e.data + 1 }
+}
+f()`,
+		expected: "3",
+	},
+	{
+		name: "finally after return",
+		input: `let x = 0
+let f = fn() { try { return 1 } finally { x = 5 } }
+f() + x`,
+		expected: "6",
+	},
+	{
+		name: "nested finally return",
+		input: `let f = fn() { try { try { return 1 } finally { 2 } } finally { return 3 } }
+f()`,
+		expected: "3",
+	},
+	{name: "throw in catch with finally", input: `try { try { throw 1 } catch (e) { throw e.data + 1 } finally { 0 } } catch (e) { e.data }`, expected: "2"},
+	{name: "throw in builtin callback", input: `try { __loop(fn(t) { throw t }, 3) } catch (e) { e.data }`, expected: "0"},
+	{
+		name: "stack overflow",
+		input: `let f = fn() { f() }
+try { f() } catch (e) { e.message }`,
+		expected: "stack overflow",
+	},
+
+	// Loops
+	{
+		name: "while",
+		input: `let n = 0
+while n < 5 { n = n + 1 }
+n`,
+		expected: "5",
+	},
+	{
+		name: "for",
+		input: `let total = 0
+for (let i = 0; i < 5; i = i + 1) {
+	if i == 2 { continue }
+	total = total + i
+}
+total`,
+		expected: "8",
+	},
+	{
+		name: "for without clauses",
+		input: `let n = 0
+for (;;) {
+	n = n + 1
+	if n == 3 { break }
+}
+n`,
+		expected: "3",
+	},
+	{
+		name: "return in while",
+		input: `let f = fn() { while true { return 7 } }
+f()`,
+		expected: "7",
+	},
+	{
+		name: "return in for in",
+		input: `let f = fn(xs) {
+	for x in xs { if x > 1 { return x } }
+	return -1
+}
+f([1, 5, 2])`,
+		expected: "5",
+	},
+	{
+		name: "for in string",
+		input: `let s = ''
+for c in 'abc' { s = c + s }
+s`,
+		expected: "cba",
+	},
+	{
+		name: "for in hash keys",
+		input: `let s = ''
+for k in {'b': 1, 'a': 2} { s = s + k }
+s`,
+		expected: "ab",
+	},
+	{
+		name: "for in growing array",
+		input: `let xs = [1]
+for x in xs { if x < 3 { xs.push(x + 1) } }
+xs`,
+		expected: "[1, 2, 3]",
+	},
+	{
+		name: "for in iterator",
+		input: `let it = {
+	'iter': fn() {
+		let i = 0
+		return {
+			'next': fn() {
+				i = i + 1
+				if i > 3 { return break }
+				return i
+			}
+		}
+	}
+}
+let total = 0
+for x in it { total = total + x }
+total`,
+		expected: "6",
+	},
+	{
+		name: "labels",
+		input: `let s = ''
+outer: for x in [1, 2, 3] {
+	for y in [1, 2] {
+		if x == 2 { continue outer }
+		if x == 3 { break outer }
+		s = s + string(x) + string(y)
+	}
+}
+s`,
+		expected: "1112",
+	},
+	{
+		name: "continue runs finally",
+		input: `let n = 0
+while n < 3 {
+	try {
+		n = n + 1
+		continue
+	} finally { n = n + 10 }
+}
+n`,
+		expected: "11",
+	},
+	{
+		name: "break runs finally",
+		input: `let n = 0
+while true { try { break } finally { n = 1 } }
+n`,
+		expected: "1",
+	},
+	{name: "while value", input: `while false { 1 }`, expected: "null"},
+	{
+		name: "for without clauses in function",
+		input: `let f = fn() {
+	let n = 0
+	for (;;) {
+		n = n + 1
+		if n == 3 { break }
+	}
+	n
+}
+f()`,
+		expected: "3",
+	},
+	{
+		name: "for in string in function",
+		input: `let f = fn() {
+	let s = ''
+	for c in 'abc' { s = c + s }
+	s
+}
+f()`,
+		expected: "cba",
+	},
+	{
+		name: "labels in function",
+		input: `let f = fn() {
+	let s = ''
+	outer: for x in [1, 2, 3] {
+		for y in [1, 2] {
+			if x == 2 { continue outer }
+			if x == 3 { break outer }
+			s = s + string(x) + string(y)
+		}
+	}
+	s
+}
+f()`,
+		expected: "1112",
+	},
+	{
+		name: "return in for in runs finally",
+		input: `let f = fn() { for x in [1, 2] { try { return x } finally { 0 } } }
+f()`,
+		expected: "1",
+	},
+	{name: "for in integer", input: `try { for x in 5 { x } } catch (e) { e.message }`, expected: "value is not iterable: INTEGER"},
+	{
+		name: "closures capture loop variable",
+		input: `let fs = []
+let f = fn() { for x in [1, 2] { fs.push(fn() { x }) } }
+f()
+fs[0]()`,
+		expected: "2",
+	},
+
+	// Generators
+	{
+		name: "next",
+		input: countGenerator + `let g = count(2)
+[g.next().value, g.next().value, g.next().value, g.next().value]`,
+		expected: "[0, 1, end, null]",
+	},
+	{
+		name: "generator done",
+		input: countGenerator + `let g = count(1)
+g.next()
+[g.next().done, g.next().done]`,
+		expected: "[true, true]",
+	},
+	{
+		name: "for in generator",
+		input: countGenerator + `let xs = []
+for x in count(3) { xs.push(x) }
+xs`,
+		expected: "[0, 1, 2]",
+	},
+	{name: "spread generator", input: countGenerator + `[...count(3), 9]`, expected: "[0, 1, 2, 9]"},
+	{
+		name: "generator array pattern",
+		input: countGenerator + `let [a, b] = count(10)
+[a, b]`,
+		expected: "[0, 1]",
+	},
+	{
+		name: "generator array rest",
+		input: countGenerator + `let [a, ...rest] = count(4)
+rest`,
+		expected: "[1, 2, 3]",
+	},
+	{
+		name: "parameter pattern",
+		input: countGenerator + `let f = fn([a, b = 7]) { [a, b] }
+f(count(1))`,
+		expected: "[0, 7]",
+	},
+	{
+		name: "break closes",
+		input: countGenerator + `let g = count(10)
+for x in g { if x == 2 { break } }
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "return closes",
+		input: countGenerator + `let g = count(10)
+let first = fn() { for x in g { return x } }
+first()
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "array pattern closes",
+		input: countGenerator + `let g = count(3)
+let [a] = g
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "close",
+		input: countGenerator + `let g = count(3)
+g.close()
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "send values",
+		input: `let sum = fn() {
+	let total = 0
+	while true {
+		let v = yield total
+		if v == null { return total }
+		total += v
+	}
+}
+let g = sum()
+g.next()
+g.next(5)
+g.next(7).value`,
+		expected: "12",
+	},
+	{
+		name: "bare yield",
+		input: `let g = fn*() { yield }
+g().next().value`,
+		expected: "null",
+	},
+	{
+		name: "arrow generator",
+		input: `let twice = x => yield x * 2
+twice(4).next().value`,
+		expected: "8",
+	},
+	{
+		name: "default parameter",
+		input: `let g = fn*(x = 5) { yield x }
+g().next().value`,
+		expected: "5",
+	},
+	{
+		name: "yield in try",
+		input: `let g = fn*() {
+	try {
+		yield 1
+		throw 'inner'
+	} catch (e) {
+		yield e.message
+	} finally {
+		yield 'finally'
+	}
+}
+[...g()]`,
+		expected: "[1, inner, finally]",
+	},
+	{
+		name: "error from generator",
+		input: `let g = fn*() {
+	yield 1
+	throw 'boom'
+}()
+g.next()
+g.next()`,
+		expected: "boom",
+	},
+	{
+		name: "failed generator is done",
+		input: `let g = fn*() {
+	yield 1
+	throw 'boom'
+}()
+g.next()
+try { g.next() } catch (e) { null }
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "running generator",
+		input: `let g = fn*() { yield g.next() }()
+g.next()`,
+		expected: "generator <anonymous> is already running",
+	},
+	{
+		name: "iter protocol",
+		input: `let h = {
+	'iter': fn() {
+		let i = 0
+		return {
+			'next': fn() {
+				i += 1
+				if i > 2 { return {'done': true} }
+				return {'value': i, 'done': false}
+			}
+		}
+	}
+}
+[...h]`,
+		expected: "[1, 2]",
+	},
+	{
+		name: "iterator returning break",
+		input: `let h = {
+	'iter': fn() {
+		let i = 0
+		return {
+			'next': fn() {
+				i += 1
+				if i > 2 { return break }
+				return i
+			}
+		}
+	}
+}
+[...h]`,
+		expected: "[1, 2]",
+	},
+	{
+		name: "break closes iterator",
+		input: `let closed = false
+let h = {'iter': fn() { {'next': fn() { {'value': 1, 'done': false} }, 'close': fn() { closed = true } } }}
+for x in h { break }
+closed`,
+		expected: "true",
+	},
+	{
+		name: "inspect",
+		input: `let g = fn*() { yield 1 }
+g()`,
+		expected: "generator g",
+	},
+	{
+		name: "for in generator in function",
+		input: countGenerator + `let f = fn() {
+	let xs = []
+	for x in count(3) { xs.push(x) }
+	xs
+}
+f()`,
+		expected: "[0, 1, 2]",
+	},
+	{
+		name: "spread generator in function",
+		input: countGenerator + `let f = fn(n) { [...count(n), 9] }
+f(3)`,
+		expected: "[0, 1, 2, 9]",
+	},
+	{
+		name: "array rest in function",
+		input: countGenerator + `let f = fn() {
+	let [a, ...rest] = count(4)
+	[a, rest]
+}
+f()`,
+		expected: "[0, [1, 2, 3]]",
+	},
+	{
+		name: "break closes in function",
+		input: countGenerator + `let g = count(10)
+let f = fn() { for x in g { if x == 2 { break } } }
+f()
+g.next().done`,
+		expected: "true",
+	},
+	{
+		name: "labeled continue closes",
+		input: countGenerator + `let a = count(3)
+let b = count(3)
+let f = fn() { outer: for x in a { for y in b { continue outer } } }
+f()
+[a.next().done, b.next().done]`,
+		expected: "[true, true]",
+	},
+	{
+		name: "continue",
+		input: countGenerator + `let g = count(3)
+let f = fn() { for x in g { continue } }
+f()
+let xs = []
+for x in count(2) { xs.push(x) }
+xs`,
+		expected: "[0, 1]",
+	},
+	{
+		name: "prototype generator",
+		input: `'ARRAY'.prototype.pairs = fn*() {
+	let i = 0
+	for x in this {
+		yield [i, x]
+		i += 1
+	}
+}
+[...['a', 'b'].pairs()]`,
+		expected: "[[0, a], [1, b]]",
+	},
+	{
+		name: "generator in for in generator",
+		input: `let outer = fn*() {
+	for x in fn*() {
+		yield 1
+		yield 2
+	}() { yield x * 10 }
+}
+[...outer()]`,
+		expected: "[10, 20]",
+	},
+
+	// Tasks and channels
+	{
+		name: "wait",
+		input: `let t = spawn(fn(a, b) { a + b }, 1, 2)
+t.wait()`,
+		expected: "3",
+	},
+	{
+		name: "tasks run once main waits",
+		input: `let log = []
+let t = spawn(fn() { log.push('task') })
+log.push('main')
+t.wait()
+log`,
+		expected: "[main, task]",
+	},
+	{
+		name: "error of a task nobody waits for",
+		input: `spawn(fn() { 1 / 0 })
+1`,
+		expected: "division by zero",
+	},
+	{
+		name: "error of a task joined later",
+		input: `let t = spawn(fn() { 1 / 0 })
+sleep(10)
+t.join().message`,
+		expected: "division by zero",
+	},
+	{
+		name: "unbuffered channel",
+		input: `let c = channel()
+spawn(fn() {
+	c.send(1)
+	c.send(2)
+})
+[c.recv(), c.recv()]`,
+		expected: "[1, 2]",
+	},
+	{
+		name: "buffered channel",
+		input: `let c = channel(2)
+c.send(1)
+c.send(2)
+c.close()
+[c.recv(), c.recv(), c.recv()]`,
+		expected: "[1, 2, null]",
+	},
+	{
+		name: "for in channel",
+		input: `let c = channel()
+spawn(fn() {
+	for i in range(3) { c.send(i) }
+	c.close()
+})
+let xs = []
+for x in c { xs.push(x) }
+xs`,
+		expected: "[0, 1, 2]",
+	},
+	{
+		name: "tasks share variables",
+		input: `let c = channel()
+let total = 0
+let add = fn(n) {
+	total += n
+	c.send(null)
+}
+for i in range(1, 5) { spawn(add, i) }
+for i in range(4) { c.recv() }
+total`,
+		expected: "10",
+	},
+	{
+		name: "ping pong",
+		input: `let ping = channel()
+let pong = channel()
+spawn(fn() { for x in ping { pong.send(x * 2) } })
+let xs = []
+for i in range(3) {
+	ping.send(i)
+	xs.push(pong.recv())
+}
+xs`,
+		expected: "[0, 2, 4]",
+	},
+	{
+		name: "select default",
+		input: `let c = channel(1)
+select {
+	v = c.recv() => v
+	_ => 'empty'
+}`,
+		expected: "empty",
+	},
+	{
+		name: "select ready",
+		input: `let a = channel(1)
+let b = channel(1)
+b.send(2)
+select {
+	v = a.recv() => ['a', v]
+	v = b.recv() => ['b', v]
+}`,
+		expected: "[b, 2]",
+	},
+	{
+		name: "select send",
+		input: `let a = channel()
+let b = channel(1)
+select {
+	a.send(1) => 'a'
+	b.send(2) => 'b'
+}
+b.recv()`,
+		expected: "2",
+	},
+	{
+		name: "select waits",
+		input: `let c = channel()
+spawn(fn() { c.send('late') })
+select { v = c.recv() => v }`,
+		expected: "late",
+	},
+	{
+		name: "select closed channel",
+		input: `let c = channel()
+c.close()
+select { v = c.recv() => v }`,
+		expected: "null",
+	},
+	{
+		name: "join failed task",
+		input: `let t = spawn(fn() { throw 'boom' })
+t.join().message`,
+		expected: "boom",
+	},
+	{
+		name: "join returns error",
+		input: `let t = spawn(fn() { throw 'boom' })
+error?(t.join())`,
+		expected: "true",
+	},
+	{
+		name: "wait throws",
+		input: `let t = spawn(fn() { throw 'boom' })
+try { t.wait() } catch (e) { 'caught ' + e.message }`,
+		expected: "caught boom",
+	},
+	{
+		name: "done",
+		input: `let t = spawn(fn() { 1 })
+let before = t.done()
+t.wait()
+[before, t.done()]`,
+		expected: "[false, true]",
+	},
+	{name: "recv deadlock", input: `channel().recv()`, expected: "deadlock, every task is waiting"},
+	{name: "send deadlock", input: `channel().send(1)`, expected: "deadlock, every task is waiting"},
+	{
+		name: "both waiting",
+		input: `let c = channel()
+spawn(fn() { c.recv() })
+c.recv()`,
+		expected: "deadlock, every task is waiting",
+	},
+	{
+		name: "joined deadlock",
+		input: `let c = channel()
+let t = spawn(fn() { c.recv() })
+t.join().message`,
+		expected: "deadlock, every task is waiting",
+	},
+	{name: "empty select", input: `select {}`, expected: "deadlock, every task is waiting"},
+	{
+		name: "send on closed",
+		input: `let c = channel()
+c.close()
+c.send(1)`,
+		expected: "send on a closed channel",
+	},
+	{
+		name: "close twice",
+		input: `let c = channel()
+c.close()
+c.close()`,
+		expected: "close of a closed channel",
+	},
+	{name: "select on integer", input: `select { v = 1.recv() => v }`, expected: "select on INTEGER, expected a channel"},
+	{name: "spawn integer", input: `spawn(1)`, expected: "argument to `spawn` not supported. got INTEGER"},
+	{name: "negative capacity", input: `channel(-1)`, expected: "prohibited value of arguments for method `channel`. got=-1, reason=the capacity cannot be negative"},
+	{
+		name: "inspect task",
+		input: `let worker = fn() { null }
+spawn(worker)`,
+		expected: "task worker",
+	},
+	{name: "inspect channel", input: `channel(3)`, expected: "channel(3)"},
+	{
+		name: "this in tasks",
+		input: `'ARRAY'.prototype.tag = fn(c) {
+	let v = c.recv()
+	[this, v]
+}
+let c = channel()
+let t = spawn(fn() { [1].tag(c) })
+let u = spawn(fn() { [2].tag(c) })
+c.send('x')
+c.send('y')
+[t.wait(), u.wait()]`,
+		expected: "[[[1], x], [[2], y]]",
+	},
+	{
+		name: "locals kept across waits",
+		input: `let square = fn(c, n) {
+	let local = n * n
+	c.send(local)
+	local + 1
+}
+let f = fn() {
+	let c = channel()
+	let t = spawn(square, c, 4)
+	let got = c.recv()
+	[got, t.wait()]
+}
+f()`,
+		expected: "[16, 17]",
+	},
+	{
+		name: "workers",
+		input: `let c = channel()
+let worker = fn(id) {
+	let total = 0
+	for i in range(3) { total += c.recv() }
+	[id, total]
+}
+let f = fn() {
+	let a = spawn(worker, 'a')
+	let b = spawn(worker, 'b')
+	for i in range(6) { c.send(i) }
+	[a.wait(), b.wait()]
+}
+f()`,
+		expected: "[[a, 3], [b, 12]]",
+	},
+	{
+		name: "iterator in task",
+		input: `let c = channel()
+let h = {
+	'iter': fn() {
+		{
+			'next': fn() {
+				let v = c.recv()
+				{'value': v, 'done': v == null}
+			}
+		}
+	}
+}
+let t = spawn(fn() {
+	let xs = []
+	for x in h { xs.push(x + 1) }
+	xs
+})
+let f = fn() {
+	for i in range(3) { c.send(i * 10) }
+	c.close()
+	t.wait()
+}
+f()`,
+		expected: "[1, 11, 21]",
+	},
+	{
+		name: "select in function",
+		input: `let f = fn() {
+	let a = channel(1)
+	let b = channel()
+	spawn(fn() { b.send('b') })
+	select {
+		v = a.recv() => ['a', v]
+		v = b.recv() => ['b', v]
+	}
+}
+f()`,
+		expected: "[b, b]",
+	},
+	{
+		name: "select in loop",
+		input: `let f = fn() {
+	let c = channel(1)
+	let xs = []
+	for i in range(3) {
+		select {
+			c.send(i) => xs.push('sent')
+			_ => xs.push(c.recv())
+		}
+	}
+	xs
+}
+f()`,
+		expected: "[sent, 0, sent]",
+	},
+	{
+		name: "continue in select",
+		input: `let f = fn() {
+	let xs = []
+	for i in range(3) {
+		select {
+			_ => {
+				if i == 1 { continue }
+				xs.push(i)
+			}
+		}
+	}
+	xs
+}
+f()`,
+		expected: "[0, 2]",
+	},
+
+	// Timers and async functions
+	{
+		name: "timers in order",
+		input: `let log = []
+setTimeout(fn() { log.push('b') }, 20)
+setTimeout(fn() { log.push('a') }, 10)
+sleep(30)
+log`,
+		expected: "[a, b]",
+	},
+	{
+		name: "timers at the same time",
+		input: `let log = []
+setTimeout(fn(x) { log.push(x) }, 10, 'first')
+setTimeout(fn(x) { log.push(x) }, 10, 'second')
+sleep(10)
+log`,
+		expected: "[first, second]",
+	},
+	{
+		name: "sleep",
+		input: `let start = __time()
+sleep(1500)
+__time() - start`,
+		expected: "1500",
+	},
+	{
+		name: "interval",
+		input: `let n = 0
+let t = setInterval(fn() {
+	n += 1
+	if n == 3 { clearInterval(t) }
+}, 10)
+sleep(100)
+n`,
+		expected: "3",
+	},
+	{
+		name: "clear timeout",
+		input: `let log = []
+let t = setTimeout(fn() { log.push('fired') }, 10)
+clearTimeout(t)
+sleep(20)
+log`,
+		expected: "[]",
+	},
+	{
+		name: "async functions",
+		input: `let log = []
+let f = async fn(name, ms) {
+	sleep(ms)
+	log.push(name)
+}
+let a = f('slow', 20)
+let b = f('fast', 10)
+await a
+await b
+log`,
+		expected: "[fast, slow]",
+	},
+	{
+		name: "await",
+		input: `let f = async fn(x) { x * 2 }
+await f(21)`,
+		expected: "42",
+	},
+	{
+		name: "async call is pending",
+		input: `let f = async fn(x) { x * 2 }
+let p = f(21)
+p.state()`,
+		expected: "pending",
+	},
+	{
+		name: "async arrow",
+		input: `let f = async x => x + 1
+await f(1)`,
+		expected: "2",
+	},
+	{
+		name: "async runs once main waits",
+		input: `let log = []
+let f = async fn() { log.push('async') }
+let p = f()
+log.push('main')
+await p
+log`,
+		expected: "[main, async]",
+	},
+	{
+		name: "async throws",
+		input: `let f = async fn() { throw 'boom' }
+try { await f() } catch (e) { 'caught ' + e.message }`,
+		expected: "caught boom",
+	},
+	{name: "promise with timer", input: `await promise(fn(resolve, reject) { setTimeout(resolve, 10, 'done') })`, expected: "done"},
+	{name: "recover", input: `await promise(fn(resolve, reject) { reject('no') }).recover(fn(e) { e.message })`, expected: "no"},
+	{name: "then", input: `await promise(fn(resolve) { resolve(1) }).then(fn(v) { v + 1 }).then(fn(v) { v * 10 })`, expected: "20"},
+	{
+		name: "then returning promise",
+		input: `let f = async fn(x) { x }
+await f(1).then(fn(v) { f(v + 1) })`,
+		expected: "2",
+	},
+	{name: "rejection skips then", input: `await promise(fn(resolve, reject) { reject('no') }).then(fn(v) { 'skipped' }).recover(fn(e) { 'got ' + e.message })`, expected: "got no"},
+	{
+		name: "promise state",
+		input: `let p = promise(fn(resolve) { resolve(1) })
+[p.state(), await p]`,
+		expected: "[fulfilled, 1]",
+	},
+	{
+		name: "rejected promise state",
+		input: `let p = promise(fn() { throw 'bad' })
+let state = p.state()
+p.recover(fn(e) { null })
+state`,
+		expected: "rejected",
+	},
+	{name: "await value", input: `await 5`, expected: "5"},
+	{name: "await task", input: `await spawn(fn() { 3 })`, expected: "3"},
+	{name: "promise never settles", input: `await promise(fn() {})`, expected: "deadlock, every task is waiting"},
+	{name: "negative sleep", input: `sleep(-1)`, expected: "prohibited value of arguments for method `sleep`. got=-1, reason=the delay cannot be negative"},
+	{name: "zero interval", input: `setInterval(fn() {}, 0)`, expected: "prohibited value of arguments for method `setInterval`. got=0, reason=the interval must be positive"},
+	{name: "timeout of integer", input: `setTimeout(1, 10)`, expected: "argument to `setTimeout` not supported. got INTEGER"},
+	{name: "inspect timer", input: `setTimeout(fn() {}, 10)`, expected: "timer"},
+	{name: "then of integer", input: `promise(fn(resolve) { resolve(1) }).then(2)`, expected: "argument to `then` not supported. got INTEGER"},
+	{
+		name: "locals kept across awaits",
+		input: `let double = async fn(x) {
+	let local = x
+	sleep(10)
+	local * 2
+}
+let f = fn() {
+	let a = double(1)
+	let b = double(2)
+	[await a, await b]
+}
+f()`,
+		expected: "[2, 4]",
+	},
+	{
+		name: "async steps interleave",
+		input: `let log = []
+let step = async fn(name, ms) {
+	for i in range(2) {
+		sleep(ms)
+		log.push(name + string(i))
+	}
+}
+let f = fn() {
+	let a = step('a', 10)
+	let b = step('b', 15)
+	await a
+	await b
+	log
+}
+f()`,
+		expected: "[a0, b0, a1, b1]",
+	},
+	{
+		name: "await in function throws",
+		input: `let f = async fn() { throw 'boom' }
+let g = fn() { try { await f() } catch (e) { 'caught ' + e.message } }
+g()`,
+		expected: "caught boom",
+	},
+	{
+		name: "interval time",
+		input: `let start = __time()
+let n = 0
+let t = setInterval(fn() {
+	n += 1
+	if n == 3 { clearInterval(t) }
+}, 10)
+sleep(100)
+[n, __time() - start]`,
+		expected: "[3, 100]",
+	},
+	{
+		name: "async arrow then",
+		input: `let add = async (a, b = 1) => a + b
+await add(1).then(x => add(x, 10))`,
+		expected: "12",
+	},
+	{
+		name: "inspect async function",
+		input: `let f = async fn(x) { x }
+f`,
+		expected: "async fn(x) { {x} }",
+	},
+
+	// Programs mixing the features
+	{
+		name: "fib",
+		input: `let fib = fn(n) { if n < 2 { return n } fib(n - 1) + fib(n - 2) }
+fib(12)`,
+		expected: "144",
+	},
+	{name: "map", input: `[1, 2, 3].map(fn(x) { x * 2 })`, expected: "[2, 4, 6]"},
+	{
+		name: "for sum",
+		input: `let total = 0
+for (let i = 0; i < 5; i = i + 1) { total = total + i }
+total`,
+		expected: "10",
+	},
+	{
+		name: "return from for in",
+		input: `let found = fn(xs) { for x in xs { if x > 1 { return x } } }
+found([1, 3, 2])`,
+		expected: "3",
+	},
+	{
+		name: "labeled loops",
+		input: `let log = []
+outer: for x in [1, 2, 3] {
+	for c in "ab" {
+		if x == 2 { continue outer }
+		if x == 3 { break outer }
+		log.push(string(x) + c)
+	}
+}
+log`,
+		expected: "[1a, 1b]",
+	},
+	{
+		name: "continue in try",
+		input: `let log = []
+let n = 0
+while n < 3 {
+	n = n + 1
+	try {
+		if n == 2 { continue }
+		log.push(n)
+	} finally { log.push(0) }
+}
+log`,
+		expected: "[1, 0, 0, 3, 0]",
+	},
+	{
+		name: "forEach callback",
+		input: `let acc = []
+forEach([1, 2], fn(x) { acc.push(x * 3) })
+acc`,
+		expected: "[3, 6]",
+	},
+	{
+		name: "module",
+		input: `let m = module {
+	let a = 2
+	let twice = fn() { a * 2 }
+}
+m.twice()`,
+		expected: "4",
+	},
+	{
+		name: "nested index assign",
+		input: `let h = {"a": [1, 2]}
+h.a[1] = 5
+h.a`,
+		expected: "[1, 5]",
+	},
+	{
+		name: "closure counter",
+		input: `let counter = fn() {
+	let c = 0
+	fn() { c = c + 1 }
+}
+let k = counter()
+k()
+k()`,
+		expected: "2",
+	},
+	{name: "slice and string", input: `"abc"[0:2] + string(1 + 1)`, expected: "ab2"},
+	{
+		name: "unicode",
+		input: `let mot = "déjà vu 😀"
+[len(mot), mot[3], mot[-1], mot[0:4], bytes(mot[1])]`,
+		expected: "[9, à, 😀, déjà, [195, 169]]",
+	},
+	{name: "number literals", input: `[0xFF, 0b1010, 0o755, 1_000_000, 1e-9, 2.5e3, 0xFFFF_FFFF_FFFF_FFFF, 0x10n, 1.5e-3d]`, expected: "[255, 10, 493, 1000000, 1e-09, 2500.0, 18446744073709551615, 16, 0.0015]"},
+	{
+		name: "checksum",
+		input: `let sum = 0
+for b in bytes("checksum") { sum = (sum << 5 ^ sum >> 2 ^ b) & 0xFFFF }
+[sum, ~sum, 3 ** 41, 1.5d ** -1, 2 ** 0.5]`,
+		expected: "[19661, -19662, 36472996377170786403, 0.66666666666666666667, 1.4142135623730951]",
+	},
+	{name: "number semantics", input: `[7 / 2, 7 / 2.0, 7 % 2, 7.5 % 2, 1 == 1.0, 0.1 + 0.2]`, expected: "[3, 3.5, 1, 1.5, true, 0.30000000000000004]"},
+	{
+		name: "templates",
+		input: `let who = {"name": "Ann"}
+let greet = fn(n) { "hi ${who.name} x${n}: ${[n, "${n * 2}"]}" }
+greet(3)`,
+		expected: "hi Ann x3: [3, 6]",
+	},
+	{
+		name: "decimals",
+		input: `let total = 0d
+for price in [19.99d, 5.01d, 0.10d] { total = total + price }
+[total, total / 3, 9223372036854775807 * 2, 10n % 3]`,
+		expected: "[25.10, 8.36666666666666666667, 18446744073709551614, 1]",
+	},
+	{
+		name: "destructuring",
+		input: `let swap = fn([a, b]) { [b, a] }
+let {name, tags: [first, ...others] = [], age = 0} = {"name": "Ann", "tags": ["x", "y", "z"]}
+let [p = 1, q = p + 1] = [null]
+[swap([1, 2]), name, first, others, age, p, q, try { let [r] = [] } catch (e) { e.message }]`,
+		expected: "[[2, 1], Ann, x, [y, z], 0, 1, 2, cannot destructure the element 0 of an array of length 0]",
+	},
+	{
+		name: "compound assignment",
+		input: `let calls = []
+let at = fn(x) {
+	calls.push(x)
+	x
+}
+let h = {"n": 1, "xs": [1, 2]}
+let pick = fn() {
+	calls.push("h")
+	h
+}
+h.xs[at(1)] **= 3
+pick().n += 1
+h[at("m")] ??= at(0)
+h.n ??= at(9)
+let i = 0
+while i < 3 { i += 1 }
+[h.n, h.xs, h.m, calls, i]`,
+		expected: "[2, [1, 8], 0, [1, h, m, 0], 3]",
+	},
+	{
+		name: "increments",
+		input: `let calls = []
+let at = fn(x) {
+	calls.push(x)
+	x
+}
+let h = {"n": 1, "xs": [1, 2]}
+let i = 0
+let f = fn() {
+	let j = 5
+	j--
+	[i++, ++i, h.xs[at(0)]++, --h[at("n")], j]
+}
+[f(), i, h.n, h.xs, calls]`,
+		expected: "[[0, 2, 1, 0, 4], 2, 0, [2, 2], [0, n]]",
+	},
+	{
+		name: "arguments",
+		input: `let f = fn(a, b = a + 1, [c] = [0], ...rest) { [a, b, c, rest] }
+let xs = [1, 2]
+let h = {...{"x": 1}, "y": 2}
+[
+	f(1),
+	f(...xs, [3], 4, 5),
+	f(b: 7, a: 0),
+	[0, ...xs, ..."ab"],
+	h.x + h.y,
+	try { f(z: 1) } catch (e) { e.message },
+]`,
+		expected: "[[1, 2, 0, []], [1, 2, 3, [4, 5]], [0, 7, 0, []], [0, 1, 2, a, b], 3, f has no parameter named z]",
+	},
+	{
+		name: "optional chaining",
+		input: `let config = {"db": {"host": "local", "ports": [1, 2]}, "name": null}
+let none = null
+let grade = fn(n) { n > 90 ? "A" : n > 80 ? "B" : "C" }
+[
+	config?.db?.host,
+	config?.cache?.host,
+	none?.a.b,
+	config.db?.ports?.[1],
+	none?.(missing),
+	config.name ?? "anon",
+	0 ?? 1,
+	null?(none) ? 1 : 2,
+	grade(85),
+	config?.db.host.length,
+]`,
+		expected: "[local, null, null, 2, null, anon, 0, 1, B, 5]",
+	},
+	{
+		name: "arrows and pipes",
+		input: `let keep = (xs, f) => {
+	let out = []
+	for x in xs { if f(x) { out.push(x) } }
+	out
+}
+let total = xs => {
+	let t = 0
+	for x in xs { t += x }
+	t
+}
+let scale = (x, by = 2) => x * by
+[
+	[1, 2, 3, 4] |> keep(x => x % 2 == 0) |> total,
+	5 |> scale,
+	5 |> scale(by: 3),
+	[1, 2].map(x => x |> scale),
+	(() => "thunk")(),
+]`,
+		expected: "[6, 10, 15, [2, 4], thunk]",
+	},
+	{
+		name: "generators",
+		input: `let count = fn*(n) {
+	let i = 0
+	while i < n {
+		let sent = yield i
+		if sent { i += sent } else { i += 1 }
+	} "end"
+}
+let g = count(6)
+let out = [g.next().value, g.next(2).value]
+for x in g { out.push(x) }
+let [a, b, ...rest] = count(5)
+let firsts = []
+for x in count(100) {
+	if x > 2 { break }
+	firsts.push(x)
+}
+[out, a, b, rest, firsts, g.next().done, [1, 2].iter().next().value]`,
+		expected: "[[0, 2, 3, 4, 5], 0, 1, [2, 3, 4], [0, 1, 2], true, 1]",
+	},
+	{
+		name: "worker pool",
+		input: `let jobs = channel(4)
+let results = channel()
+let worker = fn(id) {
+	for job in jobs { results.send([id, job * job]) }
+	"worker ${id}"
+}
+let workers = [spawn(worker, 1), spawn(worker, 2)]
+for i in range(5) { jobs.send(i) }
+jobs.close()
+let got = []
+for i in range(5) { got.push(results.recv()) }
+let idle = channel(1)
+[
+	got,
+	workers[0].wait(),
+	workers[1].join(),
+	select { v = idle.recv() => v, _ => "idle" },
+	spawn(fn() { throw "boom" }).join().message,
+	try { idle.recv() } catch (e) { e.message },
+]`,
+		expected: "[[[1, 0], [1, 4], [1, 9], [1, 16], [2, 1]], worker 1, worker 2, idle, boom, deadlock, every task is waiting]",
+	},
+	{
+		name: "event loop",
+		input: `let log = []
+let fetch = async fn(name, ms) {
+	sleep(ms)
+	log.push(name)
+	name + " done"
+}
+let fails = async () => {
+	sleep(5)
+	throw "timeout"
+}
+let ticks = 0
+let ticker = setInterval(fn() {
+	ticks += 1
+	if ticks == 4 { clearInterval(ticker) }
+}, 3)
+let later = promise(fn(resolve, reject) { setTimeout(resolve, 8, "later") })
+let slow = fetch("slow", 20)
+let fast = fetch("fast", 10)
+let start = timeMilli()
+[
+	await slow,
+	await fast,
+	try { await fails() } catch (e) { e.message },
+	await later.then(v => v + "!"),
+	await fails().recover(e => "recovered"),
+	log,
+	ticks,
+	timeMilli() - start,
+	slow.state(),
+]`,
+		expected: "[slow done, fast done, timeout, later!, recovered, [fast, slow], 4, 30, fulfilled]",
+	},
+	{
+		name: "match guards",
+		input: `let n = 1
+let guarded = fn(x) {
+	let m = 0
+	[match x { [m, n] if n > 10 => "big", [m, _] if m > 0 => "first " + string(m), _ => "none" }, m]
+}
+[guarded([1, 2]), guarded([0, 20]), guarded([0, 1]), match 5 { n if n > 10 => "x", _ => "y" }, n]`,
+		expected: "[[first 1, 1], [big, 0], [none, 0], y, 1]",
+	},
+	{
+		name: "match types",
+		input: `let Int = "INTEGER"
+let describe = fn(x) {
+	match x {
+		0 => "zero"
+		Int if x < 0 => "negative"
+		Int => "int"
+		[first, ...rest] => "list of ${len(rest) + 1}"
+		{"name": name} => { "hi " + name }
+		_ => "other"
+	}
+}
+[
+	describe(0),
+	describe(-2),
+	describe(4),
+	describe([1, 2]),
+	describe({"name": "Ann"}),
+	describe("s"),
+	match [3, 4] { [X, Y] => X * Y },
+	try { match 1 { 2 => 2 } } catch (e) { e.message },
+]`,
+		expected: "[zero, negative, int, list of 2, hi Ann, other, 12, no match arm for 1]",
+	},
+}
+
+func TestEngines(t *testing.T) {
+	for _, tt := range engineTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs []string
+			for _, engine := range []string{EngineTree, EngineVM} {
+				result, output := evalEngine(t, engine, tt.input)
+				if result != tt.expected {
+					t.Errorf("%s engine returned a wrong result. want=%q, got=%q", engine, tt.expected, result)
+				}
+				outputs = append(outputs, output)
+			}
+
+			if outputs[0] != outputs[1] {
+				t.Errorf("engines print differently. tree=%q, vm=%q", outputs[0], outputs[1])
+			}
+		})
+	}
+
+	if _, err := New(Options{Engine: "jit"}); err == nil {
+		t.Errorf("expected an error for an unknown engine")
+	}
+}
+
+// evalEngine evaluates input with a new interpreter running engine, it returns the inspected result,
+// or the message of the error that stopped it, and what the program printed
+func evalEngine(t *testing.T, engine, input string) (string, string) {
+	var out bytes.Buffer
+	interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &out, Stderr: &out, Clock: autoClock()})
+	if err != nil {
+		t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+	}
+
+	result, err := interpreter.EvalString(input)
+	if runtimeErr, ok := err.(*RuntimeError); ok {
+		return runtimeErr.Err.Message, out.String()
+	}
+	if err != nil {
+		t.Fatalf("%s engine failed. got=%v", engine, err)
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return errObj.Message, out.String()
+	}
+	return result.Inspect(), out.String()
+}
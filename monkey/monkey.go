@@ -0,0 +1,285 @@
+package monkey
+
+import (
+	"Monkey/ast"
+	"Monkey/evaluator"
+	"Monkey/lexer"
+	"Monkey/object"
+	"Monkey/options"
+	"Monkey/parser"
+	"Monkey/runner"
+	"Monkey/tmp"
+	"Monkey/token"
+	"Monkey/vm"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engines that run the programs
+const (
+	EngineTree = "tree" // the tree walking evaluator
+	EngineVM   = "vm"   // the bytecode compiler and vm
+)
+
+// Options configure a new Interpreter, the zero value is usable
+type Options struct {
+	// The engine running the programs, defaults to EngineTree
+	Engine string
+
+	// Treat every error value as fatal
+	FatalErrors bool
+
+	// Print warnings such as circular dependencies
+	Debug bool
+
+	// Calling a function with too many or too few arguments is an error
+	StrictArity bool
+
+	// Directory containing the lib folder, defaults to MKYROOT
+	Root string
+
+	// Extra library search paths, looked up after the std directory
+	Paths []string
+
+	// Directory relative includes start from, defaults to the current directory
+	Directory string
+
+	// Do not link the standard library
+	NoSTD bool
+
+	// IO, defaults to the process standard streams
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// The clock of the timers and of timeMilli, defaults to the system clock.
+	// An object.FakeClock with AutoAdvance runs the timers without waiting and in a fixed order
+	Clock object.Clock
+
+	// Do not run the event loop until it drains after an evaluation, only the tasks and the timers
+	// that are due run. The later timers wait for Advance or RunUntil, they step through them
+	NoDrain bool
+}
+
+// Interpreter owns the environment and the state of a Monkey program,
+// many interpreters can run at the same time in one process
+type Interpreter struct {
+	// guards env and runtime, an interpreter runs one evaluation at a time
+	mu sync.Mutex
+
+	env     *object.Environment
+	runtime *object.Runtime
+	noDrain bool
+}
+
+// RuntimeError is returned when a script stops on a fatal error
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (re *RuntimeError) Error() string {
+	return strings.TrimSpace(re.Err.Inspect())
+}
+
+// ParseError is returned when a source cannot be parsed
+type ParseError struct {
+	Errors []*parser.ParseError
+}
+
+func (pe *ParseError) Error() string {
+	return parser.ParseErrors(pe.Errors).Error()
+}
+
+// New creates an interpreter and links the standard library into it
+func New(opts Options) (*Interpreter, error) {
+	if opts.Root == "" {
+		opts.Root = tmp.ExeDirectory
+	}
+	if opts.Directory == "" {
+		opts.Directory = tmp.CurrentDirectory
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+
+	var engine object.Engine
+	switch opts.Engine {
+	case "", EngineTree:
+	case EngineVM:
+		engine = vm.New()
+	default:
+		return nil, fmt.Errorf("unknown engine %q, expected %q or %q", opts.Engine, EngineTree, EngineVM)
+	}
+
+	runtimeOptions := &options.Options{
+		FatalErrors: opts.FatalErrors,
+		Debug:       opts.Debug,
+		StrictArity: opts.StrictArity,
+	}
+	paths := append([]string{filepath.Join(opts.Root, "lib")}, opts.Paths...)
+	r := runner.New(opts.Directory, paths, runtimeOptions, opts.Stderr)
+
+	interpreter := &Interpreter{
+		env:     object.NewEnvironment(),
+		runtime: evaluator.NewRuntime(runtimeOptions, r, opts.Stdout, opts.Stdin),
+		noDrain: opts.NoDrain,
+	}
+	interpreter.runtime.Engine = engine
+	interpreter.runtime.Clock = opts.Clock
+	interpreter.runtime.Stderr = opts.Stderr
+	interpreter.env.SetRuntime(interpreter.runtime)
+
+	if !opts.NoSTD {
+		if err := evaluator.LinkSTD(interpreter.env); err != nil {
+			return nil, fmt.Errorf("failed to compile the standard library: %v", err)
+		}
+	}
+
+	return interpreter, nil
+}
+
+// Env returns the global environment of the interpreter
+func (i *Interpreter) Env() *object.Environment {
+	return i.env
+}
+
+// Options returns the runtime options, they can be changed between evaluations
+func (i *Interpreter) Options() *options.Options {
+	return i.runtime.Options
+}
+
+// EvalString parses and evaluates a source string, then runs the event loop until it drains unless NoDrain
+func (i *Interpreter) EvalString(source string) (object.Object, error) {
+	l := lexer.New(source, "string")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if p.HasError() {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	return i.EvalProgram(program)
+}
+
+// EvalProgram evaluates an already parsed program, expanding its includes and macros,
+// then runs the event loop until it drains unless NoDrain
+func (i *Interpreter) EvalProgram(program *ast.Program) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	included := evaluator.ExpandInclude(program, i.env)
+	evaluator.DefineMacros(included.(*ast.Program), i.env)
+	expanded := evaluator.ExpandMacros(included, i.env)
+
+	return i.result(i.drain(evaluator.Run(expanded.(*ast.Program), i.env)))
+}
+
+// EvalFile evaluates a file, relative paths start from the interpreter directory,
+// then runs the event loop until it drains unless NoDrain
+func (i *Interpreter) EvalFile(filename string) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	result, err := evaluator.LinkAndEval(filename, i.env)
+	switch err := err.(type) {
+	case parser.ParseErrors:
+		return nil, &ParseError{Errors: err}
+	case nil:
+		return i.result(i.drain(result))
+	default:
+		if err == evaluator.ErrFatal {
+			return i.result(i.drain(result))
+		}
+		return result, err
+	}
+}
+
+// Call calls a global function by name, then runs the event loop until it drains unless NoDrain
+func (i *Interpreter) Call(fnName string, args ...object.Object) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	function, ok := i.env.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("function %q not found", fnName)
+	}
+	if _, ok := function.(object.FunctionObject); !ok {
+		return nil, fmt.Errorf("%q is not a function. got=%s", fnName, function.Type())
+	}
+
+	callToken := token.Token{
+		Type:     token.IDENT,
+		Literal:  fnName,
+		Filename: "call",
+	}
+	return i.result(i.drain(evaluator.ApplyFunction(callToken, function, args, i.env)))
+}
+
+// Advance runs the timers due within d and the tasks they start, see RunUntil
+func (i *Interpreter) Advance(d time.Duration) error {
+	return i.RunUntil(i.runtime.Now().Add(d))
+}
+
+// RunUntil runs the event loop until the tasks wait for the timers after t: the timers due by t fire in order
+// and the tasks they start run. An object.FakeClock is moved to each timer and then to t.
+// It returns the first error nobody handled in them
+func (i *Interpreter) RunUntil(t time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := evaluator.RunUntil(i.env, t); err != nil {
+		return &RuntimeError{Err: err}
+	}
+	return nil
+}
+
+// drain runs the timers and the tasks left by a successful evaluation, only those that are due with NoDrain.
+// The first error nobody handled in them replaces its result. Without NoDrain, the tasks left waiting
+// on each other, or by an error, are unwound and the generators left suspended are closed,
+// so that their goroutines exit
+func (i *Interpreter) drain(result object.Object) object.Object {
+	if !evaluator.CheckError(result) {
+		var err *object.Error
+		if i.noDrain {
+			err = evaluator.RunUntil(i.env, i.runtime.Now())
+		} else {
+			err = evaluator.RunEventLoop(i.env)
+		}
+		if err != nil {
+			result = err
+		}
+	}
+	if !i.noDrain {
+		i.runtime.Unwind()
+		i.runtime.CloseGenerators()
+	}
+	return result
+}
+
+// Close unwinds the tasks still waiting and closes the generators still suspended,
+// for an interpreter that is not used anymore. They are left after the evaluations with NoDrain
+func (i *Interpreter) Close() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.runtime.Unwind()
+	i.runtime.CloseGenerators()
+}
+
+// result converts a fatal error object into a go error
+func (i *Interpreter) result(obj object.Object) (object.Object, error) {
+	if evaluator.CheckError(obj) {
+		return obj, &RuntimeError{Err: obj.(*object.Error)}
+	}
+	return obj, nil
+}
@@ -0,0 +1,415 @@
+package monkey
+
+import (
+	"Monkey/object"
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Create an interpreter linked with the std in the repository
+func newTestInterpreter(t *testing.T, out *bytes.Buffer) *Interpreter {
+	interpreter, err := New(Options{
+		Root:   "..",
+		Stdout: out,
+		Stderr: out,
+	})
+	if err != nil {
+		t.Fatalf("failed to create interpreter. got=%v", err)
+	}
+	return interpreter
+}
+
+func TestEvalString(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := newTestInterpreter(t, &out)
+
+	result, err := interpreter.EvalString("let a = 5\na * 2")
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+	if result.Inspect() != "10" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	_, err = interpreter.EvalString(`"Hello";`)
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+	if out.String() != "Hello\n" {
+		t.Errorf("output not written to Stdout. got=%q", out.String())
+	}
+
+	_, err = interpreter.EvalString("missing")
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Errorf("expected a RuntimeError. got=%T (%v)", err, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := newTestInterpreter(t, &out)
+
+	_, err := interpreter.EvalString("let = 1\nlet b = )\nb")
+	parseError, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a ParseError. got=%T (%v)", err, err)
+	}
+	if len(parseError.Errors) != 2 {
+		t.Errorf("expected every error to be reported. got=%v", parseError)
+	}
+	if out.Len() != 0 {
+		t.Errorf("parse errors should not be printed. got=%q", out.String())
+	}
+}
+
+func TestCall(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := newTestInterpreter(t, &out)
+
+	_, err := interpreter.EvalString("let add = fn(a, b) { a + b }")
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+
+	result, err := interpreter.Call("add", &object.Integer{Value: 2}, &object.Integer{Value: 3})
+	if err != nil {
+		t.Fatalf("Call returned error. got=%v", err)
+	}
+	if result.Inspect() != "5" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if _, err := interpreter.Call("nothing"); err == nil {
+		t.Errorf("expected error when calling a missing function")
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	var out bytes.Buffer
+	first := newTestInterpreter(t, &out)
+	second := newTestInterpreter(t, &out)
+
+	_, err := first.EvalString(`
+let value = 1
+Array.prototype.first = fn() { this[0] }
+`)
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+
+	if _, err := second.EvalString("value"); err == nil {
+		t.Errorf("variable leaked into another interpreter")
+	}
+	if _, err := second.EvalString("[1].first()"); err == nil {
+		t.Errorf("prototype leaked into another interpreter")
+	}
+	result, err := first.EvalString("[4, 5].first()")
+	if err != nil || result.Inspect() != "4" {
+		t.Errorf("prototype not defined. got=%v, %v", result, err)
+	}
+}
+
+func TestConcurrentInterpreters(t *testing.T) {
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	errors := make([]error, 8)
+
+	for i := 0; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var out bytes.Buffer
+			interpreter, err := New(Options{Root: "..", Stdout: &out, Stderr: &out})
+			if err != nil {
+				errors[i] = err
+				return
+			}
+			source := fmt.Sprintf(`
+let fib = fn(n) { if n < 2 { return n } fib(n - 1) + fib(n - 2) }
+let arr = []
+arr.push(%d)
+fib(10) + arr[0]
+`, i)
+			result, err := interpreter.EvalString(source)
+			if err != nil {
+				errors[i] = err
+				return
+			}
+			results[i] = result.Inspect()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if errors[i] != nil {
+			t.Fatalf("interpreter %d failed. got=%v", i, errors[i])
+		}
+		expected := fmt.Sprintf("%d", 55+i)
+		if result != expected {
+			t.Errorf("interpreter %d returned wrong result. expected=%s, got=%s", i, expected, result)
+		}
+	}
+}
+
+// autoClock returns a fake clock starting at the epoch that jumps to the timers
+func autoClock() *object.FakeClock {
+	clock := object.NewFakeClock(time.Unix(0, 0))
+	clock.AutoAdvance = true
+	return clock
+}
+
+// The event loop drains before an evaluation returns, on a clock that the tests advance themselves
+func TestEventLoop(t *testing.T) {
+	var out, errOut bytes.Buffer
+	clock := autoClock()
+	interpreter, err := New(Options{Root: "..", Stdout: &out, Stderr: &errOut, Clock: clock})
+	if err != nil {
+		t.Fatalf("failed to create interpreter. got=%v", err)
+	}
+
+	result, err := interpreter.EvalString("let start = timeMilli()\nlet log = []\nsetTimeout(fn() { log.push(timeMilli() - start) }, 250)\nsetTimeout(fn() { log.push(timeMilli() - start) }, 100)\nlog")
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+	if result.Inspect() != "[100, 250]" {
+		t.Errorf("the timers did not run before EvalString returned. got=%s", result.Inspect())
+	}
+
+	clock.Advance(time.Second)
+	result, err = interpreter.EvalString("timeMilli() - start")
+	if err != nil {
+		t.Fatalf("EvalString returned error. got=%v", err)
+	}
+	if result.Inspect() != "1250" {
+		t.Errorf("the clock was not advanced. got=%s", result.Inspect())
+	}
+
+	_, err = interpreter.EvalString("let f = async fn(e) { throw e }\nf(\"lost\")\nf(\"also lost\")")
+	runtimeError, ok := err.(*RuntimeError)
+	if !ok || runtimeError.Err.Message != "lost" {
+		t.Fatalf("expected the unhandled rejection as a RuntimeError. got=%T (%v)", err, err)
+	}
+	if out.String() != "" {
+		t.Errorf("the errors were printed to Stdout. got=%q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "Runtime Error: also lost") || strings.Contains(errOut.String(), "Runtime Error: lost") {
+		t.Errorf("only the unhandled rejection that is not returned must be logged to Stderr. got=%q", errOut.String())
+	}
+
+	interpreter.EvalString("let double = async fn(x) { sleep(10)\nx * 2 }")
+	result, err = interpreter.Call("double", &object.Integer{Value: 21})
+	if err != nil {
+		t.Fatalf("Call returned error. got=%v", err)
+	}
+	if promise, ok := result.(*object.Promise); !ok || promise.Result().Inspect() != "42" {
+		t.Errorf("the promise returned by Call did not settle. got=%s", result.Inspect())
+	}
+}
+
+// With NoDrain the timers wait for Advance and RunUntil, the state between them can be inspected
+func TestAdvance(t *testing.T) {
+	source := "let start = timeMilli()\nlet log = []\nsetTimeout(fn() { log.push(\"once\") }, 100)\nlet ticker = setInterval(fn() { log.push(timeMilli() - start) }, 40)\nlet later = async fn() { sleep(150)\nlog.push(\"slept\") }\nlater()\nlog"
+	steps := []struct {
+		advance  time.Duration
+		expected string
+	}{
+		{50 * time.Millisecond, "[40]"},
+		{50 * time.Millisecond, "[40, 80, once]"},
+		{60 * time.Millisecond, "[40, 80, once, 120, slept, 160]"},
+	}
+
+	for _, engine := range []string{EngineTree, EngineVM} {
+		var out bytes.Buffer
+		clock := object.NewFakeClock(time.Unix(0, 0))
+		interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &out, Stderr: &out, Clock: clock, NoDrain: true})
+		if err != nil {
+			t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+		}
+
+		result, err := interpreter.EvalString(source)
+		if err != nil {
+			t.Fatalf("%s: EvalString returned error. got=%v", engine, err)
+		}
+		if result.Inspect() != "[]" {
+			t.Errorf("%s: the timers ran before the clock was advanced. got=%s", engine, result.Inspect())
+		}
+
+		elapsed := time.Duration(0)
+		for _, step := range steps {
+			if err := interpreter.Advance(step.advance); err != nil {
+				t.Fatalf("%s: Advance returned error. got=%v", engine, err)
+			}
+			elapsed += step.advance
+			if now := clock.Now(); !now.Equal(time.Unix(0, 0).Add(elapsed)) {
+				t.Errorf("%s: the clock was not advanced to %s. got=%s", engine, elapsed, now.Sub(time.Unix(0, 0)))
+			}
+			result, _ = interpreter.EvalString("log")
+			if result.Inspect() != step.expected {
+				t.Errorf("%s: wrong timers ran by %s. want=%s, got=%s", engine, elapsed, step.expected, result.Inspect())
+			}
+		}
+
+		interpreter.EvalString("clearInterval(ticker)\nsetTimeout(fn() { throw \"late\" }, 10)")
+		if err := interpreter.RunUntil(time.Unix(0, 0).Add(time.Hour)); err == nil || err.(*RuntimeError).Err.Message != "late" {
+			t.Errorf("%s: expected the error of the timer from RunUntil. got=%v", engine, err)
+		}
+		result, _ = interpreter.EvalString("[log.length, timeMilli() - start]")
+		if result.Inspect() != "[6, 3600000]" {
+			t.Errorf("%s: wrong state after RunUntil. got=%s", engine, result.Inspect())
+		}
+	}
+}
+
+// The tasks left waiting by an evaluation are unwound, their goroutines do not keep the interpreter
+func TestTasksDoNotLeak(t *testing.T) {
+	sources := []string{
+		"let c = channel()\nspawn(fn() { c.recv() })\n1",
+		"let c = channel()\nspawn(fn() { c.recv() })\nc.recv()",
+		"let c = channel()\nlet g = fn*() { yield c.recv() }\nspawn(fn() { g().next() })\n1",
+		"let c = channel()\nspawn(fn() { sleep(10)\nc.recv() })\n1",
+	}
+	before := runtime.NumGoroutine()
+
+	for _, engine := range []string{EngineTree, EngineVM} {
+		for _, source := range sources {
+			for i := 0; i < 10; i++ {
+				interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &bytes.Buffer{}, Clock: autoClock()})
+				if err != nil {
+					t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+				}
+				interpreter.EvalString(source)
+			}
+		}
+
+		interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &bytes.Buffer{}, Clock: autoClock(), NoDrain: true})
+		if err != nil {
+			t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+		}
+		interpreter.EvalString(sources[3])
+		interpreter.Close()
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("the goroutines of waiting tasks leaked. before=%d, after=%d", before, after)
+	}
+}
+
+// The generators left suspended by an evaluation are closed, their goroutines do not keep the interpreter
+func TestGeneratorsDoNotLeak(t *testing.T) {
+	source := "let naturals = fn*() {\nlet i = 0\nwhile true {\nyield i\ni += 1\n}\n}\nlet g = naturals()\ng.next()\nlet h = naturals()\n[h.next().value, h.next().value]"
+	before := runtime.NumGoroutine()
+
+	for _, engine := range []string{EngineTree, EngineVM} {
+		for i := 0; i < 10; i++ {
+			interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &bytes.Buffer{}})
+			if err != nil {
+				t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+			}
+			result, err := interpreter.EvalString(source)
+			if err != nil || result.Inspect() != "[0, 1]" {
+				t.Fatalf("%s: wrong result. got=%v (%v)", engine, result, err)
+			}
+		}
+
+		interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &bytes.Buffer{}, NoDrain: true})
+		if err != nil {
+			t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+		}
+		interpreter.EvalString(source)
+		interpreter.Close()
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("the goroutines of suspended generators leaked. before=%d, after=%d", before, after)
+	}
+}
+
+func TestStrictArity(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"let f = fn(a, b = 2) { a + b }\nf(1)", "3"},
+		{"let f = fn(a, ...rest) { rest }\nf(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, b) { a - b }\nf(b: 1, a: 3)", "2"},
+		{"let f = fn(a, b = 2) { a + b }\nf()", "f is missing the argument a"},
+		{"let f = fn(a, b = 2) { a + b }\nf(1, 2, 3)", "f takes at most 2 arguments, got 3"},
+		{"[1, 2].map(fn() { 0 })", "<anonymous> takes at most 0 arguments, got 1"},
+	}
+
+	for _, engine := range []string{EngineTree, EngineVM} {
+		for _, tt := range tests {
+			var out bytes.Buffer
+			interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &out, Stderr: &out, StrictArity: true})
+			if err != nil {
+				t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+			}
+			result, err := interpreter.EvalString(tt.source)
+			got := ""
+			if runtimeErr, ok := err.(*RuntimeError); ok {
+				got = runtimeErr.Err.Message
+			} else if err != nil {
+				t.Fatalf("%s engine failed on %q. got=%v", engine, tt.source, err)
+			} else {
+				got = result.Inspect()
+			}
+			if got != tt.expected {
+				t.Errorf("%s engine on %q. want=%q, got=%q", engine, tt.source, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	source := "let inner = fn() {\n    panic!()\n}\nlet outer = fn() {\n    [1].map(fn(x) { inner() })\n}\nouter()"
+
+	for _, engine := range []string{EngineTree, EngineVM} {
+		var out bytes.Buffer
+		interpreter, err := New(Options{Engine: engine, Root: "..", Stdout: &out, Stderr: &out})
+		if err != nil {
+			t.Fatalf("failed to create %s interpreter. got=%v", engine, err)
+		}
+		result, _ := interpreter.EvalString(source)
+		runtimeErr, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("%s engine expected an error. got=%v", engine, result)
+		}
+
+		var functions []string
+		for _, frame := range runtimeErr.Stack {
+			functions = append(functions, frame.Function)
+		}
+		expected := "outer map forEach <anonymous> <anonymous> <anonymous> inner"
+		if strings.Join(functions, " ") != expected {
+			t.Errorf("%s engine has wrong stack. want=%q, got=%q", engine, expected, strings.Join(functions, " "))
+		}
+		if site := runtimeErr.Stack[0]; site.RowNumber != 7 || site.ColumnNumber != 6 {
+			t.Errorf("%s engine has wrong call site. got=%d:%d", engine, site.RowNumber, site.ColumnNumber)
+		}
+		if !strings.HasPrefix(runtimeErr.Inspect(), "Traceback (most recent call last):\n") ||
+			!strings.Contains(runtimeErr.Inspect(), "in inner\n") {
+			t.Errorf("%s engine has wrong traceback. got=%q", engine, runtimeErr.Inspect())
+		}
+
+		// the call stack is empty again for the next program
+		if _, err := interpreter.EvalString("outer"); err != nil {
+			t.Errorf("%s engine failed after an error. got=%v", engine, err)
+		}
+		if depth := len(interpreter.runtime.CallStack); depth != 0 {
+			t.Errorf("%s engine left %d frames on the call stack", engine, depth)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"fmt"
+)
+
+// A channel passes values between tasks in order. A send waits while the buffer is full,
+// a channel without a buffer only takes a value when a task is waiting to receive it.
+// Channels are only used by the running task of their runtime, see Task
+type Channel struct {
+	Capacity int
+
+	buffer    []Object
+	closed    bool
+	receivers int // The tasks waiting to receive
+}
+
+func (c *Channel) Type() ObjectType {
+	return ChannelObj
+}
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("channel(%d)", c.Capacity)
+}
+
+// Closed returns whether the channel is closed, the values in its buffer can still be received
+func (c *Channel) Closed() bool {
+	return c.closed
+}
+
+// CanSend returns whether a send would not wait, sending on a closed channel fails right away
+func (c *Channel) CanSend() bool {
+	return c.closed || len(c.buffer) < c.Capacity || c.receivers > len(c.buffer)
+}
+
+// CanReceive returns whether a receive would not wait
+func (c *Channel) CanReceive() bool {
+	return c.closed || len(c.buffer) > 0
+}
+
+// Send adds a value to the buffer, the channel must be able to send and not be closed
+func (c *Channel) Send(value Object) {
+	c.buffer = append(c.buffer, value)
+}
+
+// Receive takes the first value of the buffer, it returns false when the channel is closed and empty
+func (c *Channel) Receive() (Object, bool) {
+	if len(c.buffer) == 0 {
+		return nil, false
+	}
+	value := c.buffer[0]
+	c.buffer[0] = nil
+	c.buffer = c.buffer[1:]
+	return value, true
+}
+
+// Close closes the channel, it returns false when it is already closed
+func (c *Channel) Close() bool {
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	return true
+}
+
+// Receiving marks a task as waiting to receive from the channel, or as done waiting with -1,
+// unbuffered sends can only run while a task is waiting
+func (c *Channel) Receiving(delta int) {
+	c.receivers += delta
+}
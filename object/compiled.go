@@ -0,0 +1,120 @@
+package object
+
+import (
+	"Monkey/ast"
+	"Monkey/code"
+	"Monkey/token"
+	"strings"
+)
+
+// Object Types used by the bytecode vm
+const (
+	CompiledFunctionObj = "COMPILED_FUNCTION" // Compiled fn
+	CellObj             = "CELL"              // Captured variable
+)
+
+// CompiledFunction is a function lowered to bytecode
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+
+	// The tokens referred to by the instructions, used for errors
+	Tokens []token.Token
+
+	// The source, used by Inspect and modules
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Name       string
+
+	// The default values of the parameters, which are optional, and the ...rest parameter,
+	// see ast.FunctionLiteral. The rest is the last parameter and is counted in NumParameters
+	Defaults []ast.Expression
+	Rest     *ast.Identifier
+
+	// Whether calls return a generator running the function
+	Generator bool
+
+	// Whether calls run the function in a task and return the promise of its result
+	Async bool
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return CompiledFunctionObj
+}
+func (cf *CompiledFunction) Inspect() string {
+	return inspectFunction(cf.Parameters, cf.Rest, cf.Body, cf.Generator, cf.Async)
+}
+
+// Cell holds a variable captured by a closure so that it can be shared and modified
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CellObj
+}
+func (c *Cell) Inspect() string {
+	return c.Value.Inspect()
+}
+
+// Machine runs closures that are called from outside of the vm
+type Machine interface {
+	CallClosure(token token.Token, closure *Closure, this Object, args []Object, named []NamedArgument) Object
+
+	// Fork creates a machine for a task, sharing the globals of this one
+	Fork() Machine
+}
+
+// Closure is a compiled function with its captured variables
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+
+	// The receiver captured when the closure was created
+	This Object
+
+	// The environment global names are resolved in
+	Env *Environment
+
+	Machine Machine
+}
+
+func (c *Closure) Type() ObjectType {
+	return FunctionObj
+}
+func (c *Closure) Inspect() string {
+	return c.Fn.Inspect()
+}
+func (c *Closure) FunctionObject() {
+
+}
+
+func inspectFunction(parameters []*ast.Identifier, rest *ast.Identifier, body *ast.BlockStatement, generator, async bool) string {
+	var out strings.Builder
+
+	var params []string
+	for _, p := range parameters {
+		params = append(params, p.ToString())
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.ToString())
+	}
+
+	if async {
+		out.WriteString("async ")
+	}
+	out.WriteString("fn")
+	if generator {
+		out.WriteString("*")
+	}
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") { ")
+	if body != nil {
+		out.WriteString(body.ToString())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
@@ -4,6 +4,8 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	runtime *Runtime
 }
 
 // Create a new environment
@@ -15,9 +17,26 @@ func NewEnvironment() *Environment {
 func NewEnclosingEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.runtime = outer.Runtime()
 	return env
 }
 
+// Runtime returns the interpreter state the environment belongs to
+func (e *Environment) Runtime() *Runtime {
+	if e.runtime == nil && e.outer != nil {
+		e.runtime = e.outer.Runtime()
+	}
+	return e.runtime
+}
+
+// SetRuntime attaches the interpreter state to the root of the environment
+func (e *Environment) SetRuntime(runtime *Runtime) {
+	for e.outer != nil {
+		e = e.outer
+	}
+	e.runtime = runtime
+}
+
 // Get an item from the environment
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
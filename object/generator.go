@@ -0,0 +1,178 @@
+package object
+
+import (
+	"Monkey/token"
+	"runtime"
+	"sync"
+)
+
+// Yield suspends the body of a generator, handing value to the code resuming it.
+// It returns the value given to the next resume
+type Yield func(value Object) Object
+
+// A generator, the suspended call of a generator function resumed by its next().
+// The body runs in a goroutine taking turns with the code resuming it, so only one of them runs at a time.
+// A generator dropped before its end is closed once it is garbage collected, so that its goroutine does not leak,
+// the generators still suspended when the program ends are closed by Runtime.CloseGenerators
+type Generator struct {
+	Name string
+	co   *coroutine
+}
+
+// coroutine is the goroutine of a generator, it does not refer to the generator so that the generator
+// can be collected while the goroutine waits
+type coroutine struct {
+	runtime *Runtime
+	body    func(yield Yield) Object // nil once the goroutine is started
+
+	resume  chan Object
+	results chan generatorResult
+	closed  chan struct{}
+	exited  chan struct{}
+	cancel  sync.Once
+
+	running bool
+	done    bool
+}
+
+type generatorResult struct {
+	value   Object
+	done    bool
+	unwound bool // The task running the body is unwound, see Runtime.Unwind
+}
+
+// closedGenerator unwinds the goroutine of a closed generator from its yield
+type closedGenerator struct{}
+
+// NewGenerator creates a generator, body is run on the first resume and its result ends the generator
+func NewGenerator(rt *Runtime, name string, body func(yield Yield) Object) *Generator {
+	g := &Generator{
+		Name: name,
+		co: &coroutine{
+			runtime: rt,
+			body:    body,
+			resume:  make(chan Object),
+			results: make(chan generatorResult),
+			closed:  make(chan struct{}),
+			exited:  make(chan struct{}),
+		},
+	}
+	runtime.SetFinalizer(g, func(g *Generator) {
+		g.co.close()
+	})
+	return g
+}
+
+func (g *Generator) Type() ObjectType {
+	return GeneratorObj
+}
+func (g *Generator) Inspect() string {
+	return "generator " + FunctionName(g.Name)
+}
+
+// Running returns whether the body of the generator is running, a running generator cannot be resumed or closed
+func (g *Generator) Running() bool {
+	return g.co.running
+}
+
+// Resume runs the generator until its next yield, the yield evaluates to value. It returns the yielded value,
+// or the result of the body and true when the body ends. A generator that is done returns nil and true.
+// The generator is on the call stack of the runtime while it runs, called at site
+func (g *Generator) Resume(site token.TokenData, value Object) (Object, bool) {
+	co := g.co
+	if co.done {
+		return nil, true
+	}
+
+	depth := co.runtime.PushFrame(FunctionName(g.Name), site)
+	co.running = true
+	if co.body != nil {
+		body := co.body
+		co.body = nil
+		if co.runtime.generators == nil {
+			co.runtime.generators = map[*coroutine]bool{}
+		}
+		co.runtime.generators[co] = true
+		go co.run(body)
+	} else {
+		co.resume <- value
+	}
+	result := <-co.results
+	co.running = false
+	co.done = result.done
+	if co.done {
+		delete(co.runtime.generators, co)
+	}
+	if result.unwound {
+		// The task resuming the generator unwinds too
+		runtime.Goexit()
+	}
+	co.runtime.PopFrames(depth, result.value)
+
+	// The generator must not be collected, and closed, while it runs
+	runtime.KeepAlive(g)
+	return result.value, result.done
+}
+
+// Close ends a generator that is not running, a suspended body is left at its yield without running the rest of it
+func (g *Generator) Close() {
+	g.co.end()
+}
+
+// CloseGenerators closes the generators left suspended at a yield, their goroutines exit.
+// The goroutine of a suspended generator refers to the environment of its body, which can refer to the generator
+// and keep it from being collected
+func (r *Runtime) CloseGenerators() {
+	for co := range r.generators {
+		co.end()
+	}
+}
+
+// end ends the body of a coroutine that is not running
+func (co *coroutine) end() {
+	if co.running || co.done {
+		return
+	}
+	co.done = true
+	co.close()
+	if co.body == nil {
+		<-co.exited
+		delete(co.runtime.generators, co)
+	}
+	co.body = nil
+}
+
+func (co *coroutine) run(body func(yield Yield) Object) {
+	defer close(co.exited)
+	returned := false
+	defer func() {
+		r := recover()
+		if r == nil && !returned {
+			co.results <- generatorResult{done: true, unwound: true}
+			return
+		}
+		if _, ok := r.(closedGenerator); !ok && r != nil {
+			panic(r)
+		}
+	}()
+
+	value := body(co.yield)
+	returned = true
+	co.results <- generatorResult{value: value, done: true}
+}
+
+func (co *coroutine) yield(value Object) Object {
+	co.results <- generatorResult{value: value}
+	select {
+	case sent := <-co.resume:
+		return sent
+	case <-co.closed:
+		panic(closedGenerator{})
+	}
+}
+
+func (co *coroutine) close() {
+	co.cancel.Do(func() {
+		close(co.closed)
+	})
+}
@@ -0,0 +1,217 @@
+package object
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Clock tells the time to the event loop of a runtime, and waits for its timers
+type Clock interface {
+	Now() time.Time
+	// Sleep waits until the clock reaches until
+	Sleep(until time.Time)
+}
+
+// SystemClock is the clock of the system, the default clock of a runtime
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+func (SystemClock) Sleep(until time.Time) {
+	time.Sleep(time.Until(until))
+}
+
+// FakeClock is a clock that only moves when it is told to, by Advance, AdvanceTo or Runtime.RunUntil.
+// Sleep waits until another goroutine moves it far enough, the clock is safe to share between goroutines
+type FakeClock struct {
+	// AutoAdvance makes Sleep jump to the time it waits for, so the timers of a program
+	// fire right away and always in the same order
+	AutoAdvance bool
+
+	mu    sync.Mutex
+	moved *sync.Cond
+	now   time.Time
+}
+
+// NewFakeClock creates a fake clock starting at start
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.moved = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+func (c *FakeClock) Sleep(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.AutoAdvance && until.After(c.now) {
+		c.now = until
+	}
+	for c.now.Before(until) {
+		c.moved.Wait()
+	}
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.moved.Broadcast()
+}
+
+// AdvanceTo moves the clock forward to t, it does nothing when the clock is already past t
+func (c *FakeClock) AdvanceTo(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+		c.moved.Broadcast()
+	}
+}
+
+// A timer of the event loop, set by setTimeout, setInterval and sleep
+type Timer struct {
+	At time.Time
+	// The timer fires again after the interval, 0 for a timer firing once
+	Interval time.Duration
+	// Fire is called by the running task once the clock reaches At,
+	// it must not run code of the program but spawn a task or notify the waiting ones
+	Fire func()
+
+	seq     uint64 // Orders the timers firing at the same time, first set first
+	index   int    // The position in the timers of the scheduler
+	pending bool
+}
+
+func (t *Timer) Type() ObjectType {
+	return TimerObj
+}
+func (t *Timer) Inspect() string {
+	if t.Interval > 0 {
+		return "timer(every " + t.Interval.String() + ")"
+	}
+	return "timer"
+}
+
+// Pending returns whether the timer is set and has not fired yet, intervals are pending until stopped
+func (t *Timer) Pending() bool {
+	return t.pending
+}
+
+// timers are the pending timers of a runtime, in the order they fire
+type timers []*Timer
+
+func (ts timers) Len() int {
+	return len(ts)
+}
+func (ts timers) Less(i, j int) bool {
+	if ts[i].At.Equal(ts[j].At) {
+		return ts[i].seq < ts[j].seq
+	}
+	return ts[i].At.Before(ts[j].At)
+}
+func (ts timers) Swap(i, j int) {
+	ts[i], ts[j] = ts[j], ts[i]
+	ts[i].index, ts[j].index = i, j
+}
+func (ts *timers) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index, t.pending = len(*ts), true
+	*ts = append(*ts, t)
+}
+func (ts *timers) Pop() interface{} {
+	old := *ts
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*ts = old[:len(old)-1]
+	t.pending = false
+	return t
+}
+
+// Now returns the time of the clock of the runtime
+func (r *Runtime) Now() time.Time {
+	return r.clock().Now()
+}
+
+func (r *Runtime) clock() Clock {
+	if r.Clock == nil {
+		return SystemClock{}
+	}
+	return r.Clock
+}
+
+// SetTimer sets a timer to fire at t.At, the timer fires while the tasks wait
+func (r *Runtime) SetTimer(t *Timer) {
+	s := r.getScheduler()
+	s.seq++
+	t.seq = s.seq
+	heap.Push(&s.timers, t)
+}
+
+// StopTimer stops a pending timer, it returns false when the timer is not pending
+func (r *Runtime) StopTimer(t *Timer) bool {
+	if r.scheduler == nil || !t.Pending() {
+		return false
+	}
+	heap.Remove(&r.scheduler.timers, t.index)
+	return true
+}
+
+// fireTimers fires the timers the clock reached, intervals are set again for their next time.
+// It returns whether any timer fired
+func (r *Runtime) fireTimers() bool {
+	s := r.scheduler
+	if len(s.timers) == 0 {
+		return false
+	}
+	now := r.Now()
+	fired := false
+	for len(s.timers) > 0 && !s.timers[0].At.After(now) {
+		t := heap.Pop(&s.timers).(*Timer)
+		if t.Interval > 0 {
+			t.At = t.At.Add(t.Interval)
+			r.SetTimer(t)
+		}
+		t.Fire()
+		fired = true
+	}
+	return fired
+}
+
+// sleep lets the clock reach t, RunUntil moves a FakeClock itself instead of waiting for it
+func (r *Runtime) sleep(t time.Time) {
+	if clock, ok := r.clock().(*FakeClock); ok && r.scheduler.stepping {
+		clock.AdvanceTo(t)
+		return
+	}
+	r.clock().Sleep(t)
+}
+
+// Drain runs the other tasks and the timers until none is left, the tasks still waiting
+// on each other are left waiting
+func (r *Runtime) Drain() {
+	s := r.getScheduler()
+	s.stepper = s.current
+	r.Wait(func() bool { return false })
+	s.stepper = nil
+}
+
+// RunUntil runs the other tasks and the timers due by until, it returns once the tasks wait for the timers
+// after until or for nothing. The clock sleeps until each timer, a FakeClock is moved to it and then to until
+func (r *Runtime) RunUntil(until time.Time) {
+	s := r.getScheduler()
+	s.stepper, s.deadline, s.stepping = s.current, until, true
+	r.Wait(func() bool { return false })
+	s.stepper, s.stepping = nil, false
+
+	if clock, ok := r.clock().(*FakeClock); ok {
+		clock.AdvanceTo(until)
+	}
+}
@@ -0,0 +1,95 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+	"strings"
+)
+
+// The arbitrary precision integer wrapper, 123n
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) GetValue() interface{} {
+	return b.Value
+}
+func (b *BigInt) Type() ObjectType {
+	return BigIntObj
+}
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+// BigInt hash
+// Values that fit an integer or a float hash like them, as 1n == 1
+func (b *BigInt) HashKey() HashKey {
+	return ratHashKey(new(big.Rat).SetInt(b.Value), BigIntObj, b.Inspect())
+}
+
+// The exact decimal wrapper, 1.10d is stored as the digits 110 with a scale of 2
+type Decimal struct {
+	Value *big.Int // the digits without the point
+	Scale int      // the amount of digits after the point
+}
+
+func (d *Decimal) GetValue() interface{} {
+	return d.Rat()
+}
+func (d *Decimal) Type() ObjectType {
+	return DecimalObj
+}
+
+// Inspect keeps the trailing zeros of the decimal, 1.10d prints as 1.10
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat returns the exact value of the decimal
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Value, Pow10(d.Scale))
+}
+
+// Rescale returns the digits of the decimal with scale digits after the point, it cannot drop digits
+func (d *Decimal) Rescale(scale int) *big.Int {
+	if scale <= d.Scale {
+		return d.Value
+	}
+	return new(big.Int).Mul(d.Value, Pow10(scale-d.Scale))
+}
+
+// Decimal hash
+// Trailing zeros are ignored, and values that fit an integer or a float hash like them
+func (d *Decimal) HashKey() HashKey {
+	rat := d.Rat()
+	return ratHashKey(rat, DecimalObj, rat.RatString())
+}
+
+// Pow10 returns 10 to the power of n
+func Pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// ratHashKey hashes an exact number, the fallback text is hashed when it is neither an integer nor a float
+func ratHashKey(rat *big.Rat, typ ObjectType, fallback string) HashKey {
+	if rat.IsInt() && rat.Num().IsInt64() {
+		return (&Integer{Value: rat.Num().Int64()}).HashKey()
+	}
+	if float, exact := rat.Float64(); exact {
+		return (&Float{Value: float}).HashKey()
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(fallback))
+	return HashKey{Type: typ, Value: h.Sum64()}
+}
@@ -2,9 +2,11 @@ package object
 
 import (
 	"Monkey/ast"
+	"Monkey/parser"
 	"Monkey/token"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -12,6 +14,9 @@ import (
 // Object Types
 const (
 	IntegerObj     = "INTEGER"      // Int
+	FloatObj       = "FLOAT"        // Float
+	BigIntObj      = "BIGINT"       // 123n
+	DecimalObj     = "DECIMAL"      // 1.10d
 	BooleanObj     = "BOOLEAN"      // Bool
 	NullObj        = "NULL"         // Disgusting
 	BreakObj       = "BREAK"        // break
@@ -26,11 +31,31 @@ const (
 	QuoteObj       = "QUOTE"        // Quotes
 	MacroObj       = "MACRO"        // Macros
 	ModuleObj      = "MODULE"       // Modules
+	LoopControlObj = "LOOP_CONTROL" // break or continue
+	IteratorObj    = "ITERATOR"     // for in
+	GeneratorObj   = "GENERATOR"    // fn*
+	TaskObj        = "TASK"         // spawn
+	ChannelObj     = "CHANNEL"      // channel
+	TimerObj       = "TIMER"        // setTimeout and setInterval
+	PromiseObj     = "PROMISE"      // Promise and async fn
 )
 
 // The type of the object
 type ObjectType string
 
+var typeNames = map[ObjectType]bool{
+	IntegerObj: true, FloatObj: true, BigIntObj: true, DecimalObj: true, BooleanObj: true, NullObj: true,
+	BreakObj: true, ReturnValueObj: true, ErrorObj: true, FunctionObj: true, StringObj: true, BuiltinObj: true,
+	ProtoObj: true, ArrayObj: true, HashObj: true, QuoteObj: true, MacroObj: true, ModuleObj: true,
+	LoopControlObj: true, IteratorObj: true, GeneratorObj: true, TaskObj: true, ChannelObj: true,
+	TimerObj: true, PromiseObj: true,
+}
+
+// IsTypeName returns whether name is the name of a type, like "INTEGER"
+func IsTypeName(name string) bool {
+	return typeNames[ObjectType(name)]
+}
+
 // The struct where every object inherent from
 type Object interface {
 	Type() ObjectType // The type of the object
@@ -45,9 +70,7 @@ type FunctionObject interface {
 
 // The integer wrapper
 type Integer struct {
-	Value float64
-
-	Hash *HashKey
+	Value int64
 }
 
 func (i *Integer) GetValue() interface{} {
@@ -57,7 +80,27 @@ func (i *Integer) Type() ObjectType {
 	return IntegerObj
 }
 func (i *Integer) Inspect() string {
-	return strconv.FormatFloat(i.Value, 'f', -1, 64)
+	return strconv.FormatInt(i.Value, 10)
+}
+
+// The float wrapper
+type Float struct {
+	Value float64
+}
+
+func (f *Float) GetValue() interface{} {
+	return f.Value
+}
+func (f *Float) Type() ObjectType {
+	return FloatObj
+}
+func (f *Float) Inspect() string {
+	return FormatFloat(f.Value)
+}
+
+// FormatFloat prints a float so that it cannot be read as an integer, 2.0 instead of 2
+func FormatFloat(value float64) string {
+	return parser.FormatFloat(value)
 }
 
 type Break struct{}
@@ -70,11 +113,46 @@ func (b *Break) Inspect() string {
 	return "break"
 }
 
+// A break or continue statement on its way to its loop
+type LoopControl struct {
+	Continue bool
+	Label    string // the targeted loop, empty for the innermost
+}
+
+func (lc *LoopControl) Type() ObjectType {
+	return LoopControlObj
+}
+func (lc *LoopControl) Inspect() string {
+	keyword := "break"
+	if lc.Continue {
+		keyword = "continue"
+	}
+	if lc.Label != "" {
+		return keyword + " " + lc.Label
+	}
+	return keyword
+}
+
+// The elements of an iterable walked by a for in loop
+type Iterator struct {
+	// Next returns the next element, or false once the elements are exhausted,
+	// a fatal error stops the loop
+	Next func() (Object, bool)
+
+	// Close releases an iterator left before its end, such as a generator, nil when there is nothing to release
+	Close func()
+}
+
+func (it *Iterator) Type() ObjectType {
+	return IteratorObj
+}
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
 // The boolean wrapper
 type Boolean struct {
 	Value bool
-
-	Hash *HashKey
 }
 
 func (b *Boolean) Type() ObjectType {
@@ -109,14 +187,27 @@ func (rv *ReturnValue) Inspect() string {
 type Error struct {
 	Message string
 	*token.TokenData
+
+	// Fatal errors stop the execution instead of being treated as values
+	Fatal bool
+
+	// The calls leading to the error, outermost first
+	Stack []StackFrame
+
+	// The value given to error() or thrown, nil otherwise
+	Data Object
 }
 
 func (e *Error) Type() ObjectType {
 	return ErrorObj
 }
 func (e *Error) Inspect() string {
-	return fmt.Sprintf("Runtime Error: %s, at %d:%d, in file %s\n",
+	message := fmt.Sprintf("Runtime Error: %s, at %d:%d, in file %s\n",
 		e.Message, e.RowNumber, e.ColumnNumber, e.Filename)
+	if len(e.Stack) == 0 {
+		return message
+	}
+	return e.Traceback() + message
 }
 
 // Module type
@@ -134,8 +225,14 @@ func (m *Module) Inspect() string {
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Patterns   []ast.Expression // The patterns destructuring the parameters, see ast.FunctionLiteral
+	Defaults   []ast.Expression // The default values of the parameters, see ast.FunctionLiteral
+	Rest       *ast.Identifier  // The parameter collecting the extra arguments, nil without one
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string // The name it was assigned to, empty for anonymous functions
+	Generator  bool   // Whether calls return a generator running the body
+	Async      bool   // Whether calls run the body in a task and return the promise of its result
 }
 
 func (f *Function) Type() ObjectType {
@@ -148,8 +245,17 @@ func (f *Function) Inspect() string {
 	for _, p := range f.Parameters {
 		params = append(params, p.ToString())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.ToString())
+	}
 
+	if f.Async {
+		out.WriteString("async ")
+	}
 	out.WriteString("fn")
+	if f.Generator {
+		out.WriteString("*")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") { ")
@@ -162,6 +268,12 @@ func (f *Function) FunctionObject() {
 
 }
 
+// An argument passed by the name of its parameter, f(name: value)
+type NamedArgument struct {
+	Name  string
+	Value Object
+}
+
 // String object
 type String struct {
 	Value string
@@ -232,11 +344,8 @@ type HashKey struct {
 }
 
 // Boolean hash
+// Not cached as TRUE and FALSE are shared between interpreters
 func (b *Boolean) HashKey() HashKey {
-	if b.Hash != nil {
-		return *b.Hash
-	}
-
 	var value uint64
 
 	if b.Value {
@@ -245,21 +354,21 @@ func (b *Boolean) HashKey() HashKey {
 		value = 2
 	}
 
-	hash := HashKey{Type: b.Type(), Value: value}
-	b.Hash = &hash
-
-	return hash
+	return HashKey{Type: b.Type(), Value: value}
 }
 
 // Integer hash
 func (i *Integer) HashKey() HashKey {
-	if i.Hash != nil {
-		return *i.Hash
-	}
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
 
-	hash := HashKey{Type: i.Type(), Value: uint64(i.Value)}
-	i.Hash = &hash
-	return hash
+// Float hash
+// Whole floats hash like the equal integer, as 1 == 1.0
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: IntegerObj, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
 }
 
 // String hash
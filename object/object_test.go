@@ -1,6 +1,15 @@
 package object
 
-import "testing"
+import (
+	"Monkey/token"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 // Test Hashing Strings
 func TestStringHashKey(t *testing.T) {
@@ -28,3 +37,72 @@ func TestStringHashKey(t *testing.T) {
 		}
 	}
 }
+
+func TestTracebackRepeatedLines(t *testing.T) {
+	site := &token.TokenData{Filename: "missing.mky", RowNumber: 2, ColumnNumber: 5}
+	stack := []StackFrame{{Function: "f", TokenData: token.TokenData{Filename: "missing.mky", RowNumber: 4, ColumnNumber: 1}}}
+	for i := 0; i < 10; i++ {
+		stack = append(stack, StackFrame{Function: "f", TokenData: *site})
+	}
+	err := &Error{Message: "stack overflow", TokenData: site, Stack: stack}
+
+	expected := `Traceback (most recent call last):
+  File "missing.mky", line 4, in <module>
+  File "missing.mky", line 2, in f
+  File "missing.mky", line 2, in f
+  File "missing.mky", line 2, in f
+  [Previous line repeated 7 more times]
+  File "missing.mky", line 2, in f
+Runtime Error: stack overflow, at 2:5, in file missing.mky
+`
+	if err.Inspect() != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, err.Inspect())
+	}
+}
+
+// Every object type declared in the package is a type name, except the ones used only inside the vm
+func TestTypeNames(t *testing.T) {
+	internal := map[string]bool{CompiledFunctionObj: true, CellObj: true}
+
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := gotoken.NewFileSet()
+	found := 0
+	for _, filename := range files {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		file, err := goparser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*goast.GenDecl)
+			if !ok || decl.Tok != gotoken.CONST {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*goast.ValueSpec)
+				for i, name := range spec.Names {
+					if !strings.HasSuffix(name.Name, "Obj") || i >= len(spec.Values) {
+						continue
+					}
+					lit, ok := spec.Values[i].(*goast.BasicLit)
+					if !ok || lit.Kind != gotoken.STRING {
+						continue
+					}
+					value, _ := strconv.Unquote(lit.Value)
+					found++
+					if !internal[value] && !IsTypeName(value) {
+						t.Errorf("%s = %q is not a type name", name.Name, value)
+					}
+				}
+			}
+		}
+	}
+	if found == 0 {
+		t.Errorf("no object types found")
+	}
+}
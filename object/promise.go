@@ -0,0 +1,95 @@
+package object
+
+// The state of a promise
+type PromiseState int
+
+const (
+	PromisePending PromiseState = iota
+	PromiseFulfilled
+	PromiseRejected
+)
+
+func (s PromiseState) String() string {
+	switch s {
+	case PromiseFulfilled:
+		return "fulfilled"
+	case PromiseRejected:
+		return "rejected"
+	default:
+		return "pending"
+	}
+}
+
+// A promise, the result of a computation that finishes later such as the call of an async function.
+// A promise settles once: it is fulfilled with a value or rejected with an error.
+// Promises are only used by the running task of their runtime, see Task
+type Promise struct {
+	state PromiseState
+	value Object // The value, or the *Error the promise was rejected with
+
+	handled   bool     // Whether a reaction or an await handles the rejection of the promise
+	reactions []func() // Called once the promise settles
+}
+
+func (p *Promise) Type() ObjectType {
+	return PromiseObj
+}
+func (p *Promise) Inspect() string {
+	return "promise " + p.state.String()
+}
+
+// State returns whether the promise is pending, fulfilled or rejected
+func (p *Promise) State() PromiseState {
+	return p.state
+}
+
+// Settled returns whether the promise is fulfilled or rejected
+func (p *Promise) Settled() bool {
+	return p.state != PromisePending
+}
+
+// Result returns the value of a fulfilled promise or the error of a rejected one, nil while it is pending
+func (p *Promise) Result() Object {
+	return p.value
+}
+
+// Settle fulfills the promise with value, or rejects it with the error value, and calls its reactions.
+// It returns false when the promise is already settled
+func (p *Promise) Settle(value Object, rejected bool) bool {
+	if p.Settled() {
+		return false
+	}
+	p.value = value
+	p.state = PromiseFulfilled
+	if rejected {
+		p.state = PromiseRejected
+	}
+
+	reactions := p.reactions
+	p.reactions = nil
+	for _, react := range reactions {
+		react()
+	}
+	return true
+}
+
+// OnSettled calls react once the promise settles, right away when it is already settled.
+// Like Fire of a Timer, react must not run code of the program. The promise is then handled
+func (p *Promise) OnSettled(react func()) {
+	p.handled = true
+	if p.Settled() {
+		react()
+		return
+	}
+	p.reactions = append(p.reactions, react)
+}
+
+// Handle marks the rejection of the promise as handled, by an await
+func (p *Promise) Handle() {
+	p.handled = true
+}
+
+// Handled returns whether the rejection of the promise is handled
+func (p *Promise) Handled() bool {
+	return p.handled
+}
@@ -0,0 +1,74 @@
+package object
+
+import (
+	"Monkey/ast"
+	"Monkey/options"
+	"Monkey/runner"
+	"Monkey/token"
+	"bufio"
+	"io"
+)
+
+// Runtime is the state shared by every environment of a single interpreter
+type Runtime struct {
+	Options *options.Options
+	Runner  *runner.Runner
+
+	// Prototype functions per object type, these can be modified by the scripts
+	Prototypes map[ObjectType]*Hash
+
+	// IO, the errors no caller gets are logged to Stderr
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  *bufio.Reader
+
+	// Runs the programs instead of the tree walking evaluator when set
+	Engine Engine
+
+	// The functions being called, outermost first
+	CallStack []StackFrame
+
+	// Runs the closures called from outside of the vm, nil with the tree walking evaluator
+	Machine Machine
+
+	// The time of the timers and of __time, the system clock when nil
+	Clock Clock
+
+	// The rejected promises and the failed tasks nobody handled yet, reported once the event loop drains
+	Unhandled []Rejection
+
+	// Runs the tasks one at a time and fires the timers, created when the program first waits,
+	// spawns a task or sets a timer
+	scheduler *scheduler
+
+	// The generators whose body started and did not end, see CloseGenerators
+	generators map[*coroutine]bool
+}
+
+// PushFrame records a call of function at site, returning the depth to pop back to
+func (r *Runtime) PushFrame(function string, site token.TokenData) int {
+	depth := len(r.CallStack)
+	r.CallStack = append(r.CallStack, StackFrame{Function: function, TokenData: site})
+	return depth
+}
+
+// PopFrames returns from the calls above depth,
+// an error result without a stack gets the stack leading to it
+func (r *Runtime) PopFrames(depth int, result Object) {
+	if err, ok := result.(*Error); ok && err.Stack == nil && len(r.CallStack) > 0 {
+		err.Stack = r.Traceback()
+	}
+	r.CallStack = r.CallStack[:depth]
+}
+
+// Traceback returns a copy of the current call stack
+func (r *Runtime) Traceback() []StackFrame {
+	stack := make([]StackFrame, len(r.CallStack))
+	copy(stack, r.CallStack)
+	return stack
+}
+
+// Engine executes programs, such as the bytecode vm
+type Engine interface {
+	Run(program *ast.Program, env *Environment) Object
+}
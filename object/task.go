@@ -0,0 +1,192 @@
+package object
+
+import (
+	"runtime"
+	"time"
+)
+
+// A task, a function spawned to run alongside the program.
+// The tasks of a runtime take turns: only one of them runs at a time, and it runs until it waits,
+// on a channel, a timer, a promise or another task, or returns. Tasks can share their variables without races
+type Task struct {
+	Name string
+
+	done    bool
+	result  Object
+	handled bool // Whether a wait or a join handles the error of the task
+}
+
+func (t *Task) Type() ObjectType {
+	return TaskObj
+}
+func (t *Task) Inspect() string {
+	return "task " + FunctionName(t.Name)
+}
+
+// Done returns whether the function of the task returned
+func (t *Task) Done() bool {
+	return t.done
+}
+
+// Result returns the result of the function of the task, nil while it runs
+func (t *Task) Result() Object {
+	return t.result
+}
+
+// Handle marks the error of the task as handled, by a wait or a join
+func (t *Task) Handle() {
+	t.handled = true
+}
+
+// Handled returns whether the error of the task is handled
+func (t *Task) Handled() bool {
+	return t.handled
+}
+
+// Rejection is a rejected promise or a failed task, its error is reported unless it is handled
+type Rejection interface {
+	Handled() bool
+	Result() Object
+}
+
+// scheduler runs the tasks of a runtime one at a time in a fixed order, the program is a task too.
+// The running task hands its turn to the next runnable task when it waits or returns
+type scheduler struct {
+	current  turn
+	runnable []turn // The tasks to run next, in order
+	waiting  []turn // The tasks waiting to be notified, in the order they started waiting
+
+	timers timers // The pending timers, see Timer
+	seq    uint64 // The number of timers set
+
+	stepper  turn      // The task running Drain or RunUntil, the other tasks hand it the turn once they all wait
+	deadline time.Time // The time RunUntil runs the timers until
+	stepping bool      // Whether the stepper runs RunUntil and returns at the deadline
+
+	unwinder turn // The task running Unwind, nil when the tasks are not unwound
+}
+
+// turn wakes a task when it is its turn to run
+type turn chan struct{}
+
+func (r *Runtime) getScheduler() *scheduler {
+	if r.scheduler == nil {
+		r.scheduler = &scheduler{current: make(turn, 1)}
+	}
+	return r.scheduler
+}
+
+// handOff runs the next runnable task
+func (s *scheduler) handOff() {
+	next := s.runnable[0]
+	s.runnable = s.runnable[1:]
+	s.current = next
+	next <- struct{}{}
+}
+
+// Spawn starts a task running run, it runs after the tasks already runnable once the running task waits.
+// The task calls the closures with machine, nil with the tree walking evaluator
+func (r *Runtime) Spawn(task *Task, machine Machine, run func() Object) {
+	s := r.getScheduler()
+	wake := make(turn, 1)
+	s.runnable = append(s.runnable, wake)
+	go func() {
+		<-wake
+		returned := false
+		defer func() {
+			// An unwound task hands the turn back once its goroutine unwound
+			if !returned {
+				s.unwinder <- struct{}{}
+			}
+		}()
+		if s.unwinder != nil {
+			return
+		}
+
+		r.CallStack, r.Machine = nil, machine
+		task.result = run()
+		task.done = true
+		returned = true
+
+		r.Notify()
+		if len(s.runnable) > 0 {
+			s.handOff()
+		}
+	}()
+}
+
+// Unwind ends the tasks left waiting, their goroutines exit without running the rest of their functions.
+// The running task goes on alone, the pending timers are kept
+func (r *Runtime) Unwind() {
+	s := r.scheduler
+	if s == nil {
+		return
+	}
+	self := s.current
+	s.unwinder = self
+	for _, t := range append(s.runnable, s.waiting...) {
+		s.current = t
+		t <- struct{}{}
+		<-self
+	}
+	s.runnable, s.waiting = nil, nil
+	s.current = self
+	s.unwinder = nil
+}
+
+// Wait lets the other tasks run until ready returns true, ready is checked again whenever they notify.
+// This is the event loop: the timers fire while the tasks wait, and the clock sleeps until the next timer
+// when no task can run. It returns false when no other task and no timer is left to make ready true,
+// the task running RunUntil also returns once the timers left are after its deadline.
+// The other tasks left waiting then hand the turn to the task running Drain or RunUntil instead
+func (r *Runtime) Wait(ready func() bool) bool {
+	s := r.getScheduler()
+	for !ready() {
+		if r.fireTimers() {
+			continue
+		}
+		if len(s.runnable) == 0 {
+			if len(s.timers) > 0 && !(s.stepping && s.timers[0].At.After(s.deadline)) {
+				r.sleep(s.timers[0].At)
+				continue
+			}
+			// No timer is left, or only those after the deadline of RunUntil: the task stepping returns
+			if s.stepper == nil || s.current == s.stepper {
+				return false
+			}
+			s.wake(s.stepper)
+		}
+
+		self := s.current
+		s.waiting = append(s.waiting, self)
+		stack, machine := r.CallStack, r.Machine
+		s.handOff()
+		<-self
+		if s.unwinder != nil {
+			runtime.Goexit()
+		}
+		r.CallStack, r.Machine = stack, machine
+	}
+	return true
+}
+
+// wake makes a waiting task runnable
+func (s *scheduler) wake(t turn) {
+	for i, waiting := range s.waiting {
+		if waiting == t {
+			s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+			s.runnable = append(s.runnable, t)
+			return
+		}
+	}
+}
+
+// Notify makes the waiting tasks runnable after a change they may be waiting on
+func (r *Runtime) Notify() {
+	s := r.scheduler
+	if s == nil {
+		return
+	}
+	s.runnable = append(s.runnable, s.waiting...)
+	s.waiting = nil
+}
@@ -0,0 +1,87 @@
+package object
+
+import (
+	"Monkey/token"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Names used in tracebacks for code outside of named functions
+const (
+	AnonymousFunction = "<anonymous>"
+	TopLevel          = "<module>"
+)
+
+// The number of times a line repeated by recursion is shown in a traceback
+const tracebackRepeats = 3
+
+// StackFrame is a call of a function, at the call site
+type StackFrame struct {
+	Function string
+	token.TokenData
+}
+
+// FunctionName returns the name shown in tracebacks for a function named name
+func FunctionName(name string) string {
+	if name == "" {
+		return AnonymousFunction
+	}
+	return name
+}
+
+// Traceback formats the stack of the error, the most recent call last,
+// every line is followed by its source when the file can be read
+func (e *Error) Traceback() string {
+	var out strings.Builder
+	sources := map[string][]string{}
+
+	out.WriteString("Traceback (most recent call last):\n")
+	function := TopLevel
+	repeated := 0
+	var previous StackFrame
+	for _, frame := range e.Stack {
+		// Deep recursion repeats the same lines, only the first few are shown
+		line := StackFrame{Function: function, TokenData: frame.TokenData}
+		if line == previous {
+			repeated++
+		} else {
+			writeRepeated(&out, repeated)
+			repeated = 0
+		}
+		if repeated < tracebackRepeats {
+			writeTracebackLine(&out, sources, &frame.TokenData, function)
+		}
+		previous = line
+		function = frame.Function
+	}
+	writeRepeated(&out, repeated)
+	writeTracebackLine(&out, sources, e.TokenData, function)
+
+	return out.String()
+}
+
+func writeRepeated(out *strings.Builder, repeated int) {
+	if repeated >= tracebackRepeats {
+		fmt.Fprintf(out, "  [Previous line repeated %d more times]\n", repeated-tracebackRepeats+1)
+	}
+}
+
+func writeTracebackLine(out *strings.Builder, sources map[string][]string, data *token.TokenData, function string) {
+	if data == nil {
+		fmt.Fprintf(out, "  File unknown, in %s\n", function)
+		return
+	}
+	fmt.Fprintf(out, "  File %q, line %d, in %s\n", data.Filename, data.RowNumber, function)
+
+	lines, ok := sources[data.Filename]
+	if !ok {
+		if content, err := ioutil.ReadFile(data.Filename); err == nil {
+			lines = strings.Split(strings.ReplaceAll(string(content), "\r", ""), "\n")
+		}
+		sources[data.Filename] = lines
+	}
+	if row := int(data.RowNumber); row >= 1 && row <= len(lines) {
+		fmt.Fprintf(out, "    %s\n", strings.TrimSpace(lines[row-1]))
+	}
+}
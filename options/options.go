@@ -1,7 +1,26 @@
 package options
 
-var (
-	NicerToString = true
-	FatalErrors   = false
-	Debug         = true
-)
+// NicerToString strips the debug parenthesis from ast.Node.ToString
+// It is a display preference shared by the whole process
+var NicerToString = true
+
+// Options are the per interpreter runtime options
+type Options struct {
+	// Treat every error value as fatal, stopping the execution
+	FatalErrors bool
+
+	// Print warnings such as circular dependencies
+	Debug bool
+
+	// Calling a function with too many or too few arguments is an error,
+	// instead of dropping the extra arguments and passing null for the missing ones
+	StrictArity bool
+}
+
+// Default returns the default interpreter options
+func Default() *Options {
+	return &Options{
+		FatalErrors: false,
+		Debug:       true,
+	}
+}
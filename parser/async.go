@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+)
+
+// ParseAsyncFunction parses async fn(parameters) { body } and the async arrow functions,
+// a call of an async function runs it in a task and returns the promise of its result
+func (p *Parser) ParseAsyncFunction() ast.Expression {
+	start := p.currentToken
+	switch p.peekToken.Type {
+	case token.FUNCTION, token.IDENT, token.LPAREN:
+	default:
+		p.GenerateErrorForToken(CodeInvalidAsync, "expected a function after async", &start)
+		return nil
+	}
+	p.NextToken()
+
+	errors := p.numErrors
+	exp := p.prefixParseFns[p.currentToken.Type]()
+	if exp == nil || p.numErrors != errors {
+		return nil
+	}
+	fn, ok := exp.(*ast.FunctionLiteral)
+	if !ok {
+		p.GenerateErrorForToken(CodeInvalidAsync, "expected a function after async", &start)
+		return nil
+	}
+	if fn.Generator {
+		p.GenerateErrorForToken(CodeInvalidAsync, "a generator function cannot be async", &start)
+		return nil
+	}
+	fn.Async = true
+	return fn
+}
+
+// ParseAwaitExpression parses await value, the value binds like the operand of a prefix operator
+func (p *Parser) ParseAwaitExpression() ast.Expression {
+	exp := &ast.AwaitExpression{Token: p.currentToken}
+	p.NextToken()
+	exp.Value = p.ParseExpression(PREFIX)
+	if exp.Value == nil {
+		return nil
+	}
+	return exp
+}
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+)
+
+// ParseConditionalExpression parses condition ? consequence : alternative, it groups from the right
+// so that a ? b : c ? d : e is a ? b : (c ? d : e)
+func (p *Parser) ParseConditionalExpression(condition ast.Expression) ast.Expression {
+	exp := &ast.ConditionalExpression{Token: p.currentToken, Condition: condition}
+
+	p.NextToken()
+	exp.Consequence = p.ParseExpression(LOWEST)
+	if exp.Consequence == nil || !p.ExpectPeek(token.COLON) {
+		return nil
+	}
+
+	p.NextToken()
+	exp.Alternative = p.ParseExpression(TERNARY - 1)
+	if exp.Alternative == nil {
+		return nil
+	}
+	return exp
+}
+
+// ParseOptionalLink parses the ?. of x?.y, x?.[k] and f?.()
+func (p *Parser) ParseOptionalLink(left ast.Expression) ast.Expression {
+	switch {
+	case p.PeekTokenIs(token.LBRACKET):
+		p.NextToken()
+		exp, ok := p.ParseIndexExpression(left).(*ast.IndexExpression)
+		if !ok {
+			return nil
+		}
+		exp.Optional = true
+		return exp
+	case p.PeekTokenIs(token.LPAREN):
+		p.NextToken()
+		exp := p.ParseCallExpression(left).(*ast.CallExpression)
+		exp.Optional = true
+		return exp
+	}
+	return p.ParseInfixExpression(left)
+}
+
+// isChainLink returns whether an infix token continues the chain of members, indexes and calls on its left
+func isChainLink(typ token.TokenType) bool {
+	switch typ {
+	case token.DOT, token.OPTIONAL_DOT, token.LBRACKET, token.LPAREN:
+		return true
+	}
+	return false
+}
+
+// endChain wraps a chain with a ?. in an optional chain, it is where a null before the ?. stops
+func endChain(exp ast.Expression) ast.Expression {
+	if link, ok := ast.OptionalLink(exp); ok {
+		return &ast.OptionalChain{Token: link, Expression: exp}
+	}
+	return exp
+}
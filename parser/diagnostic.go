@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"Monkey/lexer"
+	"Monkey/token"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity of a diagnostic
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic codes, they stay stable so that tools can match on them
+const (
+	CodeUnexpectedToken  = "P001" // a different token was expected
+	CodeNoExpression     = "P002" // the token cannot start an expression
+	CodeIllegalCharacter = "P003" // the lexer could not read a character
+	CodeMissingNewline   = "P004" // two statements on one line
+	CodeInvalidNumber    = "P005" // a number literal could not be parsed
+	CodeInvalidJump      = "P006" // break or continue outside of the loop it names
+	CodeInvalidTemplate  = "P007" // an interpolation in a string is empty or not closed
+	CodeInvalidEscape    = "P008" // an escape sequence in a string is not valid
+	CodeOpenComment      = "P009" // a block comment is not closed
+	CodeInvalidPattern   = "P010" // a destructuring pattern is not valid
+	CodeInvalidArgument  = "P011" // a default, rest, spread or named argument is not in a valid place
+	CodeInvalidYield     = "P012" // a yield outside of a function or in a macro
+	CodeInvalidSelect    = "P013" // a select arm that is not a receive, a send or _
+	CodeInvalidAsync     = "P014" // async before something else than a function, or before a generator function
+)
+
+// ParseError is a diagnostic reported by the parser, from the start to the end of a token
+type ParseError struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+
+	Filename        string `json:"file"`
+	RowNumber       int64  `json:"line"`
+	ColumnNumber    int64  `json:"column"`
+	EndRowNumber    int64  `json:"endLine"`
+	EndColumnNumber int64  `json:"endColumn"`
+
+	// A suggested fix, empty when there is none
+	Fix string `json:"fix,omitempty"`
+}
+
+func (pe *ParseError) Error() string {
+	return fmt.Sprintf("%s, at %d:%d, in file %s",
+		pe.Message, pe.RowNumber, pe.ColumnNumber, pe.Filename)
+}
+
+// ParseErrors is the list of diagnostics of a source, as a go error
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	var messages []string
+	for _, err := range pe {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// newParseError creates an error diagnostic spanning a token
+func newParseError(code string, message string, tok *token.Token) *ParseError {
+	// Words and numbers are positioned at their first character, other tokens at their last one
+	width := int64(1)
+	if r, _ := utf8.DecodeRuneInString(tok.Literal); lexer.IsLetter(r) || lexer.IsDigit(r) {
+		width = int64(utf8.RuneCountInString(tok.Literal))
+	}
+
+	return &ParseError{
+		Severity:        SeverityError,
+		Code:            code,
+		Message:         message,
+		Filename:        tok.Filename,
+		RowNumber:       tok.RowNumber,
+		ColumnNumber:    tok.ColumnNumber,
+		EndRowNumber:    tok.RowNumber,
+		EndColumnNumber: tok.ColumnNumber + width,
+	}
+}
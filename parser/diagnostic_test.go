@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"Monkey/lexer"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let a = 1
+let = 5
+let f = fn(x) {
+    let y = )
+    x + 1
+}
+let b = 2 @ 3
+b`
+
+	p := New(lexer.New(input, "TestErrorRecovery"))
+	program := p.ParseProgram()
+
+	expected := []struct {
+		code   string
+		row    int64
+		column int64
+	}{
+		{CodeUnexpectedToken, 2, 5},
+		{CodeNoExpression, 4, 13},
+		{CodeIllegalCharacter, 7, 11},
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%v)", len(expected), len(errors), ParseErrors(errors))
+	}
+	for i, want := range expected {
+		err := errors[i]
+		if err.Code != want.code || err.RowNumber != want.row || err.ColumnNumber != want.column {
+			t.Errorf("error %d wrong. want=%s at %d:%d, got=%s at %d:%d (%s)",
+				i, want.code, want.row, want.column, err.Code, err.RowNumber, err.ColumnNumber, err.Message)
+		}
+		if err.Severity != SeverityError {
+			t.Errorf("error %d has wrong severity. got=%s", i, err.Severity)
+		}
+	}
+
+	// the statements around the broken ones are kept
+	var statements []string
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt.TokenLiteral())
+	}
+	if strings.Join(statements, " ") != "let let b" {
+		t.Errorf("wrong statements kept. got=%v", statements)
+	}
+}
+
+// The calls enclosing a broken argument fail on the same token, it is reported once
+func TestDuplicateErrors(t *testing.T) {
+	p := New(lexer.New("writeLine(f(a2))\nlet x = [[1 2]]", "TestDuplicateErrors"))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 2 {
+		t.Fatalf("wrong number of errors. want=2, got=%d (%v)", len(errors), ParseErrors(errors))
+	}
+	if errors[0].RowNumber != 1 || errors[0].ColumnNumber != 14 || errors[1].RowNumber != 2 || errors[1].ColumnNumber != 13 {
+		t.Errorf("errors at the wrong positions. got=%v", ParseErrors(errors))
+	}
+}
+
+func TestStatementSeparators(t *testing.T) {
+	p := New(lexer.New("let a = 5; let b = a; b", "TestStatementSeparators"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	if len(p.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics. got=%v", ParseErrors(p.Diagnostics()))
+	}
+}
+
+func TestMissingNewlineWarning(t *testing.T) {
+	p := New(lexer.New("let key = 'foo' key", "TestMissingNewlineWarning"))
+	program := p.ParseProgram()
+
+	if p.HasError() {
+		t.Fatalf("warnings should not be errors. got=%v", ParseErrors(p.Errors()))
+	}
+	if len(program.Statements) != 2 {
+		t.Errorf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityWarning || diagnostics[0].Code != CodeMissingNewline {
+		t.Fatalf("expected a missing newline warning. got=%+v", diagnostics)
+	}
+	if diagnostics[0].Fix == "" {
+		t.Errorf("expected a suggested fix")
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	p := New(lexer.New("let x = [1, 2", "test.mky"))
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	if err := RenderJSON(&out, p.Errors()); err != nil {
+		t.Fatalf("RenderJSON failed. got=%v", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json. got=%v (%s)", err, out.String())
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(decoded))
+	}
+
+	expected := map[string]interface{}{
+		"severity": "error",
+		"code":     CodeUnexpectedToken,
+		"file":     "test.mky",
+		"line":     float64(1),
+		"fix":      `insert "]"`,
+	}
+	for key, value := range expected {
+		if decoded[0][key] != value {
+			t.Errorf("wrong %s. want=%v, got=%v", key, value, decoded[0][key])
+		}
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	source := "let a = 1\nlet = 2"
+	p := New(lexer.New(source, "repl"))
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	RenderText(&out, p.Errors(), source)
+
+	text := out.String()
+	if !strings.Contains(text, "Parser Error: expected next token to be IDENT") {
+		t.Errorf("missing message. got=%q", text)
+	}
+	if !strings.Contains(text, "| 2   let = 2    <-- over here") {
+		t.Errorf("missing source line. got=%q", text)
+	}
+}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"path/filepath"
@@ -11,38 +13,65 @@ import (
 // error handling printing lines around
 const LinesAround = 4
 
-// PrintParserError prints a ParseError by reading its filename and printing a pretty message
+// RenderText prints the errors with the lines around them,
+// the lines are read from source or from the file of the error when source is empty
 // It also doesnt break for REPL which is an upside
-func PrintParserError(err *ParseError) {
-	fmt.Printf("Parser Error: %s, at %d:%d, in file %s\n",
-		err.Message, err.RowNumber, err.ColumnNumber, err.Filename)
+func RenderText(out io.Writer, errors []*ParseError, source string) {
+	for _, err := range errors {
+		renderTextError(out, err, source)
+	}
+}
+
+func renderTextError(out io.Writer, err *ParseError, source string) {
+	kind := "Error"
+	if err.Severity == SeverityWarning {
+		kind = "Warning"
+	}
+	fmt.Fprintf(out, "Parser %s: %s, at %d:%d, in file %s\n",
+		kind, err.Message, err.RowNumber, err.ColumnNumber, err.Filename)
 	rowNumber := int(err.RowNumber)
 
-	fmt.Printf("[%s]\n", filepath.Base(err.Filename))
-	rows, e := readFileRows(rowNumber, err.Filename)
+	fmt.Fprintf(out, "[%s]\n", filepath.Base(err.Filename))
+	rows, e := readSourceRows(rowNumber, err.Filename, source)
 	if e != nil {
+		fmt.Fprintf(out, "Cannot read file %q\n", err.Filename)
 		return
 	}
 	for index, row := range rows {
 		// i have no idea how it came to this
 		number := index + int(math.Max(0, float64(rowNumber-LinesAround+1))) + 1
-		fmt.Printf("| %-3d %s", number, row)
+		fmt.Fprintf(out, "| %-3d %s", number, row)
 		if number == rowNumber {
-			fmt.Print("    <-- over here")
+			fmt.Fprint(out, "    <-- over here")
 		}
-		fmt.Println()
+		fmt.Fprintln(out)
+	}
+	if err.Fix != "" {
+		fmt.Fprintf(out, "help: %s\n", err.Fix)
 	}
-	fmt.Println()
+	fmt.Fprintln(out)
 }
 
-func readFileRows(rows int, filename string) ([]string, error) {
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		fmt.Printf("Cannot read file %q\n", filename)
-		return []string{}, err
+// RenderJSON writes the errors as a JSON array, for tools
+func RenderJSON(out io.Writer, errors []*ParseError) error {
+	if errors == nil {
+		errors = []*ParseError{}
 	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(errors)
+}
 
-	cont := strings.ReplaceAll(string(content), "\r", "")
+func readSourceRows(rows int, filename string, source string) ([]string, error) {
+	if source == "" {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return []string{}, err
+		}
+		source = string(content)
+	}
+
+	cont := strings.ReplaceAll(source, "\r", "")
 	lines := strings.Split(cont, "\n")
 
 	return getRowsAround(lines, rows), nil
@@ -53,5 +82,8 @@ func getRowsAround(lines []string, rows int) []string {
 	top := math.Max(0, float64(rows-LinesAround+1))
 	bottom := math.Min(float64(len(lines)), float64(rows+LinesAround))
 
+	if int(top) > int(bottom) {
+		return []string{}
+	}
 	return lines[int(top):int(bottom)]
 }
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+)
+
+// ParseArrowFunction parses x => body and (parameters) => body into a function literal,
+// a body in braces is a block and any other body is an expression
+func (p *Parser) ParseArrowFunction() ast.Expression {
+	// The function is placed at its first token, and reads like fn(parameters) { body }
+	tok := p.currentToken
+	tok.Type, tok.Literal = token.FUNCTION, "fn"
+	fn := &ast.FunctionLiteral{Token: tok}
+
+	if p.CurrentTokenIs(token.IDENT) {
+		fn.Parameters = []*ast.Identifier{{Token: p.currentToken, Value: p.currentToken.Literal}}
+	} else if !p.ParseFunctionParameters(fn) {
+		return nil
+	}
+
+	if !p.ExpectPeek(token.FAT_ARROW) {
+		return nil
+	}
+	p.RemoveNewLines()
+	p.NextToken()
+	if p.CurrentTokenIs(token.LBRACE) {
+		fn.Body, fn.Generator = p.ParseFunctionBody()
+		return fn
+	}
+
+	start := p.currentToken
+	var body ast.Expression
+	fn.Generator = p.parseFunction(func() {
+		body = p.ParseExpression(LOWEST)
+	})
+	if body == nil {
+		return nil
+	}
+	fn.Body = &ast.BlockStatement{
+		Token:      start,
+		Statements: []ast.Statement{&ast.ExpressionStatement{Token: start, Expression: body}},
+	}
+	return fn
+}
+
+// isArrowAhead returns whether the ( at the current token starts the parameters of an arrow function,
+// the tokens up to the matching ) are read ahead to find the => after it
+func (p *Parser) isArrowAhead() bool {
+	l := p.lexer.Fork()
+	depth := 1
+	for tok := p.peekToken; tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+			if depth == 0 {
+				return tok.Type == token.RPAREN && l.NextToken().Type == token.FAT_ARROW
+			}
+		}
+	}
+	return false
+}
+
+// ParsePipeExpression parses x |> f(y) into the call f(x, y), and x |> f into f(x)
+func (p *Parser) ParsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.currentToken
+
+	p.NextToken()
+	right := p.ParseExpression(PREFIX)
+	if right == nil {
+		return nil
+	}
+
+	call, ok := right.(*ast.CallExpression)
+	if chain, isChain := right.(*ast.OptionalChain); isChain {
+		call, ok = chain.Expression.(*ast.CallExpression)
+	}
+	if ok {
+		call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+		return right
+	}
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+	"fmt"
+)
+
+// ParseMatchExpression parses match value { pattern if guard => body, ... },
+// the arms are separated by commas or line breaks and their body is a block or an expression
+func (p *Parser) ParseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.currentToken}
+
+	p.NextToken()
+	exp.Value = p.ParseExpression(LOWEST)
+	if exp.Value == nil {
+		return nil
+	}
+
+	p.RemoveNewLines()
+	if !p.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for p.RemoveNewLines(); !p.PeekTokenIs(token.RBRACE); p.RemoveNewLines() {
+		p.NextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if p.PeekTokenIs(token.COMMA) {
+			p.NextToken()
+		} else if !p.IsPeekEndOfLine() && !p.PeekTokenIs(token.RBRACE) {
+			p.PeekError(token.COMMA)
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.RBRACE) {
+		return nil
+	}
+	return exp
+}
+
+// parseMatchArm parses pattern if guard => body. The pattern is a literal, an identifier or a destructuring pattern.
+// An identifier binds the value, but one starting with an upper case letter is a type pattern when it is bound
+// to a type name at runtime, like String of the std lib: match x { String => "text", [X, Y] => X + Y }
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{}
+
+	matching := p.matching
+	p.matching = true
+	arm.Pattern = p.ParsePattern()
+	p.matching = matching
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.PeekTokenIs(token.IF) {
+		p.NextToken()
+		guard := p.guard
+		p.guard = p.depth + 1
+		p.NextToken()
+		arm.Guard = p.ParseExpression(LOWEST)
+		p.guard = guard
+		if arm.Guard == nil {
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.FAT_ARROW) {
+		return nil
+	}
+	arm.Token = p.currentToken
+
+	arm.Body = p.parseArmBody()
+	if arm.Body == nil {
+		return nil
+	}
+	return arm
+}
+
+// parseArmBody parses the body after the => of an arm, a block or an expression or jump without braces
+func (p *Parser) parseArmBody() *ast.BlockStatement {
+	p.RemoveNewLines()
+	p.NextToken()
+	if p.CurrentTokenIs(token.LBRACE) {
+		return p.ParseBlockStatement()
+	}
+
+	// A body without braces is an expression or a jump
+	start := p.currentToken
+	var body ast.Statement
+	switch {
+	case p.CurrentTokenIs(token.RETURN), p.CurrentTokenIs(token.THROW), p.CurrentTokenIs(token.CONTINUE):
+		body = p.ParseStatement()
+	case p.CurrentTokenIs(token.BREAK) && len(p.loops) > 0 &&
+		(p.PeekTokenIs(token.IDENT) || p.PeekTokenIs(token.COMMA) || p.IsPeekEndOfStatement()):
+		body = p.ParseBreakStatement()
+	default:
+		if exp := p.ParseExpression(LOWEST); exp != nil {
+			body = &ast.ExpressionStatement{Token: start, Expression: exp}
+		}
+	}
+	if body == nil {
+		return nil
+	}
+	return &ast.BlockStatement{Token: start, Statements: []ast.Statement{body}}
+}
+
+// parseLiteralPattern parses the literal of a match pattern, a number, a string, a boolean or null
+func (p *Parser) parseLiteralPattern() ast.Expression {
+	switch p.currentToken.Type {
+	case token.INT, token.FLOAT, token.BIGINT, token.DECIMAL, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		return p.prefixParseFns[p.currentToken.Type]()
+	case token.MINUS:
+		if p.PeekTokenIs(token.INT) || p.PeekTokenIs(token.FLOAT) || p.PeekTokenIs(token.BIGINT) || p.PeekTokenIs(token.DECIMAL) {
+			return p.ParsePrefixExpression()
+		}
+	}
+
+	message := fmt.Sprintf("expected a literal, an identifier or a pattern, got %s instead", p.currentToken.Type)
+	p.GenerateErrorForToken(CodeInvalidPattern, message, &p.currentToken)
+	return nil
+}
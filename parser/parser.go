@@ -6,7 +6,9 @@ import (
 	"Monkey/token"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // TODO: Implement Namespaces
@@ -22,12 +24,20 @@ const (
 	_ int = iota
 	LOWEST
 	ASSIGN  // =
+	TERNARY // c ? x : y
+	NULLISH // ??
 	GATE    // and, or, xor
 	EQUAL   // == or !=
 	COMPARE // > or < or <= or >=
+	PIPE    // |>
+	BIT_OR  // |
+	BIT_XOR // ^
+	BIT_AND // &
+	SHIFT   // << or >>
 	SUM     // + or -
 	PRODUCT // * or / or %
-	PREFIX  // !X or -X
+	PREFIX  // !X or -X or ~X
+	POWER   // **, -2 ** 2 is -(2 ** 2)
 	CALL    // foobar()
 	INDEX   // [x]
 	DOT     // x.something
@@ -53,6 +63,34 @@ var precedences = map[token.TokenType]int{
 	token.LBRACKET: INDEX,
 	token.DOT:      DOT,
 	token.ASSIGN:   ASSIGN,
+
+	token.PIPE:         PIPE,
+	token.QUESTION:     TERNARY,
+	token.NULLISH:      NULLISH,
+	token.OPTIONAL_DOT: DOT,
+
+	token.BIT_OR:      BIT_OR,
+	token.BIT_XOR:     BIT_XOR,
+	token.BIT_AND:     BIT_AND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.POWER:       POWER,
+
+	token.INCREMENT: DOT,
+	token.DECREMENT: DOT,
+
+	token.PLUS_ASSIGN:        ASSIGN,
+	token.MINUS_ASSIGN:       ASSIGN,
+	token.ASTERISK_ASSIGN:    ASSIGN,
+	token.SLASH_ASSIGN:       ASSIGN,
+	token.PERCENT_ASSIGN:     ASSIGN,
+	token.NULLISH_ASSIGN:     ASSIGN,
+	token.POWER_ASSIGN:       ASSIGN,
+	token.BIT_AND_ASSIGN:     ASSIGN,
+	token.BIT_OR_ASSIGN:      ASSIGN,
+	token.BIT_XOR_ASSIGN:     ASSIGN,
+	token.SHIFT_LEFT_ASSIGN:  ASSIGN,
+	token.SHIFT_RIGHT_ASSIGN: ASSIGN,
 }
 
 // Peek the precedence of the next token in the parser
@@ -71,15 +109,6 @@ func (p *Parser) CurrentPrecedence() int {
 	return LOWEST
 }
 
-// An error struct
-type ParseError struct {
-	Message string
-
-	Filename     string
-	RowNumber    int64
-	ColumnNumber int64
-}
-
 // The Parser struct
 type Parser struct {
 	lexer *lexer.Lexer
@@ -91,15 +120,31 @@ type Parser struct {
 	prefixParseFns map[token.TokenType]PrefixParseFn
 	infixParseFns  map[token.TokenType]InfixParseFn
 
-	// This is an array of pointers
-	errors []*ParseError
+	// This is an array of pointers, holding the errors and warnings
+	errors    []*ParseError
+	numErrors int
+
+	// The brackets open at the current token, used to recover from errors
+	brackets []token.TokenType
+	depth    int
+
+	// The labels of the loops around the current token in its function, empty for unlabeled loops
+	loops []string
+	// Set when the function around the current token yields, nil outside of functions
+	yields *bool
+
+	// Whether the patterns parsed are the patterns of a match arm, which can have literals
+	matching bool
+	// The bracket depth of the guard of the match arm being parsed plus one, zero outside of guards.
+	// A => at that depth ends the guard instead of starting an arrow function
+	guard int
 
 	done bool
 }
 
 // Check if parser have any error
 func (p *Parser) HasError() bool {
-	return len(p.errors) != 0
+	return p.numErrors != 0
 }
 
 // Construct a new Parser
@@ -116,9 +161,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]PrefixParseFn)
 	p.RegisterPrefix(token.IDENT, p.ParseIdentifier)
 	p.RegisterPrefix(token.INT, p.ParseIntegerLiteral)
+	p.RegisterPrefix(token.FLOAT, p.ParseFloatLiteral)
+	p.RegisterPrefix(token.BIGINT, p.ParseBigIntLiteral)
+	p.RegisterPrefix(token.DECIMAL, p.ParseDecimalLiteral)
 	p.RegisterPrefix(token.BANG, p.ParsePrefixExpression)
 	p.RegisterPrefix(token.MINUS, p.ParsePrefixExpression)
 	p.RegisterPrefix(token.PLUS, p.ParsePrefixExpression)
+	p.RegisterPrefix(token.BIT_NOT, p.ParsePrefixExpression)
 	p.RegisterPrefix(token.AND, p.ParsePrefixExpression)
 	p.RegisterPrefix(token.OR, p.ParsePrefixExpression)
 	p.RegisterPrefix(token.XOR, p.ParsePrefixExpression)
@@ -133,16 +182,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.RegisterPrefix(token.LPAREN, p.parseGroupedExpression)
 
 	p.RegisterPrefix(token.IF, p.ParseIfExpression)
+	p.RegisterPrefix(token.TRY, p.ParseTryExpression)
+	p.RegisterPrefix(token.MATCH, p.ParseMatchExpression)
 	p.RegisterPrefix(token.FUNCTION, p.ParseFunctionLiteral)
 	p.RegisterPrefix(token.HASH, p.ParseHashFunctionLiteral)
 	p.RegisterPrefix(token.MODULE, p.ParseModuleExpression)
 
 	p.RegisterPrefix(token.STRING, p.ParseStringLiteral)
+	p.RegisterPrefix(token.TEMPLATE, p.ParseTemplateLiteral)
 
 	p.RegisterPrefix(token.LBRACKET, p.ParseArrayLiteral)
 
 	p.RegisterPrefix(token.LBRACE, p.ParseHashLiteral)
 	p.RegisterPrefix(token.MACRO, p.ParseMacroLiteral)
+	p.RegisterPrefix(token.YIELD, p.ParseYieldExpression)
+	p.RegisterPrefix(token.SELECT, p.ParseSelectExpression)
+	p.RegisterPrefix(token.ASYNC, p.ParseAsyncFunction)
+	p.RegisterPrefix(token.AWAIT, p.ParseAwaitExpression)
 
 	// Setup Infix Functions
 	p.infixParseFns = make(map[token.TokenType]InfixParseFn)
@@ -159,6 +215,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.RegisterInfix(token.GE, p.ParseInfixExpression)
 	p.RegisterInfix(token.LE, p.ParseInfixExpression)
 	p.RegisterInfix(token.PERCENT, p.ParseInfixExpression)
+	p.RegisterInfix(token.POWER, p.ParseInfixExpression)
+	p.RegisterInfix(token.BIT_AND, p.ParseInfixExpression)
+	p.RegisterInfix(token.BIT_OR, p.ParseInfixExpression)
+	p.RegisterInfix(token.BIT_XOR, p.ParseInfixExpression)
+	p.RegisterInfix(token.SHIFT_LEFT, p.ParseInfixExpression)
+	p.RegisterInfix(token.SHIFT_RIGHT, p.ParseInfixExpression)
+
+	for compound := range token.CompoundAssignments {
+		p.RegisterInfix(compound, p.ParseCompoundAssignment)
+	}
+	for update := range token.Updates {
+		p.RegisterPrefix(update, p.ParsePrefixUpdate)
+		p.RegisterInfix(update, p.ParsePostfixUpdate)
+	}
 
 	p.RegisterInfix(token.LPAREN, p.ParseCallExpression)
 
@@ -168,6 +238,11 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.RegisterInfix(token.LBRACKET, p.ParseIndexExpression)
 
+	p.RegisterInfix(token.NULLISH, p.ParseInfixExpression)
+	p.RegisterInfix(token.QUESTION, p.ParseConditionalExpression)
+	p.RegisterInfix(token.OPTIONAL_DOT, p.ParseOptionalLink)
+	p.RegisterInfix(token.PIPE, p.ParsePipeExpression)
+
 	return p
 }
 
@@ -185,6 +260,53 @@ func (p *Parser) RegisterInfix(tokenType token.TokenType, fn InfixParseFn) {
 func (p *Parser) NextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
+
+	// Strings with invalid escapes are still read, their errors are reported with the token after them
+	for _, err := range p.lexer.TakeErrors() {
+		code := CodeInvalidEscape
+		switch err.Kind {
+		case lexer.ErrorComment:
+			code = CodeOpenComment
+		case lexer.ErrorNumber:
+			code = CodeInvalidNumber
+		}
+		tok := token.Token{
+			Type:         token.ILLEGAL,
+			Literal:      err.Literal,
+			RowNumber:    err.RowNumber,
+			ColumnNumber: err.ColumnNumber,
+			Filename:     p.peekToken.Filename,
+		}
+		p.GenerateErrorForToken(code, err.Message, &tok)
+	}
+
+	// Stray closing brackets are ignored
+	switch p.currentToken.Type {
+	case token.LBRACE, token.LPAREN, token.LBRACKET:
+		p.brackets = append(p.brackets, p.currentToken.Type)
+	case token.RBRACE, token.RPAREN, token.RBRACKET:
+		if p.depth > 0 && closingBrackets[p.brackets[p.depth-1]] == p.currentToken.Type {
+			p.brackets = p.brackets[:p.depth-1]
+		}
+	}
+	p.depth = len(p.brackets)
+}
+
+var closingBrackets = map[token.TokenType]token.TokenType{
+	token.LBRACE:   token.RBRACE,
+	token.LPAREN:   token.RPAREN,
+	token.LBRACKET: token.RBRACKET,
+}
+
+// Synchronize skips the rest of a broken statement, stopping at the end of its line
+// or at the bracket closing the block it is in
+func (p *Parser) Synchronize(depth int) {
+	for !p.CurrentTokenIs(token.EOF) && p.depth >= depth {
+		if p.depth == depth && (p.CurrentTokenIs(token.NEWLINE) || p.CurrentTokenIs(token.SEMICOLON)) {
+			return
+		}
+		p.NextToken()
+	}
 }
 
 // Deprecated
@@ -196,6 +318,11 @@ func (p *Parser) IsPeekEndOfLine() bool {
 	return p.PeekTokenIs(token.NEWLINE) || p.PeekTokenIs(token.EOF)
 }
 
+// IsPeekEndOfStatement returns whether the next token ends the current statement
+func (p *Parser) IsPeekEndOfStatement() bool {
+	return p.IsPeekEndOfLine() || p.PeekTokenIs(token.SEMICOLON) || p.PeekTokenIs(token.RBRACE)
+}
+
 func (p *Parser) RemoveNewLines() {
 	for p.PeekTokenIs(token.NEWLINE) {
 		p.NextToken()
@@ -203,6 +330,7 @@ func (p *Parser) RemoveNewLines() {
 }
 
 // Parse the Whole Program and return an ast tree
+// Errors do not stop the parsing, the broken statement is skipped and reported in Errors
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
@@ -214,11 +342,18 @@ func (p *Parser) ParseProgram() *ast.Program {
 			continue
 		}
 		// Parse a statement
+		errors, depth := p.numErrors, p.depth
 		stmt := p.ParseStatement()
+		if p.numErrors != errors {
+			p.Synchronize(depth)
+			p.NextToken()
+			continue
+		}
+		separated := p.SkipSeparator()
 		if !p.IsPeekEndOfLine() {
-			if !p.CurrentTokenIs(token.NEWLINE) && !p.HasError() {
-				// TODO: Warnings
-				p.GenerateErrorForToken("No newline after parsing statement", &p.peekToken)
+			if !p.CurrentTokenIs(token.NEWLINE) && !separated {
+				warning := p.GenerateWarningForToken(CodeMissingNewline, "No newline after parsing statement", &p.peekToken)
+				warning.Fix = "insert a newline before " + p.peekToken.Literal
 			}
 		} else {
 			p.NextToken()
@@ -237,14 +372,6 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	}
 
-	if p.HasError() {
-		for _, err := range p.Errors() {
-			PrintParserError(err)
-			//fmt.Printf("Parser Error: %s, at %d:%d, in file %s\n",
-			//	err.Message, err.RowNumber, err.ColumnNumber, err.Filename)
-		}
-	}
-
 	return program
 }
 
@@ -258,6 +385,25 @@ func (p *Parser) ParseStatement() ast.Statement {
 	case token.RETURN:
 		// Hand it over to parse return
 		return p.ParseReturnStatement()
+	case token.THROW:
+		return p.ParseThrowStatement()
+	case token.WHILE:
+		return p.ParseWhileStatement("")
+	case token.FOR:
+		return p.ParseForStatement("")
+	case token.CONTINUE:
+		return p.ParseContinueStatement()
+	case token.BREAK:
+		// Outside of loops break is the value ending an iteration
+		if len(p.loops) > 0 && (p.PeekTokenIs(token.IDENT) || p.IsPeekEndOfStatement()) {
+			return p.ParseBreakStatement()
+		}
+		return p.ParseExpressionStatement()
+	case token.IDENT:
+		if p.PeekTokenIs(token.COLON) {
+			return p.ParseLabeledStatement()
+		}
+		return p.ParseExpressionStatement()
 	default:
 		// Hand it over to parse expression
 		return p.ParseExpressionStatement()
@@ -268,6 +414,21 @@ func (p *Parser) ParseStatement() ast.Statement {
 func (p *Parser) ParseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{
 		Token: p.currentToken,
+		Doc:   p.currentToken.Doc,
+	}
+
+	// let [a, b] = value and let {a, b} = value destructure the value
+	if p.PeekTokenIs(token.LBRACKET) || p.PeekTokenIs(token.LBRACE) {
+		p.NextToken()
+		if stmt.Pattern = p.ParsePattern(); stmt.Pattern == nil {
+			return nil
+		}
+		if !p.ExpectPeek(token.ASSIGN) {
+			return nil
+		}
+		p.NextToken()
+		stmt.Value = p.ParseExpression(LOWEST)
+		return stmt
 	}
 
 	// If next token is NOT an identifier
@@ -292,6 +453,7 @@ func (p *Parser) ParseLetStatement() *ast.LetStatement {
 	p.NextToken()
 
 	stmt.Value = p.ParseExpression(LOWEST)
+	nameFunction(stmt.Value, stmt.Name.Value)
 
 	//if p.PeekTokenIs(token.SEMICOLON) {
 	//	p.NextToken()
@@ -323,15 +485,39 @@ func (p *Parser) ExpectPeek(t token.TokenType) bool {
 
 // Returns all the errors in the parser
 func (p *Parser) Errors() []*ParseError {
+	var errors []*ParseError
+	for _, err := range p.errors {
+		if err.Severity == SeverityError {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// Diagnostics returns the errors and the warnings in the parser
+func (p *Parser) Diagnostics() []*ParseError {
 	return p.errors
 }
 
+// SkipSeparator advances past a semicolon separating two statements
+func (p *Parser) SkipSeparator() bool {
+	if p.PeekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+		return true
+	}
+	return false
+}
+
 // Add a peek error to the parser
 func (p *Parser) PeekError(t token.TokenType) {
 	message := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
 
-	p.GenerateErrorForToken(message, &p.peekToken)
+	err := p.GenerateErrorForToken(CodeUnexpectedToken, message, &p.peekToken)
+	switch t {
+	case token.RPAREN, token.RBRACE, token.RBRACKET, token.LBRACE, token.LPAREN, token.COMMA, token.COLON:
+		err.Fix = fmt.Sprintf("insert %q", string(t))
+	}
 }
 
 // Parse a return statement
@@ -349,6 +535,179 @@ func (p *Parser) ParseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// Parse a throw statement
+func (p *Parser) ParseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.currentToken}
+
+	p.NextToken()
+
+	stmt.Value = p.ParseExpression(LOWEST)
+	return stmt
+}
+
+// ParseLabeledStatement parses a loop preceded by a label, outer: for x in xs {}
+func (p *Parser) ParseLabeledStatement() ast.Statement {
+	label := p.currentToken
+	p.NextToken()
+	p.RemoveNewLines()
+
+	switch p.peekToken.Type {
+	case token.WHILE:
+		p.NextToken()
+		return p.ParseWhileStatement(label.Literal)
+	case token.FOR:
+		p.NextToken()
+		return p.ParseForStatement(label.Literal)
+	}
+	message := fmt.Sprintf("expected a loop after the label %s, got %s instead", label.Literal, p.peekToken.Type)
+	p.GenerateErrorForToken(CodeUnexpectedToken, message, &p.peekToken)
+	return nil
+}
+
+// ParseLoopBody parses the block of a loop, where break and continue can reach it
+func (p *Parser) ParseLoopBody(label string) *ast.BlockStatement {
+	p.RemoveNewLines()
+	if !p.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loops = append(p.loops, label)
+	body := p.ParseBlockStatement()
+	p.loops = p.loops[:len(p.loops)-1]
+	return body
+}
+
+// Parse a while loop, while condition {}
+func (p *Parser) ParseWhileStatement(label string) ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.currentToken, Label: label}
+
+	p.NextToken()
+	stmt.Condition = p.ParseExpression(LOWEST)
+
+	stmt.Body = p.ParseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse a for loop, either for (init; condition; step) {} or for x in iterable {}
+func (p *Parser) ParseForStatement(label string) ast.Statement {
+	if p.PeekTokenIs(token.IDENT) {
+		return p.ParseForInStatement(label)
+	}
+
+	stmt := &ast.ForStatement{Token: p.currentToken, Label: label}
+	if !p.ExpectPeek(token.LPAREN) {
+		return nil
+	}
+
+	// Every clause is optional
+	if !p.PeekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+		if p.CurrentTokenIs(token.LET) {
+			stmt.Init = p.ParseLetStatement()
+		} else {
+			stmt.Init = &ast.ExpressionStatement{Token: p.currentToken, Expression: p.ParseExpression(LOWEST)}
+		}
+	}
+	if !p.ExpectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.PeekTokenIs(token.SEMICOLON) {
+		p.NextToken()
+		stmt.Condition = p.ParseExpression(LOWEST)
+	}
+	if !p.ExpectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.PeekTokenIs(token.RPAREN) {
+		p.NextToken()
+		stmt.Step = p.ParseExpression(LOWEST)
+	}
+	if !p.ExpectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.Body = p.ParseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse a for in loop, for x in iterable {}
+func (p *Parser) ParseForInStatement(label string) ast.Statement {
+	stmt := &ast.ForInStatement{Token: p.currentToken, Label: label}
+
+	p.NextToken()
+	stmt.Variable = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	// in is only a keyword here
+	if !p.PeekTokenIs(token.IDENT) || p.peekToken.Literal != "in" {
+		message := fmt.Sprintf("expected in after the loop variable, got %s instead", p.peekToken.Literal)
+		err := p.GenerateErrorForToken(CodeUnexpectedToken, message, &p.peekToken)
+		err.Fix = `insert "in"`
+		return nil
+	}
+	p.NextToken()
+
+	p.NextToken()
+	stmt.Iterable = p.ParseExpression(LOWEST)
+
+	stmt.Body = p.ParseLoopBody(label)
+	if stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// Parse a break statement inside a loop, break [label]
+func (p *Parser) ParseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.currentToken}
+	label, ok := p.parseJumpLabel()
+	if !ok {
+		return nil
+	}
+	stmt.Label = label
+	return stmt
+}
+
+// Parse a continue statement, continue [label]
+func (p *Parser) ParseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.currentToken}
+	if len(p.loops) == 0 {
+		p.GenerateErrorForToken(CodeInvalidJump, "continue outside of a loop", &p.currentToken)
+		return nil
+	}
+	label, ok := p.parseJumpLabel()
+	if !ok {
+		return nil
+	}
+	stmt.Label = label
+	return stmt
+}
+
+// parseJumpLabel parses the optional label after break or continue, it must name a loop around it
+func (p *Parser) parseJumpLabel() (string, bool) {
+	if !p.PeekTokenIs(token.IDENT) {
+		return "", true
+	}
+	p.NextToken()
+
+	label := p.currentToken.Literal
+	for _, loop := range p.loops {
+		if loop == label {
+			return label, true
+		}
+	}
+	message := fmt.Sprintf("no enclosing loop is labeled %s", label)
+	p.GenerateErrorForToken(CodeInvalidJump, message, &p.currentToken)
+	return "", false
+}
+
 // Parse an expressionStatement
 func (p *Parser) ParseExpressionStatement() interface {
 	ast.Statement
@@ -389,7 +748,11 @@ func (p *Parser) ParseExpression(precedence int) ast.Expression {
 		}
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
-			return leftExpression
+			break
+		}
+		// An optional chain ends before the first operator that is not one of its links
+		if !isChainLink(p.peekToken.Type) {
+			leftExpression = endChain(leftExpression)
 		}
 		p.NextToken()
 		leftExpression = infix(leftExpression)
@@ -402,24 +765,39 @@ func (p *Parser) ParseExpression(precedence int) ast.Expression {
 	//	p.NextToken()
 	//	leftExpression = infix(leftExpression)
 	//}
-	return leftExpression
+	return endChain(leftExpression)
 }
 
-// Generate Error for a token
-func (p *Parser) GenerateErrorForToken(message string, token *token.Token) {
-	err := &ParseError{
-		Message:      message,
-		Filename:     token.Filename,
-		RowNumber:    token.RowNumber,
-		ColumnNumber: token.ColumnNumber,
+// Generate Error for a token. An error with the code and the position of the previous one is only reported once,
+// the enclosing lists failing on the same token report it again
+func (p *Parser) GenerateErrorForToken(code string, message string, token *token.Token) *ParseError {
+	err := newParseError(code, message, token)
+	p.numErrors++
+	if last := len(p.errors) - 1; last >= 0 && p.errors[last].Code == err.Code &&
+		p.errors[last].RowNumber == err.RowNumber && p.errors[last].ColumnNumber == err.ColumnNumber {
+		return p.errors[last]
 	}
 	p.errors = append(p.errors, err)
+	return err
+}
+
+// Generate Warning for a token
+func (p *Parser) GenerateWarningForToken(code string, message string, token *token.Token) *ParseError {
+	warning := newParseError(code, message, token)
+	warning.Severity = SeverityWarning
+	p.errors = append(p.errors, warning)
+	return warning
 }
 
 // Error when can't find any available prefix parse functions
-func (p *Parser) NoPrefixParseFnError(token token.Token) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", token.Type)
-	p.GenerateErrorForToken(msg, &token)
+func (p *Parser) NoPrefixParseFnError(tok token.Token) {
+	if tok.Type == token.ILLEGAL {
+		err := p.GenerateErrorForToken(CodeIllegalCharacter, fmt.Sprintf("illegal character %q", tok.Literal), &tok)
+		err.Fix = "remove " + strconv.Quote(tok.Literal)
+		return
+	}
+	msg := fmt.Sprintf("no prefix parse function for %s found", tok.Type)
+	p.GenerateErrorForToken(CodeNoExpression, msg, &tok)
 }
 
 // Pratt Parser Function Types
@@ -436,6 +814,9 @@ func (p *Parser) ParseIdentifier() ast.Expression {
 		Token: p.currentToken,
 		Value: p.currentToken.Literal,
 	}
+	if p.PeekTokenIs(token.FAT_ARROW) && p.guard != p.depth+1 {
+		return p.ParseArrowFunction()
+	}
 
 	//if p.PeekTokenIs(token.ASSIGN) {
 	//	p.NextToken()
@@ -453,10 +834,14 @@ func (p *Parser) ParseIdentifier() ast.Expression {
 func (p *Parser) ParseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.currentToken}
 
-	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	digits, base := integerDigits(p.currentToken.Literal)
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil && err.(*strconv.NumError).Err == strconv.ErrRange {
+		// Integers too large for 64 bits become big integers
+		return p.ParseBigIntLiteral()
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
-		p.GenerateErrorForToken(msg, &p.currentToken)
+		p.numberError("integer")
 		return nil
 	}
 
@@ -464,6 +849,110 @@ func (p *Parser) ParseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// Parse a float literal
+func (p *Parser) ParseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.currentToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.currentToken.Literal, "_", ""), 64)
+	if err != nil {
+		p.numberError("float")
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+// Parse a big integer literal, 123n
+func (p *Parser) ParseBigIntLiteral() ast.Expression {
+	lit := &ast.BigIntLiteral{Token: p.currentToken}
+
+	value, ok := new(big.Int).SetString(integerDigits(strings.TrimSuffix(p.currentToken.Literal, "n")))
+	if !ok {
+		p.numberError("big integer")
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+// Parse a decimal literal, 1.10d
+func (p *Parser) ParseDecimalLiteral() ast.Expression {
+	lit := &ast.DecimalLiteral{Token: p.currentToken}
+
+	value, scale, ok := ParseDecimal(strings.ReplaceAll(strings.TrimSuffix(p.currentToken.Literal, "d"), "_", ""))
+	if !ok {
+		p.numberError("decimal")
+		return nil
+	}
+
+	lit.Value = value
+	lit.Scale = scale
+	return lit
+}
+
+// integerDigits returns the digits of an integer literal without its base prefix and underscores, and its base
+func integerDigits(literal string) (string, int) {
+	literal = strings.ReplaceAll(literal, "_", "")
+	if len(literal) > 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			return literal[2:], 16
+		case 'b', 'B':
+			return literal[2:], 2
+		case 'o', 'O':
+			return literal[2:], 8
+		}
+	}
+	return literal, 10
+}
+
+// numberError reports the number literal of the current token, unless the lexer already reported it as malformed
+func (p *Parser) numberError(kind string) {
+	tok := p.currentToken
+	for _, err := range p.errors {
+		if err.Code == CodeInvalidNumber && err.RowNumber == tok.RowNumber && err.ColumnNumber == tok.ColumnNumber {
+			return
+		}
+	}
+	msg := fmt.Sprintf("could not parse %q as %s", tok.Literal, kind)
+	p.GenerateErrorForToken(CodeInvalidNumber, msg, &tok)
+}
+
+// ParseDecimal reads a decimal such as -1.10 into its digits without the point and the amount of digits after it,
+// an exponent moves the point, 1.5e3 is 1500
+func ParseDecimal(text string) (*big.Int, int, bool) {
+	exponent := 0
+	if e := strings.IndexAny(text, "eE"); e != -1 {
+		value, err := strconv.Atoi(text[e+1:])
+		if err != nil {
+			return nil, 0, false
+		}
+		text, exponent = text[:e], value
+	}
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	scale := 0
+	if point := strings.Index(digits, "."); point != -1 {
+		scale = len(digits) - point - 1
+		digits = digits[:point] + digits[point+1:]
+	}
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil, 0, false
+	}
+
+	value, _ := new(big.Int).SetString(digits, 10)
+	if strings.HasPrefix(text, "-") {
+		value.Neg(value)
+	}
+	if scale -= exponent; scale < 0 {
+		value.Mul(value, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	return value, scale, true
+}
+
 // Parses Prefix
 func (p *Parser) ParsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
@@ -488,13 +977,70 @@ func (p *Parser) ParseInfixExpression(left ast.Expression) ast.Expression {
 
 	// Gets the Precedence of the operator
 	prec := p.CurrentPrecedence()
+	// Powers group from the right, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if expression.Operator == token.POWER {
+		prec--
+	}
 	// Advance to the right expression
 	p.NextToken()
 	expression.Right = p.ParseExpression(prec)
 
+	if expression.Operator == token.ASSIGN {
+		switch target := left.(type) {
+		case *ast.Identifier:
+			nameFunction(expression.Right, target.Value)
+		case *ast.InfixExpression:
+			if key, ok := target.Right.(*ast.Identifier); ok && target.Operator == token.DOT {
+				nameFunction(expression.Right, key.Value)
+			}
+		}
+	}
+
 	return expression
 }
 
+// ParseCompoundAssignment parses x op= y, it is kept as an infix expression so that x is evaluated once
+func (p *Parser) ParseCompoundAssignment(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Left:     left,
+	}
+
+	p.NextToken()
+	expression.Right = p.ParseExpression(ASSIGN)
+	return expression
+}
+
+// ParsePrefixUpdate parses ++x and --x, x binds like the operand of a prefix operator
+func (p *Parser) ParsePrefixUpdate() ast.Expression {
+	expression := &ast.UpdateExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Prefix:   true,
+	}
+
+	p.NextToken()
+	expression.Target = p.ParseExpression(PREFIX)
+	return expression
+}
+
+// ParsePostfixUpdate parses x++ and x--, they apply to the whole of an index or a member like a.b[0]++
+func (p *Parser) ParsePostfixUpdate(left ast.Expression) ast.Expression {
+	return &ast.UpdateExpression{
+		Token:    p.currentToken,
+		Operator: p.currentToken.Literal,
+		Target:   left,
+	}
+}
+
+// nameFunction names a function literal after the variable it is assigned to
+func nameFunction(exp ast.Expression, name string) {
+	if fn, ok := exp.(*ast.FunctionLiteral); ok && fn.Name == "" {
+		fn.Name = name
+	}
+}
+
 // Parse Boolean
 func (p *Parser) ParseBoolean() ast.Expression {
 	return &ast.Boolean{
@@ -505,6 +1051,10 @@ func (p *Parser) ParseBoolean() ast.Expression {
 
 // Parse grouped expression
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.guard != p.depth && p.isArrowAhead() {
+		return p.ParseArrowFunction()
+	}
+
 	p.RemoveNewLines()
 	p.NextToken()
 	exp := p.ParseExpression(LOWEST)
@@ -580,6 +1130,60 @@ func (p *Parser) ParseIfExpression() ast.Expression {
 	return expression
 }
 
+// Parse a try expression, it needs a catch or a finally block
+func (p *Parser) ParseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.currentToken}
+
+	p.RemoveNewLines()
+	if !p.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.ParseBlockStatement()
+
+	if p.PeekTokenIs(token.CATCH) {
+		p.NextToken()
+
+		// The error does not have to be bound
+		if p.PeekTokenIs(token.LPAREN) {
+			p.NextToken()
+			if !p.ExpectPeek(token.IDENT) {
+				return nil
+			}
+			expression.Parameter = &ast.Identifier{
+				Token: p.currentToken,
+				Value: p.currentToken.Literal,
+			}
+			if !p.ExpectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		p.RemoveNewLines()
+		if !p.ExpectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.ParseBlockStatement()
+	}
+
+	if p.PeekTokenIs(token.FINALLY) {
+		p.NextToken()
+		p.RemoveNewLines()
+		if !p.ExpectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.ParseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		message := fmt.Sprintf("expected catch or finally after try, got %s instead", p.peekToken.Type)
+		err := p.GenerateErrorForToken(CodeUnexpectedToken, message, &p.peekToken)
+		err.Fix = `insert "catch {}"`
+		return nil
+	}
+
+	return expression
+}
+
 // Parse a block statement
 func (p *Parser) ParseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
@@ -590,9 +1194,19 @@ func (p *Parser) ParseBlockStatement() *ast.BlockStatement {
 	p.NextToken()
 
 	for !p.CurrentTokenIs(token.RBRACE) && !p.CurrentTokenIs(token.EOF) {
+		errors, depth := p.numErrors, p.depth
 		stmt := p.ParseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
+		if p.numErrors != errors {
+			// Stop at the end of the line or at the closing brace of the block
+			p.Synchronize(depth)
+			if p.depth < depth {
+				break
+			}
+		} else {
+			if stmt != nil {
+				block.Statements = append(block.Statements, stmt)
+			}
+			p.SkipSeparator()
 		}
 		p.RemoveNewLines()
 		p.NextToken()
@@ -612,7 +1226,7 @@ func (p *Parser) ParseHashFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	fnLit.Body = p.ParseBlockStatement()
+	fnLit.Body, fnLit.Generator = p.ParseFunctionBody()
 	return fnLit
 }
 
@@ -627,16 +1241,23 @@ func (p *Parser) ParseModuleExpression() ast.Expression {
 	return mLit
 }
 
-// Parse a function expression
+// Parse a function expression, fn* declares a generator function
 func (p *Parser) ParseFunctionLiteral() ast.Expression {
 	fnLit := &ast.FunctionLiteral{Token: p.currentToken}
+	generator := false
+	if p.PeekTokenIs(token.ASTERISK) {
+		p.NextToken()
+		generator = true
+	}
 
 	// Check (
 	if !p.ExpectPeek(token.LPAREN) {
 		return nil
 	}
 
-	fnLit.Parameters = p.ParseFunctionParameters()
+	if !p.ParseFunctionParameters(fnLit) {
+		return nil
+	}
 
 	p.RemoveNewLines()
 
@@ -644,33 +1265,121 @@ func (p *Parser) ParseFunctionLiteral() ast.Expression {
 	if !p.ExpectPeek(token.LBRACE) {
 		return nil
 	}
-	fnLit.Body = p.ParseBlockStatement()
+	var yields bool
+	fnLit.Body, yields = p.ParseFunctionBody()
+	fnLit.Generator = generator || yields
 	return fnLit
 }
 
-// Parse the parameter list in a function
-func (p *Parser) ParseFunctionParameters() []*ast.Identifier {
+// ParseFunctionBody parses the block of a function, break and continue cannot reach the loops outside of it.
+// It returns whether the block yields, making the function a generator
+func (p *Parser) ParseFunctionBody() (*ast.BlockStatement, bool) {
+	var body *ast.BlockStatement
+	yields := p.parseFunction(func() {
+		body = p.ParseBlockStatement()
+	})
+	return body, yields
+}
+
+// parseFunction runs parse on the body of a function, returning whether the body yields
+func (p *Parser) parseFunction(parse func()) bool {
+	loops, yields := p.loops, p.yields
+	p.loops, p.yields = nil, new(bool)
+	parse()
+	generator := *p.yields
+	p.loops, p.yields = loops, yields
+	return generator
+}
+
+// ParseYieldExpression parses a yield, its value is left out before the end of a statement or a closing bracket
+func (p *Parser) ParseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.currentToken}
+	if p.yields == nil {
+		p.GenerateErrorForToken(CodeInvalidYield, "yield outside of a function", &exp.Token)
+		return nil
+	}
+	*p.yields = true
+
+	switch p.peekToken.Type {
+	case token.RPAREN, token.RBRACKET, token.COMMA, token.COLON:
+		return exp
+	}
+	if p.IsPeekEndOfStatement() {
+		return exp
+	}
+	p.NextToken()
+	exp.Value = p.ParseExpression(LOWEST)
+	if exp.Value == nil {
+		return nil
+	}
+	return exp
+}
+
+// Parse the parameter list in a function into its parameters, their patterns and defaults, and its ...rest parameter.
+// The patterns and the defaults are nil when no parameter has one
+func (p *Parser) ParseFunctionParameters(fn *ast.FunctionLiteral) bool {
 	var identifiers []*ast.Identifier
+	var patterns, defaults []ast.Expression
+	destructures, hasDefaults := false, false
 
 	p.RemoveNewLines()
 	// If parameter list is empty
 	if p.PeekTokenIs(token.RPAREN) {
 		p.NextToken()
-		return identifiers
+		fn.Parameters = identifiers
+		return true
 	}
 
 	// Advance to the first identifier
 	p.NextToken()
 
-	// Parse the first identifier
-	ident := &ast.Identifier{
-		Token: p.currentToken,
-		Value: p.currentToken.Literal,
-	}
-	identifiers = append(identifiers, ident)
+	for {
+		if p.CurrentTokenIs(token.ELLIPSIS) {
+			if !p.ExpectPeek(token.IDENT) {
+				return false
+			}
+			fn.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
-	// Parse the rest of identifiers
-	for p.PeekTokenIs(token.COMMA) {
+			p.RemoveNewLines()
+			if p.PeekTokenIs(token.COMMA) {
+				p.GenerateErrorForToken(CodeInvalidArgument, "the ...rest parameter must be the last parameter", &p.peekToken)
+				return false
+			}
+			break
+		}
+
+		start := p.currentToken
+		pattern := p.ParsePattern()
+		if pattern == nil {
+			return false
+		}
+		if ident, ok := pattern.(*ast.Identifier); ok {
+			identifiers = append(identifiers, ident)
+			patterns = append(patterns, nil)
+		} else {
+			// The argument is stored in a parameter named after the pattern, which cannot be referred to
+			identifiers = append(identifiers, &ast.Identifier{Token: start, Value: pattern.ToString()})
+			patterns = append(patterns, pattern)
+			destructures = true
+		}
+
+		// Default value, used when the argument is missing or null
+		var value ast.Expression
+		if p.PeekTokenIs(token.ASSIGN) {
+			p.NextToken()
+			p.NextToken()
+			if value = p.ParseExpression(ASSIGN); value == nil {
+				return false
+			}
+			hasDefaults = true
+		}
+		defaults = append(defaults, value)
+		p.RemoveNewLines()
+
+		// Parse the rest of identifiers
+		if !p.PeekTokenIs(token.COMMA) {
+			break
+		}
 		// Advance to next Identifier
 		p.NextToken()
 		p.RemoveNewLines()
@@ -679,21 +1388,21 @@ func (p *Parser) ParseFunctionParameters() []*ast.Identifier {
 			break
 		}
 		p.NextToken()
-
-		ident := &ast.Identifier{
-			Token: p.currentToken,
-			Value: p.currentToken.Literal,
-		}
-		identifiers = append(identifiers, ident)
-		p.RemoveNewLines()
 	}
 
 	// Check )
 	if !p.ExpectPeek(token.RPAREN) {
-		return nil
+		return false
 	}
 
-	return identifiers
+	fn.Parameters = identifiers
+	if destructures {
+		fn.Patterns = patterns
+	}
+	if hasDefaults {
+		fn.Defaults = defaults
+	}
+	return true
 }
 
 // Parse a call expression
@@ -704,7 +1413,7 @@ func (p *Parser) ParseCallExpression(function ast.Expression) ast.Expression {
 	}
 
 	if p.CurrentTokenIs(token.LPAREN) {
-		exp.Arguments = p.ParseExpressionList(token.RPAREN)
+		exp.Arguments = p.ParseCallArguments()
 	} else {
 		exp.Arguments = []ast.Expression{}
 	}
@@ -723,34 +1432,47 @@ func (p *Parser) ParseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
-// Parse function calling arguments
+// Parse function calling arguments, they can be spread and named, named arguments come last
 func (p *Parser) ParseCallArguments() []ast.Expression {
-	var args []ast.Expression
+	named := false
+	return p.parseList(token.RPAREN, func() ast.Expression {
+		start := p.currentToken
+		arg := p.parseArgument()
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named = true
+		} else if named && arg != nil {
+			p.GenerateErrorForToken(CodeInvalidArgument, "a positional argument cannot follow a named argument", &start)
+			return nil
+		}
+		return arg
+	})
+}
 
-	// Empty Arguments
-	if p.PeekTokenIs(token.RPAREN) {
+// parseArgument parses an argument of a call, name: value or an element
+func (p *Parser) parseArgument() ast.Expression {
+	if p.CurrentTokenIs(token.IDENT) && p.PeekTokenIs(token.COLON) {
+		arg := &ast.NamedArgument{Token: p.currentToken, Name: p.currentToken.Literal}
 		p.NextToken()
-		return args
-	}
-
-	p.NextToken()
-	args = append(args, p.ParseExpression(LOWEST))
-
-	for p.PeekTokenIs(token.COMMA) {
 		p.NextToken()
-		if p.PeekTokenIs(token.RPAREN) {
-			break
+		if arg.Value = p.ParseExpression(LOWEST); arg.Value == nil {
+			return nil
 		}
-		p.NextToken()
-
-		args = append(args, p.ParseExpression(LOWEST))
+		return arg
 	}
+	return p.parseElement()
+}
 
-	if !p.ExpectPeek(token.RPAREN) {
-		return nil
+// parseElement parses an element of a list, ...spread or an expression
+func (p *Parser) parseElement() ast.Expression {
+	if p.CurrentTokenIs(token.ELLIPSIS) {
+		spread := &ast.SpreadElement{Token: p.currentToken}
+		p.NextToken()
+		if spread.Value = p.ParseExpression(LOWEST); spread.Value == nil {
+			return nil
+		}
+		return spread
 	}
-
-	return args
+	return p.ParseExpression(LOWEST)
 }
 
 // Parse a string expression
@@ -761,6 +1483,70 @@ func (p *Parser) ParseStringLiteral() ast.Expression {
 	}
 }
 
+// Parse an interpolated string, "Hello ${name}"
+func (p *Parser) ParseTemplateLiteral() ast.Expression {
+	template := &ast.TemplateLiteral{Token: p.currentToken}
+
+	parts, closed := lexer.ReadTemplate(p.currentToken.Literal, p.currentToken.RowNumber, p.currentToken.ColumnNumber)
+	if !closed {
+		p.GenerateErrorForToken(CodeInvalidTemplate, "expected } to close the interpolation in the string", &p.currentToken)
+		return nil
+	}
+
+	for _, part := range parts {
+		if !part.Expression {
+			if part.Value != "" {
+				text := token.Token{
+					Type:         token.STRING,
+					Literal:      part.Value,
+					RowNumber:    part.RowNumber,
+					ColumnNumber: part.ColumnNumber,
+					Filename:     p.currentToken.Filename,
+				}
+				template.Parts = append(template.Parts, &ast.StringLiteral{Token: text, Value: part.Value})
+			}
+			continue
+		}
+
+		expression := p.parseInterpolation(part)
+		if expression == nil {
+			return nil
+		}
+		template.Parts = append(template.Parts, expression)
+	}
+
+	return template
+}
+
+// parseInterpolation parses the expression of an interpolation with its own parser,
+// its tokens are placed where they are in the string
+func (p *Parser) parseInterpolation(part lexer.TemplatePart) ast.Expression {
+	inner := New(lexer.NewAt(part.Value, p.currentToken.Filename, part.RowNumber, part.ColumnNumber))
+	for inner.CurrentTokenIs(token.NEWLINE) {
+		inner.NextToken()
+	}
+	if inner.CurrentTokenIs(token.EOF) {
+		p.GenerateErrorForToken(CodeInvalidTemplate, "expected an expression inside ${}", &p.currentToken)
+		return nil
+	}
+
+	expression := inner.ParseExpression(LOWEST)
+	for inner.PeekTokenIs(token.NEWLINE) {
+		inner.NextToken()
+	}
+	if inner.numErrors == 0 && !inner.PeekTokenIs(token.EOF) {
+		msg := fmt.Sprintf("expected } after the interpolated expression, got %s", inner.peekToken.Literal)
+		inner.GenerateErrorForToken(CodeUnexpectedToken, msg, &inner.peekToken)
+	}
+
+	p.errors = append(p.errors, inner.errors...)
+	p.numErrors += inner.numErrors
+	if inner.numErrors != 0 {
+		return nil
+	}
+	return expression
+}
+
 // Parse an array expression
 func (p *Parser) ParseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
@@ -770,8 +1556,13 @@ func (p *Parser) ParseArrayLiteral() ast.Expression {
 	return array
 }
 
-// Parse a comma separated expression
+// Parse a comma separated expression, the elements can be spread
 func (p *Parser) ParseExpressionList(end token.TokenType) []ast.Expression {
+	return p.parseList(end, p.parseElement)
+}
+
+// parseList parses a comma separated list of the elements parsed by element up to the end token
+func (p *Parser) parseList(end token.TokenType, element func() ast.Expression) []ast.Expression {
 	var list []ast.Expression
 
 	p.RemoveNewLines()
@@ -782,7 +1573,7 @@ func (p *Parser) ParseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.NextToken()
-	list = append(list, p.ParseExpression(LOWEST))
+	list = append(list, element())
 
 	p.RemoveNewLines()
 
@@ -794,7 +1585,7 @@ func (p *Parser) ParseExpressionList(end token.TokenType) []ast.Expression {
 		}
 
 		p.NextToken()
-		list = append(list, p.ParseExpression(LOWEST))
+		list = append(list, element())
 		p.RemoveNewLines()
 
 	}
@@ -843,16 +1634,25 @@ func (p *Parser) ParseHashLiteral() ast.Expression {
 	for !p.PeekTokenIs(token.RBRACE) {
 		p.RemoveNewLines()
 		p.NextToken()
-		key := p.ParseExpression(LOWEST)
 
-		if !p.ExpectPeek(token.COLON) {
-			return nil
-		}
+		if p.CurrentTokenIs(token.ELLIPSIS) {
+			spread, ok := p.parseElement().(*ast.SpreadElement)
+			if !ok {
+				return nil
+			}
+			hash.Spreads = append(hash.Spreads, spread)
+		} else {
+			key := p.ParseExpression(LOWEST)
 
-		p.NextToken()
-		value := p.ParseExpression(LOWEST)
+			if !p.ExpectPeek(token.COLON) {
+				return nil
+			}
+
+			p.NextToken()
+			value := p.ParseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+			hash.Pairs[key] = value
+		}
 
 		p.RemoveNewLines()
 		if !p.PeekTokenIs(token.RBRACE) && !p.ExpectPeek(token.COMMA) {
@@ -892,18 +1692,46 @@ func (p *Parser) ParseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.ParseFunctionParameters()
+	params := &ast.FunctionLiteral{}
+	if !p.ParseFunctionParameters(params) {
+		return nil
+	}
+	if params.Patterns != nil {
+		p.GenerateErrorForToken(CodeInvalidPattern, "the parameters of a macro cannot be destructured", &lit.Token)
+		return nil
+	}
+	if params.Defaults != nil || params.Rest != nil {
+		p.GenerateErrorForToken(CodeInvalidArgument, "the parameters of a macro cannot have defaults or a rest", &lit.Token)
+		return nil
+	}
+	lit.Parameters = params.Parameters
 
 	if !p.ExpectPeek(token.LBRACE) {
 		return nil
 	}
 
-	lit.Body = p.ParseBlockStatement()
+	var yields bool
+	lit.Body, yields = p.ParseFunctionBody()
+	if yields {
+		p.GenerateErrorForToken(CodeInvalidYield, "a macro cannot yield", &lit.Token)
+		return nil
+	}
 
 	return lit
 }
 
-// FormatFloat formats floats into a string
+// FormatFloat formats a float as a literal that reads back as the same float, 2.0 instead of 2,
+// and with an exponent when it is very small or very large, 1e-09
 func FormatFloat(t float64) string {
-	return strconv.FormatFloat(t, 'f', -1, 64)
+	if math.IsInf(t, 0) || math.IsNaN(t) {
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	if abs := math.Abs(t); abs != 0 && (abs < 1e-7 || abs >= 1e21) {
+		return strconv.FormatFloat(t, 'e', -1, 64)
+	}
+	formatted := strconv.FormatFloat(t, 'f', -1, 64)
+	if !strings.Contains(formatted, ".") {
+		formatted += ".0"
+	}
+	return formatted
 }
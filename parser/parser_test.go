@@ -5,6 +5,8 @@ import (
 	"Monkey/lexer"
 	"Monkey/options"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -96,7 +98,7 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]float64{
+	expected := map[string]int64{
 		"one":   1,
 		"two":   2,
 		"three": 3,
@@ -150,7 +152,7 @@ func TestParsingIndexRanges(t *testing.T) {
 				t.Fatalf("indexExp start is not nil. got=%T", indexExp.Start)
 			}
 		} else if val, ok := tt.start.(int); ok {
-			if !CheckIntegerLiteral(t, indexExp.Start, float64(val)) {
+			if !CheckIntegerLiteral(t, indexExp.Start, int64(val)) {
 				return
 			}
 		}
@@ -160,7 +162,7 @@ func TestParsingIndexRanges(t *testing.T) {
 				t.Fatalf("indexExp end is not nil. got=%T", indexExp.End)
 			}
 		} else if val, ok := tt.end.(int); ok {
-			if !CheckIntegerLiteral(t, indexExp.End, float64(val)) {
+			if !CheckIntegerLiteral(t, indexExp.End, int64(val)) {
 				return
 			}
 		}
@@ -243,15 +245,80 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateLiteralParsing(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items\${x}"`
+
+	l := lexer.New(input, "testTemplate")
+	p := New(l)
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("expression not type *ast.TemplateLiteral. got=%T", stmt.Expression)
+	}
+
+	expected := []string{"Hello ", "name", ", you have ", "(len(items) + 1)", " items${x}"}
+	if len(template.Parts) != len(expected) {
+		t.Fatalf("template has wrong number of parts. got=%d", len(template.Parts))
+	}
+	for i, part := range template.Parts {
+		if part.ToString() != expected[i] {
+			t.Errorf("part %d wrong. want=%q, got=%q", i, expected[i], part.ToString())
+		}
+	}
+
+	// The tokens inside the string are placed where they are in the source
+	name := template.Parts[1].(*ast.Identifier)
+	if name.Token.RowNumber != 1 || name.Token.ColumnNumber != 10 {
+		t.Errorf("name placed wrong. got=%d:%d", name.Token.RowNumber, name.Token.ColumnNumber)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		code   string
+		row    int64
+		column int64
+	}{
+		{`"a ${}"`, CodeInvalidTemplate, 1, 1},
+		{`"a ${x"`, CodeInvalidTemplate, 1, 1},
+		{"let s = 1\n\"a ${s +}\"", CodeNoExpression, 2, 9},
+		{`"${a b}"`, CodeUnexpectedToken, 1, 6},
+		{`let s = "a\qb"`, CodeInvalidEscape, 1, 11},
+		{`"${"\q"}"`, CodeInvalidEscape, 1, 5},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testTemplateErrors"))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Code != tt.code {
+			t.Errorf("expected a %s error for %q. got=%v", tt.code, tt.input, ParseErrors(errors))
+			continue
+		}
+		if errors[0].RowNumber != tt.row || errors[0].ColumnNumber != tt.column {
+			t.Errorf("error for %q placed wrong. want=%d:%d, got=%d:%d",
+				tt.input, tt.row, tt.column, errors[0].RowNumber, errors[0].ColumnNumber)
+		}
+	}
+}
+
 func TestFloatingPointNumbers(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected interface{}
 	}{
 		{"1.5", 1.5},
 		{"300.0", 300.0},
 		{"0", 0},
 		{"0.1", 0.1},
+		{"9007199254740993", int64(9007199254740993)},
+		{"1e-9", 1e-9},
+		{"1.5E3", 1500.0},
+		{"2_5.0_1e+0_1", 250.1},
 	}
 
 	for _, tt := range tests {
@@ -271,7 +338,133 @@ func TestFloatingPointNumbers(t *testing.T) {
 				program.Statements[0])
 		}
 
-		CheckIntegerLiteral(t, stmt.Expression, tt.expected)
+		CheckLiteralExpression(t, stmt.Expression, tt.expected)
+	}
+}
+
+func TestBigNumberParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		scale    int
+	}{
+		{"123n", "123", 0},
+		{"100000000000000000000", "100000000000000000000", 0},
+		{"1.10d", "110", 2},
+		{"5d", "5", 0},
+		{"0xFFFF_FFFF_FFFF_FFFF", "18446744073709551615", 0},
+		{"0b11n", "3", 0},
+		{"1_000.50d", "100050", 2},
+		{"1.5e-3d", "15", 4},
+		{"12e2d", "1200", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "testBigNumber")
+		p := New(l)
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch literal := stmt.Expression.(type) {
+		case *ast.BigIntLiteral:
+			if literal.Value.String() != tt.expected || tt.scale != 0 {
+				t.Errorf("%q wrong big integer. got=%s", tt.input, literal.Value)
+			}
+		case *ast.DecimalLiteral:
+			if literal.Value.String() != tt.expected || literal.Scale != tt.scale {
+				t.Errorf("%q wrong decimal. got=%s, scale=%d", tt.input, literal.Value, literal.Scale)
+			}
+		default:
+			t.Errorf("%q is not a big number literal. got=%T", tt.input, stmt.Expression)
+		}
+	}
+}
+
+func TestIntegerLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0XfF", 255},
+		{"0B1010", 10},
+		{"0o755", 493},
+		{"0755", 755},
+		{"1_000_000", 1000000},
+		{"0b1111_0000", 240},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testIntegerLiteralForms"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Errorf("%q is not an integer literal. got=%T", tt.input, stmt.Expression)
+			continue
+		}
+		if literal.Value != tt.expected || literal.ToString() != tt.input {
+			t.Errorf("%q wrong integer. want=%d, got=%d written %q", tt.input, tt.expected, literal.Value, literal.ToString())
+		}
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"let a = 0x", "expected hexadecimal digits after 0x"},
+		{"0b102", "invalid digit '2' in the binary number 0b102"},
+		{"0o8n", "invalid digit '8' in the octal number 0o8"},
+		{"1__000", "_ must separate two digits in the number 1__000"},
+		{"1_e5", "_ must separate two digits in the number 1_e5"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testNumberErrors"))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0].Code != CodeInvalidNumber || errors[0].Message != tt.message {
+			t.Errorf("expected one %s error %q for %q. got=%v", CodeInvalidNumber, tt.message, tt.input, ParseErrors(errors))
+		}
+	}
+}
+
+// Formatted floats read back as the same float
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{2, "2.0"},
+		{0.1, "0.1"},
+		{1e-9, "1e-09"},
+		{-1.5e300, "-1.5e+300"},
+		{123456.789, "123456.789"},
+	}
+
+	for _, tt := range tests {
+		formatted := FormatFloat(tt.value)
+		if formatted != tt.expected {
+			t.Errorf("FormatFloat(%v) wrong. want=%q, got=%q", tt.value, tt.expected, formatted)
+		}
+
+		p := New(lexer.New(formatted, "testFormatFloat"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if tt.value < 0 {
+			stmt.Expression = stmt.Expression.(*ast.PrefixExpression).Right
+			formatted = formatted[1:]
+		}
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok || literal.Value != math.Abs(tt.value) || literal.ToString() != formatted {
+			t.Errorf("%q does not read back. got=%s", formatted, stmt.Expression.ToString())
+		}
 	}
 }
 
@@ -420,6 +613,170 @@ func TestFunctionLiteralParsing(t *testing.T) {
 }
 
 // Test If else if else Expression
+func TestFunctionLiteralNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(x, y) { x + y }", "add"},
+		{"add = fn(x, y) { x + y }", "add"},
+		{"Array.prototype.sum = fn() { 0 }", "sum"},
+		{"fn(x) { x }", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testFunctionNames"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+
+		var function *ast.FunctionLiteral
+		ast.Modify(program, func(node ast.Node) ast.Node {
+			if fn, ok := node.(*ast.FunctionLiteral); ok && function == nil {
+				function = fn
+			}
+			return node
+		})
+		if function == nil {
+			t.Fatalf("no function literal in %q", tt.input)
+		}
+		if function.Name != tt.expected {
+			t.Errorf("wrong name for %q. want=%q, got=%q", tt.input, tt.expected, function.Name)
+		}
+	}
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input      string
+		parameter  string
+		hasCatch   bool
+		hasFinally bool
+	}{
+		{"try { x } catch (e) { e }", "e", true, false},
+		{"try { x } catch { 1 }", "", true, false},
+		{"try { x } finally { 1 }", "", false, true},
+		{"try { x } catch (err) { err } finally { 1 }", "err", true, true},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testTry"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.TryExpression. got=%T", stmt.Expression)
+		}
+		if len(exp.Block.Statements) != 1 {
+			t.Errorf("try block has wrong statements. got=%d", len(exp.Block.Statements))
+		}
+		if (exp.Parameter != nil) != (tt.parameter != "") || (exp.Parameter != nil && exp.Parameter.Value != tt.parameter) {
+			t.Errorf("wrong parameter for %q. got=%v", tt.input, exp.Parameter)
+		}
+		if (exp.Catch != nil) != tt.hasCatch || (exp.Finally != nil) != tt.hasFinally {
+			t.Errorf("wrong blocks for %q. catch=%v, finally=%v", tt.input, exp.Catch, exp.Finally)
+		}
+	}
+
+	p := New(lexer.New("try { x }", "testTry"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0].Code != CodeUnexpectedToken {
+		t.Errorf("expected an error for a try without catch. got=%v", ParseErrors(errors))
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	p := New(lexer.New("throw error('bad')", "testThrow"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := stmt.Value.(*ast.CallExpression); !ok {
+		t.Errorf("stmt.Value is not *ast.CallExpression. got=%T", stmt.Value)
+	}
+}
+
+func TestLoopParsing(t *testing.T) {
+	p := New(lexer.New("while x < 3 { x = x + 1 }\nfor (let i = 0; i < 3; i = i + 1) { i }\nfor (;;) { break }\nouter: for x in [1, 2] { continue outer }", "testLoops"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("program has wrong statements. got=%d", len(program.Statements))
+	}
+
+	while, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := while.Condition.(*ast.InfixExpression); !ok || len(while.Body.Statements) != 1 {
+		t.Errorf("wrong while loop. got=%s", while.ToString())
+	}
+
+	forLoop, ok := program.Statements[1].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not *ast.ForStatement. got=%T", program.Statements[1])
+	}
+	if _, ok := forLoop.Init.(*ast.LetStatement); !ok || forLoop.Condition == nil || forLoop.Step == nil {
+		t.Errorf("wrong for loop. got=%s", forLoop.ToString())
+	}
+
+	empty := program.Statements[2].(*ast.ForStatement)
+	if empty.Init != nil || empty.Condition != nil || empty.Step != nil {
+		t.Errorf("expected an empty for loop. got=%s", empty.ToString())
+	}
+	if _, ok := empty.Body.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("empty.Body.Statements[0] is not *ast.BreakStatement. got=%T", empty.Body.Statements[0])
+	}
+
+	forIn, ok := program.Statements[3].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[3] is not *ast.ForInStatement. got=%T", program.Statements[3])
+	}
+	if forIn.Label != "outer" || forIn.Variable.Value != "x" {
+		t.Errorf("wrong for in loop. got=%s", forIn.ToString())
+	}
+	if jump, ok := forIn.Body.Statements[0].(*ast.ContinueStatement); !ok || jump.Label != "outer" {
+		t.Errorf("expected continue outer. got=%v", forIn.Body.Statements[0])
+	}
+}
+
+func TestLoopJumps(t *testing.T) {
+	tests := []struct {
+		input string
+		code  string
+	}{
+		{"continue", CodeInvalidJump},
+		{"while true { let f = fn() { continue } }", CodeInvalidJump},
+		{"a: while true { break b }", CodeInvalidJump},
+		{"a: let x = 1", CodeUnexpectedToken},
+		{"for x of xs { x }", CodeUnexpectedToken},
+		{"for (i = 0, i < 3, i = i + 1) { i }", CodeUnexpectedToken},
+		// break outside of a loop is still the value ending an iteration
+		{"let f = fn() { return break }", ""},
+		{"a: while true { while true { break a } }", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testLoopJumps"))
+		p.ParseProgram()
+		errors := p.Errors()
+		if tt.code == "" {
+			if len(errors) != 0 {
+				t.Errorf("unexpected errors for %q. got=%v", tt.input, ParseErrors(errors))
+			}
+			continue
+		}
+		if len(errors) == 0 || errors[0].Code != tt.code {
+			t.Errorf("expected a %s error for %q. got=%v", tt.code, tt.input, ParseErrors(errors))
+		}
+	}
+}
+
 func TestIfElseIfElseExpression(t *testing.T) {
 	input := `
 if x < y {
@@ -753,9 +1110,11 @@ func CheckLiteralExpression(
 ) bool {
 	switch v := expected.(type) {
 	case int:
-		return CheckIntegerLiteral(t, exp, float64(v))
-	case float64:
+		return CheckIntegerLiteral(t, exp, int64(v))
+	case int64:
 		return CheckIntegerLiteral(t, exp, v)
+	case float64:
+		return CheckFloatLiteral(t, exp, v)
 	case string:
 		return CheckIdentifier(t, exp, v)
 	case bool:
@@ -780,6 +1139,17 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"-a * b", "[((-(a)) * (b))]"},
 		{"!+a", "[(!(+(a)))]"},
 		{"1 + 1 * 1", "[((1) + ((1) * (1)))]"},
+		{"a | b ^ c & d << 1 + 2", "[((a) | ((b) ^ ((c) & ((d) << ((1) + (2))))))]"},
+		{"x & 1 == 0", "[(((x) & (1)) == (0))]"},
+		{"-2 ** 2", "[(-((2) ** (2)))]"},
+		{"2 ** 3 ** 2", "[((2) ** ((3) ** (2)))]"},
+		{"~a >> b * c", "[((~(a)) >> ((b) * (c)))]"},
+		{"a <<= b | 1", "[((a) <<= ((b) | (1)))]"},
+		{"a++ + --b", "[((a++) + (--b))]"},
+		{"-a.b[0]++", "[(-((((a) . (b))[(0)])++))]"},
+		{"a\n++b", "[a(++b)]"},
+		{"a[i] += b * 2", "[(((a)[(i)]) += ((b) * (2)))]"},
+		{"a.b ??= c or d", "[(((a) . (b)) ??= ((c) or (d)))]"},
 		{
 			"a + b * c + d / e - f",
 			"[((((a) + ((b) * (c))) + ((d) / (e))) - (f))]",
@@ -938,7 +1308,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input        string
 		operator     string
-		integerValue float64
+		integerValue int64
 	}{
 		{"!5", "!", 5},
 		{"-15", "-", 15},
@@ -978,22 +1348,38 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}
 }
 
-func CheckIntegerLiteral(t *testing.T, il ast.Expression, value float64) bool {
+func CheckIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 	integ, ok := il.(*ast.IntegerLiteral)
 	if !ok {
 		t.Errorf("expression not type *ast.IntegerLiteral. got=%T", il)
 		return false
 	}
 
-	if !AlmostEqual(integ.Value, value) {
-		t.Errorf("integ.Value not %f. got=%f",
+	if integ.Value != value {
+		t.Errorf("integ.Value not %d. got=%d",
 			value, integ.Value)
 		return false
 	}
 
-	if !strings.Contains(integ.TokenLiteral(), FormatFloat(value)) {
-		t.Errorf("integ.TokenLiteral not %s. got=%s",
-			FormatFloat(value), integ.TokenLiteral())
+	if !strings.Contains(integ.TokenLiteral(), strconv.FormatInt(value, 10)) {
+		t.Errorf("integ.TokenLiteral not %d. got=%s",
+			value, integ.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func CheckFloatLiteral(t *testing.T, fl ast.Expression, value float64) bool {
+	float, ok := fl.(*ast.FloatLiteral)
+	if !ok {
+		t.Errorf("expression not type *ast.FloatLiteral. got=%T", fl)
+		return false
+	}
+
+	if !AlmostEqual(float.Value, value) {
+		t.Errorf("float.Value not %f. got=%f",
+			value, float.Value)
 		return false
 	}
 
@@ -1061,7 +1447,7 @@ func TestIntegerLiteralExpression(t *testing.T) {
 			stmt.Expression)
 	}
 	if literal.Value != 5 {
-		t.Errorf("literal.Value not %d. got=%f",
+		t.Errorf("literal.Value not %d. got=%d",
 			5, literal.Value)
 	}
 	if literal.TokenLiteral() != "5" {
@@ -1171,6 +1557,459 @@ func TestLetStatementsFully(t *testing.T) {
 	}
 }
 
+func TestDocComments(t *testing.T) {
+	input := `/// Adds two numbers
+/// and returns the sum
+let add = fn(a, b) { a + b }
+
+/// Detached
+
+let x = 1 /* a comment
+over lines */ let y = 2
+// A plain comment
+let z = 3`
+
+	p := New(lexer.New(input, "testDocComments"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+
+	expected := []struct {
+		name string
+		doc  string
+	}{
+		{"add", "Adds two numbers\nand returns the sum"},
+		{"x", ""},
+		{"y", ""},
+		{"z", ""},
+	}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", len(expected), len(program.Statements))
+	}
+	for i, tt := range expected {
+		stmt := program.Statements[i].(*ast.LetStatement)
+		if stmt.Name.Value != tt.name || stmt.Doc != tt.doc {
+			t.Errorf("statements[%d] wrong. want=%s %q, got=%s %q", i, tt.name, tt.doc, stmt.Name.Value, stmt.Doc)
+		}
+	}
+
+	p = New(lexer.New("let a = 1\n/* open", "testDocComments"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 1 || errors[0].Code != CodeOpenComment || errors[0].RowNumber != 2 {
+		t.Errorf("expected an open comment error on line 2. got=%v", ParseErrors(errors))
+	}
+}
+
+func TestDestructuringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = c", "let [a, b] = c;"},
+		{"let [a, [b], ...rest] = c", "let [a, [b], ...rest] = c;"},
+		{"let [x = 1 + 2, y = x] = c", "let [x = (1 + 2), y = x] = c;"},
+		{"let {name, age: years = 0, address: {city}} = p", "let {name, age: years = 0, address: {city}} = p;"},
+		{"let {\"first name\": first} = p", "let {first name: first} = p;"},
+		{"let [\n  a,\n  b,\n] = c", "let [a, b] = c;"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testDestructuring"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	p := New(lexer.New("fn(a, [b, c], {d}) { b }", "testDestructuring"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Parameters) != 3 || len(fn.Patterns) != 3 {
+		t.Fatalf("wrong parameters. got=%d patterns=%d", len(fn.Parameters), len(fn.Patterns))
+	}
+	if fn.Patterns[0] != nil || fn.Parameters[1].Value != "[b, c]" || fn.Patterns[2].ToString() != "{d}" {
+		t.Errorf("wrong patterns. got=%s", fn.ToString())
+	}
+	if clone := ast.Clone(fn).(*ast.FunctionLiteral); clone.Patterns[2] == fn.Patterns[2] || clone.Patterns[2].ToString() != "{d}" {
+		t.Errorf("patterns not cloned. got=%s", clone.ToString())
+	}
+
+	errors := []string{
+		"let [a, ...b, c] = d",
+		"let [1] = d",
+		"let {a: 1} = d",
+		"let {[a]} = d",
+		"let [a] d",
+		"fn([a, 1]) { a }",
+		"let m = macro([a]) { a }",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testDestructuring"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 1 + 2, ...rest) { a }", "fn(a, b = (1 + 2), ...rest) {a}"},
+		{"fn([a, b] = [1, 2]) { a }", "fn([a, b] = [1, 2]) {a}"},
+		{"fn(...xs) { xs }", "fn(...xs) {xs}"},
+		{"f(1, ...xs, b: 2, c: g(d: 3))", "f(1, ...xs, b: 2, c: g(d: 3))"},
+		{"[1, ...xs, ...[2]]", "[1, ...xs, ...[2]]"},
+		{"let h = {...a}", "let h = {...a};"},
+		{"f(\n  a,\n  b: 1,\n)", "f(a, b: 1)"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testArguments"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	p := New(lexer.New("fn(a, b = 2, ...c) { a }", "testArguments"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.Parameters) != 2 || len(fn.Defaults) != 2 || fn.Defaults[0] != nil || fn.Rest.Value != "c" {
+		t.Fatalf("wrong parameters. got=%s", fn.ToString())
+	}
+	if clone := ast.Clone(fn).(*ast.FunctionLiteral); clone.Defaults[1] == fn.Defaults[1] || clone.Rest == fn.Rest {
+		t.Errorf("defaults not cloned. got=%s", clone.ToString())
+	}
+
+	errors := []string{
+		"fn(...a, b) { a }",
+		"fn(...) { a }",
+		"f(a: 1, 2)",
+		"f(a: 1, ...b)",
+		"let m = macro(a = 1) { a }",
+		"let m = macro(...a) { a }",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testArguments"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestMatchParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match x { 0 => 1, _ => 2 }", "match x {0 => {1}, _ => {2}}"},
+		{"match x {\n  -1 => 'a'\n  [a, ...b] if a > 1 => { a }\n}", "match x {-1 => {a}, [a, ...b] if (a > 1) => {a}}"},
+		{"match x { {'k': String} => 1, n => n }", "match x {{k: String} => {1}, n => {n}}"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testMatch"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	p := New(lexer.New("match x { [a, Int, 1, _] => a }", "testMatch"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+	arm := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression).Arms[0]
+	if bindings := ast.MatchBindings(arm.Pattern); len(bindings) != 2 || bindings[0].Value != "a" || bindings[1].Value != "Int" {
+		t.Errorf("wrong bindings. got=%v", bindings)
+	}
+	if values := ast.MatchValues(arm.Pattern); len(values) != 2 {
+		t.Errorf("wrong values. got=%v", values)
+	}
+
+	errors := []string{
+		"match x { a = 1 => a }",
+		"match x { f(1) => 1 }",
+		"match x { 1 2 }",
+		"match x { 1 => }",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testMatch"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestConditionalParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a ? b : c", "(a ? b : c)"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"x = a or b ? 1 + 2 : 3", "(x = ((a or b) ? (1 + 2) : 3))"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{"a?.b.c", "((a ?. b) . c)"},
+		{"a?.[0]?.(1)", "a?.[0]?.(1)"},
+		{"-a?.b + 1", "(-(a ?. b) + 1)"},
+		{"null?(x) ? 1 : 2", "(null?(x) ? 1 : 2)"},
+		{"h[c ? 1 : 2]", "h[(c ? 1 : 2)]"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testConditional"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	// The chain of a ?. ends at the first operator that is not one of its links
+	p := New(lexer.New("a?.b.c(d) + e.f", "testConditional"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+	sum := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	chain, ok := sum.Left.(*ast.OptionalChain)
+	if !ok {
+		t.Fatalf("left is not an optional chain. got=%T", sum.Left)
+	}
+	if _, ok := chain.Expression.(*ast.CallExpression); !ok {
+		t.Errorf("the chain does not end with the call. got=%s", chain.Expression.ToString())
+	}
+	if _, ok := sum.Right.(*ast.OptionalChain); ok {
+		t.Errorf("a chain without ?. is wrapped. got=%s", sum.Right.ToString())
+	}
+
+	errors := []string{
+		"a ? b",
+		"a ? : b",
+		"a ?? ",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testConditional"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestArrowAndPipeParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x => x * 2", "fn(x) {(x * 2)}"},
+		{"(a, b = 1, ...c) => { a }", "fn(a, b = 1, ...c) {a}"},
+		{"() => 1", "fn() {1}"},
+		{"([a, b]) => a", "fn([a, b]) {a}"},
+		{"f(x => x, (a) => a)", "f(fn(x) {x}, fn(a) {a})"},
+		{"(a + b) * c", "((a + b) * c)"},
+		{"xs |> filter(f) |> map(g)", "map(filter(xs, f), g)"},
+		{"x |> f", "f(x)"},
+		{"a + b |> f == c", "(f((a + b)) == c)"},
+		{"x |> m.f(1)", "(m . f)(x, 1)"},
+		{"x |> f(b: 2)", "f(x, b: 2)"},
+		{"match x { n if ok => n }", "match x {n if ok => {n}}"},
+		{"match x { n if (a or b) => n }", "match x {n if (a or b) => {n}}"},
+		{"match x { n if f(y => y) => n }", "match x {n if f(fn(y) {y}) => {n}}"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testArrow"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	p := New(lexer.New("let double = x => x * 2", "testArrow"))
+	program := p.ParseProgram()
+	p.CheckParserErrors(t)
+	if fn, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral); !ok || fn.Name != "double" {
+		t.Errorf("the arrow function is not a named function literal. got=%s", program.ToString())
+	}
+
+	errors := []string{
+		"(1) => 1",
+		"(a, b) =>",
+		"x |>",
+		"(a, b)",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testArrow"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+// Test the parsing of generator functions and yields
+func TestGeneratorParsing(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		generator bool
+	}{
+		{"fn*() { 1 }", "fn*() {1}", true},
+		{"fn() { yield 1 }", "fn*() {(yield 1)}", true},
+		{"fn() { let x = yield\nx }", "fn*() {let x = yield;x}", true},
+		{"fn() { f(yield, yield a + 1) }", "fn*() {f(yield, (yield (a + 1)))}", true},
+		{"x => yield x", "fn*(x) {(yield x)}", true},
+		{"fn() { fn() { yield 1 } }", "fn() {fn*() {(yield 1)}}", false},
+		{"fn() { 1 }", "fn() {1}", false},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testGenerator"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+		fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if fn.Generator != tt.generator {
+			t.Errorf("%q generator wrong. want=%t, got=%t", tt.input, tt.generator, fn.Generator)
+		}
+	}
+
+	errors := []string{
+		"yield 1",
+		"macro() { yield 1 }",
+		"fn*",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testGenerator"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+// Test the parsing of select expressions
+func TestSelectParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"select { v = c.recv() => v }", "select {v = c.recv() => {v}}"},
+		{"select {\nc.recv() => 1\nout.send(a + 1) => { 2 }\n_ => 3\n}", "select {c.recv() => {1}, out.send((a + 1)) => {2}, _ => {3}}"},
+		{"select { chans[0].recv() => 1, _ => null }", "select {chans[0].recv() => {1}, _ => {null}}"},
+		{"select {}", "select {}"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testSelect"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	errors := []string{
+		"select { c.close() => 1 }",
+		"select { v = c.send(1) => v }",
+		"select { c.recv(1) => 1 }",
+		"select { c => 1 }",
+		"select { c.recv() }",
+		"select { c.recv() => 1 d.recv() => 2 }",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testSelect"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
+func TestAsyncParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"async fn(x) { await x }", "async fn(x) {(await x)}"},
+		{"async x => x + 1", "async fn(x) {(x + 1)}"},
+		{"async (a, b) => { a }", "async fn(a, b) {a}"},
+		{"await p.then(f) + 1", "((await (p . then)(f)) + 1)"},
+		{"await await f()", "(await (await f()))"},
+		{"-await x", "-(await x)"},
+	}
+
+	if !options.NicerToString {
+		return
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, "testAsync"))
+		program := p.ParseProgram()
+		p.CheckParserErrors(t)
+		if program.ToString() != tt.expected {
+			t.Errorf("%q parsed wrong. want=%q, got=%q", tt.input, tt.expected, program.ToString())
+		}
+	}
+
+	errors := []string{
+		"async 1",
+		"async x",
+		"async (a)",
+		"async fn*() { yield 1 }",
+		"async fn() { yield 1 }",
+		"await",
+	}
+	for _, input := range errors {
+		p := New(lexer.New(input, "testAsync"))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected an error for %q", input)
+		}
+	}
+}
+
 // Test the parsing of the let statements
 func TestLetStatements(t *testing.T) {
 	input := `let x = 5
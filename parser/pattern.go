@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+	"fmt"
+)
+
+// ParsePattern parses the target of a let or of a parameter starting at the current token,
+// an identifier, an array pattern [a, b = 1, ...rest] or a hash pattern {a, b: c = 1}
+func (p *Parser) ParsePattern() ast.Expression {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+	if p.matching {
+		return p.parseLiteralPattern()
+	}
+
+	message := fmt.Sprintf("expected an identifier or a pattern, got %s instead", p.currentToken.Type)
+	p.GenerateErrorForToken(CodeInvalidPattern, message, &p.currentToken)
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.currentToken}
+
+	for p.RemoveNewLines(); !p.PeekTokenIs(token.RBRACKET); p.RemoveNewLines() {
+		p.NextToken()
+
+		if p.CurrentTokenIs(token.ELLIPSIS) {
+			if !p.ExpectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+			p.RemoveNewLines()
+			if p.PeekTokenIs(token.COMMA) {
+				p.GenerateErrorForToken(CodeInvalidPattern, "the ...rest element must be the last of the pattern", &p.peekToken)
+				return nil
+			}
+			break
+		}
+
+		element := p.parsePatternElement("")
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		p.RemoveNewLines()
+		if !p.PeekTokenIs(token.RBRACKET) && !p.ExpectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.currentToken}
+
+	for p.RemoveNewLines(); !p.PeekTokenIs(token.RBRACE); p.RemoveNewLines() {
+		p.NextToken()
+
+		var element *ast.PatternElement
+		switch {
+		case p.CurrentTokenIs(token.IDENT) && !p.PeekTokenIs(token.COLON):
+			// {name} is short for {name: name}
+			element = p.parsePatternElement(p.currentToken.Literal)
+		case p.CurrentTokenIs(token.IDENT), p.CurrentTokenIs(token.STRING):
+			key := p.currentToken.Literal
+			if !p.ExpectPeek(token.COLON) {
+				return nil
+			}
+			p.NextToken()
+			element = p.parsePatternElement(key)
+		default:
+			message := fmt.Sprintf("expected a key in the hash pattern, got %s instead", p.currentToken.Type)
+			p.GenerateErrorForToken(CodeInvalidPattern, message, &p.currentToken)
+			return nil
+		}
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		p.RemoveNewLines()
+		if !p.PeekTokenIs(token.RBRACE) && !p.ExpectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+// parsePatternElement parses a target of a pattern and its default value
+func (p *Parser) parsePatternElement(key string) *ast.PatternElement {
+	target := p.ParsePattern()
+	if target == nil {
+		return nil
+	}
+	element := &ast.PatternElement{Key: key, Target: target}
+
+	if p.PeekTokenIs(token.ASSIGN) {
+		if p.matching {
+			p.GenerateErrorForToken(CodeInvalidPattern, "the patterns of a match cannot have defaults", &p.peekToken)
+			return nil
+		}
+		p.NextToken()
+		p.NextToken()
+		// The precedence stops before another =, [a = b = 1] is not an assignment
+		element.Default = p.ParseExpression(ASSIGN)
+		if element.Default == nil {
+			return nil
+		}
+	}
+	return element
+}
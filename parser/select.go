@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"Monkey/ast"
+	"Monkey/token"
+)
+
+// ParseSelectExpression parses select { name = channel.recv() => body, channel.send(value) => body, _ => body },
+// the arms are separated by commas or line breaks like the arms of a match
+func (p *Parser) ParseSelectExpression() ast.Expression {
+	exp := &ast.SelectExpression{Token: p.currentToken}
+
+	p.RemoveNewLines()
+	if !p.ExpectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for p.RemoveNewLines(); !p.PeekTokenIs(token.RBRACE); p.RemoveNewLines() {
+		p.NextToken()
+
+		arm := p.parseSelectArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if p.PeekTokenIs(token.COMMA) {
+			p.NextToken()
+		} else if !p.IsPeekEndOfLine() && !p.PeekTokenIs(token.RBRACE) {
+			p.PeekError(token.COMMA)
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.RBRACE) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseSelectArm() *ast.SelectArm {
+	arm := &ast.SelectArm{}
+
+	if !p.CurrentTokenIs(token.IDENT) || p.currentToken.Literal != ast.Wildcard || !p.PeekTokenIs(token.FAT_ARROW) {
+		if p.CurrentTokenIs(token.IDENT) && p.PeekTokenIs(token.ASSIGN) {
+			arm.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+			p.NextToken()
+			p.NextToken()
+		}
+
+		start := p.currentToken
+		operation := p.ParseExpression(LOWEST)
+		if operation == nil {
+			return nil
+		}
+		if !selectOperation(arm, operation) {
+			p.GenerateErrorForToken(CodeInvalidSelect, "expected channel.recv(), name = channel.recv(), channel.send(value) or _ in a select arm", &start)
+			return nil
+		}
+	}
+
+	if !p.ExpectPeek(token.FAT_ARROW) {
+		return nil
+	}
+	arm.Token = p.currentToken
+
+	arm.Body = p.parseArmBody()
+	if arm.Body == nil {
+		return nil
+	}
+	return arm
+}
+
+// selectOperation fills the channel and the value of an arm from its channel.recv() or channel.send(value)
+func selectOperation(arm *ast.SelectArm, operation ast.Expression) bool {
+	call, ok := operation.(*ast.CallExpression)
+	if !ok || call.Optional {
+		return false
+	}
+	for _, arg := range call.Arguments {
+		switch arg.(type) {
+		case *ast.SpreadElement, *ast.NamedArgument:
+			return false
+		}
+	}
+	member, ok := call.Function.(*ast.InfixExpression)
+	if !ok || member.Operator != token.DOT {
+		return false
+	}
+	method, ok := member.Right.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case method.Value == "recv" && len(call.Arguments) == 0:
+		arm.Channel = member.Left
+	case method.Value == "send" && len(call.Arguments) == 1 && arm.Name == nil:
+		arm.Channel, arm.Value, arm.Send = member.Left, call.Arguments[0], true
+	default:
+		return false
+	}
+	return true
+}
@@ -1,9 +1,8 @@
 package repl
 
 import (
-	"Monkey/evaluator"
 	"Monkey/lexer"
-	"Monkey/object"
+	"Monkey/monkey"
 	"Monkey/options"
 	"Monkey/parser"
 	"Monkey/tmp"
@@ -16,25 +15,17 @@ import (
 // Console Prompt header
 const PROMPT = ">> "
 
-// Print parsing errors
-func PrintParserErrors(out io.Writer, errors []*parser.ParseError) {
-	for _, err := range errors {
-		message := fmt.Sprintf("On %d:%d, %s, in %q",
-			err.RowNumber, err.ColumnNumber, err.Message, err.Filename)
-		io.WriteString(out, message+"\n")
-	}
-}
-
 // Start the REPL by repeating asking for input
-func Start(in io.Reader, out io.Writer) {
-
-	// REPL Environment
-	env := object.NewEnvironment()
-
-	// Link std
-	err := evaluator.LinkSTD(env)
+func Start(in io.Reader, out io.Writer, engine string) {
+
+	// REPL Interpreter, this links std
+	interpreter, err := monkey.New(monkey.Options{
+		Engine: engine,
+		Debug:  true,
+		Stdout: out,
+	})
 	if err != nil {
-		fmt.Printf("Failed to compile the standard library\n")
+		fmt.Printf("Failed to create the interpreter: %s\n", err)
 		return
 	}
 
@@ -54,8 +45,8 @@ func Start(in io.Reader, out io.Writer) {
 		// Retrieve Buffer Text
 		line := scanner.Text()
 
-		if strings.Contains(line, "--") {
-			ParseOptions(out, line)
+		if strings.HasPrefix(line, "--") {
+			ParseOptions(out, line, interpreter)
 			continue
 		}
 
@@ -67,8 +58,7 @@ func Start(in io.Reader, out io.Writer) {
 		// Parse Program
 		program := p.ParseProgram()
 		if p.HasError() {
-			// we've done it when calling p.ParseProgram
-			//PrintParserErrors(out, p.Errors())
+			parser.RenderText(out, p.Errors(), line)
 			continue
 		}
 
@@ -76,8 +66,8 @@ func Start(in io.Reader, out io.Writer) {
 		io.WriteString(out, program.ToString())
 		io.WriteString(out, "\n")
 
-		// Eval it
-		evaluated := evaluator.Eval(program, env)
+		// Eval it, a fatal error is printed as the evaluated value
+		evaluated, _ := interpreter.EvalProgram(program)
 
 		// Print the parsed program out
 		if evaluated != nil {
@@ -88,12 +78,12 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func ParseOptions(out io.Writer, line string) {
+func ParseOptions(out io.Writer, line string, interpreter *monkey.Interpreter) {
 	switch line {
 	case "--list":
-		fmt.Println("CD:", tmp.CurrentDirectory)
-		fmt.Println("MKYROOT:", tmp.ExeDirectory)
-		fmt.Println("STDDIR:", tmp.STDDirectory)
+		fmt.Fprintln(out, "CD:", tmp.CurrentDirectory)
+		fmt.Fprintln(out, "MKYROOT:", tmp.ExeDirectory)
+		fmt.Fprintln(out, "STDDIR:", tmp.STDDirectory)
 
 	case "--on nicer":
 		options.NicerToString = true
@@ -103,10 +93,10 @@ func ParseOptions(out io.Writer, line string) {
 		io.WriteString(out, "Disabled Nicer ToString")
 
 	case "--on fatalErrors":
-		options.FatalErrors = true
+		interpreter.Options().FatalErrors = true
 		io.WriteString(out, "Enabled FatalErrors")
 	case "--off fatalErrors":
-		options.FatalErrors = false
+		interpreter.Options().FatalErrors = false
 		io.WriteString(out, "Disabled FatalErrors")
 
 	default:
@@ -5,23 +5,41 @@ import (
 	"Monkey/lexer"
 	"Monkey/options"
 	"Monkey/parser"
-	"Monkey/tmp"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 )
 
-// Singleton
-var runner = &Runner{}
-
+// Runner reads and parses the files of a single interpreter
 type Runner struct {
+	// the files that are currently being compiled, used for circular dependencies
 	files []string
+
+	// the dir of the file that it is processing
+	Directory string
+
+	// library search paths, the first one is the std directory
+	Paths []string
+
+	Options *options.Options
+
+	// where the warnings are written to
+	Out io.Writer
 }
 
-func GetInstance() *Runner {
-	return runner
+// New creates a runner starting in directory and looking up libraries in paths
+func New(directory string, paths []string, opts *options.Options, out io.Writer) *Runner {
+	return &Runner{
+		files:     []string{},
+		Directory: directory,
+		Paths:     paths,
+		Options:   opts,
+		Out:       out,
+	}
 }
 
 func (r *Runner) CompileAbs(filename string) (*ast.Program, error) {
@@ -31,10 +49,10 @@ func (r *Runner) CompileAbs(filename string) (*ast.Program, error) {
 	if err != nil {
 		// graceful return
 		if ce, ok := err.(CError); ok {
-			if options.Debug {
-				fmt.Println("Circular Dependency Warning")
+			if r.Options.Debug {
+				fmt.Fprintln(r.Out, "Circular Dependency Warning")
 				for _, file := range ce.files {
-					fmt.Printf("  %s\n", file)
+					fmt.Fprintf(r.Out, "  %s\n", file)
 				}
 			}
 			return &ast.Program{
@@ -44,48 +62,76 @@ func (r *Runner) CompileAbs(filename string) (*ast.Program, error) {
 
 		return nil, err
 	}
-	return r.ParseProgram(string(content), filename), nil
+	return r.ParseProgram(string(content), filename)
 }
 
-func (r *Runner) Pop(filename string) {
-	var index int
-	for i, file := range r.files {
-		if filename == file {
-			index = i
+// Compiling returns whether filename is on the file stack
+func (r *Runner) Compiling(filename string) bool {
+	for _, file := range r.files {
+		if file == filename {
+			return true
 		}
 	}
+	return false
+}
 
-	end := len(r.files) - index - 1
-	for i := len(r.files) - 1; i > end; i-- {
-		r.files = remove(r.files, len(r.files)-1)
+// Pop removes filename and every file compiled after it from the file stack
+func (r *Runner) Pop(filename string) {
+	for i := len(r.files) - 1; i >= 0; i-- {
+		if r.files[i] == filename {
+			r.files = r.files[:i]
+			return
+		}
 	}
-	fmt.Printf("")
-}
-func remove(slice []string, s int) []string {
-	return append(slice[:s], slice[s+1:]...)
 }
 
+// ToAbsolute resolves an include location and moves the runner into its directory
 func (r *Runner) ToAbsolute(location string) string {
 	var filename string
 	// if is a std include
 	if !strings.HasSuffix(location, ".mky") {
-		filename = filepath.Join(tmp.STDDirectory, filepath.Base(location), location+".mky")
-		tmp.SetAbsoluteDirectory(filepath.Dir(filename))
+		filename = r.lookupLibrary(location)
+	} else if filepath.IsAbs(location) {
+		filename = filepath.Clean(location)
 	} else {
 		re := regexp.MustCompile("[/\\\\]")
 		folders := re.Split(location, -1)
-		dirs := append([]string{tmp.CurrentProcessingFileDirectory}, folders...)
+		dirs := append([]string{r.Directory}, folders...)
 		filename = filepath.Join(dirs...)
-		tmp.SetAbsoluteDirectory(filepath.Dir(filename))
 	}
+	r.Directory = filepath.Dir(filename)
 
 	return filename
 }
 
-func (r *Runner) ParseProgram(content string, filename string) *ast.Program {
+// lookupLibrary finds a library in the search paths, defaulting to the first one
+func (r *Runner) lookupLibrary(location string) string {
+	var first string
+	for i, dir := range r.Paths {
+		filename := filepath.Join(dir, filepath.Base(location), location+".mky")
+		if i == 0 {
+			first = filename
+		}
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return first
+}
+
+// ParseProgram parses a source, printing its parse errors to Out
+func (r *Runner) ParseProgram(content string, filename string) (*ast.Program, error) {
 	l := lexer.New(content, filename)
 	p := parser.New(l)
-	return p.ParseProgram()
+	program := p.ParseProgram()
+	if p.HasError() {
+		parser.RenderText(r.Out, p.Diagnostics(), content)
+		return nil, parser.ParseErrors(p.Errors())
+	}
+	if r.Options.Debug {
+		parser.RenderText(r.Out, p.Diagnostics(), content)
+	}
+	return program, nil
 }
 
 type CError struct {
@@ -103,17 +149,15 @@ func (c CError) Error() string {
 }
 
 func (r *Runner) ReadFile(filename string) ([]byte, error) {
-	for _, file := range r.files {
-		if file == filename {
-			return nil, NewCError(append(r.files, filename))
-		}
+	if r.Compiling(filename) {
+		return nil, NewCError(append(r.files, filename))
 	}
 
 	r.files = append(r.files, filename)
 
 	content, err := ioutil.ReadFile(filename)
 	if err != nil {
-		fmt.Printf("Cannot read file %q\n", filename)
+		fmt.Fprintf(r.Out, "Cannot read file %q\n", filename)
 		return []byte{}, err
 	}
 
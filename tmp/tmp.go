@@ -6,8 +6,8 @@ import (
 	"path"
 )
 
-// the dir of te file that it is processing
-var CurrentProcessingFileDirectory string
+// These are the process wide defaults, they are computed once on startup
+// and never modified. Per run directories are stored in runner.Runner
 
 // Command Directory
 var CurrentDirectory string
@@ -18,10 +18,6 @@ var ExeDirectory string
 // lib directory
 var STDDirectory string
 
-func SetAbsoluteDirectory(absolute string) {
-	CurrentProcessingFileDirectory = absolute
-}
-
 func init() {
 	CD, err := os.Getwd()
 	if err != nil {
@@ -38,6 +34,4 @@ func init() {
 	ExeDirectory = EXE
 
 	STDDirectory = path.Join(ExeDirectory, "lib")
-
-	CurrentProcessingFileDirectory = CD
 }
@@ -9,6 +9,10 @@ const (
 	// Identifiers + literals
 	IDENT = "IDENT"
 	INT   = "INT"
+	FLOAT = "FLOAT"
+
+	BIGINT  = "BIGINT"  // 123n
+	DECIMAL = "DECIMAL" // 1.10d
 
 	// Operators
 	ASSIGN   = "="
@@ -19,6 +23,43 @@ const (
 	SLASH    = "/"
 	PERCENT  = "%"
 	HASH     = "#"
+	POWER    = "**"
+
+	// Bitwise
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	// Nullish, x ?? y is y only when x is null
+	NULLISH = "??"
+
+	// Conditionals, c ? x : y and the optional chains x?.y, x?.[k] and f?.()
+	QUESTION     = "?"
+	OPTIONAL_DOT = "?."
+
+	// Pipeline, x |> f(y) is f(x, y)
+	PIPE = "|>"
+
+	// Compound assignments, x op= y is x = x op y with x evaluated once
+	PLUS_ASSIGN        = "+="
+	MINUS_ASSIGN       = "-="
+	ASTERISK_ASSIGN    = "*="
+	SLASH_ASSIGN       = "/="
+	PERCENT_ASSIGN     = "%="
+	NULLISH_ASSIGN     = "??="
+	POWER_ASSIGN       = "**="
+	BIT_AND_ASSIGN     = "&="
+	BIT_OR_ASSIGN      = "|="
+	BIT_XOR_ASSIGN     = "^="
+	SHIFT_LEFT_ASSIGN  = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
+
+	// Increment and decrement, x++ is x += 1 returning the value x had and ++x is x += 1
+	INCREMENT = "++"
+	DECREMENT = "--"
 
 	// Compare
 	LT     = "<"
@@ -46,6 +87,12 @@ const (
 	// Modules
 	DOT = "."
 
+	// Destructuring, let [a, ...rest] = value
+	ELLIPSIS = "..."
+
+	// Match arms, pattern => value
+	FAT_ARROW = "=>"
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -61,12 +108,35 @@ const (
 	OR  = "or"
 	XOR = "xor"
 
-	STRING  = "STRING"
-	NEWLINE = "NEWLINE"
+	STRING   = "STRING"
+	TEMPLATE = "TEMPLATE" // "${interpolated}"
+	NEWLINE  = "NEWLINE"
+
+	// Trivia, only produced by lexers keeping it
+	COMMENT    = "COMMENT"
+	WHITESPACE = "WHITESPACE"
 
 	MACRO = "MACRO"
 
 	MODULE = "MODULE"
+
+	TRY     = "TRY"
+	CATCH   = "CATCH"
+	FINALLY = "FINALLY"
+	THROW   = "THROW"
+
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	CONTINUE = "CONTINUE"
+
+	MATCH = "MATCH"
+
+	YIELD = "YIELD"
+
+	SELECT = "SELECT"
+
+	ASYNC = "ASYNC"
+	AWAIT = "AWAIT"
 )
 
 // The Type of a Token
@@ -80,6 +150,9 @@ type Token struct {
 	RowNumber    int64
 	ColumnNumber int64
 	Filename     string
+
+	// The /// doc comment on the lines right above the token, without the slashes
+	Doc string
 }
 
 // NewToken creates a new token
@@ -128,6 +201,46 @@ var keywords = map[string]TokenType{
 	"macro": MACRO,
 
 	"module": MODULE,
+
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"continue": CONTINUE,
+
+	"match": MATCH,
+
+	"yield": YIELD,
+
+	"select": SELECT,
+
+	"async": ASYNC,
+	"await": AWAIT,
+}
+
+// The operator of every compound assignment, += is +
+var CompoundAssignments = map[TokenType]TokenType{
+	PLUS_ASSIGN:        PLUS,
+	MINUS_ASSIGN:       MINUS,
+	ASTERISK_ASSIGN:    ASTERISK,
+	SLASH_ASSIGN:       SLASH,
+	PERCENT_ASSIGN:     PERCENT,
+	NULLISH_ASSIGN:     NULLISH,
+	POWER_ASSIGN:       POWER,
+	BIT_AND_ASSIGN:     BIT_AND,
+	BIT_OR_ASSIGN:      BIT_OR,
+	BIT_XOR_ASSIGN:     BIT_XOR,
+	SHIFT_LEFT_ASSIGN:  SHIFT_LEFT,
+	SHIFT_RIGHT_ASSIGN: SHIFT_RIGHT,
+}
+
+// The operator of increment and decrement, ++ is +
+var Updates = map[TokenType]TokenType{
+	INCREMENT: PLUS,
+	DECREMENT: MINUS,
 }
 
 // Return a TokenType from a plain string
@@ -0,0 +1,1018 @@
+package vm
+
+import (
+	"Monkey/ast"
+	"Monkey/code"
+	"Monkey/compiler"
+	"Monkey/evaluator"
+	"Monkey/object"
+	"Monkey/token"
+)
+
+// The initial size of the stack, it grows when needed
+const StackSize = 2048
+
+// The initial size of the stack of a generator
+const GeneratorStackSize = 64
+
+// The initial size of the stack of a task
+const TaskStackSize = 256
+
+// The maximum call depth
+const MaxFrames = 1 << 16
+
+var (
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+	NULL  = evaluator.NULL
+	BREAK = evaluator.BREAK
+)
+
+// Frame is a call of a closure
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+
+	// The receiver of the call, nil outside of prototype functions
+	this object.Object
+
+	// Set when the frame runs the body of a module
+	module *object.Module
+
+	// The depth of the call stack of the runtime before the call
+	depth int
+}
+
+// VM runs bytecode, one VM belongs to one interpreter and is not safe for concurrent use
+type VM struct {
+	state   *compiler.State
+	runtime *object.Runtime
+
+	stack []object.Object
+	sp    int // Always points to the next free slot, the top of the stack is stack[sp-1]
+
+	// Frames are reused between calls, so pointers to them stay valid
+	frames      []*Frame
+	framesIndex int
+
+	// The try blocks being run, innermost last
+	handlers []handler
+
+	// Suspends the generator run by this vm, nil for the vm of a program
+	yield object.Yield
+}
+
+// handler is a try block, started in a frame with sp values on the stack
+type handler struct {
+	framesIndex int
+	sp          int
+	catch       int // the position of the catch block
+}
+
+// New creates a vm
+func New() *VM {
+	return &VM{
+		state: compiler.NewState(),
+		stack: make([]object.Object, StackSize),
+	}
+}
+
+// Run compiles and runs a program in env, implementing object.Engine
+func (vm *VM) Run(program *ast.Program, env *object.Environment) object.Object {
+	// a task runs the modules it imports on its own vm
+	if machine, ok := evaluator.RuntimeOf(env).Machine.(*VM); ok && machine != vm {
+		return machine.Run(program, env)
+	}
+
+	c := compiler.New(vm.state)
+	if err := c.Compile(program); err != nil {
+		var data *token.TokenData
+		if compileErr, ok := err.(*compiler.Error); ok {
+			data = compileErr.Token.ToTokenData()
+		}
+		return evaluator.NewFatalError(data, "compile error: %s", err.Error())
+	}
+
+	vm.runtime = evaluator.RuntimeOf(env)
+	if vm.runtime.Machine == nil {
+		vm.runtime.Machine = vm
+	}
+	bytecode := c.Bytecode()
+	main := &object.Closure{
+		Fn: &object.CompiledFunction{
+			Instructions: bytecode.Instructions,
+			Tokens:       bytecode.Tokens,
+		},
+		Env:     env,
+		Machine: vm,
+	}
+
+	return vm.callClosure(token.Token{}, main, nil, nil, nil, false)
+}
+
+// CallClosure runs a closure to completion, implementing object.Machine
+func (vm *VM) CallClosure(t token.Token, cl *object.Closure, this object.Object, args []object.Object, named []object.NamedArgument) object.Object {
+	return vm.callClosure(t, cl, this, args, named, true)
+}
+
+// Fork creates a vm for a task, sharing the state of this one, implementing object.Machine
+func (vm *VM) Fork() object.Machine {
+	return &VM{
+		state:   vm.state,
+		runtime: vm.runtime,
+		stack:   make([]object.Object, TaskStackSize),
+	}
+}
+
+func (vm *VM) callClosure(t token.Token, cl *object.Closure, this object.Object, args []object.Object, named []object.NamedArgument, traced bool) object.Object {
+	if cl.Fn.Generator {
+		return vm.generator(t, cl, this, args, named)
+	}
+	if cl.Fn.Async {
+		return vm.async(t, cl, this, args, named, traced)
+	}
+	return vm.invoke(t, cl, this, args, named, traced)
+}
+
+// invoke runs a closure to completion
+func (vm *VM) invoke(t token.Token, cl *object.Closure, this object.Object, args []object.Object, named []object.NamedArgument, traced bool) object.Object {
+	sp, framesIndex := vm.sp, vm.framesIndex
+
+	vm.push(cl)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	if err := vm.pushFrame(t, cl, len(args), named, this, traced); err != nil {
+		vm.drop(sp)
+		return err
+	}
+
+	result := vm.run(framesIndex)
+	if evaluator.CheckError(result) {
+		vm.drop(sp)
+		vm.framesIndex = framesIndex
+	}
+	return result
+}
+
+// generator calls a generator function, the arguments are bound by the call
+// and the closure runs from the first next() in a vm of its own, which shares the state of this one
+func (vm *VM) generator(t token.Token, cl *object.Closure, this object.Object, args []object.Object, named []object.NamedArgument) object.Object {
+	g := &VM{
+		state:   vm.state,
+		runtime: vm.runtime,
+		stack:   make([]object.Object, GeneratorStackSize),
+	}
+	g.push(cl)
+	for _, arg := range args {
+		g.push(arg)
+	}
+	if err := g.pushFrame(t, cl, len(args), named, this, false); err != nil {
+		return err
+	}
+
+	return object.NewGenerator(vm.runtime, cl.Fn.Name, func(yield object.Yield) object.Object {
+		g.yield = yield
+		g.resumed()
+		return g.run(0)
+	})
+}
+
+// async calls an async function, the closure runs in a task on the vm of the task,
+// which shares the state of this one
+func (vm *VM) async(t token.Token, cl *object.Closure, this object.Object, args []object.Object, named []object.NamedArgument, traced bool) object.Object {
+	return evaluator.Async(t, cl.Fn.Name, cl.Env, func() object.Object {
+		return vm.runtime.Machine.(*VM).invoke(t, cl, this, args, named, traced)
+	})
+}
+
+// resumed places the frame of the generator above the frame pushed by the next() resuming it,
+// the call stack of the runtime can be deeper or shallower than at the previous resume
+func (vm *VM) resumed() {
+	vm.frames[0].depth = len(vm.runtime.CallStack)
+}
+
+// pushFrame starts a call of cl, whose positional arguments are on top of the stack,
+// traced calls are recorded in the call stack of the runtime
+func (vm *VM) pushFrame(t token.Token, cl *object.Closure, numArgs int, named []object.NamedArgument, this object.Object, traced bool) *object.Error {
+	fn := cl.Fn
+
+	if vm.framesIndex == len(vm.frames) {
+		if len(vm.frames) >= MaxFrames {
+			return evaluator.NewFatalError(t.ToTokenData(), "stack overflow")
+		}
+		vm.frames = append(vm.frames, &Frame{})
+	}
+
+	// Named arguments, rest parameters and strict arity bind the arguments like the evaluator
+	if named != nil || fn.Rest != nil || vm.runtime.Options.StrictArity {
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		values, err := evaluator.BindArguments(t, fn, args, named, vm.runtime.Options.StrictArity)
+		if err != nil {
+			return err
+		}
+		vm.drop(vm.sp - numArgs)
+		for _, value := range values {
+			vm.push(value)
+		}
+		numArgs = len(values)
+	}
+
+	// Missing arguments are null and extra arguments are dropped
+	for ; numArgs < fn.NumParameters; numArgs++ {
+		vm.push(NULL)
+	}
+	vm.drop(vm.sp - (numArgs - fn.NumParameters))
+
+	basePointer := vm.sp - fn.NumParameters
+	for vm.sp < basePointer+fn.NumLocals {
+		vm.push(nil)
+	}
+
+	depth := len(vm.runtime.CallStack)
+	if traced {
+		vm.runtime.PushFrame(object.FunctionName(fn.Name), token.TokenData{
+			Filename:     t.Filename,
+			RowNumber:    t.RowNumber,
+			ColumnNumber: t.ColumnNumber,
+		})
+	}
+
+	*vm.frames[vm.framesIndex] = Frame{
+		cl:          cl,
+		basePointer: basePointer,
+		this:        this,
+		depth:       depth,
+	}
+	vm.framesIndex++
+	return nil
+}
+
+// run executes instructions until the frame at depth stopAt returns,
+// fatal errors continue in the innermost try block or unwind the frames started by this run
+func (vm *VM) run(stopAt int) object.Object {
+	for {
+		result := vm.execute(stopAt)
+		err, ok := result.(*object.Error)
+		if !ok || !err.Fatal {
+			return result
+		}
+		if !vm.catch(err, stopAt) {
+			vm.drop(vm.frames[stopAt].basePointer - 1)
+			vm.unwind(stopAt, err)
+			return err
+		}
+	}
+}
+
+// execute runs instructions until the frame at depth stopAt returns or a fatal error happens
+func (vm *VM) execute(stopAt int) object.Object {
+	frame := vm.frames[vm.framesIndex-1]
+	ins := frame.cl.Fn.Instructions
+
+	for {
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		switch op {
+		case code.OpConstant:
+			index := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+
+			constant := vm.state.Constants[index]
+			// numbers can be changed in place by prototypes, every evaluation needs its own
+			switch number := constant.(type) {
+			case *object.Integer:
+				constant = &object.Integer{Value: number.Value}
+			case *object.Float:
+				constant = &object.Float{Value: number.Value}
+			}
+			vm.push(constant)
+
+		case code.OpTrue:
+			vm.push(TRUE)
+		case code.OpFalse:
+			vm.push(FALSE)
+		case code.OpNull:
+			vm.push(NULL)
+		case code.OpBreak:
+			vm.push(BREAK)
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpDup:
+			count := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			start := vm.sp - count
+			for i := 0; i < count; i++ {
+				vm.push(vm.stack[start+i])
+			}
+
+		case code.OpRotate:
+			count := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			top := vm.stack[vm.sp-1]
+			copy(vm.stack[vm.sp-count:vm.sp], vm.stack[vm.sp-count-1:vm.sp-1])
+			vm.stack[vm.sp-count-1] = top
+
+		case code.OpInfix:
+			operator := code.InfixOperators[code.ReadUint8(ins[frame.ip:])]
+			t := frame.token(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			right := vm.pop()
+			left := vm.pop()
+			result := vm.infix(t, operator, left, right)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpPrefix:
+			operator := code.PrefixOperators[code.ReadUint8(ins[frame.ip:])]
+			t := frame.token(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			result := evaluator.EvalPrefixExpression(operator, vm.pop(), t)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpBool:
+			vm.stack[vm.sp-1] = evaluator.NativeBoolToBooleanObject(evaluator.IsTruthful(vm.stack[vm.sp-1]))
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+
+		case code.OpJumpNotTruthy:
+			position := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if !evaluator.IsTruthful(vm.pop()) {
+				frame.ip = position
+			}
+
+		case code.OpJumpNotNull:
+			position := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if vm.stack[vm.sp-1] != NULL {
+				frame.ip = position
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpNull:
+			position := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if vm.stack[vm.sp-1] == NULL {
+				frame.ip = position
+			}
+
+		case code.OpJumpDefinedLocal:
+			value := vm.stack[frame.basePointer+int(code.ReadUint8(ins[frame.ip:]))]
+			position := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value != nil {
+				frame.ip = position
+			}
+
+		case code.OpJumpDefinedFree:
+			index := code.ReadUint8(ins[frame.ip:])
+			position := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			if frame.cl.Free[index].Value != nil {
+				frame.ip = position
+			}
+
+		case code.OpGetGlobal:
+			name := vm.name(code.ReadUint16(ins[frame.ip:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			value, ok := frame.cl.Env.Get(name)
+			if !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "identifier not found: %s", name)
+			}
+			vm.push(value)
+
+		case code.OpLookupGlobal:
+			name := vm.name(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			value, ok := frame.cl.Env.Get(name)
+			if !ok {
+				value = evaluator.NULL
+			}
+			vm.push(value)
+
+		case code.OpDefineGlobal:
+			name := vm.name(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			frame.cl.Env.Store(name, vm.stack[vm.sp-1])
+
+		case code.OpSetGlobal:
+			name := vm.name(code.ReadUint16(ins[frame.ip:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			if _, ok := frame.cl.Env.Get(name); !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "Cannot find variable %s in the current scope", name)
+			}
+			frame.cl.Env.Replace(name, vm.stack[vm.sp-1])
+
+		case code.OpGetLocal:
+			value := vm.stack[frame.basePointer+int(code.ReadUint8(ins[frame.ip:]))]
+			frame.ip++
+
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value == nil {
+				value = NULL
+			}
+			vm.push(value)
+
+		case code.OpSetLocal:
+			slot := frame.basePointer + int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			if cell, ok := vm.stack[slot].(*object.Cell); ok {
+				cell.Value = vm.stack[vm.sp-1]
+			} else {
+				vm.stack[slot] = vm.stack[vm.sp-1]
+			}
+
+		case code.OpGetFree:
+			index := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			value := frame.cl.Free[index].Value
+			if value == nil {
+				value = NULL
+			}
+			vm.push(value)
+
+		case code.OpSetFree:
+			index := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			frame.cl.Free[index].Value = vm.stack[vm.sp-1]
+
+		case code.OpLoadCell:
+			slot := frame.basePointer + int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+
+			// the local moves into a cell shared with the closure, a let that did not run leaves it empty
+			cell, ok := vm.stack[slot].(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: vm.stack[slot]}
+				vm.stack[slot] = cell
+			}
+			vm.push(cell)
+
+		case code.OpLoadFreeCell:
+			index := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+
+			vm.push(frame.cl.Free[index])
+
+		case code.OpThis:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			if frame.this == nil {
+				return evaluator.NewFatalError(t.ToTokenData(), "identifier not found: this")
+			}
+			vm.push(frame.this)
+
+		case code.OpArray:
+			length := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, length)
+			copy(elements, vm.stack[vm.sp-length:vm.sp])
+			vm.drop(vm.sp - length)
+			vm.push(&object.Array{Elements: elements})
+
+		case code.OpInterpolate:
+			length := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			str := evaluator.Interpolate(vm.stack[vm.sp-length : vm.sp])
+			vm.drop(vm.sp - length)
+			vm.push(str)
+
+		case code.OpHash:
+			length := int(code.ReadUint16(ins[frame.ip:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			pairs := make(map[object.HashKey]object.HashPair, length)
+			for i := vm.sp - length*2; i < vm.sp; i += 2 {
+				key, value := vm.stack[i], vm.stack[i+1]
+				hashKey, ok := key.(object.Hashable)
+				if !ok {
+					return evaluator.NewFatalError(t.ToTokenData(), "unusable as hash key: %s", key.Type())
+				}
+				pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+			}
+			vm.drop(vm.sp - length*2)
+			vm.push(&object.Hash{Pairs: pairs})
+
+		case code.OpSpread:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			value := vm.pop()
+			switch target := vm.stack[vm.sp-1].(type) {
+			case *object.Array:
+				elements, err := evaluator.Spread(t, value, frame.cl.Env)
+				if err != nil {
+					return err
+				}
+				target.Elements = append(target.Elements, elements...)
+			case *object.Hash:
+				if err := evaluator.SpreadPairs(t, value, target.Pairs); err != nil {
+					return err
+				}
+			}
+
+		case code.OpIndex:
+			hasRange := code.ReadUint8(ins[frame.ip:]) == 1
+			t := frame.token(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+			result := evaluator.EvalIndexExpression(left, start, end, t, hasRange)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpSetIndex:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			index := vm.pop()
+			container := vm.pop()
+			value := vm.pop()
+			result := evaluator.AssignIndex(t, container, index, value)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpMember:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			key := vm.pop()
+			left := vm.pop()
+			keyString, ok := key.(*object.String)
+			if !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "right expression is not an identifier. got=%s", key.Type())
+			}
+			result := evaluator.EvalMember(t, left, keyString.Value, frame.cl.Env)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpOptionalMember:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			key := vm.pop()
+			left := vm.pop()
+			keyString, ok := key.(*object.String)
+			if !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "right expression is not an identifier. got=%s", key.Type())
+			}
+			result := evaluator.EvalOptionalMember(t, left, keyString.Value, frame.cl.Env)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpSetMember:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			key := vm.pop()
+			container := vm.pop()
+			value := vm.pop()
+			switch container.(type) {
+			case *object.Module, *object.Hash:
+			default:
+				return evaluator.NewFatalError(t.ToTokenData(), "left expression is not a valid target. got=%s", container.Type())
+			}
+			keyString, ok := key.(*object.String)
+			if !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "cannot cast expression to key. type=%s", key.Type())
+			}
+			vm.push(evaluator.AssignMember(t, container, keyString.Value, value))
+
+		case code.OpMemberTarget:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			key := vm.pop()
+			container := vm.pop()
+			keyString, ok := key.(*object.String)
+			if !ok {
+				return evaluator.NewFatalError(t.ToTokenData(), "cannot cast expression to key. type=%s", key.Type())
+			}
+			result := evaluator.EvalMemberTarget(t, container, keyString.Value)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpPatternElement:
+			index := int(code.ReadUint16(ins[frame.ip:]))
+			hasDefault := code.ReadUint8(ins[frame.ip+2:]) == 1
+			t := frame.token(code.ReadUint16(ins[frame.ip+3:]))
+			frame.ip += 5
+
+			result := evaluator.PatternElement(t, vm.stack[vm.sp-1], index, hasDefault)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpPatternRest:
+			start := int(code.ReadUint16(ins[frame.ip:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			result := evaluator.PatternRest(t, vm.stack[vm.sp-1], start)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpPatternIterable:
+			count := int(code.ReadUint16(ins[frame.ip:]))
+			rest := code.ReadUint8(ins[frame.ip+2:]) == 1
+			t := frame.token(code.ReadUint16(ins[frame.ip+3:]))
+			frame.ip += 5
+
+			result := evaluator.PatternIterable(t, vm.stack[vm.sp-1], count, rest, frame.cl.Env)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.stack[vm.sp-1] = result
+
+		case code.OpPatternKey:
+			key := vm.name(code.ReadUint16(ins[frame.ip:]))
+			hasDefault := code.ReadUint8(ins[frame.ip+2:]) == 1
+			t := frame.token(code.ReadUint16(ins[frame.ip+3:]))
+			frame.ip += 5
+
+			result := evaluator.PatternKey(t, vm.stack[vm.sp-1], key, hasDefault)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[frame.ip:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+
+			if err := vm.call(t, numArgs, nil, frame.cl.Env); err != nil {
+				return err
+			}
+			frame = vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpApply:
+			names := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.Array).Elements
+			t := frame.token(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			var named []object.NamedArgument
+			for i, name := range names {
+				named = append(named, object.NamedArgument{
+					Name:  name.(*object.String).Value,
+					Value: vm.stack[vm.sp-len(names)+i],
+				})
+			}
+			vm.drop(vm.sp - len(names))
+			args := vm.pop().(*object.Array).Elements
+			for _, arg := range args {
+				vm.push(arg)
+			}
+
+			if err := vm.call(t, len(args), named, frame.cl.Env); err != nil {
+				return err
+			}
+			frame = vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpReturnValue, code.OpReturn:
+			var result object.Object = NULL
+			if op == code.OpReturnValue {
+				result = vm.pop()
+			}
+			if frame.module != nil {
+				result = frame.module
+			}
+
+			vm.framesIndex--
+			vm.drop(frame.basePointer - 1)
+			vm.runtime.PopFrames(frame.depth, nil)
+			vm.dropHandlers()
+			if vm.framesIndex == stopAt {
+				return result
+			}
+
+			frame = vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+			vm.push(result)
+
+		case code.OpClosure:
+			fn := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.CompiledFunction)
+			numFree := int(code.ReadUint8(ins[frame.ip+2:]))
+			frame.ip += 3
+
+			vm.push(vm.closure(frame, fn, numFree, frame.cl.Env))
+
+		case code.OpModule:
+			fn := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.CompiledFunction)
+			numFree := int(code.ReadUint8(ins[frame.ip+2:]))
+			frame.ip += 3
+
+			env := object.NewEnclosingEnvironment(frame.cl.Env)
+			cl := vm.closure(frame, fn, numFree, env)
+			vm.push(cl)
+			if err := vm.pushFrame(token.Token{}, cl, 0, nil, frame.this, false); err != nil {
+				return err
+			}
+
+			frame = vm.frames[vm.framesIndex-1]
+			frame.module = &object.Module{Body: fn.Body, Env: env}
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpYield:
+			vm.push(vm.yield(vm.pop()))
+			vm.resumed()
+
+		case code.OpAwait:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			result := evaluator.Await(t, vm.pop(), frame.cl.Env)
+			if evaluator.CheckError(result) {
+				return result
+			}
+			vm.push(result)
+
+		case code.OpPrint:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			evaluator.PrintValue(t, vm.pop(), frame.cl.Env)
+
+		case code.OpTry:
+			catch := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			vm.handlers = append(vm.handlers, handler{framesIndex: vm.framesIndex, sp: vm.sp, catch: catch})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpMatch:
+			pattern := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.Quote).Node.(ast.Expression)
+			numLiterals := int(code.ReadUint8(ins[frame.ip+2:]))
+			t := frame.token(code.ReadUint16(ins[frame.ip+3:]))
+			frame.ip += 5
+
+			literals := make([]object.Object, numLiterals)
+			copy(literals, vm.stack[vm.sp-numLiterals:vm.sp])
+			vm.drop(vm.sp - numLiterals)
+			bound, ok := evaluator.MatchPattern(t, pattern, vm.pop(), literals)
+			for _, value := range bound {
+				vm.push(value)
+			}
+			vm.push(evaluator.NativeBoolToBooleanObject(ok))
+
+		case code.OpQuote:
+			quote := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.Quote)
+			numUnquotes := int(code.ReadUint16(ins[frame.ip+2:]))
+			frame.ip += 4
+
+			values := make([]object.Object, numUnquotes)
+			copy(values, vm.stack[vm.sp-numUnquotes:vm.sp])
+			vm.drop(vm.sp - numUnquotes)
+			vm.push(evaluator.SpliceUnquotes(quote.Node, values))
+
+		case code.OpSelect:
+			node := vm.state.Constants[code.ReadUint16(ins[frame.ip:])].(*object.Quote).Node.(*ast.SelectExpression)
+			frame.ip += 2
+
+			numOperands := len(ast.SelectOperands(node))
+			operands := make([]object.Object, numOperands)
+			copy(operands, vm.stack[vm.sp-numOperands:vm.sp])
+			vm.drop(vm.sp - numOperands)
+			index, value, err := evaluator.Select(node, operands, frame.cl.Env)
+			if err != nil {
+				return err
+			}
+			vm.push(value)
+			vm.push(&object.Integer{Value: int64(index)})
+
+		case code.OpNoMatch:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			return evaluator.NoMatchError(t, vm.pop())
+
+		case code.OpThrow:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			return evaluator.ThrowError(t, vm.pop())
+
+		case code.OpIter:
+			t := frame.token(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			iterator, err := evaluator.Iterate(t, vm.pop(), frame.cl.Env)
+			if err != nil {
+				return err
+			}
+			vm.push(iterator)
+
+		case code.OpIterNext:
+			position := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+
+			value, ok := vm.pop().(*object.Iterator).Next()
+			if !ok {
+				frame.ip = position
+			} else if evaluator.CheckError(value) {
+				return value
+			} else {
+				vm.push(value)
+			}
+
+		case code.OpIterClose:
+			evaluator.CloseIterator(vm.pop().(*object.Iterator))
+		}
+	}
+}
+
+// catch jumps to the innermost try block started by this run, it returns false when there is none
+func (vm *VM) catch(err *object.Error, stopAt int) bool {
+	if len(vm.handlers) == 0 || vm.handlers[len(vm.handlers)-1].framesIndex <= stopAt {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.unwind(h.framesIndex, err)
+	vm.drop(h.sp)
+	vm.push(evaluator.CatchError(err))
+	vm.frames[vm.framesIndex-1].ip = h.catch
+	return true
+}
+
+// unwind drops the frames above framesIndex after an error, attaching the call stack to it
+func (vm *VM) unwind(framesIndex int, err object.Object) {
+	depth := len(vm.runtime.CallStack)
+	if vm.framesIndex > framesIndex {
+		depth = vm.frames[framesIndex].depth
+	}
+	vm.runtime.PopFrames(depth, err)
+	vm.framesIndex = framesIndex
+	vm.dropHandlers()
+}
+
+// dropHandlers removes the try blocks of the frames that are gone
+func (vm *VM) dropHandlers() {
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+}
+
+// call calls the function below the positional arguments on top of the stack
+func (vm *VM) call(t token.Token, numArgs int, named []object.NamedArgument, env *object.Environment) object.Object {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch fn := callee.(type) {
+	case *object.Closure:
+		if fn.Fn.Generator || fn.Fn.Async {
+			break
+		}
+		if err := vm.pushFrame(t, fn, numArgs, named, fn.This, true); err != nil {
+			return err
+		}
+		return nil
+
+	case *object.PrototypeFunction:
+		if cl, ok := fn.Fn.(*object.Closure); ok && !cl.Fn.Generator && !cl.Fn.Async {
+			if err := vm.pushFrame(t, cl, numArgs, named, *fn.This, true); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+
+	// builtins, generator and async functions, and functions of the evaluator
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	result := evaluator.ApplyFunctionNamed(t, callee, args, named, env)
+	if evaluator.CheckError(result) {
+		return result
+	}
+	if result == nil {
+		result = NULL
+	}
+
+	vm.drop(vm.sp - numArgs - 1)
+	vm.push(result)
+	return nil
+}
+
+// closure creates a closure capturing the cells on top of the stack
+func (vm *VM) closure(frame *Frame, fn *object.CompiledFunction, numFree int, env *object.Environment) *object.Closure {
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+	}
+	vm.drop(vm.sp - numFree)
+
+	return &object.Closure{
+		Fn:      fn,
+		Free:    free,
+		This:    frame.this,
+		Env:     env,
+		Machine: vm,
+	}
+}
+
+// infix applies a binary operator, numbers take a fast path
+func (vm *VM) infix(t token.Token, operator string, left object.Object, right object.Object) object.Object {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			if result := evaluator.IntegerInfix(operator, l.Value, r.Value, t); result != nil {
+				return result
+			}
+		}
+	}
+
+	if operator == "xor" {
+		return evaluator.NativeBoolToBooleanObject(evaluator.IsTruthful(left) != evaluator.IsTruthful(right))
+	}
+	return evaluator.EvalOperatorExpression(t, operator, left, right)
+}
+
+func (f *Frame) token(index uint16) token.Token {
+	return f.cl.Fn.Tokens[index]
+}
+
+func (vm *VM) name(index uint16) string {
+	return vm.state.Constants[index].(*object.String).Value
+}
+
+func (vm *VM) push(obj object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	obj := vm.stack[vm.sp]
+	// the popped slot must not keep the value alive, like an abandoned generator
+	vm.stack[vm.sp] = nil
+	return obj
+}
+
+// drop pops the values above sp
+func (vm *VM) drop(sp int) {
+	for i := sp; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = sp
+}
@@ -0,0 +1,190 @@
+package vm
+
+import (
+	"Monkey/evaluator"
+	"Monkey/lexer"
+	"Monkey/object"
+	"Monkey/parser"
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type vmTest struct {
+	input    string
+	expected string
+}
+
+func run(t *testing.T, input string) (object.Object, string) {
+	program := parser.New(lexer.New(input, "test")).ParseProgram()
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	rt := evaluator.NewRuntime(evaluator.NewDefaultRuntime().Options, nil, &out, strings.NewReader(""))
+	clock := object.NewFakeClock(time.Unix(0, 0))
+	clock.AutoAdvance = true
+	rt.Clock = clock
+	env.SetRuntime(rt)
+
+	return New().Run(program, env), out.String()
+}
+
+// Generators run by their own vm are unwound when they are closed or collected, their goroutines do not leak
+func TestGeneratorsDoNotLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	result, _ := run(t, `let naturals = fn*() {
+	let i = 0
+	while true {
+		yield i
+		i += 1
+	}
+}
+let closed = naturals()
+closed.next()
+closed.close()
+let abandon = fn() {
+	let g = naturals()
+	g.next()
+	g.next().value
+}
+for i in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10] {
+	abandon()
+}
+for x in naturals() { if x > 3 { break } }
+let [a, b] = naturals()
+b`)
+	if result.Inspect() != "1" {
+		t.Fatalf("wrong result. got=%s", result.Inspect())
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("the goroutines of abandoned generators leaked. before=%d, after=%d", before, after)
+	}
+}
+
+// The values popped from the stack are not kept by their slots, a generator abandoned by a call is collected
+// while the vm that ran it is still used
+func TestPoppedValuesAreCollected(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	program := parser.New(lexer.New(`let naturals = fn*() {
+	let i = 0
+	while true {
+		yield i
+		i += 1
+	}
+}
+let abandon = fn() {
+	let g = naturals()
+	g.next().value
+}
+abandon()`, "test")).ParseProgram()
+	env := object.NewEnvironment()
+	env.SetRuntime(evaluator.NewRuntime(evaluator.NewDefaultRuntime().Options, nil, &bytes.Buffer{}, strings.NewReader("")))
+	machine := New()
+	if result := machine.Run(program, env); result.Inspect() != "0" {
+		t.Fatalf("wrong result. got=%s", result.Inspect())
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("the abandoned generator was kept by the stack. before=%d, after=%d", before, after)
+	}
+	runtime.KeepAlive(machine)
+}
+
+func TestTryUnwinds(t *testing.T) {
+	machine := New()
+	env := object.NewEnvironment()
+	env.SetRuntime(evaluator.NewRuntime(evaluator.NewDefaultRuntime().Options, nil, &bytes.Buffer{}, strings.NewReader("")))
+
+	inputs := []string{
+		"let f = fn(n) { if n == 0 { throw 'bottom' }\ntry { f(n - 1) } finally { 0 } }",
+		"try { f(10) } catch (e) { e.message }",
+		"let g = fn() { try { return 1 } catch { 0 } }\ng()",
+		"f(2)",
+	}
+	var results []object.Object
+	for _, input := range inputs {
+		results = append(results, machine.Run(parser.New(lexer.New(input, "test")).ParseProgram(), env))
+	}
+
+	if results[1].Inspect() != "bottom" || results[2].Inspect() != "1" {
+		t.Errorf("wrong results. got=%v", results)
+	}
+	if !evaluator.CheckError(results[3]) {
+		t.Errorf("expected an uncaught error. got=%v", results[3])
+	}
+	if len(machine.handlers) != 0 || machine.sp != 0 || machine.framesIndex != 0 {
+		t.Errorf("try blocks not unwound. handlers=%d sp=%d frames=%d", len(machine.handlers), machine.sp, machine.framesIndex)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	_, out := run(t, `let a = "hi"
+a;`)
+	if out != "hi\n" {
+		t.Errorf("wrong output. got=%q", out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []vmTest{
+		{"missing", "identifier not found: missing"},
+		{"let f = fn() { missing }\nf()", "identifier not found: missing"},
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() { this }\nf()", "identifier not found: this"},
+		{"let f = fn() { f() }\nf()", "stack overflow"},
+		{"unquote(1)", "compile error: unquote can only be used inside of quote"},
+		{"let f = fn(a) { a }\nf(b: 1)", "f has no parameter named b"},
+		{"let f = fn(a) { a }\nf(1, a: 1)", "the argument a of f is given twice"},
+		{"len(ele: [])", "builtin functions do not take named arguments"},
+		{"[...1]", "cannot spread INTEGER"},
+		{"{...[1]}", "cannot spread ARRAY into a hash"},
+		{"match 3 { 1 => 'one' }", "no match arm for 3"},
+	}
+
+	for _, tt := range tests {
+		result, out := run(t, tt.input)
+		err, ok := result.(*object.Error)
+		if !ok || !err.Fatal {
+			t.Errorf("%q expected a fatal error. got=%v", tt.input, result)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%q wrong message. want=%q, got=%q", tt.input, tt.expected, err.Message)
+		}
+		if out != "" {
+			t.Errorf("%q the returned error was printed. got=%q", tt.input, out)
+		}
+	}
+}
+
+func TestRunAfterError(t *testing.T) {
+	machine := New()
+	env := object.NewEnvironment()
+	env.SetRuntime(evaluator.NewRuntime(evaluator.NewDefaultRuntime().Options, nil, &bytes.Buffer{}, strings.NewReader("")))
+
+	inputs := []string{"let f = fn(n) { if n == 0 { missing }\nf(n - 1) }", "f(10)", "f"}
+	var result object.Object
+	for _, input := range inputs {
+		result = machine.Run(parser.New(lexer.New(input, "test")).ParseProgram(), env)
+	}
+
+	if _, ok := result.(*object.Closure); !ok {
+		t.Errorf("wrong result after error. got=%v", result)
+	}
+	if machine.sp != 0 || machine.framesIndex != 0 {
+		t.Errorf("stack not unwound. sp=%d frames=%d", machine.sp, machine.framesIndex)
+	}
+}